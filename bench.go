@@ -0,0 +1,167 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/sanchezhs/py-broom/finder"
+	"github.com/sanchezhs/py-broom/printers"
+	"github.com/spf13/cobra"
+)
+
+// benchRun holds the wall time of each stage of one pipeline run
+type benchRun struct {
+	walk, find, analyze, print time.Duration
+	search, classify           time.Duration // Summed over the analysis workers
+	files, methods             int
+}
+
+func (r benchRun) total() time.Duration {
+	return r.walk + r.find + r.analyze + r.print
+}
+
+func newBenchCmd(opts *options) *cobra.Command {
+	var runs int
+	var compare bool
+	cmd := &cobra.Command{
+		Use:   "bench",
+		Short: "Time the analysis pipeline stage by stage",
+		Long: "Time the analysis pipeline stage by stage.\n\n" +
+			"The pipeline runs --runs times and the mean of every stage is reported: walk,\n" +
+			"find, search, classify and print, plus methods analyzed per second. Search and\n" +
+			"classify are summed over the --jobs workers. --compare runs it once per installed\n" +
+			"search backend.",
+		SilenceUsage: true,
+		Args:         cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if runs < 1 {
+				return fmt.Errorf("%s: --runs must be at least 1", programName)
+			}
+			engines := []string{opts.engine}
+			switch {
+			case compare:
+				engines = nil
+				for _, engine := range finder.Engines {
+					if backend := finder.Backend(engine); backend != nil {
+						if _, err := exec.LookPath(backend.Command()); err != nil {
+							continue
+						}
+					}
+					engines = append(engines, engine)
+				}
+			case opts.engine == "":
+				engines = []string{defaultEngine()}
+			case finder.Backend(opts.engine) != nil:
+				if _, err := exec.LookPath(finder.Backend(opts.engine).Command()); err != nil {
+					return fmt.Errorf("%s: %s is not installed, it can not be benchmarked", programName, finder.Backend(opts.engine).Command())
+				}
+			case opts.engine != finder.EngineNative:
+				return fmt.Errorf("%s: invalid --search-backend '%s', valid values are %s", programName, opts.engine, strings.Join(finder.Engines, ", "))
+			}
+			return runBench(cmd, opts, engines, runs)
+		},
+	}
+	cmd.Flags().IntVar(&runs, "runs", 3, "Number of times the pipeline runs per search backend")
+	cmd.Flags().BoolVar(&compare, "compare", false, "Benchmark every installed search backend")
+	return cmd
+}
+
+func runBench(cmd *cobra.Command, opts *options, engines []string, runs int) error {
+	var encoding string
+	if opts.encoding != "" {
+		var err error
+		if encoding, err = finder.ParseEncoding(opts.encoding); err != nil {
+			return fmt.Errorf("%s: --encoding: %w", programName, err)
+		}
+	}
+	pr, err := newPrinter(cmd, opts, nil, nil)
+	if pr == nil {
+		return err
+	}
+	ctx := cmd.Context()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	searchDirs := opts.searchDirs
+	if len(searchDirs) == 0 {
+		searchDirs = opts.dirs
+	}
+
+	for _, engine := range engines {
+		var mean benchRun
+		for range runs {
+			run, err := benchOnce(ctx, opts, pr, encoding, engine, searchDirs)
+			if err != nil {
+				return err
+			}
+			mean.walk += run.walk / time.Duration(runs)
+			mean.find += run.find / time.Duration(runs)
+			mean.analyze += run.analyze / time.Duration(runs)
+			mean.search += run.search / time.Duration(runs)
+			mean.classify += run.classify / time.Duration(runs)
+			mean.print += run.print / time.Duration(runs)
+			mean.files, mean.methods = run.files, run.methods
+		}
+		printBench(cmd.OutOrStdout(), engine, runs, mean)
+	}
+	return nil
+}
+
+// benchOnce runs the whole pipeline, printing to nowhere
+func benchOnce(ctx context.Context, opts *options, pr printers.Printer, encoding, engine string, searchDirs []string) (benchRun, error) {
+	var run benchRun
+
+	start := time.Now()
+	files, err := finder.ReadDirs(ctx, opts.dirs, newDirFilter(opts))
+	if err != nil {
+		return run, fmt.Errorf("%s: error reading directory: %w", programName, err)
+	}
+	run.walk, run.files = time.Since(start), len(files)
+
+	start = time.Now()
+	methods := finder.FindMethods(ctx, files, newMethodFilter(opts, encoding))
+	run.find, run.methods = time.Since(start), len(methods)
+
+	filters := newFileFilter(opts, encoding, engine)
+	filters.Stats = &finder.Stats{}
+	start = time.Now()
+	results := finder.AnalyzeMethodUsages(ctx, methods, searchDirs, filters)
+	run.analyze = time.Since(start)
+	run.search, run.classify = filters.Stats.Search(), filters.Stats.Classify()
+
+	start = time.Now()
+	finder.SortResults(results, opts.sortBy, opts.asc)
+	if err := pr.Print(io.Discard, results); err != nil {
+		return run, err
+	}
+	run.print = time.Since(start)
+
+	if ctx.Err() != nil {
+		return run, cancelled(ctx, opts, "benchmarking")
+	}
+	return run, nil
+}
+
+func printBench(w io.Writer, engine string, runs int, mean benchRun) {
+	fmt.Fprintf(w, "Backend: %s (%d runs, %d files, %d methods)\n", engine, runs, mean.files, mean.methods)
+	stage := func(name string, d time.Duration, note string) {
+		fmt.Fprintf(w, "  %-10s %12s%s\n", name, d.Round(time.Microsecond), note)
+	}
+	stage("walk", mean.walk, "")
+	stage("find", mean.find, "")
+	stage("analyze", mean.analyze, "")
+	stage("  search", mean.search, "  (summed over workers)")
+	stage("  classify", mean.classify, "  (summed over workers)")
+	stage("print", mean.print, "")
+	stage("total", mean.total(), "")
+	rate := 0.0
+	if mean.analyze > 0 {
+		rate = float64(mean.methods) / mean.analyze.Seconds()
+	}
+	fmt.Fprintf(w, "  %-10s %12.0f\n", "methods/s", rate)
+	fmt.Fprintln(w, strings.Repeat("-", 40))
+}
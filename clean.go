@@ -0,0 +1,314 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/sanchezhs/py-broom/colors"
+	"github.com/sanchezhs/py-broom/finder"
+	"github.com/spf13/cobra"
+)
+
+// defaultBaseline is the baseline the clean command uses without --baseline
+const defaultBaseline = ".pybroom-baseline.json"
+
+const (
+	cleanContext   = 2  // Lines shown before and after a definition
+	cleanBodyLines = 15 // Lines of a definition shown before the rest is elided
+)
+
+// cleanOptions set how the clean command edits the sources
+type cleanOptions struct {
+	imports bool // Also delete the imports only a deleted definition used
+}
+
+func newCleanCmd(opts *options) *cobra.Command {
+	clean := &cleanOptions{}
+	cmd := &cobra.Command{
+		Use:   "clean [file.py ...]",
+		Short: "Walk through the unused functions and methods, deciding what to do with each",
+		Long: "Walk through the unused functions and methods, deciding what to do with each.\n\n" +
+			"Every definition the unused command reports is shown with its surrounding\n" +
+			"code, to be [d]eleted, [i]gnored with a '" + finder.IgnoreComment + "' comment, added to\n" +
+			"the [b]aseline or [s]kipped; [q] stops. Changes are written as they are made.\n" +
+			"The baseline is --baseline, " + defaultBaseline + " by default, and the definitions it\n" +
+			"lists are not shown again.",
+		SilenceUsage: true,
+		Args:         cobra.ArbitraryArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if opts.stream || opts.summaryOnly {
+				return fmt.Errorf("%s: clean can not be combined with --stream or --summary-only", programName)
+			}
+			if opts.baseline == "" {
+				opts.baseline = defaultBaseline
+			}
+			opts.clean = clean
+			unusedDefaults(cmd, opts)
+			return runAnalysis(cmd, opts, finder.SymbolFunction, args)
+		},
+	}
+	cmd.Flags().BoolVar(&clean.imports, "imports", false, "Also delete the imported names only a deleted definition used")
+	return cmd
+}
+
+// cleanSource is a file edited by the clean command
+type cleanSource struct {
+	lines   []string
+	newline bool // The file ends with a newline
+}
+
+// cleaner walks through the unused definitions, editing their files
+type cleaner struct {
+	sources map[string]*cleanSource
+	keys    *bufio.Reader // Answers, read a line at a time when stdin is not a terminal
+}
+
+// cleanResults asks what to do with each unused definition of results and
+// does it right away. The definitions refs mentions are in use and skipped.
+func cleanResults(opts *options, results []finder.MethodUsage, refs map[string]string) error {
+	c := &cleaner{sources: make(map[string]*cleanSource)}
+	if !isTerminal(os.Stdin) {
+		c.keys = bufio.NewReader(os.Stdin)
+	}
+	var methods []finder.Method
+	for _, r := range results {
+		if ref := refs[r.Method.Name]; ref != "" {
+			fmt.Fprintf(os.Stderr, "%s: skipping %s at %s:%d, referenced as an attribute or decorator at %s\n",
+				programName, methodLabel(r.Method), r.Method.Filename, r.Method.LineNo, ref)
+			continue
+		}
+		methods = append(methods, r.Method)
+	}
+	// Top to bottom, the way the files are read
+	sort.SliceStable(methods, func(i, j int) bool {
+		if methods[i].Filename != methods[j].Filename {
+			return methods[i].Filename < methods[j].Filename
+		}
+		return methods[i].LineNo < methods[j].LineNo
+	})
+
+	deleted, ignored, accepted := 0, 0, 0
+	for i := range methods {
+		m := &methods[i]
+		if m.LineNo == 0 {
+			// Went with a definition deleted before
+			continue
+		}
+		src, err := c.source(m.Filename)
+		if err != nil {
+			return err
+		}
+		if src == nil || m.EndLine > len(src.lines) {
+			continue
+		}
+
+		fmt.Printf("\n[%d/%d] %s  %s:%d\n", i+1, len(methods), colors.Colorize(methodLabel(*m), colors.ColorYellow, opts.noColor), m.Filename, m.LineNo)
+		c.show(src.lines, *m)
+		edits, removed := finder.RemoveDefinitions(src.lines, []finder.Method{*m}, opts.clean.imports)
+		reason := finder.Unremovable(*m)
+		if reason == "" && len(removed) == 0 {
+			reason = "its name is still used in the file"
+		}
+		actions := "dibsq"
+		if reason != "" {
+			fmt.Printf("(can not be deleted, %s)\n", reason)
+			actions = "ibsq"
+		}
+		if m.Cell > 0 {
+			// Notebook lines are not those of the file
+			actions = "bsq"
+		}
+
+		action, err := c.ask(actions)
+		if err != nil {
+			return err
+		}
+		switch action {
+		case 'd':
+			src.lines = finder.ApplyEdits(src.lines, edits)
+			if err := c.save(m.Filename); err != nil {
+				return err
+			}
+			for j := i + 1; j < len(methods); j++ {
+				if methods[j].Filename == m.Filename && !shiftMethod(&methods[j], edits) {
+					methods[j].LineNo = 0
+				}
+			}
+			fmt.Printf("Deleted %s\n", methodLabel(*m))
+			deleted++
+		case 'i':
+			src.lines[m.LineNo-1] = finder.AddIgnoreComment(src.lines[m.LineNo-1])
+			if err := c.save(m.Filename); err != nil {
+				return err
+			}
+			fmt.Printf("Ignored %s\n", methodLabel(*m))
+			ignored++
+		case 'b':
+			opts.accepted.Add(*m)
+			if err := opts.accepted.Save(); err != nil {
+				return fmt.Errorf("%s: saving baseline: %w", programName, err)
+			}
+			fmt.Printf("Added %s to %s\n", methodLabel(*m), opts.baseline)
+			accepted++
+		case 's':
+		case 'q':
+			i = len(methods)
+		}
+	}
+	fmt.Printf("\n%s: %d deleted, %d ignored, %d added to the baseline\n", programName, deleted, ignored, accepted)
+	return nil
+}
+
+// source returns the lines of path, read on first use, nil for the files
+// the clean command does not edit
+func (c *cleaner) source(path string) (*cleanSource, error) {
+	if src, ok := c.sources[path]; ok {
+		return src, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", programName, err)
+	}
+	var src *cleanSource
+	if bytes.HasPrefix(data, []byte{0xff, 0xfe}) || bytes.HasPrefix(data, []byte{0xfe, 0xff}) {
+		fmt.Fprintf(os.Stderr, "%s: skipping %s, UTF-16 sources are not edited\n", programName, path)
+	} else {
+		src = &cleanSource{lines: strings.Split(string(data), "\n")}
+		if src.newline = src.lines[len(src.lines)-1] == ""; src.newline {
+			src.lines = src.lines[:len(src.lines)-1]
+		}
+	}
+	c.sources[path] = src
+	return src, nil
+}
+
+// save writes the edited lines of path back to it
+func (c *cleaner) save(path string) error {
+	src := c.sources[path]
+	text := strings.Join(src.lines, "\n")
+	if src.newline {
+		text += "\n"
+	}
+	err := replaceFile(path, func(w io.Writer) error {
+		_, err := io.WriteString(w, text)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", programName, err)
+	}
+	return nil
+}
+
+// show prints the definition of m with the lines around it, numbered, the
+// middle of long bodies elided
+func (c *cleaner) show(lines []string, m finder.Method) {
+	start := m.LineNo - 1 - len(m.Decorators)
+	end := max(m.EndLine, m.LineNo)
+	row := func(i int, marker string) {
+		fmt.Printf("%5d %s %s\n", i+1, marker, strings.TrimSuffix(lines[i], "\r"))
+	}
+	for i := max(start-cleanContext, 0); i < max(start, 0); i++ {
+		row(i, " ")
+	}
+	for i := max(start, 0); i < end; i++ {
+		if i == max(start, 0)+cleanBodyLines-1 && end-i > 2 {
+			fmt.Printf("      │ ... %d more lines\n", end-i-1)
+			i = end - 1
+		}
+		row(i, "│")
+	}
+	for i := end; i < min(end+cleanContext, len(lines)); i++ {
+		row(i, " ")
+	}
+}
+
+// cleanActions name the keys of the actions of the clean command
+var cleanActions = map[byte]string{'d': "[d]elete", 'i': "[i]gnore", 'b': "[b]aseline", 's': "[s]kip", 'q': "[q]uit"}
+
+// ask reads the action chosen for a definition among the keys of actions.
+// The end of the input quits.
+func (c *cleaner) ask(actions string) (byte, error) {
+	var names []string
+	for i := range len(actions) {
+		names = append(names, cleanActions[actions[i]])
+	}
+	prompt := strings.Join(names, ", ") + "? "
+	for {
+		fmt.Print(prompt)
+		key, err := c.readKey()
+		if err != nil {
+			fmt.Println()
+			if err == io.EOF {
+				return 'q', nil
+			}
+			return 0, fmt.Errorf("%s: %w", programName, err)
+		}
+		if key == 3 {
+			// ctrl-c in raw mode
+			fmt.Println()
+			return 'q', nil
+		}
+		if strings.IndexByte(actions, key) >= 0 {
+			return key, nil
+		}
+	}
+}
+
+// readKey returns the next key pressed, or the first character of the next
+// line when stdin is not a terminal
+func (c *cleaner) readKey() (byte, error) {
+	if c.keys != nil {
+		line, err := c.keys.ReadString('\n')
+		line = strings.ToLower(strings.TrimSpace(line))
+		if line == "" {
+			if err != nil {
+				return 0, err
+			}
+			return ' ', nil
+		}
+		return line[0], nil
+	}
+	restore, err := rawTerminal(os.Stdin)
+	if err != nil {
+		return 0, err
+	}
+	defer restore()
+	buf := make([]byte, 8)
+	n, err := os.Stdin.Read(buf)
+	if err != nil {
+		return 0, err
+	}
+	key := buf[0]
+	if n == 1 && key >= 'A' && key <= 'Z' {
+		key += 'a' - 'A'
+	}
+	fmt.Printf("%c\n", max(key, ' '))
+	return key, nil
+}
+
+// shiftMethod moves the lines of m past the edits made to its file, sorted,
+// reporting false when they deleted its def line
+func shiftMethod(m *finder.Method, edits []finder.Edit) bool {
+	def := m.LineNo - 1
+	defShift, endShift := 0, 0
+	for _, e := range edits {
+		delta := len(e.Insert) - (e.End - e.Start)
+		switch {
+		case e.End <= def:
+			defShift += delta
+			endShift += delta
+		case e.Start <= def:
+			return false
+		case e.End <= m.EndLine:
+			endShift += delta
+		}
+	}
+	m.LineNo += defShift
+	m.EndLine += endShift
+	return true
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCleanOffersOnlyUnusedCode(t *testing.T) {
+	python := lookPython(t)
+	dir := writeProject(t, fixProject)
+
+	// Every definition offered is deleted
+	answers := filepath.Join(t.TempDir(), "answers")
+	if err := os.WriteFile(answers, []byte(strings.Repeat("d\n", 10)), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	stdin, err := os.Open(answers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stdin.Close()
+	saved := os.Stdin
+	os.Stdin = stdin
+	defer func() { os.Stdin = saved }()
+
+	cmd := newRootCmd()
+	cmd.SetArgs([]string{"clean", "--no-config", "--search-backend", "native", "--dir", dir,
+		"--baseline", filepath.Join(dir, ".pybroom-baseline.json")})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("clean: %v", err)
+	}
+	checkFixedProject(t, python, dir)
+}
@@ -10,6 +10,7 @@ const (
 	ColorPurple = "\033[35m"
 	ColorCyan   = "\033[36m"
 	ColorWhite  = "\033[37m"
+	ColorGray   = "\033[90m"
 	ColorBold   = "\033[1m"
 )
 
@@ -19,3 +20,13 @@ func Colorize(text string, color string, noColor bool) string {
 	}
 	return color + text + ColorReset
 }
+
+// Hyperlink wraps text in an OSC 8 escape sequence, so that terminals
+// supporting it open url when text is clicked. It returns text unchanged when
+// url is empty.
+func Hyperlink(text, url string) string {
+	if url == "" {
+		return text
+	}
+	return "\033]8;;" + url + "\033\\" + text + "\033]8;;\033\\"
+}
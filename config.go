@@ -0,0 +1,580 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/sanchezhs/py-broom/finder"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// configTable is the pyproject.toml table holding the defaults of the flags
+const configTable = "tool.pybroom"
+
+// configNames are the configuration files looked up in each directory, the
+// first one found winning
+var configNames = []string{".pybroom.yaml", ".pybroom.yml", "pybroom.toml", "pyproject.toml"}
+
+// pathKeys are the configuration keys holding paths, resolved from the
+// directory of the configuration file rather than the current one
+var pathKeys = map[string]bool{
+	"dir": true, "search-dir": true, "output": true, "paths-root": true, "marks": true, "baseline": true,
+	"cpuprofile": true, "memprofile": true, "trace": true,
+}
+
+// unsettableKeys are the flags a configuration file cannot set
+var unsettableKeys = map[string]bool{"config": true, "no-config": true, "help": true, "version": true}
+
+// applyConfig sets the flags of cmd not given on the command line from
+// --config, or from the closest configuration file from the first --dir up.
+// Keys are flag names, with - or _.
+func applyConfig(cmd *cobra.Command, opts *options) error {
+	if opts.noConfig {
+		return nil
+	}
+	path := opts.config
+	if path == "" {
+		start := "."
+		if cmd.Flags().Changed("dir") && len(opts.dirs) > 0 {
+			start = opts.dirs[0]
+		}
+		var err error
+		if path, err = findConfig(start); path == "" || err != nil {
+			return err
+		}
+	}
+	values, err := readConfig(path)
+	if err != nil {
+		return fmt.Errorf("%s: %w", programName, err)
+	}
+	if values == nil {
+		return fmt.Errorf("%s: %s has no [%s] table", programName, path, configTable)
+	}
+
+	known := make(map[string]bool)
+	collectFlags(cmd.Root(), known)
+	flags := cmd.Flags()
+	for _, key := range values.keys {
+		name := strings.ReplaceAll(key, "_", "-")
+		if unsettableKeys[name] {
+			return fmt.Errorf("%s: %s: '%s' cannot be set in a configuration file", programName, path, key)
+		}
+		f := flags.Lookup(name)
+		if f == nil {
+			if known[name] {
+				// A flag of another command
+				continue
+			}
+			return fmt.Errorf("%s: %s: unknown key '%s'", programName, path, key)
+		}
+		if f.Changed {
+			// Flags override the configuration
+			continue
+		}
+		if err := setFlag(flags, f, values.values[key], filepath.Dir(path)); err != nil {
+			return fmt.Errorf("%s: %s: %s: %w", programName, path, key, err)
+		}
+	}
+	if opts.verbose {
+		log.Printf("Using the configuration of %s\n", path)
+	}
+	return nil
+}
+
+// findConfig returns the closest configuration file from dir up, empty when
+// there is none. A pyproject.toml without a [tool.pybroom] table is skipped.
+func findConfig(dir string) (string, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	if info, err := os.Stat(dir); err == nil && !info.IsDir() {
+		dir = filepath.Dir(dir)
+	}
+	for {
+		for _, name := range configNames {
+			path := filepath.Join(dir, name)
+			if _, err := os.Stat(path); err != nil {
+				continue
+			}
+			if values, err := readConfig(path); err != nil || values != nil {
+				return path, err
+			}
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", nil
+		}
+		dir = parent
+	}
+}
+
+// applyIgnoreFile merges the path patterns of the closest .pybroomignore from
+// the first --dir up into --exclude, rebased on every --dir and --search-dir,
+// and its method patterns into --exclude-method
+func applyIgnoreFile(opts *options) error {
+	if opts.noConfig {
+		return nil
+	}
+	start := "."
+	if len(opts.dirs) > 0 {
+		start = opts.dirs[0]
+	}
+	path, err := findUp(start, finder.IgnoreFileName)
+	if path == "" || err != nil {
+		return err
+	}
+	f, err := finder.ReadIgnoreFile(path)
+	if err != nil {
+		return fmt.Errorf("%s: %w", programName, err)
+	}
+	if opts.verbose {
+		log.Printf("Using ignore file %s\n", path)
+	}
+	for _, root := range append(slices.Clone(opts.dirs), opts.searchDirs...) {
+		for _, glob := range f.Excludes(root) {
+			if !slices.Contains(opts.exclude, glob) {
+				opts.exclude = append(opts.exclude, glob)
+			}
+		}
+	}
+	opts.excludeMethods = append(opts.excludeMethods, f.Methods...)
+	return nil
+}
+
+// findUp returns the closest file named name at or above dir, empty when
+// there is none
+func findUp(dir, name string) (string, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	for {
+		path := filepath.Join(dir, name)
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", nil
+		}
+		dir = parent
+	}
+}
+
+// readConfig reads a configuration file by its name: YAML, the
+// [tool.pybroom] table of a pyproject.toml or the top level of another TOML
+// file. It returns nil for a pyproject.toml without the table.
+func readConfig(path string) (*configValues, error) {
+	switch {
+	case strings.HasSuffix(path, ".yaml") || strings.HasSuffix(path, ".yml"):
+		return readYAMLConfig(path)
+	case filepath.Base(path) == "pyproject.toml":
+		return readTOMLConfig(path, configTable)
+	default:
+		return readTOMLConfig(path, "")
+	}
+}
+
+// collectFlags adds the names of the flags of cmd and its subcommands to known
+func collectFlags(cmd *cobra.Command, known map[string]bool) {
+	cmd.Flags().VisitAll(func(f *pflag.Flag) { known[f.Name] = true })
+	cmd.PersistentFlags().VisitAll(func(f *pflag.Flag) { known[f.Name] = true })
+	for _, sub := range cmd.Commands() {
+		collectFlags(sub, known)
+	}
+}
+
+// setFlag sets f to a configuration value. Arrays replace the default of list
+// flags, relative paths are resolved from dir.
+func setFlag(flags *pflag.FlagSet, f *pflag.Flag, value any, dir string) error {
+	list, isList := value.([]any)
+	if !isList {
+		list = []any{value}
+	}
+	values := make([]string, len(list))
+	for i, v := range list {
+		switch v := v.(type) {
+		case string:
+			values[i] = v
+			if pathKeys[f.Name] && v != "" && v != "-" && !filepath.IsAbs(v) {
+				values[i] = filepath.Join(dir, v)
+			}
+		case []any:
+			return errors.New("nested arrays are not supported")
+		default:
+			values[i] = fmt.Sprint(v)
+		}
+	}
+
+	if sv, ok := f.Value.(pflag.SliceValue); ok {
+		if err := sv.Replace(values); err != nil {
+			return err
+		}
+		f.Changed = true
+		return nil
+	}
+	if isList {
+		return fmt.Errorf("expected a single value, not an array")
+	}
+	return flags.Set(f.Name, values[0])
+}
+
+// configValues are the keys of a configuration file in the order they were
+// written, and their values: strings, int64, float64, bool or []any of them
+type configValues struct {
+	keys   []string
+	values map[string]any
+}
+
+func (c *configValues) add(key string, value any) error {
+	if _, dup := c.values[key]; dup {
+		return fmt.Errorf("%s is defined twice", key)
+	}
+	c.keys = append(c.keys, key)
+	c.values[key] = value
+	return nil
+}
+
+//==================================================================
+// TOML
+//==================================================================
+
+// readTOMLConfig returns the keys of a table of a TOML file, its top level
+// when table is empty, and nil when the file has no such table. Only the
+// TOML needed by flags is read in that table: strings, integers, floats,
+// booleans and arrays of them.
+func readTOMLConfig(path, table string) (*configValues, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var values *configValues
+	inTable := table == ""
+	if inTable {
+		values = &configValues{values: make(map[string]any)}
+	}
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 1<<20)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "[") {
+			header := strings.TrimSpace(strings.SplitN(line, "#", 2)[0])
+			if strings.HasSuffix(header, "]") && !strings.HasPrefix(header, "[[") {
+				name := strings.ReplaceAll(strings.Trim(header, "[]"), " ", "")
+				inTable = table != "" && name == table
+				if inTable && values == nil {
+					values = &configValues{values: make(map[string]any)}
+				}
+				continue
+			}
+			if strings.HasPrefix(header, "[[") {
+				inTable = false
+				continue
+			}
+		}
+		if !inTable || line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		key, text, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("%s:%d: expected key = value", path, lineNo)
+		}
+		key = strings.Trim(strings.TrimSpace(key), `"'`)
+		start := lineNo
+		for {
+			value, rest, err := parseTOMLValue(text)
+			if errors.Is(err, errTOMLIncomplete) && scanner.Scan() {
+				// An array spanning several lines
+				lineNo++
+				text += "\n" + scanner.Text()
+				continue
+			}
+			if err != nil {
+				return nil, fmt.Errorf("%s:%d: %s: %w", path, start, key, err)
+			}
+			if rest = strings.TrimSpace(rest); rest != "" && !strings.HasPrefix(rest, "#") {
+				return nil, fmt.Errorf("%s:%d: %s: unexpected %q after the value", path, start, key, rest)
+			}
+			if err := values.add(key, value); err != nil {
+				return nil, fmt.Errorf("%s:%d: %w", path, start, err)
+			}
+			break
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+// errTOMLIncomplete reports a value continuing on the next line
+var errTOMLIncomplete = errors.New("incomplete value")
+
+// parseTOMLValue parses the value at the start of s and returns the text
+// after it
+func parseTOMLValue(s string) (any, string, error) {
+	s = strings.TrimLeft(s, " \t")
+	if s == "" {
+		return nil, "", errors.New("missing value")
+	}
+	switch c := s[0]; {
+	case c == '"':
+		if strings.HasPrefix(s, `"""`) {
+			return nil, "", errors.New("multi-line strings are not supported")
+		}
+		for i := 1; i < len(s); i++ {
+			switch s[i] {
+			case '\\':
+				i++
+			case '"':
+				v, err := strconv.Unquote(s[:i+1])
+				return v, s[i+1:], err
+			}
+		}
+		return nil, "", errors.New("unterminated string")
+	case c == '\'':
+		if strings.HasPrefix(s, "'''") {
+			return nil, "", errors.New("multi-line strings are not supported")
+		}
+		end := strings.IndexByte(s[1:], '\'')
+		if end < 0 {
+			return nil, "", errors.New("unterminated string")
+		}
+		return s[1 : end+1], s[end+2:], nil
+	case c == '[':
+		var list []any
+		rest := s[1:]
+		for {
+			rest = skipTOMLSpace(rest)
+			if rest == "" {
+				return nil, "", errTOMLIncomplete
+			}
+			if rest[0] == ']' {
+				return list, rest[1:], nil
+			}
+			v, after, err := parseTOMLValue(rest)
+			if err != nil {
+				return nil, "", err
+			}
+			list = append(list, v)
+			rest = skipTOMLSpace(after)
+			switch {
+			case rest == "":
+				return nil, "", errTOMLIncomplete
+			case rest[0] == ',':
+				rest = rest[1:]
+			case rest[0] != ']':
+				return nil, "", fmt.Errorf("expected , or ] in array, found %q", rest[:1])
+			}
+		}
+	case c == '{':
+		return nil, "", errors.New("inline tables are not supported")
+	}
+
+	end := strings.IndexAny(s, " \t,]#\n")
+	if end < 0 {
+		end = len(s)
+	}
+	word, rest := s[:end], s[end:]
+	switch word {
+	case "true":
+		return true, rest, nil
+	case "false":
+		return false, rest, nil
+	}
+	digits := strings.ReplaceAll(word, "_", "")
+	if n, err := strconv.ParseInt(digits, 0, 64); err == nil {
+		return n, rest, nil
+	}
+	if x, err := strconv.ParseFloat(digits, 64); err == nil {
+		return x, rest, nil
+	}
+	return nil, "", fmt.Errorf("invalid value %q", word)
+}
+
+// skipTOMLSpace skips white space, new lines and comments inside arrays
+func skipTOMLSpace(s string) string {
+	for {
+		s = strings.TrimLeft(s, " \t\r\n")
+		if !strings.HasPrefix(s, "#") {
+			return s
+		}
+		end := strings.IndexByte(s, '\n')
+		if end < 0 {
+			return ""
+		}
+		s = s[end+1:]
+	}
+}
+
+//==================================================================
+// YAML
+//==================================================================
+
+// readYAMLConfig returns the keys of a YAML mapping. Only the YAML needed by
+// flags is read: scalars, and flow or block sequences of scalars.
+func readYAMLConfig(path string) (*configValues, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	values := &configValues{values: make(map[string]any)}
+	lines := strings.Split(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n")
+	for i := 0; i < len(lines); i++ {
+		line := stripYAMLComment(lines[i])
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || trimmed == "---" {
+			continue
+		}
+		if line[0] == ' ' || line[0] == '\t' {
+			return nil, fmt.Errorf("%s:%d: nested mappings are not supported", path, i+1)
+		}
+		key, text, ok := strings.Cut(line, ":")
+		if !ok {
+			return nil, fmt.Errorf("%s:%d: expected key: value", path, i+1)
+		}
+		key = strings.Trim(strings.TrimSpace(key), `"'`)
+		text = strings.TrimSpace(text)
+		start := i + 1
+
+		var value any
+		switch {
+		case text == "":
+			// A block sequence on the next lines
+			var list []any
+			for i+1 < len(lines) {
+				item := strings.TrimSpace(stripYAMLComment(lines[i+1]))
+				if item == "" {
+					i++
+					continue
+				}
+				if !strings.HasPrefix(item, "- ") && item != "-" {
+					break
+				}
+				v, err := parseYAMLScalar(strings.TrimSpace(item[1:]))
+				if err != nil {
+					return nil, fmt.Errorf("%s:%d: %s: %w", path, i+2, key, err)
+				}
+				list = append(list, v)
+				i++
+			}
+			if list == nil {
+				if i+1 < len(lines) && strings.TrimSpace(lines[i+1]) != "" && (lines[i+1][0] == ' ' || lines[i+1][0] == '\t') {
+					return nil, fmt.Errorf("%s:%d: %s: nested mappings are not supported", path, i+2, key)
+				}
+				return nil, fmt.Errorf("%s:%d: %s: missing value", path, start, key)
+			}
+			value = list
+		case strings.HasPrefix(text, "["):
+			// A flow sequence, maybe spanning several lines
+			for !strings.HasSuffix(text, "]") && i+1 < len(lines) {
+				i++
+				text += " " + strings.TrimSpace(stripYAMLComment(lines[i]))
+			}
+			if !strings.HasSuffix(text, "]") {
+				return nil, fmt.Errorf("%s:%d: %s: unterminated sequence", path, start, key)
+			}
+			list := []any{}
+			if inner := strings.TrimSpace(text[1 : len(text)-1]); inner != "" {
+				for _, item := range splitYAMLFlow(inner) {
+					v, err := parseYAMLScalar(strings.TrimSpace(item))
+					if err != nil {
+						return nil, fmt.Errorf("%s:%d: %s: %w", path, start, key, err)
+					}
+					list = append(list, v)
+				}
+			}
+			value = list
+		case strings.HasPrefix(text, "{"):
+			return nil, fmt.Errorf("%s:%d: %s: flow mappings are not supported", path, start, key)
+		default:
+			if value, err = parseYAMLScalar(text); err != nil {
+				return nil, fmt.Errorf("%s:%d: %s: %w", path, start, key, err)
+			}
+		}
+		if err := values.add(key, value); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, start, err)
+		}
+	}
+	return values, nil
+}
+
+// parseYAMLScalar unquotes a YAML scalar. Plain scalars are kept as written,
+// the flags parsing them.
+func parseYAMLScalar(s string) (any, error) {
+	switch {
+	case s == "":
+		return nil, errors.New("missing value")
+	case s[0] == '"':
+		return strconv.Unquote(s)
+	case s[0] == '\'':
+		if len(s) < 2 || s[len(s)-1] != '\'' {
+			return nil, errors.New("unterminated string")
+		}
+		return strings.ReplaceAll(s[1:len(s)-1], "''", "'"), nil
+	case s[0] == '[' || s[0] == '{':
+		return nil, errors.New("nested collections are not supported")
+	}
+	return s, nil
+}
+
+// splitYAMLFlow splits the items of a flow sequence on the commas outside quotes
+func splitYAMLFlow(s string) []string {
+	var items []string
+	var quote byte
+	start := 0
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case (c == '"' || c == '\'') && strings.TrimSpace(s[start:i]) == "":
+			quote = c
+		case c == ',':
+			items = append(items, s[start:i])
+			start = i + 1
+		}
+	}
+	if rest := strings.TrimSpace(s[start:]); rest != "" {
+		items = append(items, rest)
+	}
+	return items
+}
+
+// stripYAMLComment removes a comment, a # starting the line or following a
+// space outside quotes
+func stripYAMLComment(line string) string {
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		switch c := line[i]; {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case (c == '"' || c == '\'') && (i == 0 || strings.IndexByte(" \t[,:", line[i-1]) >= 0):
+			quote = c
+		case c == '#' && (i == 0 || line[i-1] == ' ' || line[i-1] == '\t'):
+			return strings.TrimRight(line[:i], " \t")
+		}
+	}
+	return strings.TrimRight(line, " \t")
+}
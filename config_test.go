@@ -0,0 +1,347 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+func TestReadTOMLConfig(t *testing.T) {
+	tests := []struct {
+		name  string
+		file  string // pyproject.toml reads [tool.pybroom], other files the top level
+		src   string
+		keys  []string
+		want  map[string]any
+		isNil bool
+		err   string
+	}{
+		{
+			name: "scalars",
+			file: "pybroom.toml",
+			src:  "format = \"json\"\nmin_usages = 1_000\nsimilarity = 0.85\nskip-tests = false\n",
+			keys: []string{"format", "min_usages", "similarity", "skip-tests"},
+			want: map[string]any{"format": "json", "min_usages": int64(1000), "similarity": 0.85, "skip-tests": false},
+		},
+		{
+			name: "multi-line array",
+			file: "pybroom.toml",
+			src: `exclude = [
+    "migrations/**",  # generated
+    # "vendor/**",
+    'build/**',
+]
+dir = ["src",
+       "lib"]
+`,
+			keys: []string{"exclude", "dir"},
+			want: map[string]any{
+				"exclude": []any{"migrations/**", "build/**"},
+				"dir":     []any{"src", "lib"},
+			},
+		},
+		{
+			name: "comments",
+			file: "pybroom.toml",
+			src:  "# defaults\n\nformat = \"table\" # trailing\ntop = 5#tight\n",
+			keys: []string{"format", "top"},
+			want: map[string]any{"format": "table", "top": int64(5)},
+		},
+		{
+			name: "pyproject tables",
+			file: "pyproject.toml",
+			src: `[project]
+name = "app"
+
+[tool.black]
+line-length = 100
+
+[ tool.pybroom ] # ours
+format = "csv"
+
+[[tool.other]]
+format = "json"
+
+[tool.isort]
+profile = "black"
+`,
+			keys: []string{"format"},
+			want: map[string]any{"format": "csv"},
+		},
+		{
+			name:  "pyproject without the table",
+			file:  "pyproject.toml",
+			src:   "[tool.black]\nline-length = 100\n",
+			isNil: true,
+		},
+		{
+			name: "quoting and escapes",
+			file: "pybroom.toml",
+			src: `"name-regex" = "^handle_\\w+\t\"x\""
+'output' = 'C:\reports\pybr.json'
+empty = ""
+unicode = "caf\u00e9"
+`,
+			keys: []string{"name-regex", "output", "empty", "unicode"},
+			want: map[string]any{"name-regex": "^handle_\\w+\t\"x\"", "output": `C:\reports\pybr.json`, "empty": "", "unicode": "café"},
+		},
+		{name: "unterminated string", file: "pybroom.toml", src: "format = \"json\n", err: "unterminated string"},
+		{name: "multi-line string", file: "pybroom.toml", src: "format = \"\"\"json\"\"\"\n", err: "multi-line strings are not supported"},
+		{name: "inline table", file: "pybroom.toml", src: "budget = { max = 1 }\n", err: "inline tables are not supported"},
+		{name: "missing value", file: "pybroom.toml", src: "format =\n", err: "missing value"},
+		{name: "no equals", file: "pybroom.toml", src: "format\n", err: "expected key = value"},
+		{name: "text after value", file: "pybroom.toml", src: "format = \"json\" csv\n", err: `unexpected "csv" after the value`},
+		{name: "duplicate key", file: "pybroom.toml", src: "top = 1\ntop = 2\n", err: "top is defined twice"},
+		{name: "unterminated array", file: "pybroom.toml", src: "dir = [\"src\",\n", err: "incomplete value"},
+		{name: "invalid value", file: "pybroom.toml", src: "format = json\n", err: `invalid value "json"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := writeProject(t, map[string]string{tt.file: tt.src})
+			got, err := readConfig(filepath.Join(dir, tt.file))
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("readConfig() error = %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("readConfig() error = %v", err)
+			}
+			if tt.isNil {
+				if got != nil {
+					t.Errorf("readConfig() = %+v, want nil", got)
+				}
+				return
+			}
+			if !reflect.DeepEqual(got.keys, tt.keys) || !reflect.DeepEqual(got.values, tt.want) {
+				t.Errorf("readConfig() = %v %v, want %v %v", got.keys, got.values, tt.keys, tt.want)
+			}
+		})
+	}
+}
+
+// configuredFlags runs the unused command with args and returns its flags
+// once the configuration is applied, without analyzing anything
+func configuredFlags(t *testing.T, args ...string) (*pflag.FlagSet, error) {
+	t.Helper()
+	root := newRootCmd()
+	unused, _, err := root.Find([]string{"unused"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var flags *pflag.FlagSet
+	unused.RunE = func(cmd *cobra.Command, _ []string) error {
+		flags = cmd.Flags()
+		return nil
+	}
+	root.SetArgs(append([]string{"unused"}, args...))
+	return flags, root.Execute()
+}
+
+func TestApplyTOMLConfig(t *testing.T) {
+	dir := writeProject(t, map[string]string{"pybroom.toml": `format = "json"
+skip_private = true
+top = 3
+output = "reports/pybr.json"
+exclude = ["migrations/**"]
+min_change = 5  # a flag of the diff command
+`})
+	config := filepath.Join(dir, "pybroom.toml")
+
+	tests := []struct {
+		name string
+		args []string
+		want map[string]string
+	}{
+		{
+			name: "file values",
+			args: []string{"--config", config},
+			want: map[string]string{
+				"format":       "json",
+				"skip-private": "true",
+				"top":          "3",
+				"output":       filepath.Join(dir, "reports", "pybr.json"),
+				"exclude":      "[migrations/**]",
+			},
+		},
+		{
+			name: "flags win",
+			args: []string{"--config", config, "--format", "csv", "--top=0", "--exclude", "build/**", "-o", "out.json"},
+			want: map[string]string{
+				"format":       "csv",
+				"skip-private": "true",
+				"top":          "0",
+				"output":       "out.json",
+				"exclude":      "[build/**]",
+			},
+		},
+		{
+			name: "no config",
+			args: []string{"--config", config, "--no-config"},
+			want: map[string]string{"format": "console", "skip-private": "false", "top": "0", "output": "", "exclude": "[]"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			flags, err := configuredFlags(t, tt.args...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for name, want := range tt.want {
+				if got := flags.Lookup(name).Value.String(); got != want {
+					t.Errorf("--%s = %q, want %q", name, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestApplyTOMLConfig_Errors(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		err  string
+	}{
+		{"unknown key", "format = \"json\"\nno_such_flag = 1\n", "unknown key 'no_such_flag'"},
+		{"unsettable key", "no-config = true\n", "'no-config' cannot be set in a configuration file"},
+		{"array for a single value", "format = [\"json\", \"csv\"]\n", "expected a single value, not an array"},
+		{"invalid flag value", "top = \"many\"\n", "top"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := writeProject(t, map[string]string{"pybroom.toml": tt.src})
+			_, err := configuredFlags(t, "--config", filepath.Join(dir, "pybroom.toml"))
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("error = %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestReadYAMLConfig(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		keys []string
+		want map[string]any
+		err  string
+	}{
+		{
+			name: "scalars",
+			src:  "---\nformat: json\ntop: 3\nskip_private: true\n",
+			keys: []string{"format", "top", "skip_private"},
+			want: map[string]any{"format": "json", "top": "3", "skip_private": "true"},
+		},
+		{
+			name: "block sequence",
+			src:  "exclude:\n  - migrations/**\n\n  # generated\n  - 'build/**'\nformat: csv\n",
+			keys: []string{"exclude", "format"},
+			want: map[string]any{"exclude": []any{"migrations/**", "build/**"}, "format": "csv"},
+		},
+		{
+			name: "flow sequence",
+			src:  "dir: [src, \"lib, old\",\n  tools]  # roots\nexclude: []\n",
+			keys: []string{"dir", "exclude"},
+			want: map[string]any{"dir": []any{"src", "lib, old", "tools"}, "exclude": []any{}},
+		},
+		{
+			name: "comments and quoting",
+			src:  "# defaults\nname-regex: \"^handle_\\\\w+ #x\"  # names\nlabel: 'it''s'\nmarks: a#b\n\"output\": out.json\r\n",
+			keys: []string{"name-regex", "label", "marks", "output"},
+			want: map[string]any{"name-regex": `^handle_\w+ #x`, "label": "it's", "marks": "a#b", "output": "out.json"},
+		},
+		{name: "nested mapping", src: "budget:\n  max: 1\n", err: "nested mappings are not supported"},
+		{name: "flow mapping", src: "budget: {max: 1}\n", err: "flow mappings are not supported"},
+		{name: "nested sequence", src: "dir: [[src]]\n", err: "nested collections are not supported"},
+		{name: "unterminated sequence", src: "dir: [src,\n  lib\n", err: "unterminated sequence"},
+		{name: "unterminated string", src: "label: 'open\n", err: "unterminated string"},
+		{name: "missing value", src: "format:\ntop: 3\n", err: "format: missing value"},
+		{name: "no colon", src: "format\n", err: "expected key: value"},
+		{name: "duplicate key", src: "top: 1\ntop: 2\n", err: "top is defined twice"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := writeProject(t, map[string]string{".pybroom.yaml": tt.src})
+			got, err := readConfig(filepath.Join(dir, ".pybroom.yaml"))
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("readConfig() error = %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("readConfig() error = %v", err)
+			}
+			if !reflect.DeepEqual(got.keys, tt.keys) || !reflect.DeepEqual(got.values, tt.want) {
+				t.Errorf("readConfig() = %v %v, want %v %v", got.keys, got.values, tt.keys, tt.want)
+			}
+		})
+	}
+}
+
+func TestConfigDiscovery(t *testing.T) {
+	// cwd/.pybroom.yaml, cwd/project/pybroom.toml with pyproject.toml next to
+	// it, cwd/bare/sub/pyproject.toml without a [tool.pybroom] table, and
+	// cwd/other.yml only read through --config
+	cwd := writeProject(t, map[string]string{".pybroom.yaml": "format: json\ntop: 7\n", "other.yml": "format: markdown\n"})
+	project := filepath.Join(cwd, "project")
+	bare := filepath.Join(cwd, "bare", "sub")
+	for dir, files := range map[string]map[string]string{
+		project: {"pybroom.toml": "format = \"csv\"\n", "pyproject.toml": "[tool.pybroom]\nformat = \"table\"\n"},
+		bare:    {"pyproject.toml": "[tool.black]\nline-length = 100\n"},
+	} {
+		for name, src := range files {
+			writeConfigFile(t, filepath.Join(dir, name), src)
+		}
+	}
+	t.Chdir(cwd)
+
+	tests := []struct {
+		name   string
+		args   []string
+		format string
+		top    string
+	}{
+		{"current directory", nil, "json", "7"},
+		{"first --dir", []string{"--dir", project, "--dir", cwd}, "csv", "0"},
+		{"--dir above a pyproject.toml without the table", []string{"--dir", bare}, "json", "7"},
+		{"flags over the discovered file", []string{"--dir", project, "--format", "console"}, "console", "0"},
+		{"explicit --config", []string{"--dir", project, "--config", "other.yml"}, "markdown", "0"},
+		{"--no-config", []string{"--dir", project, "--no-config"}, "console", "0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			flags, err := configuredFlags(t, tt.args...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := flags.Lookup("format").Value.String(); got != tt.format {
+				t.Errorf("--format = %q, want %q", got, tt.format)
+			}
+			if got := flags.Lookup("top").Value.String(); got != tt.top {
+				t.Errorf("--top = %q, want %q", got, tt.top)
+			}
+		})
+	}
+
+	if _, err := configuredFlags(t, "--config", "missing.yaml"); err == nil {
+		t.Error("--config missing.yaml succeeded, want an error")
+	}
+}
+
+// writeConfigFile writes src to path, creating its directory
+func writeConfigFile(t *testing.T, path, src string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(src), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/sanchezhs/py-broom/finder"
+	"github.com/spf13/cobra"
+)
+
+// daemonRequest is a query sent to the daemon, one JSON object per line
+type daemonRequest struct {
+	Method string `json:"method"`           // --method style spec, every method when empty
+	Unused bool   `json:"unused,omitempty"` // Only methods without real usages
+}
+
+// daemonResponse answers a daemonRequest on a single line
+type daemonResponse struct {
+	Results []finder.MethodUsage `json:"results"`
+	Indexed time.Time            `json:"indexed"` // When the answering index was built
+	Error   string               `json:"error,omitempty"`
+}
+
+func newDaemonCmd(opts *options) *cobra.Command {
+	var socket string
+	var interval time.Duration
+	cmd := &cobra.Command{
+		Use:   "daemon",
+		Short: "Keep an in-memory index of definitions and usages and answer queries over a socket",
+		Long: "Keep an in-memory index of definitions and usages and answer queries over a socket.\n\n" +
+			"The index is rebuilt whenever a file under --dir or --search-dir changes. Each\n" +
+			"request is a JSON line such as {\"method\": \"Invoice.total\"} or {\"unused\": true},\n" +
+			"answered with a JSON line holding the matching results.",
+		SilenceUsage: true,
+		Args:         cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if interval <= 0 {
+				return fmt.Errorf("%s: --interval must be positive", programName)
+			}
+			return runDaemon(cmd.Context(), opts, socket, interval)
+		},
+	}
+	cmd.Flags().StringVar(&socket, "socket", filepath.Join(os.TempDir(), programName+".sock"), "Unix socket to listen on")
+	cmd.Flags().DurationVar(&interval, "interval", 2*time.Second, "How often files are checked for changes")
+	return cmd
+}
+
+func runDaemon(ctx context.Context, opts *options, socket string, interval time.Duration) error {
+	index, err := buildIndex(ctx, opts)
+	if err != nil {
+		return err
+	}
+
+	// A socket left behind by a daemon that did not shut down cleanly
+	if conn, err := net.Dial("unix", socket); err == nil {
+		conn.Close()
+		return fmt.Errorf("%s: a daemon is already listening on %s", programName, socket)
+	}
+	os.Remove(socket)
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		return fmt.Errorf("%s: %w", programName, err)
+	}
+	defer os.Remove(socket)
+
+	go func() {
+		<-ctx.Done()
+		listener.Close()
+	}()
+
+	go func() {
+		for range time.Tick(interval) {
+			rebuilt, err := index.Refresh(ctx)
+			switch {
+			case ctx.Err() != nil:
+				return
+			case err != nil:
+				log.Printf("Error refreshing index: %v", err)
+			case rebuilt && opts.verbose:
+				log.Printf("Reindexed %d methods\n", len(index.Lookup(finder.MethodSpec{})))
+			}
+		}
+	}()
+
+	log.Printf("Listening on %s\n", socket)
+	for {
+		conn, err := listener.Accept()
+		if errors.Is(err, net.ErrClosed) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", programName, err)
+		}
+		go serveDaemonConn(conn, index)
+	}
+}
+
+// buildIndex indexes the definitions and usages of the trees of the flags,
+// for the commands answering queries from memory
+func buildIndex(ctx context.Context, opts *options) (*finder.Index, error) {
+	var encoding string
+	if opts.encoding != "" {
+		var err error
+		if encoding, err = finder.ParseEncoding(opts.encoding); err != nil {
+			return nil, fmt.Errorf("%s: --encoding: %w", programName, err)
+		}
+	}
+	engine := opts.engine
+	if engine == "" {
+		engine = defaultEngine()
+	}
+
+	index := &finder.Index{
+		Dirs:         opts.dirs,
+		SearchDirs:   opts.searchDirs,
+		DirFilter:    newDirFilter(opts),
+		MethodFilter: newMethodFilter(opts, encoding),
+		FileFilter:   newFileFilter(opts, encoding, engine),
+	}
+	if _, err := index.Refresh(ctx); err != nil {
+		return nil, fmt.Errorf("%s: building index: %w", programName, err)
+	}
+	if opts.verbose {
+		log.Printf("Indexed %d methods\n", len(index.Lookup(finder.MethodSpec{})))
+	}
+	return index, nil
+}
+
+// serveDaemonConn answers the requests of a client until it disconnects
+func serveDaemonConn(conn net.Conn, index *finder.Index) {
+	defer conn.Close()
+	scanner := bufio.NewScanner(conn)
+	encoder := json.NewEncoder(conn)
+	for scanner.Scan() {
+		var req daemonRequest
+		var resp daemonResponse
+		if err := json.Unmarshal(scanner.Bytes(), &req); err != nil {
+			resp.Error = fmt.Sprintf("invalid request: %v", err)
+		} else {
+			resp.Results = index.Lookup(finder.ParseMethodSpec(req.Method))
+			if req.Unused {
+				resp.Results = finder.FilterUnused(resp.Results)
+			}
+			resp.Indexed = index.Built()
+		}
+		if resp.Results == nil {
+			resp.Results = []finder.MethodUsage{}
+		}
+		if err := encoder.Encode(resp); err != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/sanchezhs/py-broom/finder"
+	"github.com/sanchezhs/py-broom/printers"
+	"github.com/spf13/cobra"
+)
+
+func newDiffCmd(opts *options) *cobra.Command {
+	diff := finder.DiffOptions{}
+	cmd := &cobra.Command{
+		Use:   "diff old.json new.json",
+		Short: "Compare the JSON outputs of two runs",
+		Long: "Compare the JSON outputs of two runs.\n\n" +
+			"Both files hold the --format json or jsonl output of a run over every definition,\n" +
+			"such as one on the base branch and one on a pull request, with paths written\n" +
+			"alike (see --paths relative). Definitions that became unused, were added or\n" +
+			"removed, and whose real usages changed by at least --min-change and\n" +
+			"--min-change-percent are printed in the console, table, markdown or json format.\n" +
+			"--fail-on-unused, --max-unused and --max-unused-percent count the unused\n" +
+			"definitions the new run brings, those that became unused and the added ones.",
+		SilenceUsage: true,
+		Args:         cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var runs [2][]finder.MethodUsage
+			for i, path := range args {
+				results, err := readResults(path)
+				if err != nil {
+					return fmt.Errorf("%s: %s: %w", programName, path, err)
+				}
+				runs[i] = results
+			}
+			pr, err := newPrinter(cmd, opts, nil, nil)
+			if pr == nil {
+				return err
+			}
+			dp, ok := pr.(printers.DiffPrinter)
+			if !ok {
+				return fmt.Errorf("%s: format '%s' does not support diff", programName, opts.format)
+			}
+			d := finder.DiffResults(runs[0], runs[1], diff)
+			if err := writeOutput(opts, func(w io.Writer) error { return dp.PrintDiff(w, d) }); err != nil {
+				return err
+			}
+			opts.unusedCount, opts.analyzedCount = d.NewUnused(), d.MethodsAfter
+			return checkBudget(opts)
+		},
+	}
+	cmd.Flags().IntVar(&diff.MinChange, "min-change", 2, "Only report usage changes of at least N usages")
+	cmd.Flags().Float64Var(&diff.MinChangePercent, "min-change-percent", 50, "Only report usage changes of at least P percent of the old usages")
+	return cmd
+}
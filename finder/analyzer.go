@@ -0,0 +1,283 @@
+package finder
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Options configures an Analyzer, like the flags of the command line do
+type Options struct {
+	Dirs         []string       // Roots to look for definitions in, the current directory when empty
+	SearchDirs   []string       // Roots to search usages in, Dirs when empty
+	Paths        []string       // Files to take the definitions from instead of walking Dirs
+	Kind         SymbolKind     // Kind of definitions, SymbolFunction when empty
+	Methods      []MethodSpec   // Only analyze the definitions matching one of these when set
+	NameRegex    *regexp.Regexp // Only analyze the definitions whose name matches when set
+	ChangedSince string         // Only take definitions from the files changed since this git ref
+	Shard        Shard          // Only analyze this slice of the definitions
+
+	// Report the functions with similar bodies instead of usages
+	Duplicates bool
+	Similarity float64 // Minimum body overlap of duplicates, DefaultSimilarity when 0
+
+	Walk     DirFilter
+	Find     MethodFilter // Methods and NameRegex are taken from above
+	Search   FileFilter
+	RefIndex string // Reference index answering usage searches, loaded and saved by every run when set
+
+	Baseline *Baseline                         // Accepted definitions, neither reported nor counted
+	Filter   func([]MethodUsage) []MethodUsage // Keeps the results to report, all when nil
+	Relocate func(path string) string          // Rewrites the reported paths, see RelocatePaths
+
+	SortBy string // Sort key of SortResults, completion order when empty
+	Asc    bool
+	Top    int // Keeps only the first results once sorted, all when 0
+
+	Log func(format string, args ...any) // Receives progress messages when set
+}
+
+// Analyzer runs the whole pipeline of the command line: it walks the
+// directories, finds the definitions, analyzes their usages, then filters and
+// sorts the results. Programs embed it instead of running pybr.
+type Analyzer struct {
+	opts Options
+}
+
+// New returns an Analyzer configured by opts
+func New(opts Options) *Analyzer {
+	if len(opts.Dirs) == 0 {
+		opts.Dirs = []string{"."}
+	}
+	if len(opts.SearchDirs) == 0 {
+		opts.SearchDirs = opts.Dirs
+	}
+	if opts.Kind == "" {
+		opts.Kind = SymbolFunction
+	}
+	if opts.Similarity == 0 {
+		opts.Similarity = DefaultSimilarity
+	}
+	opts.Find.Methods, opts.Find.NameRegex = opts.Methods, opts.NameRegex
+	return &Analyzer{opts: opts}
+}
+
+// PartialError is returned along with the results analyzed until the context
+// of a run was done
+type PartialError struct {
+	Stage    string // What the run was doing, such as "finding definitions"
+	Analyzed int    // Definitions analyzed out of Total, when stopped while analyzing usages
+	Total    int
+	Err      error // The error of the context
+}
+
+func (e *PartialError) Error() string {
+	if e.Total > 0 {
+		return fmt.Sprintf("analysis stopped after %d/%d definitions: %v", e.Analyzed, e.Total, e.Err)
+	}
+	return fmt.Sprintf("stopped while %s: %v", e.Stage, e.Err)
+}
+
+func (e *PartialError) Unwrap() error { return e.Err }
+
+// Run analyzes every definition and returns the results once all of them
+// completed. TotalMethods counts the definitions found, and Stats.Files the
+// Python files they were looked for in, so empty results can be told apart.
+func (a *Analyzer) Run(ctx context.Context) (AnalysisResult, error) {
+	methods, result, err := a.discover(ctx)
+	if err != nil || len(methods) == 0 {
+		return result, err
+	}
+	opts := a.opts
+
+	if opts.Duplicates {
+		// Duplicates are not filtered
+		result.Results = FindDuplicates(methods, opts.Similarity, opts.Find.Encoding)
+		if opts.Relocate != nil {
+			RelocatePaths(result.Results, opts.Relocate)
+		}
+		return result, nil
+	}
+
+	a.logf("Analyzing %s usages in: %s", a.noun(), strings.Join(opts.SearchDirs, ", "))
+	filters, saveIndex := a.fileFilter()
+	start := time.Now()
+	results := AnalyzeMethodUsages(ctx, methods, opts.SearchDirs, filters)
+	result.Stats.Collect(filters.Stats, time.Since(start))
+	saveIndex()
+	if ctx.Err() != nil {
+		err = &PartialError{Stage: "analyzing usages", Analyzed: len(results), Total: len(methods), Err: ctx.Err()}
+	}
+
+	results = a.filter(results, &result)
+	if opts.Filter != nil {
+		a.logf("Filtered to %d %ss", len(results), a.noun())
+	}
+	if opts.SortBy != "" {
+		SortResults(results, opts.SortBy, opts.Asc)
+	}
+	if opts.Top > 0 && len(results) > opts.Top {
+		results = results[:opts.Top]
+	}
+	result.Results = results
+	return result, err
+}
+
+// Stream analyzes every definition like Run, handing each result passing the
+// filter to emit as soon as it completes, in completion order. The returned
+// result holds no results.
+func (a *Analyzer) Stream(ctx context.Context, emit func(MethodUsage)) (AnalysisResult, error) {
+	methods, result, err := a.discover(ctx)
+	if err != nil || len(methods) == 0 {
+		return result, err
+	}
+	opts := a.opts
+
+	a.logf("Analyzing %s usages in: %s", a.noun(), strings.Join(opts.SearchDirs, ", "))
+	filters, saveIndex := a.fileFilter()
+	start := time.Now()
+	analyzed := 0
+	StreamMethodUsages(ctx, methods, opts.SearchDirs, filters, func(r MethodUsage) {
+		analyzed++
+		for _, r := range a.filter([]MethodUsage{r}, &result) {
+			emit(r)
+		}
+	})
+	result.Stats.Collect(filters.Stats, time.Since(start))
+	saveIndex()
+	if ctx.Err() != nil {
+		err = &PartialError{Stage: "analyzing usages", Analyzed: analyzed, Total: len(methods), Err: ctx.Err()}
+	}
+	return result, err
+}
+
+// discover reads the files and finds the definitions to analyze
+func (a *Analyzer) discover(ctx context.Context) ([]Method, AnalysisResult, error) {
+	opts := a.opts
+	result := AnalysisResult{Stats: &RunStats{}}
+	stats := result.Stats
+
+	// Definitions naming their file need no directory walk
+	paths := opts.Paths
+	fullySpecified := len(opts.Methods) > 0
+	for _, spec := range opts.Methods {
+		fullySpecified = fullySpecified && spec.FullySpecified()
+	}
+	if len(paths) == 0 && fullySpecified {
+		for _, spec := range opts.Methods {
+			paths = append(paths, spec.File)
+		}
+		paths = slices.Compact(slices.Sorted(slices.Values(paths)))
+	}
+
+	var files []File
+	var err error
+	start := time.Now()
+	if len(paths) > 0 {
+		if files, err = FilesFromPaths(paths, opts.Dirs); err != nil {
+			return nil, result, err
+		}
+	} else {
+		a.logf("Searching for Python files in: %s", strings.Join(opts.Dirs, ", "))
+		files, err = ReadDirs(ctx, opts.Dirs, opts.Walk)
+		if ctx.Err() != nil {
+			return nil, result, &PartialError{Stage: "reading directories", Err: ctx.Err()}
+		}
+		if err != nil {
+			return nil, result, fmt.Errorf("reading directory: %w", err)
+		}
+	}
+	if opts.ChangedSince != "" {
+		if files, err = ChangedSince(files, opts.ChangedSince); err != nil {
+			return nil, result, fmt.Errorf("changed since %s: %w", opts.ChangedSince, err)
+		}
+	}
+	stats.Elapsed.Walk = Millis(time.Since(start))
+	stats.Files = len(files)
+	a.logf("Found %d Python files", len(files))
+	if len(files) == 0 {
+		return nil, result, nil
+	}
+
+	start = time.Now()
+	var methods []Method
+	switch opts.Kind {
+	case SymbolVariable:
+		methods = FindVariables(files, opts.Find)
+	case SymbolAttribute:
+		methods = FindAttributes(files, opts.Find)
+	default:
+		methods = FindMethods(ctx, files, opts.Find)
+	}
+	if ctx.Err() != nil {
+		return nil, result, &PartialError{Stage: "finding definitions", Err: ctx.Err()}
+	}
+	stats.Elapsed.Find = Millis(time.Since(start))
+	if opts.Shard.Count > 1 {
+		methods = opts.Shard.Select(methods)
+		a.logf("Shard %d/%d holds %d %ss", opts.Shard.Index, opts.Shard.Count, len(methods), a.noun())
+	}
+	a.logf("Found %d %ss", len(methods), a.noun())
+	stats.Methods = len(methods)
+	result.TotalMethods = len(methods)
+	return methods, result, nil
+}
+
+// fileFilter returns the usage search options of a run, recording into a
+// fresh Stats unless the caller passed its own, and the function saving the
+// reference index once the search is done
+func (a *Analyzer) fileFilter() (FileFilter, func()) {
+	filters := a.opts.Search
+	if filters.Stats == nil {
+		filters.Stats = &Stats{}
+	}
+	path := a.opts.RefIndex
+	if path == "" {
+		return filters, func() {}
+	}
+	index, err := LoadRefIndex(path)
+	if err != nil {
+		a.logf("Rebuilding reference index %s: %v", path, err)
+	}
+	filters.RefIndex = index
+	return filters, func() {
+		if err := index.Save(path); err != nil {
+			filters.Warnings.add("", "saving reference index %s: %v", path, err)
+		}
+	}
+}
+
+// filter drops the accepted definitions from results and counts the others
+// into result, then keeps those passing Filter with their paths relocated
+func (a *Analyzer) filter(results []MethodUsage, result *AnalysisResult) []MethodUsage {
+	opts := a.opts
+	if opts.Baseline != nil {
+		results = FilterBaseline(results, opts.Baseline)
+	}
+	result.Analyzed += len(results)
+	result.Unused += len(FilterUnused(results))
+	if opts.Filter != nil {
+		results = opts.Filter(results)
+	}
+	if opts.Relocate != nil {
+		RelocatePaths(results, opts.Relocate)
+	}
+	return results
+}
+
+// noun names the kind of definitions in messages
+func (a *Analyzer) noun() string {
+	if a.opts.Kind == SymbolFunction {
+		return "method"
+	}
+	return string(a.opts.Kind)
+}
+
+func (a *Analyzer) logf(format string, args ...any) {
+	if a.opts.Log != nil {
+		a.opts.Log(format, args...)
+	}
+}
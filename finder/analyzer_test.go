@@ -0,0 +1,87 @@
+package finder
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestAnalyzerRun(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, dir, "lib.py", "def helper():\n    pass\n\ndef unused():\n    pass\n")
+	writeTestFile(t, dir, "app.py", "helper()\nhelper()\n")
+
+	analyzer := New(Options{
+		Dirs:   []string{dir},
+		Search: FileFilter{Engine: EngineNative},
+		Filter: FilterUnused,
+		SortBy: "name",
+	})
+	result, err := analyzer.Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if result.TotalMethods != 2 || result.Stats.Files != 2 {
+		t.Errorf("TotalMethods = %d, Files = %d, want 2 and 2", result.TotalMethods, result.Stats.Files)
+	}
+	if len(result.Results) != 1 || result.Results[0].Method.Name != "unused" {
+		t.Errorf("Results = %+v, want only unused", result.Results)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = analyzer.Run(ctx)
+	var partial *PartialError
+	if !errors.As(err, &partial) || !errors.Is(err, context.Canceled) {
+		t.Errorf("Run with a cancelled context = %v, want a PartialError", err)
+	}
+}
+
+func TestAnalyzerRun_BaselineAndRelocate(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, dir, "lib.py", "def helper():\n    pass\n\ndef unused():\n    pass\n\ndef accepted():\n    pass\n")
+	writeTestFile(t, dir, "app.py", "helper()\n")
+
+	baseline, err := LoadBaseline(filepath.Join(dir, "baseline.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	baseline.Add(Method{Name: "accepted", Filename: filepath.Join(dir, "lib.py")})
+	index := filepath.Join(t.TempDir(), "index")
+	analyzer := New(Options{
+		Dirs:     []string{dir},
+		Search:   FileFilter{Engine: EngineNative},
+		RefIndex: index,
+		Baseline: baseline,
+		Filter:   FilterUnused,
+		Relocate: RelativeTo(dir),
+	})
+	result, err := analyzer.Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	// The accepted definition is neither reported nor counted
+	if result.Analyzed != 2 || result.Unused != 1 {
+		t.Errorf("Analyzed = %d, Unused = %d, want 2 and 1", result.Analyzed, result.Unused)
+	}
+	if len(result.Results) != 1 || result.Results[0].Method.Filename != "lib.py" {
+		t.Errorf("Results = %+v, want unused in lib.py", result.Results)
+	}
+	if _, err := os.Stat(index); err != nil {
+		t.Errorf("reference index not saved: %v", err)
+	}
+
+	var streamed []string
+	result, err = analyzer.Stream(context.Background(), func(r MethodUsage) {
+		streamed = append(streamed, r.Method.Filename+":"+r.Method.Name)
+	})
+	if err != nil {
+		t.Fatalf("Stream: %v", err)
+	}
+	if result.Analyzed != 2 || result.Unused != 1 || !slices.Equal(streamed, []string{"lib.py:unused"}) {
+		t.Errorf("Stream emitted %v, Analyzed = %d, Unused = %d, want lib.py:unused, 2 and 1", streamed, result.Analyzed, result.Unused)
+	}
+}
@@ -0,0 +1,160 @@
+package finder
+
+import (
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+var (
+	classAttrRe = regexp.MustCompile(`^\s*([A-Za-z_][A-Za-z0-9_]*)\s*(?::[^=]+)?=[^=]`)
+	selfAttrRe  = regexp.MustCompile(`\bself\.([A-Za-z_][A-Za-z0-9_]*)\s*(?::[^=]+)?=[^=]`)
+)
+
+// FindAttributes finds class attributes, either assigned at class level or
+// through self.x = ... inside __init__.
+func FindAttributes(files []File, filters MethodFilter) []Method {
+	attrsChan := make(chan []Method, len(files))
+	var wg sync.WaitGroup
+	pool := newWorkerPool(filters.Jobs)
+
+	for _, pyFile := range files {
+		if skipFile(pyFile, filters) {
+			continue
+		}
+
+		wg.Add(1)
+		pool.acquire()
+		go func(file File) {
+			defer wg.Done()
+			defer pool.release()
+
+			var fileAttrs []Method
+			src, err := readSource(file.Path, filters.Encoding)
+			if err != nil {
+				log.Printf("Error reading file %s: %v", file.Path, err)
+				attrsChan <- fileAttrs
+				return
+			}
+
+			var scopes scopeTracker
+			seen := make(map[string]bool)
+			add := func(class, name string, lineNo int) {
+				line := src.lines[lineNo-1]
+				key := class + "." + name
+				if isDunder(name) || seen[key] {
+					return
+				}
+				if filters.skipName(name) {
+					return
+				}
+				seen[key] = true
+				fileAttrs = append(fileAttrs, Method{
+					Name:     name,
+					Filename: file.Path,
+					LineNo:   lineNo,
+					Kind:     SymbolAttribute,
+					Class:    class,
+					Root:     file.Root,
+					Cell:     src.cellOf(lineNo),
+					Ignored:  HasIgnoreComment(line),
+				})
+			}
+
+			for i, line := range src.lines {
+				lineNo := i + 1
+				if !scopes.update(line, lineNo) {
+					continue
+				}
+				top, ok := scopes.top()
+				if !ok || top.Line == lineNo {
+					continue
+				}
+
+				if top.IsClass {
+					if m := classAttrRe.FindStringSubmatch(line); m != nil {
+						add(top.Name, m[1], lineNo)
+					}
+					continue
+				}
+
+				if parent, ok := scopes.parent(); ok && parent.IsClass && top.Name == "__init__" {
+					for _, m := range selfAttrRe.FindAllStringSubmatch(line, -1) {
+						add(parent.Name, m[1], lineNo)
+					}
+				}
+			}
+			attrsChan <- fileAttrs
+		}(pyFile)
+	}
+
+	go func() {
+		wg.Wait()
+		close(attrsChan)
+	}()
+
+	var allAttrs []Method
+	for attrs := range attrsChan {
+		allAttrs = append(allAttrs, attrs...)
+	}
+
+	return selectMethods(allAttrs, filters)
+}
+
+// attributePattern matches obj.name accesses and class-level name = ... lines
+func attributePattern(name string) string {
+	quoted := regexp.QuoteMeta(name)
+	return `\.` + quoted + `\b|^\s*` + quoted + `\s*(?::[^=]+)?=[^=]`
+}
+
+// attributeColumn moves the 1-based byte column of a match of
+// attributePattern, which starts at the dot or the indentation, onto the name
+func attributeColumn(line, col, name string) string {
+	n, err := strconv.Atoi(col)
+	if err != nil || n < 1 || n > len(line) {
+		return col
+	}
+	rest := line[n-1:]
+	trimmed := strings.TrimLeft(rest, " \t.")
+	if !strings.HasPrefix(trimmed, name) {
+		return col
+	}
+	return strconv.Itoa(n + len(rest) - len(trimmed))
+}
+
+// classifyAttributeUsage tells apart attribute writes (obj.x = ...) from reads
+func classifyAttributeUsage(line string, name string, filters FileFilter) (CallType, bool) {
+	trimmed := strings.TrimSpace(line)
+	quoted := regexp.QuoteMeta(name)
+	wordRe := regexp.MustCompile(`\b` + quoted + `\b`)
+
+	if strings.HasPrefix(trimmed, "#") {
+		if filters.IncludeComments && wordRe.MatchString(trimmed) {
+			return CallTypeComment, true
+		}
+		return "", false
+	}
+
+	if idx := strings.Index(line, "#"); idx != -1 {
+		if !wordRe.MatchString(line[:idx]) {
+			if filters.IncludeComments && wordRe.MatchString(line[idx:]) {
+				return CallTypeComment, true
+			}
+			return "", false
+		}
+		line = line[:idx]
+	}
+
+	if !regexp.MustCompile(attributePattern(name)).MatchString(line) {
+		return "", false
+	}
+
+	write := regexp.MustCompile(`(?:\.|^\s*)` + quoted + `\s*(?::[^=]+)?(?:[-+*/%&|^@]|//|\*\*|<<|>>)?=[^=]`)
+	if write.MatchString(line) {
+		return CallTypeWrite, true
+	}
+
+	return CallTypeRead, true
+}
@@ -0,0 +1,74 @@
+package finder
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestFindAttributes(t *testing.T) {
+	dir := t.TempDir()
+	p := writeTestFile(t, dir, "box.py", `class Box:
+    size = 3
+
+    def __init__(self, w):
+        self.w = w
+        if w:
+            self.h: int = 2
+        self.w = 0
+
+    def area(self):
+        self.tmp = 1
+        return self.w * self.h
+`)
+
+	got := FindAttributes([]File{{Dir: dir, Base: filepath.Base(p), Path: p}}, MethodFilter{})
+	want := []Method{
+		{Name: "size", Filename: p, LineNo: 2, Kind: SymbolAttribute, Class: "Box"},
+		{Name: "w", Filename: p, LineNo: 5, Kind: SymbolAttribute, Class: "Box"},
+		{Name: "h", Filename: p, LineNo: 7, Kind: SymbolAttribute, Class: "Box"},
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("FindAttributes mismatch\n got: %#v\nwant: %#v", got, want)
+	}
+}
+
+func TestAnalyzeAttributeUsages_DotRoot(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, dir, "pkg/a.py", `class A:
+    limit = 3
+
+    def __init__(self):
+        self.count = 0
+
+    def bump(self):
+        self.count += self.limit
+`)
+	t.Chdir(dir)
+
+	for _, engine := range searchEngines(t) {
+		result, err := New(Options{Dirs: []string{"."}, Kind: SymbolAttribute, Search: FileFilter{Engine: engine}}).Run(context.Background())
+		if err != nil {
+			t.Fatalf("%s: Run: %v", engine, err)
+		}
+		got := make(map[string]CallType)
+		for _, r := range result.Results {
+			for _, u := range r.Usages {
+				got[filepath.Clean(u.Location)] = u.CallType
+			}
+		}
+		// Columns point at the name, not at the indentation or the dot
+		file := filepath.Join("pkg", "a.py")
+		want := map[string]CallType{
+			file + ":2:5":  CallTypeDefinition,
+			file + ":5:14": CallTypeDefinition,
+			file + ":8:14": CallTypeWrite,
+			file + ":8:28": CallTypeRead,
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: usages = %v, want %v", engine, got, want)
+		}
+	}
+}
@@ -0,0 +1,295 @@
+package finder
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// SearchBackend searches the files below a set of directories with an
+// external tool. Notebooks are never handed to a backend, they are decoded
+// and searched in-process.
+type SearchBackend interface {
+	// Command is the executable the backend runs
+	Command() string
+	// Search returns a vimgrep line, path:line:column:text, for every match
+	// of pattern in the searched files. Finding nothing is not an error.
+	Search(ctx context.Context, pattern string, searchDirs []string, filters FileFilter) ([]string, error)
+}
+
+// patternLimiter is implemented by backends whose regex engine caps the size
+// of a pattern, and so the methods combined into one batch
+type patternLimiter interface {
+	maxPattern() int
+}
+
+var searchBackends = map[string]SearchBackend{
+	EngineRipgrep: ripgrepBackend{},
+	EngineGitGrep: gitGrepBackend{},
+	EngineUgrep:   ugrepBackend{},
+}
+
+// Backend returns the backend running the engine, nil for the native engine
+// and unknown names
+func Backend(engine string) SearchBackend {
+	return searchBackends[engine]
+}
+
+// search runs the backend of filters.Engine, ripgrep when empty, once a
+// process slot is free
+func search(ctx context.Context, pattern string, searchDirs []string, filters FileFilter) ([]string, error) {
+	backend := Backend(filters.Engine)
+	if backend == nil {
+		backend = ripgrepBackend{}
+	}
+	if filters.procs != nil {
+		filters.procs.acquire()
+		defer filters.procs.release()
+	}
+	filters.Stats.addBackendRun()
+	lines, err := backend.Search(ctx, pattern, searchDirs, filters)
+	if ctx.Err() != nil {
+		return nil, ctx.Err() // Killed part way, whatever it printed is incomplete
+	}
+	return lines, err
+}
+
+// searchGlobs returns, in ripgrep syntax, the globs of the files a backend
+// searches and of those it skips
+func searchGlobs(filters FileFilter) (include, exclude []string) {
+	for _, ext := range extensions(filters.Extensions, filters.Stubs) {
+		if ext != ".ipynb" {
+			include = append(include, "*"+ext)
+		}
+	}
+	if filters.SkipTests {
+		exclude = append(exclude, testGlobs(filters.TestGlobs)...)
+	}
+	exclude = append(exclude, filters.Exclude...)
+	return include, exclude
+}
+
+// runSearch runs a grep-like command, which exits with 1 when nothing matched.
+// A command that failed on some files but still matched others returns its
+// matches along with a *searchWarning.
+func runSearch(cmd *exec.Cmd) ([]string, error) {
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		exitErr, ok := err.(*exec.ExitError)
+		if !ok {
+			return nil, err
+		}
+		if exitErr.ExitCode() == 1 {
+			return []string{}, nil
+		}
+		name := filepath.Base(cmd.Path)
+		var complaints []string
+		for _, line := range strings.Split(strings.TrimSpace(stderr.String()), "\n") {
+			if line != "" {
+				complaints = append(complaints, name+": "+line)
+			}
+		}
+		if len(complaints) == 0 {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		if len(bytes.TrimSpace(out)) > 0 {
+			return strings.Split(strings.TrimSpace(string(out)), "\n"), &searchWarning{lines: complaints}
+		}
+		return nil, errors.New(strings.Join(complaints, "; "))
+	}
+	return strings.Split(strings.TrimSpace(string(out)), "\n"), nil
+}
+
+// perMatch turns lines reported once per matching line into one vimgrep
+// line per match, with the byte columns ripgrep reports
+func perMatch(lines []string, re *regexp.Regexp) []string {
+	var out []string
+	for _, raw := range lines {
+		parts := strings.SplitN(raw, ":", 4)
+		if len(parts) < 4 {
+			continue
+		}
+		out = append(out, matchLine(parts[0], parts[1], parts[3], re)...)
+	}
+	return out
+}
+
+// ripgrepBackend runs rg --vimgrep, the fast path
+type ripgrepBackend struct{}
+
+func (ripgrepBackend) Command() string { return "rg" }
+
+func (ripgrepBackend) Search(ctx context.Context, pattern string, searchDirs []string, filters FileFilter) ([]string, error) {
+	args := []string{"--vimgrep"}
+	if filters.NoIgnore {
+		args = append(args, "--no-ignore")
+	}
+	if filters.FollowSymlinks {
+		args = append(args, "--follow")
+	}
+	if filters.MaxDepth > 0 {
+		args = append(args, "--max-depth", strconv.Itoa(filters.MaxDepth))
+	}
+	if filters.Encoding != "" {
+		args = append(args, "--encoding", rgEncodings[filters.Encoding])
+	}
+	include, exclude := searchGlobs(filters)
+	for _, g := range include {
+		args = append(args, "--glob", g)
+	}
+	for _, g := range exclude {
+		args = append(args, "--glob", "!"+g)
+	}
+	args = append(args, filters.RgArgs...)
+
+	args = append(args, "--", pattern)
+	args = append(args, searchDirs...)
+	return runSearch(exec.CommandContext(ctx, "rg", args...))
+}
+
+// gitGrepBackend runs git grep in every search directory, falling back to
+// --no-index outside of a work tree. It neither follows symlinks nor decodes
+// UTF-16.
+type gitGrepBackend struct{}
+
+func (gitGrepBackend) Command() string { return "git" }
+
+// maxPattern keeps patterns well within PCRE2's 64KiB of compiled code
+func (gitGrepBackend) maxPattern() int { return 16 << 10 }
+
+// pathspec translates a ripgrep glob: one without a slash matches at any depth
+func pathspec(glob string, exclude bool) string {
+	magic := ":(glob)"
+	if exclude {
+		magic = ":(glob,exclude)"
+	}
+	if !strings.Contains(glob, "/") {
+		return magic + "**/" + glob
+	}
+	return magic + strings.TrimPrefix(glob, "/")
+}
+
+func (gitGrepBackend) Search(ctx context.Context, pattern string, searchDirs []string, filters FileFilter) ([]string, error) {
+	if filters.Encoding == EncodingUTF16LE || filters.Encoding == EncodingUTF16BE {
+		return nil, fmt.Errorf("git grep can not search %s files", filters.Encoding)
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	include, exclude := searchGlobs(filters)
+
+	var lines []string
+	for _, dir := range searchDirs {
+		// A single file, as searched for nested functions
+		root, file := dir, ""
+		if info, err := os.Stat(dir); err == nil && !info.IsDir() {
+			root, file = filepath.Dir(dir), filepath.Base(dir)
+		}
+
+		args := []string{"-C", root, "-c", "core.quotePath=false", "grep", "-n", "--column", "-I", "-P"}
+		if out, err := git(root, "rev-parse", "--is-inside-work-tree"); err == nil && len(out) == 1 && out[0] == "true" {
+			args = append(args, "--untracked")
+			if filters.NoIgnore {
+				args = append(args, "--no-exclude-standard")
+			}
+		} else {
+			args = append(args, "--no-index")
+			if !filters.NoIgnore {
+				args = append(args, "--exclude-standard")
+			}
+		}
+		args = append(args, "-e", pattern, "--")
+		if file != "" {
+			args = append(args, file)
+		} else {
+			for _, g := range include {
+				args = append(args, pathspec(g, false))
+			}
+		}
+		for _, g := range exclude {
+			args = append(args, pathspec(g, true))
+		}
+
+		out, err := runSearch(exec.CommandContext(ctx, "git", args...))
+		if err != nil {
+			return nil, err
+		}
+		for _, line := range out {
+			rel, rest, ok := strings.Cut(line, ":")
+			if !ok {
+				continue
+			}
+			if file != "" {
+				lines = append(lines, dir+":"+rest)
+				continue
+			}
+			// --max-depth is ignored by git for wildcard pathspecs
+			if filters.MaxDepth > 0 && strings.Count(rel, "/")+1 > filters.MaxDepth {
+				continue
+			}
+			lines = append(lines, strings.TrimSuffix(dir, "/")+"/"+rel+":"+rest)
+		}
+	}
+	return perMatch(lines, re), nil
+}
+
+// ugrepEncodings are the labels ugrep understands for each encoding
+var ugrepEncodings = map[string]string{
+	EncodingUTF8:    "UTF-8",
+	EncodingLatin1:  "LATIN1",
+	EncodingUTF16LE: "UTF-16LE",
+	EncodingUTF16BE: "UTF-16BE",
+}
+
+// ugrepBackend runs ugrep, whose globs and ignore files work as ripgrep's
+type ugrepBackend struct{}
+
+func (ugrepBackend) Command() string { return "ugrep" }
+
+// maxPattern matches gitGrepBackend's, -P patterns are compiled by PCRE2 too
+func (ugrepBackend) maxPattern() int { return 16 << 10 }
+
+func (ugrepBackend) Search(ctx context.Context, pattern string, searchDirs []string, filters FileFilter) ([]string, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	args := []string{"-r", "-H", "-n", "-k", "-I", "-P", "--color=never"}
+	if filters.FollowSymlinks {
+		args[0] = "-R"
+	}
+	if !filters.NoIgnore {
+		args = append(args, "--ignore-files")
+	}
+	if filters.MaxDepth > 0 {
+		args = append(args, "--depth="+strconv.Itoa(filters.MaxDepth))
+	}
+	if filters.Encoding != "" {
+		args = append(args, "--encoding="+ugrepEncodings[filters.Encoding])
+	}
+	include, exclude := searchGlobs(filters)
+	for _, g := range include {
+		args = append(args, "-g", g)
+	}
+	for _, g := range exclude {
+		args = append(args, "-g", "!"+g)
+	}
+
+	args = append(args, "-e", pattern, "--")
+	args = append(args, searchDirs...)
+	lines, err := runSearch(exec.CommandContext(ctx, "ugrep", args...))
+	if err != nil {
+		return nil, err
+	}
+	return perMatch(lines, re), nil
+}
@@ -0,0 +1,62 @@
+package finder
+
+import (
+	"context"
+	"os/exec"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestGitGrepBackend(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	dir := t.TempDir()
+	if _, err := git(dir, "init", "-q"); err != nil {
+		t.Fatal(err)
+	}
+	writeTestFile(t, dir, ".gitignore", "build/\n")
+	app := writeTestFile(t, dir, "app.py", "run(1); run(2)\n")
+	pkg := writeTestFile(t, dir, "pkg/jobs.py", "def run(x):\n    pass\n")
+	writeTestFile(t, dir, "pkg/test_jobs.py", "run(3)\n")
+	writeTestFile(t, dir, "build/app.py", "run(4)\n")
+
+	backend := gitGrepBackend{}
+	got, err := backend.Search(context.Background(), `\brun\s*\(`, []string{dir}, FileFilter{SkipTests: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(got)
+	want := []string{app + ":1:1:run(1); run(2)", app + ":1:9:run(1); run(2)", pkg + ":1:5:def run(x):"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Search = %q, want %q", got, want)
+	}
+
+	// A single file, as searched for nested functions
+	got, err = backend.Search(context.Background(), `\brun\s*\(`, []string{pkg}, FileFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{pkg + ":1:5:def run(x):"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Search = %q, want %q", got, want)
+	}
+}
+
+func TestPathspec(t *testing.T) {
+	tests := []struct {
+		glob    string
+		exclude bool
+		want    string
+	}{
+		{"*.py", false, ":(glob)**/*.py"},
+		{"test_*.py", true, ":(glob,exclude)**/test_*.py"},
+		{"migrations/**", true, ":(glob,exclude)migrations/**"},
+		{"/setup.py", true, ":(glob,exclude)setup.py"},
+	}
+	for _, tt := range tests {
+		if got := pathspec(tt.glob, tt.exclude); got != tt.want {
+			t.Errorf("pathspec(%q, %v) = %q, want %q", tt.glob, tt.exclude, got, tt.want)
+		}
+	}
+}
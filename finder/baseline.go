@@ -0,0 +1,113 @@
+package finder
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// baselineVersion is the format version of the baseline files written
+const baselineVersion = 1
+
+// BaselineEntry identifies a definition by its file, relative to the
+// baseline, and its Class.name, so that edits moving it keep it matched
+type BaselineEntry struct {
+	File string `json:"file"`
+	Name string `json:"name"`
+}
+
+// Baseline lists the definitions accepted as unused, left out of reports
+type Baseline struct {
+	Version int             `json:"version"`
+	Entries []BaselineEntry `json:"entries"`
+
+	path  string
+	index map[BaselineEntry]bool
+}
+
+// LoadBaseline reads the baseline at path, empty when the file does not exist yet
+func LoadBaseline(path string) (*Baseline, error) {
+	b := &Baseline{Version: baselineVersion, path: path}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		b.reindex()
+		return b, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, b); err != nil {
+		return nil, err
+	}
+	b.reindex()
+	return b, nil
+}
+
+func (b *Baseline) reindex() {
+	b.index = make(map[BaselineEntry]bool, len(b.Entries))
+	for _, e := range b.Entries {
+		b.index[e] = true
+	}
+}
+
+// entry returns the baseline entry of m
+func (b *Baseline) entry(m Method) BaselineEntry {
+	file := m.Filename
+	if abs, err := filepath.Abs(file); err == nil {
+		if dir, err := filepath.Abs(filepath.Dir(b.path)); err == nil {
+			if rel, err := filepath.Rel(dir, abs); err == nil {
+				file = rel
+			}
+		}
+	}
+	name := m.Name
+	if m.Class != "" {
+		name = m.Class + "." + name
+	}
+	return BaselineEntry{File: filepath.ToSlash(file), Name: name}
+}
+
+// Contains reports whether m is in the baseline
+func (b *Baseline) Contains(m Method) bool {
+	return b.index[b.entry(m)]
+}
+
+// Add puts m in the baseline, reporting false when it already was
+func (b *Baseline) Add(m Method) bool {
+	e := b.entry(m)
+	if b.index[e] {
+		return false
+	}
+	b.index[e] = true
+	b.Entries = append(b.Entries, e)
+	return true
+}
+
+// Save writes the baseline back to its file, entries sorted
+func (b *Baseline) Save() error {
+	sort.Slice(b.Entries, func(i, j int) bool {
+		if b.Entries[i].File != b.Entries[j].File {
+			return b.Entries[i].File < b.Entries[j].File
+		}
+		return b.Entries[i].Name < b.Entries[j].Name
+	})
+	data, err := json.MarshalIndent(b, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(b.path, append(data, '\n'), 0o644)
+}
+
+// FilterBaseline leaves out the results whose definition is in the baseline
+func FilterBaseline(results []MethodUsage, b *Baseline) []MethodUsage {
+	var kept []MethodUsage
+	for _, r := range results {
+		if !b.Contains(r.Method) {
+			kept = append(kept, r)
+		}
+	}
+	return kept
+}
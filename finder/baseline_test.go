@@ -0,0 +1,55 @@
+package finder
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestBaseline(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "baseline.json")
+	b, err := LoadBaseline(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stale := Method{Name: "stale", Class: "Service", Filename: filepath.Join(dir, "app", "svc.py"), LineNo: 10}
+	if b.Contains(stale) || !b.Add(stale) || b.Add(stale) {
+		t.Fatal("Add() of a new method did not add it exactly once")
+	}
+	if err := b.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err = LoadBaseline(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (BaselineEntry{File: "app/svc.py", Name: "Service.stale"}); len(b.Entries) != 1 || b.Entries[0] != want {
+		t.Errorf("saved entries = %+v, want [%+v]", b.Entries, want)
+	}
+	moved := stale
+	moved.LineNo = 42
+	results := []MethodUsage{{Method: moved}, {Method: Method{Name: "stale", Filename: stale.Filename}}}
+	if got := FilterBaseline(results, b); len(got) != 1 || got[0].Method.Class != "" {
+		t.Errorf("FilterBaseline() = %+v, want the function outside Service", got)
+	}
+}
+
+func TestIgnoreComment(t *testing.T) {
+	for line, want := range map[string]bool{
+		"def hook():  # pybr: ignore":        true,
+		"def hook():  # noqa  # pybr:ignore": true,
+		"def hook():":                        false,
+		`def pybr(): return "pybr: ignore"`:  false,
+	} {
+		if got := HasIgnoreComment(line); got != want {
+			t.Errorf("HasIgnoreComment(%q) = %v, want %v", line, got, want)
+		}
+	}
+	if got, want := AddIgnoreComment("def hook():   \r"), "def hook():  # pybr: ignore\r"; got != want {
+		t.Errorf("AddIgnoreComment() = %q, want %q", got, want)
+	}
+	if !IsImplicitlyUsed(Method{Name: "hook", Ignored: true}) {
+		t.Error("IsImplicitlyUsed() of an ignored method = false")
+	}
+}
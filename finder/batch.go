@@ -0,0 +1,120 @@
+package finder
+
+import (
+	"context"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// searchBatchSize caps the names combined into a single search pattern
+const searchBatchSize = 500
+
+// wordRe splits a line into the identifiers a method name can match
+var wordRe = regexp.MustCompile(`\w+`)
+
+// searchBatch holds methods searched together in one backend pass
+type searchBatch struct {
+	indexes []int // Positions of the methods in the analyzed list
+	err     error
+	matches map[int][]string // Vimgrep lines per method position
+}
+
+// batchSearch searches the given methods with a handful of backend passes
+// instead of one process per method. Each pass combines the patterns of up
+// to searchBatchSize methods; its matching lines are then demultiplexed back
+// to every method whose own pattern matches them, with the same columns a
+// dedicated search would report.
+func batchSearch(ctx context.Context, methods []Method, indexes []int, searchDirs []string, filters FileFilter) []*searchBatch {
+	maxPattern := 0
+	if limiter, ok := Backend(filters.Engine).(patternLimiter); ok {
+		maxPattern = limiter.maxPattern()
+	}
+	var batches []*searchBatch
+	for start := 0; start < len(indexes); {
+		end, size := start, 0
+		for end < len(indexes) && end-start < searchBatchSize {
+			size += len(searchPattern(methods[indexes[end]].Kind, methods[indexes[end]].Name, filters)) + len("(?:)|")
+			if maxPattern > 0 && size > maxPattern && end > start {
+				break
+			}
+			end++
+		}
+		batches = append(batches, &searchBatch{indexes: indexes[start:end]})
+		start = end
+	}
+
+	var wg sync.WaitGroup
+	pool := newWorkerPool(filters.Jobs)
+	for _, batch := range batches {
+		wg.Add(1)
+		pool.acquire()
+		go func(b *searchBatch) {
+			defer wg.Done()
+			defer pool.release()
+			b.matches, b.err = b.run(ctx, methods, searchDirs, filters)
+		}(batch)
+	}
+	wg.Wait()
+	return batches
+}
+
+func (b *searchBatch) run(ctx context.Context, methods []Method, searchDirs []string, filters FileFilter) (map[int][]string, error) {
+	patterns := make([]string, 0, len(b.indexes))
+	for _, i := range b.indexes {
+		patterns = append(patterns, "(?:"+searchPattern(methods[i].Kind, methods[i].Name, filters)+")")
+	}
+	lines, err := search(ctx, strings.Join(patterns, "|"), searchDirs, filters)
+	if err != nil {
+		return nil, err
+	}
+	return demultiplex(lines, methods, b.indexes, filters), nil
+}
+
+// demultiplex assigns the vimgrep lines of a combined search to the methods
+// at indexes whose own pattern matches them
+func demultiplex(lines []string, methods []Method, indexes []int, filters FileFilter) map[int][]string {
+	res := make(map[int]*regexp.Regexp, len(indexes))
+	byName := make(map[string][]int)
+	for _, i := range indexes {
+		res[i] = regexp.MustCompile(searchPattern(methods[i].Kind, methods[i].Name, filters))
+		byName[methods[i].Name] = append(byName[methods[i].Name], i)
+	}
+
+	// ripgrep reports a line once per match, each line is demultiplexed once
+	matches := make(map[int][]string)
+	seen := make(map[string]bool)
+	for _, raw := range lines {
+		parts := strings.SplitN(raw, ":", 4)
+		if len(parts) < 4 {
+			continue
+		}
+		key := parts[0] + ":" + parts[1]
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		notified := make(map[int]bool)
+		for _, word := range wordRe.FindAllString(parts[3], -1) {
+			for _, i := range byName[word] {
+				if notified[i] {
+					continue
+				}
+				notified[i] = true
+				matches[i] = append(matches[i], matchLine(parts[0], parts[1], parts[3], res[i])...)
+			}
+		}
+	}
+	return matches
+}
+
+// matchLine returns a vimgrep line for every match of re in a line
+func matchLine(path, lineNo, line string, re *regexp.Regexp) []string {
+	var out []string
+	for _, loc := range re.FindAllStringIndex(line, -1) {
+		out = append(out, path+":"+lineNo+":"+strconv.Itoa(loc[0]+1)+":"+line)
+	}
+	return out
+}
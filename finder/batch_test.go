@@ -0,0 +1,30 @@
+package finder
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDemultiplex(t *testing.T) {
+	methods := []Method{
+		{Name: "load", Kind: SymbolFunction},
+		{Name: "save", Kind: SymbolFunction},
+		{Name: "reload", Kind: SymbolFunction},
+	}
+	lines := []string{
+		"app.py:3:1:save(load(x))",
+		"app.py:3:6:save(load(x))",
+		"app.py:7:5:def reload():",
+		"lib.py:1:5:def load(path):",
+	}
+
+	got := demultiplex(lines, methods, []int{0, 1, 2}, FileFilter{})
+	want := map[int][]string{
+		0: {"app.py:3:6:save(load(x))", "lib.py:1:5:def load(path):"},
+		1: {"app.py:3:1:save(load(x))"},
+		2: {"app.py:7:5:def reload():"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("demultiplex = %v, want %v", got, want)
+	}
+}
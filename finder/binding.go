@@ -0,0 +1,51 @@
+package finder
+
+import "regexp"
+
+// Binding is how a method defined in a class receives its first argument
+type Binding string
+
+const (
+	BindingInstance Binding = "instance" // def method(self)
+	BindingStatic   Binding = "static"   // @staticmethod
+	BindingClass    Binding = "class"    // @classmethod
+)
+
+// bindingOf derives the binding of a def from its class and decorators.
+// Functions outside classes have none.
+func bindingOf(class string, decorators []string) Binding {
+	switch {
+	case class == "":
+		return ""
+	case hasDecorator(decorators, []string{"staticmethod"}):
+		return BindingStatic
+	case hasDecorator(decorators, []string{"classmethod"}):
+		return BindingClass
+	default:
+		return BindingInstance
+	}
+}
+
+// explicitSelfRe matches an unbound call passing the instance explicitly,
+// as in Base.method(self, ...)
+var explicitSelfRe = regexp.MustCompile(`\.\w+\s*\(\s*self\b`)
+
+// bindingMismatch explains why a call does not fit the binding of the method
+// it refers to, or returns an empty string when it does. ClassName.method()
+// is expected on static and class methods but suspicious on instance
+// methods, unless the instance is passed explicitly.
+func bindingMismatch(m Method, callType CallType, line string) string {
+	if m.Binding != BindingInstance {
+		return ""
+	}
+	switch callType {
+	case CallTypeStatic:
+		if explicitSelfRe.MatchString(line) {
+			return ""
+		}
+		return "instance method called on a class"
+	case CallTypeClass:
+		return "instance method called on cls"
+	}
+	return ""
+}
@@ -0,0 +1,113 @@
+package finder
+
+import (
+	"log"
+	"strings"
+	"sync"
+)
+
+// sourceCache reads and resolves the scopes of each file once, shared by the
+// goroutines attributing usages to their callers
+type sourceCache struct {
+	mu       sync.Mutex
+	files    map[string]cachedSource
+	sources  map[string]sourceFile // Already decoded files, such as notebooks
+	encoding string
+	stats    *Stats // Counts the files read against those reused
+}
+
+type cachedSource struct {
+	lines  []string
+	scopes []callerScope
+}
+
+func newSourceCache(preloaded map[string]sourceFile, encoding string) *sourceCache {
+	return &sourceCache{files: make(map[string]cachedSource), sources: preloaded, encoding: encoding}
+}
+
+// load returns the lines and scopes of a file, reading it on first use
+func (c *sourceCache) load(path string) cachedSource {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	cached, ok := c.files[path]
+	c.stats.addCacheLookup(ok)
+	if !ok {
+		src, loaded := c.sources[path]
+		if !loaded {
+			var err error
+			if src, err = readSource(path, c.encoding); err != nil {
+				log.Printf("Error reading file %s: %v", path, err)
+			}
+		}
+		cached = cachedSource{lines: src.lines, scopes: callerScopes(src.lines)}
+		c.files[path] = cached
+	}
+	return cached
+}
+
+// callerOf returns the scope around a line of a file
+func (c *sourceCache) callerOf(path string, lineNo int) callerScope {
+	scopes := c.load(path).scopes
+	if lineNo < 1 || lineNo > len(scopes) {
+		return callerScope{}
+	}
+	return scopes[lineNo-1]
+}
+
+// contextOf returns up to n lines of a file before and after a line, dedented
+// by the indentation of that line so they line up with the trimmed usage
+func (c *sourceCache) contextOf(path string, lineNo, n int) (before, after []string) {
+	lines := c.load(path).lines
+	if lineNo < 1 || lineNo > len(lines) {
+		return nil, nil
+	}
+	line := lines[lineNo-1]
+	indent := line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+	dedent := func(window []string) []string {
+		out := make([]string, len(window))
+		for i, l := range window {
+			trimmed := strings.TrimLeft(l, " \t")
+			out[i] = strings.TrimRight(strings.TrimPrefix(l, indent), " \t\r")
+			if len(l)-len(trimmed) < len(indent) {
+				out[i] = strings.TrimRight(trimmed, " \t\r")
+			}
+		}
+		return out
+	}
+	start, end := max(lineNo-1-n, 0), min(lineNo+n, len(lines))
+	return dedent(lines[start : lineNo-1]), dedent(lines[lineNo:end])
+}
+
+// callerScope is the function and class enclosing a line
+type callerScope struct {
+	Function string
+	Class    string
+}
+
+// callerScopes resolves every line of a file against the classes and
+// functions defined in it. Functions are empty at module and class body level;
+// a def line belongs to the scope around it, not to itself.
+func callerScopes(lines []string) []callerScope {
+	scopes := make([]callerScope, len(lines))
+	var tracker scopeTracker
+	for i, line := range lines {
+		tracker.update(line, i+1)
+		stack := tracker.stack
+		if n := len(stack); n > 0 && stack[n-1].Line == i+1 {
+			stack = stack[:n-1]
+		}
+		if len(stack) == 0 {
+			continue
+		}
+		inner := stack[len(stack)-1]
+		if inner.IsClass {
+			scopes[i].Class = inner.Name
+			continue
+		}
+		scopes[i].Function = inner.Name
+		if len(stack) > 1 && stack[len(stack)-2].IsClass {
+			scopes[i].Class = stack[len(stack)-2].Name
+		}
+	}
+	return scopes
+}
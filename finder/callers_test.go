@@ -0,0 +1,70 @@
+package finder
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestCallerScopes(t *testing.T) {
+	lines := strings.Split(`run()
+
+class Service:
+    client = build()
+
+    def start(self):
+        if self.ready:
+
+            # comment
+            self.run()
+
+        def retry():
+            self.run()
+
+def main():
+    Service().start()
+`, "\n")
+
+	scopes := callerScopes(lines)
+	tests := []struct {
+		line int
+		want callerScope
+	}{
+		{1, callerScope{}},
+		{4, callerScope{Class: "Service"}},
+		{6, callerScope{Class: "Service"}},
+		{10, callerScope{Function: "start", Class: "Service"}},
+		{13, callerScope{Function: "retry"}},
+		{15, callerScope{}},
+		{16, callerScope{Function: "main"}},
+	}
+	for _, tt := range tests {
+		if got := scopes[tt.line-1]; got != tt.want {
+			t.Errorf("callerScopes()[line %d] = %+v, want %+v", tt.line, got, tt.want)
+		}
+	}
+}
+
+func TestSourceCacheContextOf(t *testing.T) {
+	lines := []string{
+		"class Shop:",
+		"    def total(self):",
+		"        if self.items:",
+		"            return sum(self.items)",
+		"        return 0",
+	}
+	cache := newSourceCache(map[string]sourceFile{"shop.py": {lines: lines}}, "")
+
+	before, after := cache.contextOf("shop.py", 3, 2)
+	if want := []string{"class Shop:", "def total(self):"}; !slices.Equal(before, want) {
+		t.Errorf("before = %q, want %q", before, want)
+	}
+	if want := []string{"    return sum(self.items)", "return 0"}; !slices.Equal(after, want) {
+		t.Errorf("after = %q, want %q", after, want)
+	}
+
+	before, after = cache.contextOf("shop.py", 1, 1)
+	if len(before) != 0 || !slices.Equal(after, []string{"    def total(self):"}) {
+		t.Errorf("context of first line = %q, %q", before, after)
+	}
+}
@@ -0,0 +1,213 @@
+package finder
+
+import (
+	"regexp"
+	"testing"
+)
+
+func TestClassifyUsage_DocReferences(t *testing.T) {
+	cases := []struct {
+		line    string
+		enabled bool
+		want    CallType
+		valid   bool
+	}{
+		{"    See :func:`pkg.mod.run` for details.", true, CallTypeDocReference, true},
+		{"    :py:meth:`~Runner.run`", true, CallTypeDocReference, true},
+		{"    Calls :meth:`the runner <Runner.run>`.", true, CallTypeDocReference, true},
+		{"    See :func:`pkg.mod.rerun` instead.", true, "", false},
+		{"    See :func:`pkg.mod.run` for details.", false, "", false},
+		{"    run(1)", true, CallTypeFunction, true},
+	}
+
+	for _, c := range cases {
+		got, valid := classifyUsage(c.line, "run", FileFilter{DocReferences: c.enabled})
+		if got != c.want || valid != c.valid {
+			t.Errorf("classifyUsage(%q, docs=%v) = (%q, %v), want (%q, %v)",
+				c.line, c.enabled, got, valid, c.want, c.valid)
+		}
+	}
+}
+
+func TestClassifyUsage_Comments(t *testing.T) {
+	cases := []struct {
+		line    string
+		enabled bool
+		want    CallType
+		valid   bool
+	}{
+		{"# TODO: wire run into the scheduler", true, CallTypeComment, true},
+		{"# TODO: wire run into the scheduler", false, "", false},
+		{"x = 1  # later: run(x)", true, CallTypeComment, true},
+		{"x = 1  # later: run(x)", false, "", false},
+		{"# rerun everything", true, "", false},
+		{"run(x)  # run it", true, CallTypeFunction, true},
+	}
+
+	for _, c := range cases {
+		got, valid := classifyUsage(c.line, "run", FileFilter{IncludeComments: c.enabled})
+		if got != c.want || valid != c.valid {
+			t.Errorf("classifyUsage(%q, comments=%v) = (%q, %v), want (%q, %v)",
+				c.line, c.enabled, got, valid, c.want, c.valid)
+		}
+	}
+}
+
+func TestClassifyUsage_LambdaAndPartial(t *testing.T) {
+	cases := []struct {
+		line  string
+		want  CallType
+		valid bool
+	}{
+		{"run = lambda x: x + 1", CallTypeDefinition, true},
+		{"    run: Callable = lambda: None", CallTypeDefinition, true},
+		{"h = functools.partial(run, 1)", CallTypePartial, true},
+		{"h = partial(self.run)", CallTypePartial, true},
+		{"h = partial(rerun, 1)", "", false},
+		{"x = run(1)", CallTypeFunction, true},
+	}
+
+	for _, c := range cases {
+		got, valid := classifyUsage(c.line, "run", FileFilter{})
+		if got != c.want || valid != c.valid {
+			t.Errorf("classifyUsage(%q) = (%q, %v), want (%q, %v)", c.line, got, valid, c.want, c.valid)
+		}
+	}
+}
+
+func TestClassifyUsage_AttributeReferences(t *testing.T) {
+	cases := []struct {
+		line    string
+		enabled bool
+		want    CallType
+		valid   bool
+	}{
+		{"button.on_click(self.handle)", true, CallTypeAttributeReference, true},
+		{"rows = map(handle, data)", true, CallTypeAttributeReference, true},
+		{"Thread(target=worker.handle, daemon=True)", true, CallTypeAttributeReference, true},
+		{"callback = handle", true, CallTypeAttributeReference, true},
+		{"    handle,", true, CallTypeAttributeReference, true},
+		{"if event == handle)", true, "", false},
+		{"button.on_click(self.handle)", false, "", false},
+		{"self.handle(event)", true, CallTypeInstance, true},
+		{"rehandle = 1", true, "", false},
+	}
+
+	for _, c := range cases {
+		got, valid := classifyUsage(c.line, "handle", FileFilter{AttributeReferences: c.enabled})
+		if got != c.want || valid != c.valid {
+			t.Errorf("classifyUsage(%q, refs=%v) = (%q, %v), want (%q, %v)",
+				c.line, c.enabled, got, valid, c.want, c.valid)
+		}
+	}
+}
+
+func TestClassifyUsage_StringReferences(t *testing.T) {
+	cases := []struct {
+		line    string
+		enabled bool
+		want    CallType
+		valid   bool
+	}{
+		{`    "myapp.views.handler",`, true, CallTypeStringReference, true},
+		{`ROOT_URLCONF = 'myapp.views:handler'`, true, CallTypeStringReference, true},
+		{`importlib.import_module("myapp.views.handler")`, true, CallTypeStringReference, true},
+		{`    "myapp.views.handler",`, false, "", false},
+		{`name = "handler"`, true, "", false},
+		{`    "myapp.views.rehandler",`, true, "", false},
+	}
+
+	for _, c := range cases {
+		got, valid := classifyUsage(c.line, "handler", FileFilter{StringReferences: c.enabled})
+		if got != c.want || valid != c.valid {
+			t.Errorf("classifyUsage(%q, strings=%v) = (%q, %v), want (%q, %v)",
+				c.line, c.enabled, got, valid, c.want, c.valid)
+		}
+	}
+}
+
+func TestClassifyUsage_CallForms(t *testing.T) {
+	cases := []struct {
+		line  string
+		want  CallType
+		valid bool
+	}{
+		{"self.run()", CallTypeInstance, true},
+		{"cls.run()", CallTypeClass, true},
+		{"Runner.run()", CallTypeStatic, true},
+		{"runner.run()", CallTypeInstance, true},
+		{"tasks.run(job)", CallTypeInstance, true},
+		{"super().run()", CallTypeInstance, true},
+		{"Runner().run()", CallTypeInstance, true},
+		{"runners[0].run()", CallTypeInstance, true},
+		{"get_runner() .run()", CallTypeInstance, true},
+		{"run()", CallTypeFunction, true},
+		{"@run", CallTypeDecorator, true},
+		{"@tasks.run", CallTypeDecorator, true},
+		{"runner.rerun()", "", false},
+		{"runner.run_all()", "", false},
+	}
+
+	for _, c := range cases {
+		got, valid := classifyUsage(c.line, "run", FileFilter{})
+		if got != c.want || valid != c.valid {
+			t.Errorf("classifyUsage(%q) = (%q, %v), want (%q, %v)", c.line, got, valid, c.want, c.valid)
+		}
+	}
+}
+
+func TestSearchPattern_CallForms(t *testing.T) {
+	re := regexp.MustCompile(searchPattern(SymbolFunction, "run", FileFilter{}))
+	for _, line := range []string{"runner.run()", "super().run()", "Runner().run()", "@run", "    @tasks.run", "run(1)"} {
+		if !re.MatchString(line) {
+			t.Errorf("search pattern does not match %q", line)
+		}
+	}
+	for _, line := range []string{"@rerun", "@run_all", "runner.rerun()"} {
+		if re.MatchString(line) {
+			t.Errorf("search pattern matches %q", line)
+		}
+	}
+}
+
+func TestBindingMismatch(t *testing.T) {
+	instance := Method{Name: "area", Class: "Circle", Binding: BindingInstance}
+	static := Method{Name: "unit", Class: "Circle", Binding: BindingStatic}
+
+	tests := []struct {
+		m        Method
+		callType CallType
+		line     string
+		want     bool
+	}{
+		{instance, CallTypeStatic, "Circle.area()", true},
+		{instance, CallTypeStatic, "return Shape.area(self)", false},
+		{instance, CallTypeClass, "cls.area()", true},
+		{instance, CallTypeInstance, "self.area()", false},
+		{static, CallTypeStatic, "Circle.unit()", false},
+		{static, CallTypeInstance, "self.unit()", false},
+	}
+	for _, tt := range tests {
+		if got := bindingMismatch(tt.m, tt.callType, tt.line) != ""; got != tt.want {
+			t.Errorf("bindingMismatch(%s, %s, %q) suspicious = %v, want %v", tt.m.Name, tt.callType, tt.line, got, tt.want)
+		}
+	}
+}
+
+func TestParseUsages_CollapsesDuplicates(t *testing.T) {
+	m := Method{Name: "foo", Filename: "lib.py", LineNo: 1, Kind: SymbolFunction}
+	raw := []string{
+		"app.py:3:1:foo(foo())",
+		"app.py:3:5:foo(foo())",
+		"./app.py:3:1:foo(foo())", // The same file reached through an overlapping root
+		"app.py:3:5:foo(foo())",
+		"app.py:3:5:foo(foo())",
+	}
+	usages := ParseUsages(raw, m, FileFilter{})
+	if len(usages) != 2 {
+		t.Fatalf("got %d usages, want 2: %+v", len(usages), usages)
+	}
+	if usages[0].Collapsed != 1 || usages[1].Collapsed != 2 {
+		t.Errorf("collapsed = %d, %d, want 1, 2", usages[0].Collapsed, usages[1].Collapsed)
+	}
+}
@@ -0,0 +1,114 @@
+package finder
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Confidence is how likely a name match really refers to the analyzed method
+type Confidence string
+
+const (
+	ConfidenceLow    Confidence = "low"    // Bare name collision anywhere in the repository
+	ConfidenceMedium Confidence = "medium" // Match in a file importing the defining module
+	ConfidenceHigh   Confidence = "high"   // Definition, or usage within the defining file
+)
+
+func (c Confidence) rank() int {
+	switch c {
+	case ConfidenceHigh:
+		return 2
+	case ConfidenceMedium:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// ParseConfidence validates a --min-confidence value
+func ParseConfidence(s string) (Confidence, error) {
+	switch c := Confidence(s); c {
+	case ConfidenceLow, ConfidenceMedium, ConfidenceHigh:
+		return c, nil
+	}
+	return "", fmt.Errorf("invalid confidence %q, valid values are low, medium, high", s)
+}
+
+// importsDefinitionOf reports whether a file imports the module defining m,
+// m itself, or a package re-exporting it
+func (t ImportTable) importsDefinitionOf(path string, m Method, reexporters []string) bool {
+	for _, imp := range t[filepath.Clean(path)] {
+		if providesMethod(path, imp.Module, m, reexporters) {
+			return true
+		}
+		if imp.Name != "" && providesMethod(path, imp.Module+"."+imp.Name, m, reexporters) {
+			return true
+		}
+	}
+	return false
+}
+
+// confidenceOf grades a usage: self./cls. calls and anything else in the
+// defining file are high, matches resolved through an import medium, and
+// mentions in comments, docs or unrelated files low.
+func (t ImportTable) confidenceOf(u Usage, m Method, reexporters []string) Confidence {
+	switch u.CallType {
+	case CallTypeDefinition:
+		return ConfidenceHigh
+	case CallTypeComment, CallTypeDocReference:
+		return ConfidenceLow
+	case CallTypeStringReference:
+		// The string carries its own module, "myapp.views.handler" points to myapp/views.py
+		match := regexp.MustCompile(stringReferencePattern(m.Name)).FindStringSubmatch(u.Context)
+		if match != nil {
+			module := strings.TrimRight(match[1], ".:")
+			if ModuleFileMatches("", module, m.Filename) {
+				return ConfidenceMedium
+			}
+			if m.Class != "" && strings.HasSuffix(module, "."+m.Class) &&
+				ModuleFileMatches("", strings.TrimSuffix(module, "."+m.Class), m.Filename) {
+				return ConfidenceMedium
+			}
+		}
+		return ConfidenceLow
+	}
+	// Backends may report ./app.py for the app.py the definitions were found in
+	path := filepath.Clean(usagePath(u.Location))
+	switch {
+	case path == filepath.Clean(m.Filename):
+		return ConfidenceHigh
+	case u.Alias != "", u.CallType == CallTypeImport, u.CallType == CallTypeReexport,
+		u.CallType == CallTypeTypeHint, u.CallType == CallTypeDynamicImport:
+		return ConfidenceMedium
+	case t.importsDefinitionOf(path, m, reexporters):
+		return ConfidenceMedium
+	}
+	return ConfidenceLow
+}
+
+// FilterByConfidence drops the usages below min and recounts each result
+func FilterByConfidence(results []MethodUsage, min Confidence) []MethodUsage {
+	for i, result := range results {
+		usages := result.Usages[:0]
+		byType := make(map[CallType]int)
+		for root := range result.UsagesByRoot {
+			result.UsagesByRoot[root] = 0
+		}
+		for _, u := range result.Usages {
+			if u.Confidence.rank() < min.rank() {
+				continue
+			}
+			usages = append(usages, u)
+			byType[u.CallType]++
+			if result.UsagesByRoot != nil {
+				result.UsagesByRoot[u.Root]++
+			}
+		}
+		results[i].Usages = usages
+		results[i].UsagesByType = byType
+		results[i].TotalUsages = len(usages)
+	}
+	return results
+}
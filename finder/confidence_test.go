@@ -0,0 +1,79 @@
+package finder
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+)
+
+func TestConfidenceOf(t *testing.T) {
+	dir := t.TempDir()
+	def := writeTestFile(t, dir, "pkg/svc.py", "class Service:\n    def run(self):\n        self.run()\n")
+	importer := writeTestFile(t, dir, "app.py", "from pkg import svc\nsvc.Service().run()\n")
+	other := writeTestFile(t, dir, "other.py", "runner.run()\n")
+
+	var files []File
+	for _, p := range []string{def, importer, other} {
+		files = append(files, File{Dir: filepath.Dir(p), Base: filepath.Base(p), Path: p})
+	}
+	imports := BuildImportTable(files, "")
+	m := Method{Name: "run", Filename: def, LineNo: 2, Class: "Service"}
+
+	tests := []struct {
+		usage Usage
+		want  Confidence
+	}{
+		{Usage{Location: def + ":2:9", CallType: CallTypeDefinition}, ConfidenceHigh},
+		{Usage{Location: def + ":3:14", CallType: CallTypeInstance}, ConfidenceHigh},
+		{Usage{Location: importer + ":2:15", CallType: CallTypeInstance}, ConfidenceMedium},
+		{Usage{Location: other + ":1:8", CallType: CallTypeInstance}, ConfidenceLow},
+		{Usage{Location: importer + ":1:1", CallType: CallTypeComment}, ConfidenceLow},
+		{Usage{Location: other + ":1:1", CallType: CallTypeStringReference, Context: `TASK = "pkg.svc.Service.run"`}, ConfidenceMedium},
+		{Usage{Location: other + ":1:1", CallType: CallTypeStringReference, Context: `TASK = "jobs.run"`}, ConfidenceLow},
+	}
+	for _, tt := range tests {
+		if got := imports.confidenceOf(tt.usage, m, nil); got != tt.want {
+			t.Errorf("confidenceOf(%s) = %s, want %s", tt.usage.Location, got, tt.want)
+		}
+	}
+
+	results := FilterByConfidence([]MethodUsage{{
+		Method: m,
+		Usages: []Usage{
+			{CallType: CallTypeDefinition, Confidence: ConfidenceHigh},
+			{CallType: CallTypeInstance, Confidence: ConfidenceMedium},
+			{CallType: CallTypeInstance, Confidence: ConfidenceLow},
+		},
+	}}, ConfidenceMedium)
+	if got := results[0]; got.TotalUsages != 2 || got.UsagesByType[CallTypeInstance] != 1 {
+		t.Errorf("FilterByConfidence() = %d usages %v, want 2 with 1 instance call", got.TotalUsages, got.UsagesByType)
+	}
+}
+
+func TestConfidenceOf_RelativeRoot(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, dir, "pkg/svc.py", "def run():\n    run()\n")
+	writeTestFile(t, dir, "app.py", "from pkg.svc import run\nrun()\n")
+	t.Chdir(dir)
+
+	files, err := ReadDir(context.Background(), ".", DirFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	imports := BuildImportTable(files, "")
+	m := Method{Name: "run", Filename: filepath.Join("pkg", "svc.py"), LineNo: 1}
+
+	// ripgrep and git grep report the files of --dir . as ./app.py
+	tests := []struct {
+		usage Usage
+		want  Confidence
+	}{
+		{Usage{Location: "./pkg/svc.py:2:5", CallType: CallTypeFunction}, ConfidenceHigh},
+		{Usage{Location: "./app.py:2:1", CallType: CallTypeFunction}, ConfidenceMedium},
+	}
+	for _, tt := range tests {
+		if got := imports.confidenceOf(tt.usage, m, nil); got != tt.want {
+			t.Errorf("confidenceOf(%s) = %s, want %s", tt.usage.Location, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package finder
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+)
+
+// DiffOptions set how much the real usages of a definition must change for
+// DiffResults to report it
+type DiffOptions struct {
+	MinChange        int     // Usages gained or lost
+	MinChangePercent float64 // Of the usages of the old run, 100 for definitions unused before
+}
+
+// UsageChange is a definition of both runs along with its real usages in each
+type UsageChange struct {
+	Method Method `json:"method"` // As defined in the new run
+	Before int    `json:"before"`
+	After  int    `json:"after"`
+}
+
+// ResultDiff compares the results of an old and a new run
+type ResultDiff struct {
+	MethodsBefore int           `json:"methods_before"`
+	MethodsAfter  int           `json:"methods_after"`
+	UnusedBefore  int           `json:"unused_before"`
+	UnusedAfter   int           `json:"unused_after"`
+	NewlyUnused   []UsageChange `json:"newly_unused"` // Used in the old run, unused in the new one
+	Changed       []UsageChange `json:"changed"`      // Still used, with significantly more or fewer usages
+	Added         []MethodUsage `json:"added"`
+	Removed       []MethodUsage `json:"removed"`
+}
+
+// NewUnused counts the unused definitions the new run brings: those that
+// became unused and the added ones without usages
+func (d ResultDiff) NewUnused() int {
+	n := len(d.NewlyUnused)
+	for _, r := range d.Added {
+		if IsUnused(r) {
+			n++
+		}
+	}
+	return n
+}
+
+// diffKey identifies a definition across runs, where its line may differ
+func diffKey(m Method) string {
+	return fmt.Sprintf("%s:%s:%s:%s:%s", filepath.ToSlash(m.Filename), m.Kind, m.Class, m.Parent, m.Name)
+}
+
+// DiffResults compares the results of two runs. Definitions are matched by
+// file, kind, class and name, in line order when a file defines a name twice.
+func DiffResults(before, after []MethodUsage, opts DiffOptions) ResultDiff {
+	d := ResultDiff{
+		MethodsBefore: len(before), MethodsAfter: len(after),
+		NewlyUnused: []UsageChange{}, Changed: []UsageChange{}, Added: []MethodUsage{}, Removed: []MethodUsage{},
+	}
+	old := make(map[string][]MethodUsage)
+	for _, r := range sortedByLine(before) {
+		key := diffKey(r.Method)
+		old[key] = append(old[key], r)
+		if IsUnused(r) {
+			d.UnusedBefore++
+		}
+	}
+	for _, r := range sortedByLine(after) {
+		if IsUnused(r) {
+			d.UnusedAfter++
+		}
+		key := diffKey(r.Method)
+		if len(old[key]) == 0 {
+			d.Added = append(d.Added, r)
+			continue
+		}
+		prev := old[key][0]
+		old[key] = old[key][1:]
+		change := UsageChange{Method: r.Method, Before: RealUsages(prev), After: RealUsages(r)}
+		switch {
+		case IsUnused(r) && !IsUnused(prev) && prev.SearchError == "":
+			d.NewlyUnused = append(d.NewlyUnused, change)
+		case !IsUnused(r) && significantChange(change, opts):
+			d.Changed = append(d.Changed, change)
+		}
+	}
+	for _, rest := range old {
+		d.Removed = append(d.Removed, rest...)
+	}
+	d.Removed = sortedByLine(d.Removed)
+	return d
+}
+
+// significantChange reports whether the usages of a definition changed by at
+// least both thresholds of opts
+func significantChange(c UsageChange, opts DiffOptions) bool {
+	delta := c.After - c.Before
+	if delta < 0 {
+		delta = -delta
+	}
+	if delta == 0 || delta < opts.MinChange {
+		return false
+	}
+	percent := 100.0
+	if c.Before > 0 {
+		percent = float64(delta) * 100 / float64(c.Before)
+	}
+	return percent >= opts.MinChangePercent
+}
+
+// sortedByLine returns a copy of results sorted by file and line
+func sortedByLine(results []MethodUsage) []MethodUsage {
+	sorted := append([]MethodUsage(nil), results...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		a, b := sorted[i].Method, sorted[j].Method
+		if a.Filename != b.Filename {
+			return a.Filename < b.Filename
+		}
+		return a.LineNo < b.LineNo
+	})
+	return sorted
+}
@@ -0,0 +1,49 @@
+package finder
+
+import "testing"
+
+func TestDiffResults(t *testing.T) {
+	result := func(file, class, name string, line, calls int) MethodUsage {
+		return MethodUsage{
+			Method:       Method{Name: name, Class: class, Filename: file, LineNo: line, Kind: SymbolFunction},
+			UsagesByType: map[CallType]int{CallTypeDefinition: 1, CallTypeFunction: calls},
+		}
+	}
+	before := []MethodUsage{
+		result("app/a.py", "", "parse", 10, 3),
+		result("app/a.py", "", "render", 20, 10),
+		result("app/a.py", "", "render", 40, 1),
+		result("app/a.py", "Service", "stop", 50, 4),
+		result("app/b.py", "", "legacy", 5, 0),
+		result("app/b.py", "", "gone", 9, 2),
+	}
+	after := []MethodUsage{
+		result("app/a.py", "", "parse", 12, 0),  // Became unused
+		result("app/a.py", "", "render", 22, 9), // Small change
+		result("app/a.py", "", "render", 42, 4), // Significant change
+		result("app/a.py", "Service", "stop", 55, 4),
+		result("app/b.py", "", "legacy", 5, 0), // Still unused
+		result("app/c.py", "", "helper", 1, 0), // Added unused
+		result("app/c.py", "", "main", 8, 1),   // Added used
+	}
+	d := DiffResults(before, after, DiffOptions{MinChange: 2, MinChangePercent: 50})
+
+	if d.MethodsBefore != 6 || d.MethodsAfter != 7 || d.UnusedBefore != 1 || d.UnusedAfter != 3 {
+		t.Errorf("totals = %d -> %d methods, %d -> %d unused, want 6 -> 7, 1 -> 3", d.MethodsBefore, d.MethodsAfter, d.UnusedBefore, d.UnusedAfter)
+	}
+	if len(d.NewlyUnused) != 1 || d.NewlyUnused[0].Method.Name != "parse" || d.NewlyUnused[0].Before != 3 {
+		t.Errorf("NewlyUnused = %+v, want parse, used 3 times before", d.NewlyUnused)
+	}
+	if len(d.Changed) != 1 || d.Changed[0].Method.LineNo != 42 || d.Changed[0].Before != 1 || d.Changed[0].After != 4 {
+		t.Errorf("Changed = %+v, want the render of line 42, 1 -> 4", d.Changed)
+	}
+	if len(d.Added) != 2 || d.Added[0].Method.Name != "helper" || d.Added[1].Method.Name != "main" {
+		t.Errorf("Added = %+v, want helper and main", d.Added)
+	}
+	if len(d.Removed) != 1 || d.Removed[0].Method.Name != "gone" {
+		t.Errorf("Removed = %+v, want gone", d.Removed)
+	}
+	if got := d.NewUnused(); got != 2 {
+		t.Errorf("NewUnused() = %d, want 2", got)
+	}
+}
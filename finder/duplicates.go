@@ -0,0 +1,181 @@
+package finder
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+)
+
+// DefaultSimilarity is the shingle overlap above which two bodies are reported as similar
+const DefaultSimilarity = 0.8
+
+// minFingerprintTokens keeps trivial bodies such as "return self.x" out of the
+// comparison, they are identical everywhere and carry no signal
+const minFingerprintTokens = 12
+
+var tokenRe = regexp.MustCompile(`[A-Za-z_][A-Za-z0-9_]*|\d[\w.]*|"(?:\\.|[^"\\])*"|'(?:\\.|[^'\\])*'|\S`)
+
+var pythonKeywords = map[string]bool{
+	"False": true, "None": true, "True": true, "and": true, "as": true, "assert": true,
+	"async": true, "await": true, "break": true, "class": true, "continue": true,
+	"def": true, "del": true, "elif": true, "else": true, "except": true, "finally": true,
+	"for": true, "from": true, "global": true, "if": true, "import": true, "in": true,
+	"is": true, "lambda": true, "nonlocal": true, "not": true, "or": true, "pass": true,
+	"raise": true, "return": true, "try": true, "while": true, "with": true, "yield": true,
+}
+
+// fingerprint is the normalized token stream of a function body
+type fingerprint struct {
+	Method   Method
+	Context  string
+	Hash     [sha256.Size]byte
+	Shingles map[string]bool
+}
+
+// normalizeBody tokenizes a body so that renaming locals or editing comments
+// and docstrings does not change it. Bare names become ID, literals STR and
+// NUM, while keywords, operators and attribute names are kept.
+func normalizeBody(body []string) []string {
+	var tokens []string
+	inString := false
+	for _, line := range body {
+		if quotes := len(tripleQuoteRe.FindAllString(line, -1)); inString || quotes > 0 {
+			if quotes%2 == 1 {
+				inString = !inString
+			}
+			continue
+		}
+		prev := ""
+		for _, tok := range tokenRe.FindAllString(stripComment(line), -1) {
+			norm := tok
+			switch c := tok[0]; {
+			case c == '"' || c == '\'':
+				norm = "STR"
+			case c >= '0' && c <= '9':
+				norm = "NUM"
+			case c == '_' || c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z':
+				if !pythonKeywords[tok] && prev != "." {
+					norm = "ID"
+				}
+			}
+			tokens = append(tokens, norm)
+			prev = tok
+		}
+	}
+	return tokens
+}
+
+// shingles returns the set of consecutive token triples
+func shingles(tokens []string) map[string]bool {
+	set := make(map[string]bool)
+	for i := 0; i+3 <= len(tokens); i++ {
+		set[strings.Join(tokens[i:i+3], " ")] = true
+	}
+	return set
+}
+
+// jaccard is the size of the intersection over the size of the union
+func jaccard(a, b map[string]bool) float64 {
+	if len(a) > len(b) {
+		a, b = b, a
+	}
+	common := 0
+	for s := range a {
+		if b[s] {
+			common++
+		}
+	}
+	union := len(a) + len(b) - common
+	if union == 0 {
+		return 0
+	}
+	return float64(common) / float64(union)
+}
+
+// fingerprints extracts and normalizes the body of every def among methods
+func fingerprints(methods []Method, encoding string) []fingerprint {
+	sources := make(map[string]sourceFile)
+	var prints []fingerprint
+	for _, m := range methods {
+		if m.Kind != SymbolFunction || isStubFile(m.Filename) {
+			continue
+		}
+		src, ok := sources[m.Filename]
+		if !ok {
+			var err error
+			src, err = readSource(m.Filename, encoding)
+			if err != nil {
+				log.Printf("Error reading file %s: %v", m.Filename, err)
+			}
+			sources[m.Filename] = src
+		}
+		if m.LineNo > len(src.lines) {
+			continue
+		}
+		block, ok := extractFunction(src.lines, m.LineNo-1)
+		if !ok {
+			continue
+		}
+		tokens := normalizeBody(block.Body)
+		if len(tokens) < minFingerprintTokens {
+			continue
+		}
+		prints = append(prints, fingerprint{
+			Method:   m,
+			Context:  strings.TrimSpace(src.lines[m.LineNo-1]),
+			Hash:     sha256.Sum256([]byte(strings.Join(tokens, " "))),
+			Shingles: shingles(tokens),
+		})
+	}
+	return prints
+}
+
+// FindDuplicates compares the bodies of the given functions and returns, for
+// each one with a twin, the identical (duplicate) and similar implementations.
+// similarity is the minimum shingle overlap, from 0 to 1, to report a pair as
+// similar; files are read in encoding, or a detected one when empty.
+func FindDuplicates(methods []Method, similarity float64, encoding string) []MethodUsage {
+	prints := fingerprints(methods, encoding)
+
+	var results []MethodUsage
+	for i, fp := range prints {
+		result := MethodUsage{Method: fp.Method, UsagesByType: make(map[CallType]int)}
+		for j, other := range prints {
+			if i == j {
+				continue
+			}
+			callType, score := CallTypeDuplicate, 1.0
+			if other.Hash != fp.Hash {
+				// Jaccard can not exceed the ratio of the set sizes
+				small, large := len(fp.Shingles), len(other.Shingles)
+				if small > large {
+					small, large = large, small
+				}
+				if float64(small) < similarity*float64(large) {
+					continue
+				}
+				score = jaccard(fp.Shingles, other.Shingles)
+				if score < similarity {
+					continue
+				}
+				callType = CallTypeSimilar
+			}
+			result.Usages = append(result.Usages, Usage{
+				Location:   fmt.Sprintf("%s:%d:1", other.Method.Filename, other.Method.LineNo),
+				CallType:   callType,
+				Context:    other.Context,
+				Root:       other.Method.Root,
+				Cell:       other.Method.Cell,
+				Similarity: score,
+			})
+			result.UsagesByType[callType]++
+		}
+		if len(result.Usages) > 0 {
+			result.TotalUsages = len(result.Usages)
+			results = append(results, result)
+		}
+	}
+	return results
+}
@@ -0,0 +1,57 @@
+package finder
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+)
+
+func TestFindDuplicates(t *testing.T) {
+	dir := t.TempDir()
+	a := writeTestFile(t, dir, "a.py", `def total(items):
+    """Sum the active items."""
+    result = 0
+    for item in items:
+        if item.active:
+            result += item.price * item.qty
+    return result
+
+def tiny():
+    return 1
+`)
+	b := writeTestFile(t, dir, "b.py", `def sum_cart(lines):
+    acc = 0  # renamed copy
+    for line in lines:
+        if line.active:
+            acc += line.price * line.qty
+    return acc
+
+def weight(lines):
+    acc = 0
+    for line in lines:
+        if line.enabled:
+            acc += line.weight * line.qty
+    return acc
+
+def tiny2():
+    return 2
+`)
+	files := []File{
+		{Dir: dir, Base: filepath.Base(a), Path: a},
+		{Dir: dir, Base: filepath.Base(b), Path: b},
+	}
+
+	got := make(map[string]map[CallType]int)
+	for _, r := range FindDuplicates(FindMethods(context.Background(), files, MethodFilter{}), 0.5, "") {
+		got[r.Method.Name] = r.UsagesByType
+	}
+	if len(got) != 3 {
+		t.Fatalf("FindDuplicates() reported %v, want total, sum_cart and weight", got)
+	}
+	if got["total"][CallTypeDuplicate] != 1 || got["total"][CallTypeSimilar] != 1 {
+		t.Errorf("total = %v, want one duplicate and one similar", got["total"])
+	}
+	if got["weight"][CallTypeDuplicate] != 0 || got["weight"][CallTypeSimilar] != 2 {
+		t.Errorf("weight = %v, want two similar", got["weight"])
+	}
+}
@@ -0,0 +1,104 @@
+package finder
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// Supported source encodings, as accepted by --encoding
+const (
+	EncodingUTF8    = "utf-8"
+	EncodingLatin1  = "latin-1"
+	EncodingUTF16LE = "utf-16le"
+	EncodingUTF16BE = "utf-16be"
+)
+
+var encodingAliases = map[string]string{
+	"utf-8": EncodingUTF8, "utf8": EncodingUTF8,
+	"latin-1": EncodingLatin1, "latin1": EncodingLatin1, "iso-8859-1": EncodingLatin1,
+	"iso8859-1": EncodingLatin1, "cp1252": EncodingLatin1, "windows-1252": EncodingLatin1,
+	"utf-16": EncodingUTF16LE, "utf-16le": EncodingUTF16LE, "utf-16be": EncodingUTF16BE,
+}
+
+// rgEncodings are the labels ripgrep understands for each encoding
+var rgEncodings = map[string]string{
+	EncodingUTF8:    "utf-8",
+	EncodingLatin1:  "iso-8859-1",
+	EncodingUTF16LE: "utf-16le",
+	EncodingUTF16BE: "utf-16be",
+}
+
+// ParseEncoding validates an --encoding value and returns its canonical name
+func ParseEncoding(name string) (string, error) {
+	enc, ok := encodingAliases[strings.ReplaceAll(strings.ToLower(name), "_", "-")]
+	if !ok {
+		return "", fmt.Errorf("unsupported encoding %q, valid values are utf-8, latin-1, utf-16le, utf-16be", name)
+	}
+	return enc, nil
+}
+
+// codingCookieRe is the PEP 263 declaration, e.g. "# -*- coding: latin-1 -*-"
+var codingCookieRe = regexp.MustCompile(`^[ \t\f]*#.*?coding[:=][ \t]*([-\w.]+)`)
+
+// detectEncoding guesses the encoding of a file from its byte order mark, then
+// from a coding cookie in its first two lines. Files that are not valid UTF-8
+// fall back to latin-1, which decodes any byte sequence.
+func detectEncoding(data []byte) string {
+	switch {
+	case bytes.HasPrefix(data, []byte{0xEF, 0xBB, 0xBF}):
+		return EncodingUTF8
+	case bytes.HasPrefix(data, []byte{0xFF, 0xFE}):
+		return EncodingUTF16LE
+	case bytes.HasPrefix(data, []byte{0xFE, 0xFF}):
+		return EncodingUTF16BE
+	}
+	for i, line := range bytes.SplitN(data, []byte("\n"), 3) {
+		if i == 2 {
+			break
+		}
+		if m := codingCookieRe.FindSubmatch(line); m != nil {
+			if enc, err := ParseEncoding(string(m[1])); err == nil {
+				return enc
+			}
+		}
+	}
+	if !utf8.Valid(data) {
+		return EncodingLatin1
+	}
+	return EncodingUTF8
+}
+
+// decodeSource converts the contents of a file to UTF-8, in the given
+// encoding or a detected one when empty
+func decodeSource(data []byte, encoding string) string {
+	if encoding == "" {
+		encoding = detectEncoding(data)
+	}
+	switch encoding {
+	case EncodingLatin1:
+		runes := make([]rune, len(data))
+		for i, b := range data {
+			runes[i] = rune(b)
+		}
+		return string(runes)
+	case EncodingUTF16LE, EncodingUTF16BE:
+		var order binary.ByteOrder = binary.LittleEndian
+		bom := []byte{0xFF, 0xFE}
+		if encoding == EncodingUTF16BE {
+			order, bom = binary.BigEndian, []byte{0xFE, 0xFF}
+		}
+		data = bytes.TrimPrefix(data, bom)
+		units := make([]uint16, len(data)/2)
+		for i := range units {
+			units[i] = order.Uint16(data[2*i:])
+		}
+		return string(utf16.Decode(units))
+	default:
+		return string(bytes.TrimPrefix(data, []byte{0xEF, 0xBB, 0xBF}))
+	}
+}
@@ -0,0 +1,49 @@
+package finder
+
+import (
+	"testing"
+	"unicode/utf16"
+)
+
+func TestDecodeSource(t *testing.T) {
+	utf16le := func(s string) []byte {
+		out := []byte{0xFF, 0xFE}
+		for _, u := range utf16.Encode([]rune(s)) {
+			out = append(out, byte(u), byte(u>>8))
+		}
+		return out
+	}
+
+	tests := []struct {
+		name     string
+		data     []byte
+		encoding string
+		want     string
+	}{
+		{"utf-8", []byte("def café():\n"), "", "def café():\n"},
+		{"utf-8 bom", []byte("\xEF\xBB\xBFdef run():\n"), "", "def run():\n"},
+		{"utf-16 bom", utf16le("def run():\n"), "", "def run():\n"},
+		{"utf-16 big endian", []byte{0xFE, 0xFF, 0, 'o', 0, 'k'}, "", "ok"},
+		{"invalid utf-8", []byte("s = '\xe9t\xe9'\n"), "", "s = 'été'\n"},
+		{"coding cookie", []byte("# -*- coding: latin-1 -*-\ns = '\xe9'\n"), "", "# -*- coding: latin-1 -*-\ns = 'é'\n"},
+		{"override", []byte("s = '\xc3\xa9'\n"), EncodingLatin1, "s = 'Ã©'\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := decodeSource(tt.data, tt.encoding); got != tt.want {
+				t.Errorf("decodeSource() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseEncoding(t *testing.T) {
+	for name, want := range map[string]string{"UTF8": EncodingUTF8, "iso_8859_1": EncodingLatin1, "utf-16": EncodingUTF16LE} {
+		if got, err := ParseEncoding(name); err != nil || got != want {
+			t.Errorf("ParseEncoding(%q) = %q, %v, want %q", name, got, err, want)
+		}
+	}
+	if _, err := ParseEncoding("ebcdic"); err == nil {
+		t.Error("ParseEncoding(ebcdic) succeeded, want an error")
+	}
+}
@@ -0,0 +1,10 @@
+//go:build !unix
+
+package finder
+
+import "io/fs"
+
+// fileID is not available on this platform, callers fall back to real paths
+func fileID(info fs.FileInfo) (any, bool) {
+	return nil, false
+}
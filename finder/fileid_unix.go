@@ -0,0 +1,17 @@
+//go:build unix
+
+package finder
+
+import (
+	"io/fs"
+	"syscall"
+)
+
+// fileID returns the device and inode of a file
+func fileID(info fs.FileInfo) (any, bool) {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return nil, false
+	}
+	return [2]uint64{uint64(st.Dev), st.Ino}, true
+}
@@ -2,67 +2,217 @@
 package finder
 
 import (
+	"cmp"
+	"context"
 	"fmt"
-	"io/fs"
 	"log"
 	"os"
-	"os/exec"
+	"path"
 	"path/filepath"
 	"regexp"
-	"sort"
+	"slices"
+	"strconv"
 	"strings"
 	"sync"
+	"time"
 )
 
 type CallType string
 
+// SymbolKind is the kind of Python symbol being analyzed
+type SymbolKind string
+
+const (
+	SymbolFunction  SymbolKind = "function"  // def name():
+	SymbolVariable  SymbolKind = "variable"  // NAME = ... at module level
+	SymbolAttribute SymbolKind = "attribute" // self.name = ... or class-level name = ...
+)
+
 const (
-	CallTypeInstance   CallType = "instance"   // self.method()
-	CallTypeClass      CallType = "class"      // cls.method()
-	CallTypeStatic     CallType = "static"     // ClassName.method()
-	CallTypeFunction   CallType = "function"   // method() - standalone or imported
-	CallTypeDefinition CallType = "definition" // def method():
-	CallTypeDecorator  CallType = "decorator"  // @decorator
+	CallTypeInstance           CallType = "instance"            // self.method()
+	CallTypeClass              CallType = "class"               // cls.method()
+	CallTypeStatic             CallType = "static"              // ClassName.method()
+	CallTypeFunction           CallType = "function"            // method() - standalone or imported
+	CallTypeDefinition         CallType = "definition"          // def method():
+	CallTypeDecorator          CallType = "decorator"           // @decorator
+	CallTypeDocReference       CallType = "doc-reference"       // :func:`method` in a docstring
+	CallTypeComment            CallType = "comment"             // # method mentioned in a comment
+	CallTypeRead               CallType = "read"                // print(NAME)
+	CallTypeWrite              CallType = "write"               // NAME = ... outside its definition
+	CallTypeImport             CallType = "import"              // from module import NAME
+	CallTypePartial            CallType = "partial"             // functools.partial(method, ...)
+	CallTypeTypeHint           CallType = "type-hint"           // Reference from a .pyi stub file
+	CallTypeAttributeReference CallType = "attribute-reference" // button.on_click(self.handle), map(parse, rows)
+	CallTypeStringReference    CallType = "string-reference"    // "myapp.views.handler" in settings or registries
+	CallTypeReexport           CallType = "re-export"           // from .impl import method in a package __init__.py
+	CallTypeDynamicImport      CallType = "dynamic-import"      // importlib.import_module("pkg.mod") of the defining module
+	CallTypeOverload           CallType = "overload"            // @overload signature of the method
+	CallTypeDuplicate          CallType = "duplicate"           // Another function with the same normalized body
+	CallTypeSimilar            CallType = "similar"             // Another function with a highly similar body
 )
 
 type Usage struct {
-	Location string   `json:"location"`
-	CallType CallType `json:"call_type"`
-	Context  string   `json:"context"` // The actual line of code
+	Location    string   `json:"location"`
+	CallType    CallType `json:"call_type"`
+	Context     string   `json:"context"`                // The actual line of code
+	Alias       string   `json:"alias,omitempty"`        // Local name the method was imported as
+	Root        string   `json:"root,omitempty"`         // Search root the usage was found in
+	Cell        int      `json:"cell,omitempty"`         // Notebook cell, line numbers count code cells only
+	Caller      string   `json:"caller,omitempty"`       // Function around the usage, empty at module level
+	CallerClass string   `json:"caller_class,omitempty"` // Class around the usage or its caller
+	// Body overlap, from 0 to 1, of duplicate and similar implementations
+	Similarity float64 `json:"similarity,omitempty"`
+	// How likely the match really refers to the method rather than a namesake
+	Confidence Confidence `json:"confidence,omitempty"`
+	// Why the call does not fit how the method is bound, e.g. a class-level call of an instance method
+	Suspicious string   `json:"suspicious,omitempty"`
+	Before     []string `json:"before,omitempty"` // Source lines preceding the usage, see FileFilter.Context
+	After      []string `json:"after,omitempty"`  // Source lines following the usage
+	// Further matches reported at the same location and collapsed into this one
+	Collapsed int `json:"collapsed,omitempty"`
 }
 
 type Method struct {
-	Name     string `json:"name"`
-	Filename string `json:"filename"`
-	LineNo   int    `json:"line_number"`
+	Name       string     `json:"name"`
+	Filename   string     `json:"filename"`
+	LineNo     int        `json:"line_number"`
+	EndLine    int        `json:"end_line,omitempty"` // Last line of a def body
+	Kind       SymbolKind `json:"kind"`
+	Class      string     `json:"class,omitempty"`
+	Parent     string     `json:"parent,omitempty"`     // Function a nested definition lives in
+	Binding    Binding    `json:"binding,omitempty"`    // How a method defined in a class is bound
+	Decorators []string   `json:"decorators,omitempty"` // Dotted decorator names, without arguments
+	Root       string     `json:"root,omitempty"`       // Source root the definition was collected from
+	Cell       int        `json:"cell,omitempty"`       // Notebook cell of the definition
+	StubOnly   bool       `json:"stub_only,omitempty"`  // Only defined in a .pyi stub
+	Overloads  []int      `json:"overloads,omitempty"`  // Lines of the @overload signatures
+	// The defining module is only loaded through importlib or __import__
+	DynamicallyLoaded bool `json:"dynamically_loaded,omitempty"`
+	// Parameters the function body never references
+	UnusedParams []string `json:"unused_params,omitempty"`
+	Metrics      *Metrics `json:"metrics,omitempty"` // Size and complexity of a def body
+	Ignored      bool     `json:"ignored,omitempty"` // Carries a "# pybr: ignore" comment
 }
 
 type File struct {
 	Dir  string
 	Base string
 	Path string
+	Root string // Directory the file was discovered from
+}
+
+// RelPath returns the file path relative to the root it was discovered from
+func (f File) RelPath() string {
+	if f.Root == "" {
+		return f.Path
+	}
+	rel, err := filepath.Rel(f.Root, f.Path)
+	if err != nil {
+		return f.Path
+	}
+	return rel
 }
 
+// DefaultTestGlobs are the file patterns treated as tests unless configured otherwise
+var DefaultTestGlobs = []string{"test_*.py", "*_test.py"}
+
 type MethodUsage struct {
 	Method       Method           `json:"method"`
 	Usages       []Usage          `json:"usages"`
 	UsagesByType map[CallType]int `json:"usages_by_type"`
+	UsagesByRoot map[string]int   `json:"usages_by_root,omitempty"` // Only set when searching several roots
 	TotalUsages  int              `json:"total_usages"`
+	// Usages counted but not stored because of FileFilter.MaxStoredUsages
+	TruncatedCount int `json:"truncated_count,omitempty"`
+	// More matches than FileFilter.UsageCap were found, TotalUsages is a lower bound
+	Capped bool `json:"capped,omitempty"`
+	// Why the search failed, leaving the usages unknown rather than absent
+	SearchError string `json:"search_error,omitempty"`
 }
 
 type AnalysisResult struct {
 	TotalMethods int           `json:"total_methods"`
 	Results      []MethodUsage `json:"results"`
+	Stats        *RunStats     `json:"stats,omitempty"`
+	Summary      *Summary      `json:"summary,omitempty"`
+	// Definitions analyzed past the baseline, and the unused ones among them,
+	// whether Filter keeps them or not
+	Analyzed int `json:"-"`
+	Unused   int `json:"-"`
+}
+
+type DirFilter struct {
+	NoIgnore bool     // Do not honor .gitignore and .git/info/exclude
+	Exclude  []string // Globs, relative to the root, of paths to leave out
+	Stubs    bool     // Also collect .pyi type stubs
+	// Extensions of the files to collect, DefaultExtensions when empty
+	Extensions     []string
+	FollowSymlinks bool // Descend into symlinked directories, each directory is visited once
+	MaxDepth       int  // Maximum directory depth below the root, 0 for no limit
 }
 
 type MethodFilter struct {
-	SkipPrivate bool
+	SkipPrivate   bool
+	SkipDecorated []string // Skip methods carrying any of these decorators
+	SkipTests     bool
+	TestGlobs     []string       // Globs identifying test files, DefaultTestGlobs when empty
+	Include       []string       // When set, only files matching one of these globs contribute definitions
+	NameRegex     *regexp.Regexp // When set, only names matching it are analyzed
+	Methods       []MethodSpec   // When set, only the named methods are analyzed
+	ExcludeNames  []string       // Globs of names or Class.names never analyzed
+	Encoding      string         // Source encoding, detected per file when empty
+	Jobs          int            // Files read concurrently, runtime.NumCPU() when not positive
+}
+
+// skipName reports whether a definition must be ignored because of its name
+func (f MethodFilter) skipName(name string) bool {
+	if f.SkipPrivate && isPrivateMethod(name) {
+		return true
+	}
+	return f.NameRegex != nil && !f.NameRegex.MatchString(name)
 }
 
 type FileFilter struct {
 	SkipImports     bool
 	SkipTests       bool
+	TestGlobs       []string // Globs identifying test files, DefaultTestGlobs when empty
+	NoIgnore        bool     // Search files excluded by .gitignore too
+	Exclude         []string // Globs of paths never searched for usages
+	Stubs           bool     // Search .pyi stubs, reporting their references as type hints
+	Extensions      []string // Extensions of the files searched, DefaultExtensions when empty
+	FollowSymlinks  bool     // Follow symlinked directories while searching
+	MaxDepth        int      // Maximum directory depth below each search root, 0 for no limit
 	SkipDefinitions bool
+	DocReferences   bool // Also count Sphinx cross-references in docstrings
+	IncludeComments bool // Report mentions inside comments instead of dropping them
+	// Count references passed without calling them, such as callbacks
+	AttributeReferences bool
+	// Count dotted string paths such as "myapp.views.handler"
+	StringReferences bool
+	// Only search the defining file for usages of nested functions
+	LocalNested bool
+	Encoding    string // Source encoding, detected per file when empty
+	Context     int    // Lines of source captured around each usage
+	Engine      string // Search engine, EngineRipgrep when empty
+	Jobs        int    // Methods searched concurrently, runtime.NumCPU() when not positive
+	// Search backend processes run at once, Jobs when not positive
+	SearchProcs int
+	// Raw arguments appended to every ripgrep invocation, such as --threads=4
+	RgArgs []string
+	// Usages below this confidence are dropped before they are stored
+	MinConfidence Confidence
+	// Usages stored per method, 0 for no limit. Counts still cover every usage.
+	MaxStoredUsages int
+	// Matches collected per method, 0 for no limit. Past it the rest are not
+	// classified and the result is marked Capped.
+	UsageCap int
+	Stats    *Stats // Receives the times and counters of the analysis when set
+	// Answers the searches of source files instead of the engine when set,
+	// rescanning the files changed since it was saved
+	RefIndex *RefIndex
+	Warnings *Warnings // Collects the problems that did not stop the analysis, logged when nil
+
+	procs workerPool // Shared by the searches of one analysis
 }
 
 type CallPattern struct {
@@ -70,53 +220,157 @@ type CallPattern struct {
 	Pattern *regexp.Regexp
 }
 
-func isPythonFile(filename string) bool {
-	return strings.HasSuffix(filename, ".py")
+// Search engines, as accepted by --search-backend
+const (
+	EngineRipgrep = "rg"      // ripgrep, the fast path
+	EngineGitGrep = "gitgrep" // git grep, for hosts with git but without ripgrep
+	EngineUgrep   = "ugrep"   // ugrep
+	EngineNative  = "native"  // In-process regexp scan, needing no external tool
+)
+
+// Engines lists every search engine
+var Engines = []string{EngineRipgrep, EngineGitGrep, EngineUgrep, EngineNative}
+
+// DefaultExtensions are the file extensions analyzed unless configured otherwise
+var DefaultExtensions = []string{".py", ".ipynb"}
+
+// extensions normalizes an extension allowlist, adding .pyi when stubs are on
+func extensions(exts []string, stubs bool) []string {
+	if len(exts) == 0 {
+		exts = DefaultExtensions
+	}
+	normalized := make([]string, 0, len(exts)+1)
+	for _, ext := range exts {
+		ext = strings.TrimSpace(ext)
+		if ext == "" {
+			continue
+		}
+		if !strings.HasPrefix(ext, ".") {
+			ext = "." + ext
+		}
+		normalized = append(normalized, ext)
+	}
+	if stubs && !hasExtension("x.pyi", normalized) {
+		normalized = append(normalized, ".pyi")
+	}
+	return normalized
+}
+
+func hasExtension(filename string, exts []string) bool {
+	for _, ext := range exts {
+		if strings.HasSuffix(filename, ext) {
+			return true
+		}
+	}
+	return false
 }
 
 func readEntireFile(filepath string) ([]byte, error) {
 	return os.ReadFile(filepath)
 }
 
-func ReadDir(rootDir string) ([]File, error) {
-	var pythonFiles []File
-	err := filepath.WalkDir(rootDir,
-		func(path string, entry fs.DirEntry, err error) error {
+// ReadDirs reads the Python files of several roots. Files reachable from more
+// than one root are reported once, tagged with the first root listing them.
+func ReadDirs(ctx context.Context, rootDirs []string, filter DirFilter) ([]File, error) {
+	var files []File
+	seen := make(map[string]bool)
+	for _, root := range rootDirs {
+		rootFiles, err := ReadDir(ctx, root, filter)
+		if err != nil {
+			return files, err
+		}
+		for _, f := range rootFiles {
+			abs, err := filepath.Abs(f.Path)
 			if err != nil {
-				return err
+				abs = f.Path
 			}
-			if entry.IsDir() {
-				name := entry.Name()
-				if name == ".git" || name == "__pycache__" || name == ".venv" || name == "venv" || name == "node_modules" {
-					return filepath.SkipDir
-				}
+			if seen[abs] {
+				continue
 			}
-			if isPythonFile(path) {
-				pyFile := File{
-					Dir:  filepath.Dir(path),
-					Base: filepath.Base(path),
-					Path: path,
-				}
-				pythonFiles = append(pythonFiles, pyFile)
+			seen[abs] = true
+			files = append(files, f)
+		}
+	}
+	return files, nil
+}
+
+// rootOf returns the most specific root containing path
+func rootOf(path string, roots []string) string {
+	best := ""
+	for _, r := range roots {
+		rel, err := filepath.Rel(r, path)
+		if err != nil || strings.HasPrefix(rel, "..") {
+			continue
+		}
+		if len(r) > len(best) {
+			best = r
+		}
+	}
+	return best
+}
+
+// FilesFromPaths builds the File list for explicitly given files, whatever
+// their extension. Each file is tagged with the first root containing it,
+// relative and absolute paths alike, or with its own directory.
+func FilesFromPaths(paths []string, roots []string) ([]File, error) {
+	var files []File
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if info.IsDir() {
+			return nil, fmt.Errorf("%s is a directory, use --dir instead", path)
+		}
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			return nil, err
+		}
+
+		root := filepath.Dir(path)
+		for _, r := range roots {
+			absRoot, err := filepath.Abs(r)
+			if err != nil {
+				continue
+			}
+			if rel, err := filepath.Rel(absRoot, abs); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+				root = r
+				break
 			}
-			return nil
+		}
+		files = append(files, File{
+			Dir:  filepath.Dir(path),
+			Base: filepath.Base(path),
+			Path: path,
+			Root: root,
 		})
-	return pythonFiles, err
+	}
+	return files, nil
 }
 
 func buildCallPatterns(methodName string) []CallPattern {
 	escaped := regexp.QuoteMeta(methodName)
 
 	return []CallPattern{
-		// Definition: def method_name(
+		// Definition: def method_name(, also async def and Cython cdef/cpdef
+		{
+			Type:    CallTypeDefinition,
+			Pattern: regexp.MustCompile(`^\s*(?:async\s+)?c?p?def\s+(?:[\w*\[\]]+\s+)*?` + escaped + `\s*\(`),
+		},
+		// Lambda definition: method_name = lambda ...
 		{
 			Type:    CallTypeDefinition,
-			Pattern: regexp.MustCompile(`^\s*def\s+` + escaped + `\s*\(`),
+			Pattern: regexp.MustCompile(lambdaPattern(methodName)),
+		},
+		// Partial application: functools.partial(method_name, ...)
+		{
+			Type:    CallTypePartial,
+			Pattern: regexp.MustCompile(partialPattern(methodName)),
 		},
 		// Decorator: @method_name or @something.method_name
 		{
 			Type:    CallTypeDecorator,
-			Pattern: regexp.MustCompile(`^\s*@(\w+\.)*` + escaped + `\s*$`),
+			Pattern: regexp.MustCompile(decoratorPattern(methodName) + `\s*$`),
 		},
 		// Instance call: self.method_name(
 		{
@@ -128,12 +382,18 @@ func buildCallPatterns(methodName string) []CallPattern {
 			Type:    CallTypeClass,
 			Pattern: regexp.MustCompile(`\bcls\.` + escaped + `\s*\(`),
 		},
-		// Static/Class name call: ClassName.method_name( or obj.method_name(
-		// This pattern matches any identifier followed by .method_name(
+		// Static/Class name call: ClassName.method_name(
 		{
 			Type:    CallTypeStatic,
 			Pattern: regexp.MustCompile(`\b[A-Z][a-zA-Z0-9_]*\.` + escaped + `\s*\(`),
 		},
+		// Call through any other receiver: obj.method_name(, module.func(,
+		// super().method_name(, Factory().method_name(, items[0].method_name(.
+		// The receiver is not resolved, so it counts as an instance call.
+		{
+			Type:    CallTypeInstance,
+			Pattern: regexp.MustCompile(`[\w)\]"']\s*\.\s*` + escaped + `\s*\(`),
+		},
 		// Function call: method_name( (not preceded by a dot)
 		// Negative lookbehind would be ideal but Go doesn't support it
 		// So we'll check this separately in classifyUsage
@@ -144,21 +404,74 @@ func buildCallPatterns(methodName string) []CallPattern {
 	}
 }
 
-func classifyUsage(line string, methodName string) (CallType, bool) {
+// lambdaPattern matches callables defined through an assignment: name = lambda ...
+func lambdaPattern(methodName string) string {
+	return `^\s*` + regexp.QuoteMeta(methodName) + `\s*(?::[^=]+)?=\s*lambda\b`
+}
+
+// decoratorPattern matches a bare decorator line, @name or @module.name,
+// which calls name without parentheses
+func decoratorPattern(methodName string) string {
+	return `^\s*@(?:\w+\.)*` + regexp.QuoteMeta(methodName) + `\b`
+}
+
+// partialPattern matches references passed to partial(), e.g.
+// functools.partial(process, 1) or partial(self.process)
+func partialPattern(methodName string) string {
+	return `\bpartial\s*\(\s*(?:[\w.]+\.)?` + regexp.QuoteMeta(methodName) + `\s*[,)]`
+}
+
+// referencePattern matches a function passed around without being called,
+// as an argument, keyword argument or assigned value: map(parse, rows),
+// on_click=self.handle or callback = handler
+func referencePattern(methodName string) string {
+	return `(?:^\s*|[(,]\s*|(?:^|[^=!<>])=\s*)(?:\w+\.)*` + regexp.QuoteMeta(methodName) + `\s*(?:[,)]|$)`
+}
+
+// stringReferencePattern matches a dotted path naming the method inside a
+// string literal, as used by Django settings, importlib and entry points:
+// "myapp.views.handler" or "pkg.cli:main". The module part is captured.
+func stringReferencePattern(methodName string) string {
+	return `["']((?:\w+[.:])+)` + regexp.QuoteMeta(methodName) + `["']`
+}
+
+// docRolePattern matches Sphinx cross-reference roles such as
+// :func:`pkg.method`, :py:meth:`~Class.method` or :meth:`title <method>`.
+func docRolePattern(methodName string) string {
+	return `:(?:py:)?(?:func|meth|obj|attr|class|data):` + "`(?:[^`]*?[\\s.~!<])?" +
+		regexp.QuoteMeta(methodName) + `(?:\(\))?>?` + "`"
+}
+
+// commentPattern matches a whole-word mention of the method after a '#'.
+func commentPattern(methodName string) string {
+	return `#.*\b` + regexp.QuoteMeta(methodName) + `\b`
+}
+
+func classifyUsage(line string, methodName string, filters FileFilter) (CallType, bool) {
 	trimmed := strings.TrimSpace(line)
 
 	if strings.HasPrefix(trimmed, "#") {
+		if filters.IncludeComments && regexp.MustCompile(commentPattern(methodName)).MatchString(trimmed) {
+			return CallTypeComment, true
+		}
 		return "", false
 	}
 
 	if idx := strings.Index(line, "#"); idx != -1 {
 		codePart := line[:idx]
 		if !strings.Contains(codePart, methodName) {
+			if filters.IncludeComments && regexp.MustCompile(commentPattern(methodName)).MatchString(line[idx:]) {
+				return CallTypeComment, true
+			}
 			return "", false
 		}
 		line = codePart
 	}
 
+	if filters.DocReferences && regexp.MustCompile(docRolePattern(methodName)).MatchString(line) {
+		return CallTypeDocReference, true
+	}
+
 	patterns := buildCallPatterns(methodName)
 
 	for _, pattern := range patterns {
@@ -172,34 +485,124 @@ func classifyUsage(line string, methodName string) (CallType, bool) {
 		}
 	}
 
+	if filters.StringReferences && regexp.MustCompile(stringReferencePattern(methodName)).MatchString(line) {
+		return CallTypeStringReference, true
+	}
+	if filters.AttributeReferences && regexp.MustCompile(referencePattern(methodName)).MatchString(line) {
+		return CallTypeAttributeReference, true
+	}
+
 	return "", false
 }
 
-func searchMethodUsages(methodName string, searchDir string, skipTests bool) ([]string, error) {
-	globs := []string{"*.py"}
-	if skipTests {
-		globs = append(globs, "!test_*.py", "!*_test.py")
+// classifySymbolUsage classifies a line according to the kind of symbol searched
+func classifySymbolUsage(kind SymbolKind, line string, name string, filters FileFilter) (CallType, bool) {
+	switch kind {
+	case SymbolVariable:
+		return classifyVariableUsage(line, name, filters)
+	case SymbolAttribute:
+		return classifyAttributeUsage(line, name, filters)
+	default:
+		return classifyUsage(line, name, filters)
 	}
+}
 
-	args := []string{"--vimgrep"}
-	for _, g := range globs {
-		args = append(args, "--glob", g)
+// searchPattern builds the ripgrep pattern that finds candidate usage lines
+func searchPattern(kind SymbolKind, name string, filters FileFilter) string {
+	switch kind {
+	case SymbolVariable:
+		return variablePattern(name)
+	case SymbolAttribute:
+		return attributePattern(name)
+	}
+
+	pattern := fmt.Sprintf(`\b%s\s*\(`, regexp.QuoteMeta(name))
+	pattern += "|" + lambdaPattern(name) + "|" + partialPattern(name) + "|" + decoratorPattern(name)
+	if filters.DocReferences {
+		pattern += "|" + docRolePattern(name)
+	}
+	if filters.IncludeComments {
+		pattern += "|" + commentPattern(name)
+	}
+	if filters.AttributeReferences {
+		pattern += "|" + referencePattern(name)
 	}
+	if filters.StringReferences {
+		pattern += "|" + stringReferencePattern(name)
+	}
+	return pattern
+}
 
-	searchPattern := fmt.Sprintf(`\b%s\s*\(`, regexp.QuoteMeta(methodName))
-	args = append(args, searchPattern, searchDir)
+func searchMethodUsages(ctx context.Context, m Method, searchDirs []string, filters FileFilter) ([]string, error) {
+	return search(ctx, searchPattern(m.Kind, m.Name, filters), searchDirs, filters)
+}
 
-	cmd := exec.Command("rg", args...)
-	out, err := cmd.Output()
+func testGlobs(globs []string) []string {
+	if len(globs) == 0 {
+		return DefaultTestGlobs
+	}
+	return globs
+}
+
+// IsTestFile reports whether the file matches any of the test globs
+func IsTestFile(file File, globs []string) bool {
+	return matchAnyGlob(testGlobs(globs), file.RelPath())
+}
+
+// skipFile reports whether definitions in the file must be ignored
+func skipFile(file File, filters MethodFilter) bool {
+	if len(filters.Include) > 0 && !matchAnyGlob(filters.Include, file.RelPath()) {
+		return true
+	}
+	return filters.SkipTests && IsTestFile(file, filters.TestGlobs)
+}
+
+// searchAliasUsages scans a file that imports methodName under another name
+// and reports calls made through that alias.
+func searchAliasUsages(site aliasSite, m Method, filters FileFilter) []Usage {
+	if filters.SkipTests && IsTestFile(site.File, filters.TestGlobs) {
+		return nil
+	}
+
+	src, err := readSource(site.File.Path, filters.Encoding)
 	if err != nil {
-		if exitErr, ok := err.(*exec.ExitError); ok && exitErr.ExitCode() == 1 {
-			return []string{}, nil
+		filters.Warnings.add("", "reading file %s: %v", site.File.Path, err)
+		return nil
+	}
+
+	callRe := regexp.MustCompile(searchPattern(m.Kind, site.Alias, FileFilter{}))
+
+	var usages []Usage
+	for lineNo, line := range src.lines {
+		code := blankImportStatements(line)
+		callType, valid := classifySymbolUsage(m.Kind, code, site.Alias, filters)
+		if !valid || callType == CallTypeDefinition || callType == CallTypeImport {
+			continue
+		}
+		col := 1
+		if loc := callRe.FindStringIndex(code); loc != nil {
+			col = loc[0] + 1
 		}
-		return nil, err
+		usages = append(usages, Usage{
+			Location: fmt.Sprintf("%s:%d:%d", site.File.Path, lineNo+1, col),
+			CallType: callType,
+			Context:  strings.TrimSpace(line),
+			Alias:    site.Alias,
+		})
 	}
+	return usages
+}
 
-	lines := strings.Split(strings.TrimSpace(string(out)), "\n")
-	return lines, nil
+// blankImportStatements replaces the import statements of a line with spaces,
+// keeping columns intact for whatever follows a ';'.
+func blankImportStatements(line string) string {
+	stmts := strings.Split(line, ";")
+	for i, stmt := range stmts {
+		if isImportLine(stmt) {
+			stmts[i] = strings.Repeat(" ", len(stmt))
+		}
+	}
+	return strings.Join(stmts, ";")
 }
 
 func isImportLine(line string) bool {
@@ -207,8 +610,11 @@ func isImportLine(line string) bool {
 	return strings.HasPrefix(trimmed, "import ") || strings.HasPrefix(trimmed, "from ")
 }
 
-func ParseUsages(rawUsages []string, methodName string, filters FileFilter, definedFile string) []Usage {
+func ParseUsages(rawUsages []string, m Method, filters FileFilter) []Usage {
 	var usages []Usage
+	// Overlapping search roots or patterns report a location more than once;
+	// each location is kept once, at its position in usages or -1 when dropped
+	seen := make(map[string]int)
 
 	for _, rawUsage := range rawUsages {
 		parts := strings.SplitN(rawUsage, ":", 4)
@@ -216,31 +622,67 @@ func ParseUsages(rawUsages []string, methodName string, filters FileFilter, defi
 			continue
 		}
 
-		filepath := parts[0]
+		file := parts[0]
 		lineNo := parts[1]
 		colNo := parts[2]
 		lineContent := parts[3]
 
+		key := path.Clean(file) + ":" + lineNo + ":" + colNo
+		if i, ok := seen[key]; ok {
+			if i >= 0 {
+				usages[i].Collapsed++
+			}
+			continue
+		}
+		seen[key] = -1
+
 		if filters.SkipImports && isImportLine(lineContent) {
 			continue
 		}
 
-		callType, valid := classifyUsage(lineContent, methodName)
+		callType, valid := classifySymbolUsage(m.Kind, lineContent, m.Name, filters)
 		if !valid {
 			continue
 		}
 
+		// Backends report the files of --dir . as ./pkg/mod.py, the walker as
+		// pkg/mod.py
+		inDefiningFile := filepath.Clean(file) == filepath.Clean(m.Filename)
+
 		if filters.SkipDefinitions && callType == CallTypeDefinition {
-			if filepath != definedFile {
+			if !inDefiningFile {
 				continue
 			}
 		}
 
-		location := fmt.Sprintf("%s:%s:%s", filepath, lineNo, colNo)
+		// Stubs only describe code, so a stub mentioning a method defined
+		// elsewhere is a type-hint reference rather than a call or definition
+		if isStubFile(file) && !inDefiningFile {
+			callType = CallTypeTypeHint
+		}
+
+		// Overload signatures were grouped under their implementation
+		if callType == CallTypeDefinition && len(m.Overloads) > 0 && inDefiningFile {
+			if n, _ := strconv.Atoi(lineNo); slices.Contains(m.Overloads, n) {
+				callType = CallTypeOverload
+			}
+		}
+
+		if m.Kind == SymbolAttribute {
+			// The assignment that introduced an attribute is its definition
+			if callType == CallTypeWrite && inDefiningFile && lineNo == strconv.Itoa(m.LineNo) {
+				callType = CallTypeDefinition
+			}
+			colNo = attributeColumn(lineContent, colNo, m.Name)
+		}
+
+		location := fmt.Sprintf("%s:%s:%s", file, lineNo, colNo)
+		seen[key] = len(usages)
 		usages = append(usages, Usage{
-			Location: location,
-			CallType: callType,
-			Context:  strings.TrimSpace(lineContent),
+			Location:   location,
+			CallType:   callType,
+			Context:    strings.TrimSpace(lineContent),
+			Suspicious: bindingMismatch(m, callType, lineContent),
 		})
 	}
 
@@ -251,43 +693,125 @@ func isPrivateMethod(methodName string) bool {
 	return strings.HasPrefix(methodName, "_")
 }
 
-func FindMethods(files []File, filters MethodFilter) []Method {
-	re := regexp.MustCompile(`def\s+([a-zA-Z_][a-zA-Z0-9_]*)\s*\(`)
+var decoratorRe = regexp.MustCompile(`^\s*@\s*([A-Za-z_][\w.]*)`)
+
+// hasDecorator reports whether any decorator matches one of the names. A name
+// matches the full dotted decorator or its trailing components, so "fixture"
+// matches both @fixture and @pytest.fixture.
+func hasDecorator(decorators []string, names []string) bool {
+	for _, d := range decorators {
+		for _, name := range names {
+			if d == name || strings.HasSuffix(d, "."+name) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// FindMethods collects the function definitions of files. Once the context is
+// cancelled no further file is read and the definitions found so far are
+// returned.
+func FindMethods(ctx context.Context, files []File, filters MethodFilter) []Method {
+	// cdef/cpdef may carry a return type in Cython: cpdef double area(
+	re := regexp.MustCompile(`^\s*(?:async\s+)?c?p?def\s+(?:[\w*\[\]]+\s+)*?([a-zA-Z_][a-zA-Z0-9_]*)\s*\(`)
+	lambdaRe := regexp.MustCompile(`^\s*([a-zA-Z_][a-zA-Z0-9_]*)\s*(?::[^=]+)?=\s*lambda\b`)
 
 	methodsChan := make(chan []Method, len(files))
 	var wg sync.WaitGroup
+	pool := newWorkerPool(filters.Jobs)
 
 	for _, pyFile := range files {
+		if ctx.Err() != nil {
+			break
+		}
+		if skipFile(pyFile, filters) {
+			continue
+		}
+
 		wg.Add(1)
+		pool.acquire()
 		go func(file File) {
 			defer wg.Done()
+			defer pool.release()
 
 			var fileMethods []Method
-			data, err := readEntireFile(file.Path)
+			src, err := readSource(file.Path, filters.Encoding)
 			if err != nil {
 				log.Printf("Error reading file %s: %v", file.Path, err)
 				methodsChan <- fileMethods
 				return
 			}
 
-			lines := strings.Split(string(data), "\n")
-			for lineNo, line := range lines {
+			var scopes scopeTracker
+			var decorators []string
+			decoratorDepth := 0 // open parens of a decorator spanning several lines
+
+			for lineNo, line := range src.lines {
+				if decoratorDepth > 0 {
+					decoratorDepth += strings.Count(line, "(") - strings.Count(line, ")")
+					continue
+				}
+				if m := decoratorRe.FindStringSubmatch(line); m != nil {
+					decorators = append(decorators, m[1])
+					decoratorDepth = strings.Count(line, "(") - strings.Count(line, ")")
+					continue
+				}
+				lineDecorators := decorators
+				if trimmed := strings.TrimSpace(line); trimmed != "" && !strings.HasPrefix(trimmed, "#") {
+					decorators = nil
+				}
+				if !scopes.update(line, lineNo+1) {
+					// Blank, comment, or a def shown in a multi-line string
+					continue
+				}
+
 				matches := re.FindStringSubmatch(line)
+				isDef := matches != nil
+				if !isDef {
+					matches = lambdaRe.FindStringSubmatch(line)
+				}
 				if len(matches) > 1 {
 					methodName := matches[1]
 
-					if filters.SkipPrivate && isPrivateMethod(methodName) {
+					if filters.skipName(methodName) {
 						continue
 					}
+					if len(filters.SkipDecorated) > 0 && hasDecorator(lineDecorators, filters.SkipDecorated) {
+						continue
+					}
+
+					var unused []string
+					var metrics *Metrics
+					endLine := lineNo + 1
+					if isDef {
+						if metrics = measureFunction(src.lines, lineNo); metrics != nil {
+							endLine = lineNo + metrics.Lines
+						}
+						if !isStubFile(file.Path) && !hasDecorator(lineDecorators, []string{"abstractmethod", "overload"}) {
+							unused = unusedParams(src.lines, lineNo)
+						}
+					}
 
 					fileMethods = append(fileMethods, Method{
-						Name:     methodName,
-						Filename: file.Path,
-						LineNo:   lineNo + 1,
+						Name:         methodName,
+						Filename:     file.Path,
+						LineNo:       lineNo + 1,
+						EndLine:      endLine,
+						Kind:         SymbolFunction,
+						Class:        scopes.classOf(lineNo + 1),
+						Parent:       scopes.parentFunction(lineNo + 1),
+						Binding:      bindingOf(scopes.classOf(lineNo+1), lineDecorators),
+						Decorators:   lineDecorators,
+						Root:         file.Root,
+						Cell:         src.cellOf(lineNo + 1),
+						UnusedParams: unused,
+						Metrics:      metrics,
+						Ignored:      HasIgnoreComment(line),
 					})
 				}
 			}
-			methodsChan <- fileMethods
+			methodsChan <- groupOverloads(fileMethods)
 		}(pyFile)
 	}
 
@@ -301,45 +825,239 @@ func FindMethods(files []File, filters MethodFilter) []Method {
 		allMethods = append(allMethods, methods...)
 	}
 
-	return allMethods
+	return selectMethods(resolveStubs(allMethods), filters)
 }
 
-func AnalyzeMethodUsages(methods []Method, searchDir string, filters FileFilter) []MethodUsage {
+// AnalyzeMethodUsages searches the usages of every method and returns the
+// results once all of them completed. Once the context is cancelled, running
+// searches are stopped; methods whose search already completed are still
+// classified, and only those are returned.
+func AnalyzeMethodUsages(ctx context.Context, methods []Method, searchDirs []string, filters FileFilter) []MethodUsage {
+	var results []MethodUsage
+	StreamMethodUsages(ctx, methods, searchDirs, filters, func(result MethodUsage) {
+		results = append(results, result)
+	})
+	return results
+}
+
+// StreamMethodUsages searches the usages of every method like
+// AnalyzeMethodUsages, handing each result to emit as soon as it completes.
+// Results arrive in completion order and emit is never called concurrently.
+func StreamMethodUsages(ctx context.Context, methods []Method, searchDirs []string, filters FileFilter, emit func(MethodUsage)) {
+	// Imports such as "from utils import calc as c" hide calls behind an alias,
+	// so index them once up front and resolve them per method.
+	dirFilter := DirFilter{
+		NoIgnore:       filters.NoIgnore,
+		Exclude:        filters.Exclude,
+		Stubs:          filters.Stubs,
+		Extensions:     filters.Extensions,
+		FollowSymlinks: filters.FollowSymlinks,
+		MaxDepth:       filters.MaxDepth,
+	}
+	searchFiles, err := ReadDirs(ctx, searchDirs, dirFilter)
+	if ctx.Err() != nil {
+		return
+	}
+	if err != nil {
+		filters.Warnings.add("", "reading directories %v: %v", searchDirs, err)
+	}
+	filters.Stats.addFiles(len(searchFiles))
+	imports := BuildImportTable(searchFiles, filters.Encoding)
+	aliases := imports.aliasesByName(searchFiles)
+
+	// Backends only search source files; notebooks, and every file with the
+	// native engine, are decoded once and searched in-process.
+	native := filters.Engine == EngineNative
+	inProcess := make(map[string]sourceFile)
+	var inProcessPaths []string
+	for _, file := range searchFiles {
+		if (!native && !isNotebook(file.Path)) || (filters.SkipTests && IsTestFile(file, filters.TestGlobs)) {
+			continue
+		}
+		src, err := readSource(file.Path, filters.Encoding)
+		if err != nil {
+			filters.Warnings.add("", "reading file %s: %v", file.Path, err)
+			continue
+		}
+		inProcess[file.Path] = src
+		inProcessPaths = append(inProcessPaths, file.Path)
+	}
+
+	sources := newSourceCache(inProcess, filters.Encoding)
+	sources.stats = filters.Stats
+
+	indexed := filters.RefIndex != nil && !native
+	if indexed {
+		var indexable []File
+		for _, file := range searchFiles {
+			if !isNotebook(file.Path) && (!filters.SkipTests || !IsTestFile(file, filters.TestGlobs)) {
+				indexable = append(indexable, file)
+			}
+		}
+		start := time.Now()
+		scanned := filters.RefIndex.update(ctx, indexable, filters.Encoding, filters.Jobs)
+		filters.Stats.addIndexUpdate(len(indexable), scanned)
+		filters.Stats.addSearch(start)
+		if ctx.Err() != nil {
+			return
+		}
+	}
+
+	procs := filters.SearchProcs
+	if procs < 1 {
+		procs = filters.Jobs
+	}
+	filters.procs = newWorkerPool(procs)
+
+	// Methods searched across the whole tree share a few backend passes
+	batchOf := make(map[int]*searchBatch)
+	if !native && !indexed {
+		var shared []int
+		for i, m := range methods {
+			if !filters.LocalNested || m.Parent == "" {
+				shared = append(shared, i)
+			}
+		}
+		start := time.Now()
+		for _, batch := range batchSearch(ctx, methods, shared, searchDirs, filters) {
+			// Warnings are shared by the whole batch, so they are reported once
+			if filters.Warnings.addSearchWarning("", batch.err) {
+				batch.err = nil
+			}
+			for _, i := range batch.indexes {
+				batchOf[i] = batch
+			}
+		}
+		filters.Stats.addSearch(start)
+	}
+
+	// Decoded files are scanned once for every method
+	var inProcessMatches map[int][]string
+	if len(inProcess) > 0 {
+		start := time.Now()
+		inProcessMatches = scanFiles(ctx, inProcess, inProcessPaths, methods, filters)
+		filters.Stats.addSearch(start)
+	}
+
 	resultsChan := make(chan MethodUsage, len(methods))
 	var wg sync.WaitGroup
+	pool := newWorkerPool(filters.Jobs)
 
-	for _, method := range methods {
+	for idx, method := range methods {
+		if ctx.Err() != nil {
+			break
+		}
 		wg.Add(1)
-		go func(m Method) {
+		pool.acquire()
+		go func(idx int, m Method) {
 			defer wg.Done()
+			defer pool.release()
+
+			// A nested function can only be reached from its own file
+			local := filters.LocalNested && m.Parent != ""
+			dirs := searchDirs
+			if local {
+				dirs = []string{m.Filename}
+			}
 
-			rawUsages, err := searchMethodUsages(m.Name, searchDir, filters.SkipTests)
+			searchStart := time.Now()
+			var rawUsages []string
+			var err error
+			if batch, ok := batchOf[idx]; ok {
+				rawUsages, err = batch.matches[idx], batch.err
+			} else if indexed {
+				only := ""
+				if local {
+					only = m.Filename
+				}
+				re := regexp.MustCompile(searchPattern(m.Kind, m.Name, filters))
+				rawUsages = filters.RefIndex.search(m.Name, re, only, func(path string) []string {
+					return sources.load(path).lines
+				})
+			} else if !native && !isNotebook(m.Filename) {
+				rawUsages, err = searchMethodUsages(ctx, m, dirs, filters)
+			}
+			if err != nil && ctx.Err() != nil {
+				return // Interrupted searches are incomplete, leave the method out
+			}
+			if filters.Warnings.addSearchWarning(m.Name, err) {
+				err = nil
+			}
 			if err != nil {
-				log.Printf("Error searching for method %s: %v", m.Name, err)
+				filters.Warnings.add(m.Name, "%v", err)
 				resultsChan <- MethodUsage{
 					Method:       m,
 					Usages:       []Usage{},
 					UsagesByType: make(map[CallType]int),
 					TotalUsages:  0,
+					SearchError:  err.Error(),
 				}
 				return
 			}
 
-			usages := ParseUsages(rawUsages, m.Name, filters, m.Filename)
+			rawUsages = append(rawUsages, inProcessMatches[idx]...)
+			filters.Stats.addSearch(searchStart)
+			classifyStart := time.Now()
 
-			// Count usages by type
+			sortMatches(rawUsages)
+			rawUsages, capped := capMatches(rawUsages, m, filters.UsageCap)
+			usages := ParseUsages(rawUsages, m, filters)
+			if !local {
+				for _, site := range aliases[m.Name] {
+					usages = append(usages, searchAliasUsages(site, m, filters)...)
+				}
+			}
+			reexporters := imports.reexporters(m)
+			usages = imports.scopeUsages(usages, m, reexporters)
+			if dynamic := imports.dynamicImportUsages(m); len(dynamic) > 0 {
+				usages = append(usages, dynamic...)
+				m.DynamicallyLoaded = !imports.staticallyImported(m)
+			}
+
+			sortUsages(usages)
+
+			// Count usages by type, and by repository when searching several
 			usagesByType := make(map[CallType]int)
-			for _, usage := range usages {
+			var usagesByRoot map[string]int
+			if len(searchDirs) > 1 {
+				usagesByRoot = make(map[string]int, len(searchDirs))
+				for _, dir := range searchDirs {
+					usagesByRoot[dir] = 0
+				}
+			}
+			for i, usage := range usages {
+				if src, ok := inProcess[usagePath(usage.Location)]; ok {
+					usages[i].Cell = src.cellOf(usageLine(usage.Location))
+				}
+				usages[i].Confidence = imports.confidenceOf(usage, m, reexporters)
+				if usage.CallType != CallTypeDefinition {
+					scope := sources.callerOf(usagePath(usage.Location), usageLine(usage.Location))
+					usages[i].Caller, usages[i].CallerClass = scope.Function, scope.Class
+				}
+				if filters.Context > 0 && usage.CallType != CallTypeDynamicImport {
+					usages[i].Before, usages[i].After = sources.contextOf(usagePath(usage.Location), usageLine(usage.Location), filters.Context)
+				}
 				usagesByType[usage.CallType]++
+				if usagesByRoot != nil {
+					usages[i].Root = rootOf(usagePath(usage.Location), searchDirs)
+					usagesByRoot[usages[i].Root]++
+				}
 			}
 
-			resultsChan <- MethodUsage{
+			result := MethodUsage{
 				Method:       m,
 				Usages:       usages,
 				UsagesByType: usagesByType,
+				UsagesByRoot: usagesByRoot,
 				TotalUsages:  len(usages),
+				Capped:       capped,
+			}
+			if filters.MinConfidence.rank() > ConfidenceLow.rank() {
+				result = FilterByConfidence([]MethodUsage{result}, filters.MinConfidence)[0]
 			}
-		}(method)
+			filters.Stats.addClassify(classifyStart)
+			resultsChan <- truncateUsages(result, filters.MaxStoredUsages)
+		}(idx, method)
 	}
 
 	go func() {
@@ -347,12 +1065,9 @@ func AnalyzeMethodUsages(methods []Method, searchDir string, filters FileFilter)
 		close(resultsChan)
 	}()
 
-	var results []MethodUsage
 	for result := range resultsChan {
-		results = append(results, result)
+		emit(result)
 	}
-
-	return results
 }
 
 func FilterByUsageCount(results []MethodUsage, minUsages, maxUsages int) []MethodUsage {
@@ -373,49 +1088,64 @@ func FilterByUsageCount(results []MethodUsage, minUsages, maxUsages int) []Metho
 	return filtered
 }
 
+// complexityOf is the complexity of a result, 0 for definitions without a body
+func complexityOf(result MethodUsage) int {
+	if result.Method.Metrics == nil {
+		return 0
+	}
+	return result.Method.Metrics.Complexity
+}
+
+// SortResults orders results by sortBy: name, file, usages or complexity.
+// Ties are broken by the location of the definition, so the order never
+// depends on the order the results were analyzed in.
 func SortResults(results []MethodUsage, sortBy string, asc bool) {
 	sortBy = strings.ToLower(sortBy)
 
-	less := func(i, j int) bool {
+	compare := func(a, b MethodUsage) int {
 		switch sortBy {
 		case "name":
-			return results[i].Method.Name < results[j].Method.Name
-
-		case "file":
-			if results[i].Method.Filename == results[j].Method.Filename {
-				return results[i].Method.LineNo < results[j].Method.LineNo
-			}
-			return results[i].Method.Filename < results[j].Method.Filename
-
+			return cmp.Or(strings.Compare(a.Method.Name, b.Method.Name), compareMethods(a.Method, b.Method))
 		case "usages":
-			if results[i].TotalUsages == results[j].TotalUsages {
-				return results[i].Method.Name < results[j].Method.Name
-			}
-			return results[i].TotalUsages < results[j].TotalUsages
-
-		default:
-			if results[i].Method.Filename == results[j].Method.Filename {
-				return results[i].Method.LineNo < results[j].Method.LineNo
-			}
-			return results[i].Method.Filename < results[j].Method.Filename
+			return cmp.Or(cmp.Compare(a.TotalUsages, b.TotalUsages), strings.Compare(a.Method.Name, b.Method.Name),
+				compareMethods(a.Method, b.Method))
+		case "complexity":
+			return cmp.Or(cmp.Compare(complexityOf(a), complexityOf(b)), strings.Compare(a.Method.Name, b.Method.Name),
+				compareMethods(a.Method, b.Method))
+		default: // file
+			return compareMethods(a.Method, b.Method)
 		}
 	}
 
 	if asc {
-		sort.Slice(results, less)
+		slices.SortFunc(results, compare)
 	} else {
-		sort.Slice(results, func(i, j int) bool { return !less(i, j) })
+		slices.SortFunc(results, func(a, b MethodUsage) int { return compare(b, a) })
 	}
 }
 
 func GetCallTypeOrder() []CallType {
 	return []CallType{
 		CallTypeDefinition,
+		CallTypeOverload,
 		CallTypeInstance,
 		CallTypeClass,
 		CallTypeStatic,
 		CallTypeFunction,
 		CallTypeDecorator,
+		CallTypePartial,
+		CallTypeAttributeReference,
+		CallTypeStringReference,
+		CallTypeDynamicImport,
+		CallTypeTypeHint,
+		CallTypeDocReference,
+		CallTypeRead,
+		CallTypeWrite,
+		CallTypeImport,
+		CallTypeReexport,
+		CallTypeComment,
+		CallTypeDuplicate,
+		CallTypeSimilar,
 	}
 }
 
@@ -423,6 +1153,8 @@ func GetCallTypeLabel(ct CallType) string {
 	switch ct {
 	case CallTypeDefinition:
 		return "Definition"
+	case CallTypeOverload:
+		return "Overload signatures"
 	case CallTypeInstance:
 		return "Instance calls"
 	case CallTypeClass:
@@ -433,6 +1165,32 @@ func GetCallTypeLabel(ct CallType) string {
 		return "Function calls"
 	case CallTypeDecorator:
 		return "Decorator usage"
+	case CallTypePartial:
+		return "Partial applications"
+	case CallTypeAttributeReference:
+		return "References"
+	case CallTypeStringReference:
+		return "String references"
+	case CallTypeDynamicImport:
+		return "Dynamic imports"
+	case CallTypeTypeHint:
+		return "Type hints (stubs)"
+	case CallTypeDocReference:
+		return "Doc references"
+	case CallTypeComment:
+		return "Comment mentions"
+	case CallTypeRead:
+		return "Reads"
+	case CallTypeWrite:
+		return "Writes"
+	case CallTypeImport:
+		return "Imports"
+	case CallTypeReexport:
+		return "Package re-exports"
+	case CallTypeDuplicate:
+		return "Duplicate implementations"
+	case CallTypeSimilar:
+		return "Similar implementations"
 	default:
 		return string(ct)
 	}
@@ -0,0 +1,368 @@
+package finder
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// Edit replaces the lines [Start, End) of a file, counted from 0, with Insert
+type Edit struct {
+	Start, End int
+	Insert     []string
+}
+
+// Unremovable explains why RemoveDefinitions cannot delete m, empty when it can
+func Unremovable(m Method) string {
+	switch {
+	case m.Kind != SymbolFunction || m.Metrics == nil || m.EndLine < m.LineNo:
+		return "not a def statement"
+	case m.Cell > 0:
+		return "defined in a notebook"
+	case m.StubOnly:
+		return "only defined in a stub"
+	case len(m.Overloads) > 0:
+		return "has @overload signatures"
+	}
+	return ""
+}
+
+// memberReferenceRe captures the names used as an attribute, obj.name, or
+// as a decorator, @name
+var memberReferenceRe = regexp.MustCompile(`\.\s*([A-Za-z_]\w*)|^\s*@\s*([A-Za-z_]\w*)`)
+
+// MemberReferences returns, for the names of methods mentioned in the files
+// of dirs after a dot or as a decorator, where the first mention is. The
+// usage search does not resolve obj.name to the class defining it, nor
+// @name without a call, so the fix and clean commands keep those
+// definitions rather than risk deleting code still in use.
+func MemberReferences(ctx context.Context, methods []Method, dirs []string, walk DirFilter, encoding string) (map[string]string, error) {
+	names := make(map[string]bool)
+	for _, m := range methods {
+		names[m.Name] = true
+	}
+	refs := make(map[string]string)
+	if len(names) == 0 {
+		return refs, nil
+	}
+	files, err := ReadDirs(ctx, dirs, walk)
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range files {
+		src, err := readSource(file.Path, encoding)
+		if err != nil {
+			return nil, err
+		}
+		for i, line := range src.lines {
+			for _, match := range memberReferenceRe.FindAllStringSubmatch(line, -1) {
+				name := match[1] + match[2]
+				if names[name] && refs[name] == "" {
+					refs[name] = fmt.Sprintf("%s:%d", file.Path, i+1)
+				}
+			}
+		}
+	}
+	return refs, nil
+}
+
+// blockKeywords start the statements whose body is an indented block
+var blockKeywords = map[string]bool{
+	"class": true, "def": true, "async": true, "if": true, "elif": true, "else": true,
+	"try": true, "except": true, "finally": true, "for": true, "while": true, "with": true,
+	"match": true, "case": true,
+}
+
+// importLineRe matches the single-line imports RemoveDefinitions can edit
+var importLineRe = regexp.MustCompile(`^(\s*)(from\s+\S+\s+)?import\s+([\w., ]+?)\s*$`)
+
+// RemoveDefinitions returns the edits deleting methods, with their
+// decorators, from the lines of their file, and the methods deleted. Methods
+// nested in another removed one go with it, and blocks left empty get a pass
+// statement. Methods still named by the lines kept are left in place, in
+// case the usage search missed a reference. With imports, the names only the
+// removed code used are dropped from single-line imports.
+func RemoveDefinitions(lines []string, methods []Method, imports bool) ([]Edit, []Method) {
+	type span struct {
+		start, end, def int
+		indent          string
+		method          Method
+	}
+	var spans []span
+	for _, m := range methods {
+		if Unremovable(m) != "" || m.EndLine > len(lines) {
+			continue
+		}
+		start, end := definitionRange(lines, m)
+		def := lines[m.LineNo-1]
+		spans = append(spans, span{start, end, m.LineNo - 1, def[:indentOf(def)], m})
+	}
+	sort.Slice(spans, func(i, j int) bool { return spans[i].start < spans[j].start })
+
+	var removed []bool
+	var kept []span
+	for {
+		removed = make([]bool, len(lines))
+		kept = kept[:0]
+		for _, s := range spans {
+			if removed[s.def] {
+				// Nested in a removed definition
+				continue
+			}
+			for removed[s.start] {
+				// Blank lines the previous removal already took
+				s.start++
+			}
+			for i := s.start; i < s.end; i++ {
+				removed[i] = true
+			}
+			kept = append(kept, s)
+		}
+		referenced := slices.IndexFunc(kept, func(s span) bool {
+			return usedOutside(lines, removed, -1, s.method.Name)
+		})
+		if referenced < 0 {
+			break
+		}
+		spans = slices.DeleteFunc(spans, func(s span) bool { return s.def == kept[referenced].def })
+	}
+	var deleted []Method
+	for _, s := range spans {
+		if removed[s.def] {
+			deleted = append(deleted, s.method)
+		}
+	}
+
+	var edits []Edit
+	if imports {
+		var names map[string]bool
+		for _, s := range kept {
+			for i := s.start; i < s.end; i++ {
+				for _, word := range wordRe.FindAllString(lines[i], -1) {
+					if names == nil {
+						names = make(map[string]bool)
+					}
+					names[word] = true
+				}
+			}
+		}
+		for i, line := range lines {
+			if removed[i] {
+				continue
+			}
+			edit, ok := pruneImport(lines, removed, i, names)
+			if !ok {
+				continue
+			}
+			if edit.Insert == nil {
+				removed[i] = true
+				kept = append(kept, span{start: i, end: i + 1, def: i, indent: line[:indentOf(line)]})
+				continue
+			}
+			edits = append(edits, edit)
+		}
+	}
+
+	padded := make(map[int]bool)
+	for _, s := range kept {
+		edit := Edit{Start: s.start, End: s.end}
+		if header := emptiedBlock(lines, removed, s.start, s.end, s.def); header >= 0 && !padded[header] {
+			padded[header] = true
+			pass := s.indent + "pass"
+			if strings.HasSuffix(lines[s.def], "\r") {
+				pass += "\r"
+			}
+			edit.Insert = []string{pass}
+		}
+		edits = append(edits, edit)
+	}
+	sort.Slice(edits, func(i, j int) bool { return edits[i].Start < edits[j].Start })
+	return edits, deleted
+}
+
+// definitionRange returns the lines [start, end) of a def with its
+// decorators and the blank lines around it, but those keeping the code
+// before and after it apart: as many as the larger of the two gaps, none
+// when it opens its block with a sibling after it or ends the file.
+func definitionRange(lines []string, m Method) (int, int) {
+	def := m.LineNo - 1
+	indent := indentOf(lines[def])
+	start := def
+	for remaining := len(m.Decorators); remaining > 0 && start > 0; {
+		start--
+		if strings.HasPrefix(strings.TrimSpace(lines[start]), "@") && indentOf(lines[start]) == indent {
+			remaining--
+		}
+	}
+	end := m.EndLine
+
+	isBlank := func(i int) bool { return strings.TrimSpace(lines[i]) == "" }
+	before, after := 0, 0
+	for start-before > 0 && isBlank(start-before-1) {
+		before++
+	}
+	for end+after < len(lines) && isBlank(end+after) {
+		after++
+	}
+	opens := start-before == 0 || strings.HasSuffix(strings.TrimSpace(lines[start-before-1]), ":")
+	gap := max(before, after)
+	switch {
+	case end+after == len(lines):
+		gap = 0
+	case opens && indentOf(lines[end+after]) == indent:
+		gap = 0
+	}
+	keepAfter := min(gap, after)
+	return start - before + gap - keepAfter, end + after - keepAfter
+}
+
+// emptiedBlock returns the line of the block header whose body the removal
+// of [start, end), holding the statement at line def, leaves empty, -1 when
+// the block keeps a statement
+func emptiedBlock(lines []string, removed []bool, start, end, def int) int {
+	isCode := func(i int) bool {
+		t := strings.TrimSpace(lines[i])
+		return !removed[i] && t != "" && !strings.HasPrefix(t, "#")
+	}
+	header := start - 1
+	for header >= 0 && !isCode(header) {
+		header--
+	}
+	if header < 0 {
+		return -1
+	}
+	text := strings.TrimSpace(strings.SplitN(lines[header], "#", 2)[0])
+	keyword, _, _ := strings.Cut(strings.TrimRight(text, ":"), " ")
+	if !strings.HasSuffix(text, ":") || !blockKeywords[keyword] || indentOf(lines[header]) >= indentOf(lines[def]) {
+		return -1
+	}
+	next := end
+	for next < len(lines) && !isCode(next) {
+		next++
+	}
+	if next < len(lines) && indentOf(lines[next]) > indentOf(lines[header]) {
+		return -1
+	}
+	return header
+}
+
+// pruneImport returns the edit dropping from the import at line i the names
+// used by the removed code and nowhere else. The edit has no Insert when the
+// whole line goes, and ok is false when the line is kept as is.
+func pruneImport(lines []string, removed []bool, i int, names map[string]bool) (edit Edit, ok bool) {
+	m := importLineRe.FindStringSubmatch(strings.TrimSuffix(lines[i], "\r"))
+	if m == nil || strings.Contains(m[2], "__future__") {
+		return Edit{}, false
+	}
+	var items []string
+	dropped := false
+	for _, item := range strings.Split(m[3], ",") {
+		item = strings.TrimSpace(item)
+		fields := strings.Fields(item)
+		if len(fields) == 0 {
+			return Edit{}, false
+		}
+		bound := fields[len(fields)-1]
+		if m[2] == "" && len(fields) == 1 {
+			bound, _, _ = strings.Cut(bound, ".")
+		}
+		if names[bound] && !usedOutside(lines, removed, i, bound) {
+			dropped = true
+			continue
+		}
+		items = append(items, item)
+	}
+	if !dropped {
+		return Edit{}, false
+	}
+	if len(items) == 0 {
+		return Edit{Start: i, End: i + 1}, true
+	}
+	line := m[1] + m[2] + "import " + strings.Join(items, ", ")
+	if strings.HasSuffix(lines[i], "\r") {
+		line += "\r"
+	}
+	return Edit{Start: i, End: i + 1, Insert: []string{line}}, true
+}
+
+// usedOutside reports whether name appears in the lines kept, but line skip
+func usedOutside(lines []string, removed []bool, skip int, name string) bool {
+	re := regexp.MustCompile(`\b` + regexp.QuoteMeta(name) + `\b`)
+	for i, line := range lines {
+		if i != skip && !removed[i] && re.MatchString(line) {
+			return true
+		}
+	}
+	return false
+}
+
+// ApplyEdits returns lines with edits, sorted and not overlapping, applied
+func ApplyEdits(lines []string, edits []Edit) []string {
+	var out []string
+	i := 0
+	for _, e := range edits {
+		out = append(out, lines[i:e.Start]...)
+		out = append(out, e.Insert...)
+		i = e.End
+	}
+	return append(out, lines[i:]...)
+}
+
+// diffContext is the number of unchanged lines around the hunks of UnifiedDiff
+const diffContext = 3
+
+// UnifiedDiff renders edits of the lines of path as a unified diff, empty
+// without edits
+func UnifiedDiff(path string, lines []string, edits []Edit) string {
+	if len(edits) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "--- %s\n+++ %s\n", path, path)
+	shift := 0 // Lines added minus lines removed by the previous hunks
+	for first := 0; first < len(edits); {
+		last := first
+		for last+1 < len(edits) && edits[last+1].Start-edits[last].End <= 2*diffContext {
+			last++
+		}
+		from := max(edits[first].Start-diffContext, 0)
+		to := min(edits[last].End+diffContext, len(lines))
+
+		var body strings.Builder
+		oldCount, newCount := to-from, to-from
+		i := from
+		for _, e := range edits[first : last+1] {
+			for ; i < e.Start; i++ {
+				body.WriteString(" " + lines[i] + "\n")
+			}
+			for ; i < e.End; i++ {
+				body.WriteString("-" + lines[i] + "\n")
+			}
+			for _, line := range e.Insert {
+				body.WriteString("+" + line + "\n")
+			}
+			newCount += len(e.Insert) - (e.End - e.Start)
+		}
+		for ; i < to; i++ {
+			body.WriteString(" " + lines[i] + "\n")
+		}
+
+		fmt.Fprintf(&b, "@@ -%s +%s @@\n", hunkRange(from, oldCount), hunkRange(from+shift, newCount))
+		b.WriteString(body.String())
+		shift += newCount - oldCount
+		first = last + 1
+	}
+	return b.String()
+}
+
+// hunkRange formats the start and length of a side of a hunk, an empty side
+// starting at the line before it
+func hunkRange(start, count int) string {
+	if count == 0 {
+		return fmt.Sprintf("%d,0", start)
+	}
+	return fmt.Sprintf("%d,%d", start+1, count)
+}
@@ -0,0 +1,136 @@
+package finder
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRemoveDefinitions(t *testing.T) {
+	src := strings.Split(`import os
+import json, re
+from typing import Any, List
+
+
+def used() -> List:
+    return os.getcwd()
+
+
+@cache
+@route(
+    "/x",
+)
+def dead(x: Any) -> List:
+    return json.dumps(re.escape(x))
+
+
+class Holder:
+    def a(self):
+        def inner():
+            pass
+        return inner
+
+    def b(self):
+        pass
+
+
+def tail():
+    pass`, "\n")
+	def := func(name, class string, line, end int, decorators ...string) Method {
+		return Method{Name: name, Class: class, Kind: SymbolFunction, LineNo: line, EndLine: end, Decorators: decorators, Metrics: &Metrics{}}
+	}
+	methods := []Method{
+		def("dead", "", 14, 15, "cache", "route"),
+		def("a", "Holder", 19, 22),
+		def("inner", "", 20, 21),
+		def("b", "Holder", 24, 25),
+		def("tail", "", 28, 29),
+	}
+
+	edits, deleted := RemoveDefinitions(src, methods, false)
+	if len(deleted) != len(methods) {
+		t.Errorf("RemoveDefinitions() deleted %d methods, want %d", len(deleted), len(methods))
+	}
+	got := strings.Join(ApplyEdits(src, edits), "\n")
+	want := `import os
+import json, re
+from typing import Any, List
+
+
+def used() -> List:
+    return os.getcwd()
+
+
+class Holder:
+    pass`
+	if got != want {
+		t.Errorf("RemoveDefinitions() =\n%s\nwant\n%s", got, want)
+	}
+
+	edits, _ = RemoveDefinitions(src, methods[:1], true)
+	got = strings.Join(ApplyEdits(src, edits)[:5], "\n")
+	want = `import os
+from typing import List
+
+
+def used() -> List:`
+	if got != want {
+		t.Errorf("RemoveDefinitions() with imports =\n%s\nwant\n%s", got, want)
+	}
+
+	lambda := Method{Name: "f", Kind: SymbolFunction, LineNo: 1, EndLine: 1}
+	if edits, _ := RemoveDefinitions(src, []Method{lambda}, false); Unremovable(lambda) == "" || len(edits) != 0 {
+		t.Error("RemoveDefinitions() removed a definition without def metrics")
+	}
+
+	// A reference the usage search missed keeps the definition
+	src = append(src, "", "fallback = None or tail")
+	if edits, deleted := RemoveDefinitions(src, methods[4:], false); len(edits) != 0 || len(deleted) != 0 {
+		t.Errorf("RemoveDefinitions() of a referenced function = %v, deleted %v, want no edit", edits, deleted)
+	}
+}
+
+func TestUnifiedDiff(t *testing.T) {
+	lines := []string{"a", "b", "c", "d", "e", "f", "g", "h", "i", "j", "k", "l", "m", "n"}
+	edits := []Edit{{Start: 1, End: 2}, {Start: 11, End: 12, Insert: []string{"L"}}}
+	want := `--- m.py
++++ m.py
+@@ -1,5 +1,4 @@
+ a
+-b
+ c
+ d
+ e
+@@ -9,6 +8,6 @@
+ i
+ j
+ k
+-l
++L
+ m
+ n
+`
+	if got := UnifiedDiff("m.py", lines, edits); got != want {
+		t.Errorf("UnifiedDiff() =\n%s\nwant\n%s", got, want)
+	}
+	if got := UnifiedDiff("m.py", lines, nil); got != "" {
+		t.Errorf("UnifiedDiff() without edits = %q, want empty", got)
+	}
+}
+
+func TestMemberReferences(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, dir, "lib.py", "class Svc:\n    def handle(self):\n        pass\n\n    def dead(self):\n        pass\n\n\ndef traced(fn):\n    return fn\n")
+	app := writeTestFile(t, dir, "app.py", "from lib import Svc, traced\n\n\n@traced\ndef main():\n    callbacks = [Svc().handle]\n")
+
+	methods := []Method{{Name: "handle"}, {Name: "dead"}, {Name: "traced"}}
+	refs, err := MemberReferences(context.Background(), methods, []string{dir}, DirFilter{}, "")
+	if err != nil {
+		t.Fatalf("MemberReferences() error = %v", err)
+	}
+	want := map[string]string{"handle": app + ":6", "traced": app + ":4"}
+	if !reflect.DeepEqual(refs, want) {
+		t.Errorf("MemberReferences() = %v, want %v", refs, want)
+	}
+}
@@ -0,0 +1,113 @@
+package finder
+
+import (
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// git runs a git command in dir and returns its output lines
+func git(dir string, args ...string) ([]string, error) {
+	cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+	out, err := cmd.Output()
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok && len(exitErr.Stderr) > 0 {
+			return nil, fmt.Errorf("git %s: %s", args[0], strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return nil, fmt.Errorf("git %s: %w", args[0], err)
+	}
+	var lines []string
+	for _, line := range strings.Split(string(out), "\n") {
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines, nil
+}
+
+// realPath resolves a path to an absolute one without symlinks, as git reports them
+func realPath(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		path = resolved
+	}
+	return path
+}
+
+// changedPaths returns the files of the repository around dir added or
+// modified since ref, committed or not, plus untracked files
+func changedPaths(dir, ref string) (map[string]bool, error) {
+	top, err := git(dir, "rev-parse", "--show-toplevel")
+	if err != nil {
+		return nil, err
+	}
+	if len(top) == 0 {
+		return nil, fmt.Errorf("%s is not inside a git repository", dir)
+	}
+	diff, err := git(top[0], "diff", "--name-only", "--no-renames", "--diff-filter=d", ref, "--")
+	if err != nil {
+		return nil, err
+	}
+	untracked, err := git(top[0], "ls-files", "--others", "--exclude-standard")
+	if err != nil {
+		return nil, err
+	}
+	changed := make(map[string]bool, len(diff)+len(untracked))
+	for _, name := range append(diff, untracked...) {
+		changed[filepath.Join(top[0], filepath.FromSlash(name))] = true
+	}
+	return changed, nil
+}
+
+// ChangedSince keeps the files touched since the git ref, including
+// uncommitted and untracked ones. Each root is resolved to its repository,
+// so roots from several repositories are supported.
+func ChangedSince(files []File, ref string) ([]File, error) {
+	byRoot := make(map[string]map[string]bool)
+	var changed []File
+	for _, file := range files {
+		root := file.Root
+		if root == "" {
+			root = file.Dir
+		}
+		paths, ok := byRoot[root]
+		if !ok {
+			var err error
+			if paths, err = changedPaths(root, ref); err != nil {
+				return nil, err
+			}
+			byRoot[root] = paths
+		}
+		if paths[realPath(file.Path)] {
+			changed = append(changed, file)
+		}
+	}
+	return changed, nil
+}
+
+// RepoRoot returns the top-level directory of the git repository around dir
+func RepoRoot(dir string) (string, error) {
+	top, err := git(dir, "rev-parse", "--show-toplevel")
+	if err != nil {
+		return "", err
+	}
+	if len(top) == 0 {
+		return "", fmt.Errorf("%s is not inside a git repository", dir)
+	}
+	return top[0], nil
+}
+
+// HeadCommit returns the commit checked out in the repository around dir
+func HeadCommit(dir string) (string, error) {
+	head, err := git(dir, "rev-parse", "HEAD")
+	if err != nil {
+		return "", err
+	}
+	if len(head) == 0 {
+		return "", fmt.Errorf("%s has no commit checked out", dir)
+	}
+	return head[0], nil
+}
@@ -0,0 +1,71 @@
+package finder
+
+import (
+	"context"
+	"os/exec"
+	"reflect"
+	"testing"
+)
+
+func TestChangedSince(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	dir := t.TempDir()
+	run := func(args ...string) {
+		t.Helper()
+		if _, err := git(dir, args...); err != nil {
+			t.Fatal(err)
+		}
+	}
+	run("init", "-q")
+	writeTestFile(t, dir, "old.py", "def old(): pass\n")
+	writeTestFile(t, dir, "edited.py", "def edited(): pass\n")
+	run("add", ".")
+	run("-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "-q", "-m", "base")
+	writeTestFile(t, dir, "edited.py", "def edited(): return 1\n")
+	writeTestFile(t, dir, "added.py", "def added(): pass\n")
+
+	files, err := ReadDirs(context.Background(), []string{dir}, DirFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	changed, err := ChangedSince(files, "HEAD")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := relPaths(t, changed), []string{"added.py", "edited.py"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ChangedSince = %v, want %v", got, want)
+	}
+
+	if _, err := ChangedSince(files, "no-such-ref"); err == nil {
+		t.Error("ChangedSince with an unknown ref succeeded")
+	}
+}
+
+func TestHeadCommit(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	dir := t.TempDir()
+	if _, err := HeadCommit(dir); err == nil {
+		t.Error("HeadCommit outside a repository succeeded")
+	}
+	if _, err := git(dir, "init", "-q"); err != nil {
+		t.Fatal(err)
+	}
+	writeTestFile(t, dir, "a.py", "def a(): pass\n")
+	if _, err := git(dir, "add", "."); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := git(dir, "-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "-q", "-m", "base"); err != nil {
+		t.Fatal(err)
+	}
+	commit, err := HeadCommit(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(commit) != 40 && len(commit) != 64 {
+		t.Errorf("HeadCommit() = %q, want a full object name", commit)
+	}
+}
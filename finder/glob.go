@@ -0,0 +1,106 @@
+package finder
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"regexp/syntax"
+	"strings"
+	"sync"
+)
+
+var (
+	globCache   = make(map[string]*regexp.Regexp)
+	globCacheMu sync.Mutex
+)
+
+// globToRegexp translates a gitignore/ripgrep style glob into a regexp.
+// "**" spans directories, "*" and "?" stay within one path component.
+// Malformed bracket expressions, such as [z-a], are an error.
+func globToRegexp(glob string) (*regexp.Regexp, error) {
+	globCacheMu.Lock()
+	defer globCacheMu.Unlock()
+	if re, ok := globCache[glob]; ok {
+		return re, nil
+	}
+
+	var b strings.Builder
+	b.WriteString("^")
+	for i := 0; i < len(glob); i++ {
+		c := glob[i]
+		switch {
+		case strings.HasPrefix(glob[i:], "**/"):
+			b.WriteString("(?:.*/)?")
+			i += 2
+		case strings.HasPrefix(glob[i:], "**"):
+			b.WriteString(".*")
+			i++
+		case c == '*':
+			b.WriteString("[^/]*")
+		case c == '?':
+			b.WriteString("[^/]")
+		case c == '[':
+			if end := strings.IndexByte(glob[i:], ']'); end > 0 {
+				class := glob[i+1 : i+end]
+				if strings.HasPrefix(class, "!") {
+					class = "^" + class[1:]
+				}
+				b.WriteString("[" + class + "]")
+				i += end
+			} else {
+				b.WriteString(regexp.QuoteMeta("["))
+			}
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	b.WriteString("$")
+
+	re, err := regexp.Compile(b.String())
+	if err != nil {
+		var syntaxErr *syntax.Error
+		if errors.As(err, &syntaxErr) {
+			return nil, fmt.Errorf("invalid glob '%s': %s", glob, syntaxErr.Code)
+		}
+		return nil, fmt.Errorf("invalid glob '%s': %w", glob, err)
+	}
+	globCache[glob] = re
+	return re, nil
+}
+
+// CheckGlob reports why glob is malformed, nil when it is not. Malformed
+// globs match nothing.
+func CheckGlob(glob string) error {
+	_, err := globToRegexp(strings.TrimPrefix(glob, "/"))
+	return err
+}
+
+// globMatch reports whether s matches glob, false when glob is malformed
+func globMatch(glob, s string) bool {
+	re, err := globToRegexp(glob)
+	return err == nil && re.MatchString(s)
+}
+
+// MatchGlob matches a slash or OS separated path, relative to the search
+// root, against a glob. Globs without a '/' match the base name at any depth,
+// like in .gitignore files and ripgrep's --glob; a leading '/' anchors them.
+func MatchGlob(glob, relPath string) bool {
+	relPath = filepath.ToSlash(relPath)
+	anchored := strings.HasPrefix(glob, "/")
+	glob = strings.TrimPrefix(glob, "/")
+	if !anchored && !strings.Contains(glob, "/") {
+		return globMatch(glob, relPath[strings.LastIndex(relPath, "/")+1:])
+	}
+	return globMatch(glob, relPath)
+}
+
+// matchAnyGlob reports whether the path matches at least one glob
+func matchAnyGlob(globs []string, relPath string) bool {
+	for _, g := range globs {
+		if MatchGlob(g, relPath) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,82 @@
+package finder
+
+import "testing"
+
+func TestMatchGlob(t *testing.T) {
+	cases := []struct {
+		glob, path string
+		want       bool
+	}{
+		{"test_*.py", "pkg/test_models.py", true},
+		{"test_*.py", "pkg/models.py", false},
+		{"conftest.py", "a/b/conftest.py", true},
+		{"tests/**", "tests/unit/test_a.py", true},
+		{"tests/**", "src/tests/test_a.py", false},
+		{"**/tests/**", "src/tests/test_a.py", true},
+		{"migrations/**", "migrations/0001_initial.py", true},
+		{"**/generated_*.py", "api/generated_client.py", true},
+		{"**/generated_*.py", "generated_client.py", true},
+		{"src/*.py", "src/pkg/a.py", false},
+		{"src/**/*.py", "src/pkg/a.py", true},
+		{"src/**/*.py", "src/a.py", true},
+		{"[!_]*.py", "a.py", true},
+		{"[!_]*.py", "_a.py", false},
+		{"/setup.py", "setup.py", true},
+		{"/setup.py", "pkg/setup.py", false},
+	}
+
+	for _, c := range cases {
+		if got := MatchGlob(c.glob, c.path); got != c.want {
+			t.Errorf("MatchGlob(%q, %q) = %v, want %v", c.glob, c.path, got, c.want)
+		}
+	}
+}
+
+func TestCheckGlob(t *testing.T) {
+	cases := []struct {
+		glob    string
+		wantErr string
+	}{
+		{"[a-z]*.py", ""},
+		{"[!_]*.py", ""},
+		{"/src/[ab]/*.py", ""},
+		{"[z-a].py", "invalid glob '[z-a].py': invalid character class range"},
+		{"[]", "invalid glob '[]': missing closing ]"},
+		{"src/[9-0]/**", "invalid glob 'src/[9-0]/**': invalid character class range"},
+		{"[[:nope:]].py", "invalid glob '[[:nope:]].py': invalid character class range"},
+	}
+	for _, c := range cases {
+		err := CheckGlob(c.glob)
+		switch {
+		case c.wantErr == "" && err != nil:
+			t.Errorf("CheckGlob(%q) = %v, want nil", c.glob, err)
+		case c.wantErr != "" && (err == nil || err.Error() != c.wantErr):
+			t.Errorf("CheckGlob(%q) = %v, want %q", c.glob, err, c.wantErr)
+		}
+		// Malformed globs match nothing rather than panic
+		if c.wantErr != "" && MatchGlob(c.glob, "a.py") {
+			t.Errorf("MatchGlob(%q, a.py) = true, want false", c.glob)
+		}
+	}
+}
+
+func TestMatchName(t *testing.T) {
+	cases := []struct {
+		glob string
+		m    Method
+		want bool
+	}{
+		{"legacy_*", Method{Name: "legacy_save"}, true},
+		{"legacy_*", Method{Name: "save", Class: "legacy_Model"}, false},
+		{"legacy_*", Method{Name: "legacy_save", Class: "Model"}, true},
+		{"*Admin.get_*", Method{Name: "get_queryset", Class: "UserAdmin"}, true},
+		{"*Admin.get_*", Method{Name: "get_queryset"}, false},
+		{"Outer.*.run", Method{Name: "run", Class: "Outer.Inner"}, true},
+		{"Outer.*", Method{Name: "run", Class: "Outer.Inner"}, false},
+	}
+	for _, c := range cases {
+		if got := MatchName(c.glob, c.m); got != c.want {
+			t.Errorf("MatchName(%q, %+v) = %v, want %v", c.glob, c.m, got, c.want)
+		}
+	}
+}
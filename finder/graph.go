@@ -0,0 +1,248 @@
+package finder
+
+import (
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// GraphNode is a function or module of a dependency graph
+type GraphNode struct {
+	ID     string `json:"id"`
+	Label  string `json:"label"`
+	Group  string `json:"group,omitempty"`  // Package of the function or module
+	Unused bool   `json:"unused,omitempty"` // A definition without real usages
+}
+
+// GraphEdge links a caller or importer to what it depends on. Weight counts
+// the usages or imported names behind the edge.
+type GraphEdge struct {
+	From   string `json:"source"`
+	To     string `json:"target"`
+	Weight int    `json:"weight"`
+}
+
+// Graph is a directed dependency graph with sorted nodes and edges
+type Graph struct {
+	Nodes []GraphNode `json:"nodes"`
+	Edges []GraphEdge `json:"edges"`
+}
+
+// graphBuilder accumulates nodes and weighted edges
+type graphBuilder struct {
+	nodes map[string]GraphNode
+	edges map[[2]string]int
+	roots packageRoots
+}
+
+func newGraphBuilder() *graphBuilder {
+	return &graphBuilder{nodes: make(map[string]GraphNode), edges: make(map[[2]string]int), roots: packageRoots{}}
+}
+
+func (b *graphBuilder) node(id, label, group string) {
+	if _, ok := b.nodes[id]; !ok {
+		b.nodes[id] = GraphNode{ID: id, Label: label, Group: group}
+	}
+}
+
+func (b *graphBuilder) unused(id string) {
+	n := b.nodes[id]
+	n.Unused = true
+	b.nodes[id] = n
+}
+
+func (b *graphBuilder) edge(from, to string) {
+	b.edges[[2]string{from, to}]++
+}
+
+func (b *graphBuilder) graph() Graph {
+	var g Graph
+	for _, n := range b.nodes {
+		g.Nodes = append(g.Nodes, n)
+	}
+	for key, weight := range b.edges {
+		g.Edges = append(g.Edges, GraphEdge{From: key[0], To: key[1], Weight: weight})
+	}
+	sort.Slice(g.Nodes, func(i, j int) bool { return g.Nodes[i].ID < g.Nodes[j].ID })
+	sort.Slice(g.Edges, func(i, j int) bool {
+		if g.Edges[i].From != g.Edges[j].From {
+			return g.Edges[i].From < g.Edges[j].From
+		}
+		return g.Edges[i].To < g.Edges[j].To
+	})
+	return g
+}
+
+// callNode names a function node after its file, as in "views:Handler.get"
+func callNode(path, name string) string {
+	base := filepath.Base(path)
+	return strings.TrimSuffix(base, filepath.Ext(base)) + ":" + name
+}
+
+// BuildCallGraph connects the caller of every usage to the method it uses.
+// Usages at module level are attributed to the file's <module> node.
+func BuildCallGraph(results []MethodUsage) Graph {
+	b := newGraphBuilder()
+	for _, r := range results {
+		name := r.Method.Name
+		if r.Method.Class != "" {
+			name = r.Method.Class + "." + name
+		}
+		callee := callNode(r.Method.Filename, name)
+		b.node(callee, callee, dottedPath(r.Method, false, b.roots))
+		if RealUsages(r) == 0 && !IsImplicitlyUsed(r.Method) {
+			b.unused(callee)
+		}
+
+		for _, u := range r.Usages {
+			if u.CallType == CallTypeDefinition || u.CallType == CallTypeOverload {
+				continue
+			}
+			path := usagePath(u.Location)
+			if path == "" {
+				continue
+			}
+			caller := u.Caller
+			if caller == "" {
+				caller = "<module>"
+			}
+			if u.CallerClass != "" {
+				caller = u.CallerClass + "." + caller
+			}
+			from := callNode(path, caller)
+			b.node(from, from, dottedPath(Method{Filename: path, Root: r.Method.Root}, false, b.roots))
+			b.edge(from, callee)
+		}
+	}
+	return b.graph()
+}
+
+// moduleName is the dotted name of a file, see dottedPath
+func moduleName(file File) string {
+	return ModuleName(Method{Filename: file.Path, Root: file.Root})
+}
+
+// BuildImportGraph connects every module to the modules of the tree it
+// imports. Imports of modules outside files, such as the standard library,
+// are left out.
+func BuildImportGraph(files []File, imports ImportTable) Graph {
+	// Index files by their last module component so that each import is only
+	// matched against the few files it can refer to
+	byLast := make(map[string][]File)
+	for _, file := range files {
+		last := strings.TrimSuffix(file.Base, filepath.Ext(file.Base))
+		if last == "__init__" {
+			last = filepath.Base(file.Dir)
+		}
+		byLast[last] = append(byLast[last], file)
+	}
+	lastOf := func(module string) string {
+		return module[strings.LastIndex(module, ".")+1:]
+	}
+
+	b := newGraphBuilder()
+	for _, file := range files {
+		from := moduleName(file)
+		b.node(from, from, dottedPath(Method{Filename: file.Path, Root: file.Root}, false, b.roots))
+		for _, imp := range imports[file.Path] {
+			modules := []string{imp.Module}
+			if imp.Name != "" {
+				modules = append(modules, strings.TrimSuffix(imp.Module, ".")+"."+imp.Name)
+			}
+			for _, module := range modules {
+				for _, target := range byLast[lastOf(module)] {
+					if target.Path != file.Path && ModuleFileMatches(file.Path, module, target.Path) {
+						b.edge(from, moduleName(target))
+					}
+				}
+			}
+		}
+	}
+	return b.graph()
+}
+
+// FocusGraph keeps the nodes at most depth edges away, in either direction,
+// from those named name, and the edges between them. A node is named by its
+// id, or by the function or Class.function part of a call graph id. It
+// reports false when no node is named name.
+func FocusGraph(g Graph, name string, depth int) (Graph, bool) {
+	neighbors := make(map[string][]string)
+	for _, e := range g.Edges {
+		neighbors[e.From] = append(neighbors[e.From], e.To)
+		neighbors[e.To] = append(neighbors[e.To], e.From)
+	}
+
+	kept := make(map[string]bool)
+	var frontier []string
+	for _, n := range g.Nodes {
+		_, function, _ := strings.Cut(n.ID, ":")
+		if n.ID == name || function == name || strings.HasSuffix(function, "."+name) {
+			kept[n.ID] = true
+			frontier = append(frontier, n.ID)
+		}
+	}
+	if len(frontier) == 0 {
+		return Graph{}, false
+	}
+	for range depth {
+		var next []string
+		for _, id := range frontier {
+			for _, n := range neighbors[id] {
+				if !kept[n] {
+					kept[n] = true
+					next = append(next, n)
+				}
+			}
+		}
+		frontier = next
+	}
+
+	var focused Graph
+	for _, n := range g.Nodes {
+		if kept[n.ID] {
+			focused.Nodes = append(focused.Nodes, n)
+		}
+	}
+	for _, e := range g.Edges {
+		if kept[e.From] && kept[e.To] {
+			focused.Edges = append(focused.Edges, e)
+		}
+	}
+	return focused, true
+}
+
+// FocusModule keeps the nodes of a call graph defined in module, given as a
+// dotted module name, a package or a file name without extension, along with
+// the nodes they call or are called by and the edges touching the module. It
+// reports false when no node belongs to module.
+func FocusModule(g Graph, module string) (Graph, bool) {
+	inModule := make(map[string]bool)
+	for _, n := range g.Nodes {
+		file, _, _ := strings.Cut(n.ID, ":")
+		dotted := n.Group + "." + file
+		if file == "__init__" {
+			dotted = n.Group
+		}
+		if file == module || dotted == module || n.Group == module || strings.HasPrefix(n.Group, module+".") {
+			inModule[n.ID] = true
+		}
+	}
+	if len(inModule) == 0 {
+		return Graph{}, false
+	}
+
+	kept := make(map[string]bool)
+	var focused Graph
+	for _, e := range g.Edges {
+		if inModule[e.From] || inModule[e.To] {
+			focused.Edges = append(focused.Edges, e)
+			kept[e.From], kept[e.To] = true, true
+		}
+	}
+	for _, n := range g.Nodes {
+		if inModule[n.ID] || kept[n.ID] {
+			focused.Nodes = append(focused.Nodes, n)
+		}
+	}
+	return focused, true
+}
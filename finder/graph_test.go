@@ -0,0 +1,134 @@
+package finder
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestBuildImportGraph(t *testing.T) {
+	dir := t.TempDir()
+	paths := []string{
+		writeTestFile(t, dir, "app/__init__.py", "from .views import index\n"),
+		writeTestFile(t, dir, "app/views.py", "import os\nfrom app.models import User, Group\nfrom . import utils\n"),
+		writeTestFile(t, dir, "app/models.py", "class User:\n    pass\n"),
+		writeTestFile(t, dir, "app/utils.py", "import app.models\n"),
+	}
+	var files []File
+	for _, p := range paths {
+		files = append(files, File{Dir: filepath.Dir(p), Base: filepath.Base(p), Path: p, Root: dir})
+	}
+
+	g := BuildImportGraph(files, BuildImportTable(files, ""))
+
+	var nodes []string
+	for _, n := range g.Nodes {
+		nodes = append(nodes, n.ID)
+	}
+	if want := []string{"app", "app.models", "app.utils", "app.views"}; !reflect.DeepEqual(nodes, want) {
+		t.Errorf("nodes = %v, want %v", nodes, want)
+	}
+	want := []GraphEdge{
+		{From: "app", To: "app.views", Weight: 1},
+		{From: "app.utils", To: "app.models", Weight: 1},
+		{From: "app.views", To: "app.models", Weight: 2},
+		{From: "app.views", To: "app.utils", Weight: 1},
+	}
+	if !reflect.DeepEqual(g.Edges, want) {
+		t.Errorf("edges = %+v, want %+v", g.Edges, want)
+	}
+}
+
+func TestBuildCallGraph(t *testing.T) {
+	results := []MethodUsage{{
+		Method: Method{Name: "save", Class: "User", Filename: "/repo/models.py"},
+		Usages: []Usage{
+			{Location: "/repo/models.py:10:5", CallType: CallTypeDefinition},
+			{Location: "/repo/views.py:3:5", CallType: CallTypeInstance, Caller: "post", CallerClass: "View"},
+			{Location: "/repo/views.py:8:5", CallType: CallTypeInstance, Caller: "post", CallerClass: "View"},
+			{Location: "/repo/cli.py:1:1", CallType: CallTypeInstance},
+		},
+	}}
+
+	want := []GraphEdge{
+		{From: "cli:<module>", To: "models:User.save", Weight: 1},
+		{From: "views:View.post", To: "models:User.save", Weight: 2},
+	}
+	if g := BuildCallGraph(results); !reflect.DeepEqual(g.Edges, want) {
+		t.Errorf("edges = %+v, want %+v", g.Edges, want)
+	}
+}
+
+func TestFocusGraph(t *testing.T) {
+	g := Graph{
+		Nodes: []GraphNode{{ID: "a:main"}, {ID: "b:User.save"}, {ID: "c:write"}, {ID: "d:flush"}, {ID: "e:other"}},
+		Edges: []GraphEdge{
+			{From: "a:main", To: "b:User.save"},
+			{From: "b:User.save", To: "c:write"},
+			{From: "c:write", To: "d:flush"},
+		},
+	}
+	ids := func(g Graph) []string {
+		var ids []string
+		for _, n := range g.Nodes {
+			ids = append(ids, n.ID)
+		}
+		return ids
+	}
+
+	focused, ok := FocusGraph(g, "save", 1)
+	if want := []string{"a:main", "b:User.save", "c:write"}; !ok || !reflect.DeepEqual(ids(focused), want) {
+		t.Errorf("FocusGraph(save, 1) nodes = %v, want %v", ids(focused), want)
+	}
+	if len(focused.Edges) != 2 {
+		t.Errorf("FocusGraph(save, 1) edges = %+v, want 2", focused.Edges)
+	}
+	if focused, _ := FocusGraph(g, "User.save", 2); len(focused.Nodes) != 4 {
+		t.Errorf("FocusGraph(User.save, 2) nodes = %v, want 4", ids(focused))
+	}
+	if _, ok := FocusGraph(g, "missing", 1); ok {
+		t.Error("FocusGraph(missing) found a node")
+	}
+}
+
+func TestFocusModule(t *testing.T) {
+	g := Graph{
+		Nodes: []GraphNode{
+			{ID: "cli:main", Group: "app"},
+			{ID: "models:User.save", Group: "app"},
+			{ID: "store:write", Group: "app.db"},
+			{ID: "store:flush", Group: "app.db"},
+			{ID: "__init__:connect", Group: "app.db"},
+			{ID: "other:unrelated", Group: "tools"},
+		},
+		Edges: []GraphEdge{
+			{From: "cli:main", To: "models:User.save"},
+			{From: "models:User.save", To: "store:write"},
+			{From: "store:write", To: "store:flush"},
+			{From: "__init__:connect", To: "store:flush"},
+		},
+	}
+	ids := func(g Graph) []string {
+		var ids []string
+		for _, n := range g.Nodes {
+			ids = append(ids, n.ID)
+		}
+		return ids
+	}
+
+	for _, module := range []string{"models", "app.models"} {
+		focused, ok := FocusModule(g, module)
+		if want := []string{"cli:main", "models:User.save", "store:write"}; !ok || !reflect.DeepEqual(ids(focused), want) {
+			t.Errorf("FocusModule(%s) nodes = %v, want %v", module, ids(focused), want)
+		}
+		if len(focused.Edges) != 2 {
+			t.Errorf("FocusModule(%s) edges = %+v, want 2", module, focused.Edges)
+		}
+	}
+	if focused, _ := FocusModule(g, "app.db"); len(focused.Nodes) != 4 || len(focused.Edges) != 3 {
+		t.Errorf("FocusModule(app.db) = %v with %d edges, want 4 nodes and 3 edges", ids(focused), len(focused.Edges))
+	}
+	if _, ok := FocusModule(g, "missing"); ok {
+		t.Error("FocusModule(missing) found a node")
+	}
+}
@@ -0,0 +1,30 @@
+package finder
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func writeTestFile(t *testing.T, dir, name, contents string) string {
+	t.Helper()
+	p := filepath.Join(dir, name)
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(p, []byte(contents), 0o644); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+	return p
+}
+
+// searchEngines returns the native engine, and ripgrep when it is installed
+func searchEngines(t *testing.T) []string {
+	t.Helper()
+	engines := []string{EngineNative}
+	if _, err := exec.LookPath("rg"); err == nil {
+		engines = append(engines, EngineRipgrep)
+	}
+	return engines
+}
@@ -0,0 +1,265 @@
+package finder
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ignoreRule is a single line of a .gitignore style file
+type ignoreRule struct {
+	base     string // Directory the rule is relative to
+	pattern  string
+	negate   bool
+	dirOnly  bool
+	anchored bool
+}
+
+// ignoreMatcher applies .gitignore rules the way git and ripgrep do: rules are
+// relative to the directory of the file defining them and the last match wins.
+type ignoreMatcher struct {
+	rules []ignoreRule
+}
+
+func parseIgnoreLine(base, line string) (ignoreRule, bool) {
+	line = strings.TrimRight(line, " \t\r")
+	if line == "" || strings.HasPrefix(line, "#") {
+		return ignoreRule{}, false
+	}
+
+	rule := ignoreRule{base: base}
+	if strings.HasPrefix(line, "!") {
+		rule.negate = true
+		line = line[1:]
+	}
+	line = strings.TrimPrefix(line, `\`)
+	if strings.HasSuffix(line, "/") {
+		rule.dirOnly = true
+		line = strings.TrimSuffix(line, "/")
+	}
+	if strings.Contains(line, "/") {
+		rule.anchored = true
+		line = strings.TrimPrefix(line, "/")
+	}
+	if line == "" {
+		return ignoreRule{}, false
+	}
+	rule.pattern = line
+	return rule, true
+}
+
+// load adds the rules of an ignore file; missing files are not an error.
+// Malformed patterns are skipped with a warning, as git does.
+func (m *ignoreMatcher) load(path, base string) {
+	f, err := os.Open(path)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		rule, ok := parseIgnoreLine(base, scanner.Text())
+		if !ok {
+			continue
+		}
+		if err := CheckGlob(rule.pattern); err != nil {
+			log.Printf("Skipping %s:%d: %v", path, lineNo, err)
+			continue
+		}
+		m.rules = append(m.rules, rule)
+	}
+}
+
+// ignored reports whether an absolute path is excluded by the loaded rules
+func (m *ignoreMatcher) ignored(path string, isDir bool) bool {
+	ignored := false
+	for _, rule := range m.rules {
+		if rule.dirOnly && !isDir {
+			continue
+		}
+		rel, err := filepath.Rel(rule.base, path)
+		if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+			continue
+		}
+		rel = filepath.ToSlash(rel)
+
+		var matched bool
+		if rule.anchored {
+			matched = globMatch(rule.pattern, rel)
+		} else {
+			matched = globMatch(rule.pattern, rel[strings.LastIndex(rel, "/")+1:])
+		}
+		if matched {
+			ignored = !rule.negate
+		}
+	}
+	return ignored
+}
+
+// findGitRoot returns the closest directory at or above dir containing .git
+func findGitRoot(dir string) (string, bool) {
+	for {
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			return dir, true
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", false
+		}
+		dir = parent
+	}
+}
+
+// newIgnoreMatcher prepares the rules applying to rootDir. Like ripgrep,
+// ignore files are only honored inside a git repository; .gitignore files of
+// the directories between the repository root and rootDir apply as well.
+func newIgnoreMatcher(rootDir string) (*ignoreMatcher, bool) {
+	abs, err := filepath.Abs(rootDir)
+	if err != nil {
+		return nil, false
+	}
+	gitRoot, ok := findGitRoot(abs)
+	if !ok {
+		return nil, false
+	}
+
+	m := &ignoreMatcher{}
+	m.load(filepath.Join(gitRoot, ".git", "info", "exclude"), gitRoot)
+
+	var ancestors []string
+	for dir := filepath.Dir(abs); strings.HasPrefix(dir, gitRoot); dir = filepath.Dir(dir) {
+		ancestors = append([]string{dir}, ancestors...)
+		if dir == gitRoot {
+			break
+		}
+	}
+	for _, dir := range ancestors {
+		m.load(filepath.Join(dir, ".gitignore"), dir)
+	}
+	return m, true
+}
+
+// IgnoreFileName is the project ignore file, committed along with the code
+const IgnoreFileName = ".pybroomignore"
+
+// ignoreMethodPrefix starts the lines of an ignore file naming definitions
+const ignoreMethodPrefix = "method:"
+
+// IgnoreFile holds the rules of a .pybroomignore: .gitignore style path
+// patterns, relative to the directory of the file, and "method:" lines with
+// globs of names or Class.names
+type IgnoreFile struct {
+	Dir     string
+	Methods []string
+	paths   []ignoreRule
+}
+
+// ReadIgnoreFile parses the ignore file at path. Negated path patterns are
+// an error, the rules being merged into plain exclude globs.
+func ReadIgnoreFile(path string) (*IgnoreFile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	dir, err := filepath.Abs(filepath.Dir(path))
+	if err != nil {
+		return nil, err
+	}
+	f := &IgnoreFile{Dir: dir}
+	for i, line := range strings.Split(string(data), "\n") {
+		if name, ok := strings.CutPrefix(strings.TrimSpace(line), ignoreMethodPrefix); ok {
+			if name = strings.TrimSpace(name); name != "" {
+				if err := CheckGlob(name); err != nil {
+					return nil, fmt.Errorf("%s:%d: %w", path, i+1, err)
+				}
+				f.Methods = append(f.Methods, name)
+			}
+			continue
+		}
+		rule, ok := parseIgnoreLine(dir, line)
+		if !ok {
+			continue
+		}
+		if rule.negate {
+			return nil, fmt.Errorf("%s:%d: negated patterns are not supported", path, i+1)
+		}
+		if err := CheckGlob(rule.pattern); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, i+1, err)
+		}
+		f.paths = append(f.paths, rule)
+	}
+	return f, nil
+}
+
+// Excludes returns the path patterns as globs relative to root, in the
+// syntax of DirFilter.Exclude. Anchored patterns are rebased on root, and
+// those leading elsewhere are left out.
+func (f *IgnoreFile) Excludes(root string) []string {
+	abs, err := filepath.Abs(root)
+	if err != nil {
+		return nil
+	}
+	var globs []string
+	rel, err := filepath.Rel(f.Dir, abs)
+	if err != nil {
+		return nil
+	}
+	rel = filepath.ToSlash(rel)
+	if rel == ".." || strings.HasPrefix(rel, "../") {
+		// The ignore file is below root, or beside it
+		prefix, err := filepath.Rel(abs, f.Dir)
+		if err != nil || strings.HasPrefix(prefix, "..") {
+			return nil
+		}
+		prefix = filepath.ToSlash(prefix)
+		for _, rule := range f.paths {
+			if rule.anchored {
+				globs = append(globs, prefix+"/"+rule.pattern)
+			} else {
+				globs = append(globs, prefix+"/**/"+rule.pattern)
+			}
+		}
+		return globs
+	}
+	for _, rule := range f.paths {
+		switch {
+		case !rule.anchored:
+			globs = append(globs, rule.pattern)
+			continue
+		case rel == ".":
+			globs = append(globs, "/"+rule.pattern)
+			continue
+		}
+		if glob, ok := rebaseGlob(rule.pattern, strings.Split(rel, "/")); ok {
+			globs = append(globs, glob)
+		}
+	}
+	return globs
+}
+
+// rebaseGlob strips the leading components of glob matching the directories
+// of dirs, reporting false when glob cannot match below them
+func rebaseGlob(glob string, dirs []string) (string, bool) {
+	parts := strings.Split(glob, "/")
+	for i, dir := range dirs {
+		if i == len(parts) {
+			// The whole of root is ignored
+			return "**", true
+		}
+		if parts[i] == "**" {
+			return strings.Join(parts[i:], "/"), true
+		}
+		if !globMatch(parts[i], dir) {
+			return "", false
+		}
+	}
+	if len(parts) == len(dirs) {
+		return "**", true
+	}
+	// Anchored again, below root
+	return "/" + strings.Join(parts[len(dirs):], "/"), true
+}
@@ -0,0 +1,309 @@
+package finder
+
+import (
+	"fmt"
+	"log"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Import is a single name bound by an import statement
+type Import struct {
+	Module string // Module being imported from, e.g. "a.b" or ".impl"
+	Name   string // Imported name for "from" imports, empty for plain "import x"
+	Alias  string // Local name the import is bound to
+	Line   int
+	// Dynamic imports load a module named by a string literal at runtime
+	Dynamic bool
+	Column  int    // Column of a dynamic import call
+	Call    string // Source of a dynamic import call, e.g. importlib.import_module("a.b")
+}
+
+// ImportTable maps a file path, cleaned, to the imports found in it
+type ImportTable map[string][]Import
+
+var (
+	fromImportRe  = regexp.MustCompile(`^\s*from\s+([.\w]+)\s+import\s+(.+)$`)
+	plainImportRe = regexp.MustCompile(`^\s*import\s+(.+)$`)
+	// importlib.import_module("a.b") or __import__("a.b"), relative names are skipped
+	dynamicImportRe = regexp.MustCompile(`\b(?:import_module|__import__)\(\s*["']([A-Za-z_][\w.]*)["'][^)]*\)`)
+)
+
+// splitImportNames splits "a as b, c" into (name, alias) pairs
+func splitImportNames(list string) [][2]string {
+	var pairs [][2]string
+	for _, part := range strings.Split(list, ",") {
+		fields := strings.Fields(part)
+		switch {
+		case len(fields) == 1:
+			pairs = append(pairs, [2]string{fields[0], fields[0]})
+		case len(fields) == 3 && fields[1] == "as":
+			pairs = append(pairs, [2]string{fields[0], fields[2]})
+		}
+	}
+	return pairs
+}
+
+// ParseImports extracts import bindings from the lines of a Python file.
+// Parenthesized multi-line "from" imports and ';'-separated statements are supported.
+func ParseImports(lines []string) []Import {
+	var imports []Import
+
+	for i := 0; i < len(lines); i++ {
+		lineNo := i + 1
+		line := lines[i]
+		if idx := strings.Index(line, "#"); idx != -1 {
+			line = line[:idx]
+		}
+
+		for _, loc := range dynamicImportRe.FindAllStringSubmatchIndex(line, -1) {
+			imports = append(imports, Import{
+				Module:  line[loc[2]:loc[3]],
+				Line:    lineNo,
+				Dynamic: true,
+				Column:  loc[0] + 1,
+				Call:    line[loc[0]:loc[1]],
+			})
+		}
+
+		for _, stmt := range strings.Split(line, ";") {
+			if m := fromImportRe.FindStringSubmatch(stmt); m != nil {
+				names := strings.TrimSpace(m[2])
+				if strings.HasPrefix(names, "(") {
+					names = strings.TrimPrefix(names, "(")
+					for !strings.Contains(names, ")") && i+1 < len(lines) {
+						i++
+						next := lines[i]
+						if idx := strings.Index(next, "#"); idx != -1 {
+							next = next[:idx]
+						}
+						names += "," + next
+					}
+					names = strings.SplitN(names, ")", 2)[0]
+				}
+				names = strings.TrimSuffix(strings.TrimSpace(names), "\\")
+				for _, p := range splitImportNames(names) {
+					if p[0] == "*" {
+						continue
+					}
+					imports = append(imports, Import{Module: m[1], Name: p[0], Alias: p[1], Line: lineNo})
+				}
+				continue
+			}
+			if m := plainImportRe.FindStringSubmatch(stmt); m != nil {
+				for _, p := range splitImportNames(m[1]) {
+					imports = append(imports, Import{Module: p[0], Alias: p[1], Line: lineNo})
+				}
+			}
+		}
+	}
+
+	return imports
+}
+
+// BuildImportTable parses the imports of every file, read in the given
+// encoding or a detected one when empty
+func BuildImportTable(files []File, encoding string) ImportTable {
+	table := make(ImportTable, len(files))
+	for _, file := range files {
+		src, err := readSource(file.Path, encoding)
+		if err != nil {
+			log.Printf("Error reading file %s: %v", file.Path, err)
+			continue
+		}
+		if imports := ParseImports(src.lines); len(imports) > 0 {
+			table[filepath.Clean(file.Path)] = imports
+		}
+	}
+	return table
+}
+
+type aliasSite struct {
+	File  File
+	Alias string
+}
+
+// aliasesByName indexes "from x import name as alias" bindings by the original name
+func (t ImportTable) aliasesByName(files []File) map[string][]aliasSite {
+	aliases := make(map[string][]aliasSite)
+	for _, file := range files {
+		for _, imp := range t[filepath.Clean(file.Path)] {
+			if imp.Name == "" || imp.Alias == imp.Name {
+				continue
+			}
+			aliases[imp.Name] = append(aliases[imp.Name], aliasSite{File: file, Alias: imp.Alias})
+		}
+	}
+	return aliases
+}
+
+// ModuleFileMatches reports whether module, as imported from importingFile,
+// refers to the Python file path. Relative modules are resolved against the
+// importing file's package, absolute ones by path suffix.
+func ModuleFileMatches(importingFile, module, path string) bool {
+	trimmed := strings.TrimLeft(module, ".")
+	levels := len(module) - len(trimmed)
+	rel := filepath.FromSlash(strings.ReplaceAll(trimmed, ".", "/"))
+
+	if levels > 0 {
+		base := filepath.Dir(importingFile)
+		for i := 1; i < levels; i++ {
+			base = filepath.Dir(base)
+		}
+		candidate := filepath.Join(base, rel)
+		return filepath.Clean(path) == candidate+".py" ||
+			filepath.Clean(path) == filepath.Join(candidate, "__init__.py")
+	}
+
+	path = filepath.Clean(path)
+	for _, suffix := range []string{rel + ".py", filepath.Join(rel, "__init__.py")} {
+		if path == suffix || strings.HasSuffix(path, string(filepath.Separator)+suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// fromImportOf returns the "from" import binding localName in a file, if any.
+// Search backends may report the file as ./app.py where it was walked as
+// app.py, so the path is cleaned first.
+func (t ImportTable) fromImportOf(path, localName string) (Import, bool) {
+	for _, imp := range t[filepath.Clean(path)] {
+		if imp.Name != "" && imp.Alias == localName {
+			return imp, true
+		}
+	}
+	return Import{}, false
+}
+
+// usagePath extracts the file path from a "path:line:col" location
+func usagePath(location string) string {
+	parts := strings.Split(location, ":")
+	if len(parts) >= 3 {
+		return strings.Join(parts[:len(parts)-2], ":")
+	}
+	return location
+}
+
+// usageLine extracts the line number from a "path:line:col" location
+func usageLine(location string) int {
+	parts := strings.Split(location, ":")
+	if len(parts) < 3 {
+		return 0
+	}
+	n, _ := strconv.Atoi(parts[len(parts)-2])
+	return n
+}
+
+// reexporters returns the package __init__.py files re-exporting m, directly
+// or through another package, as in "from .impl import run"
+func (t ImportTable) reexporters(m Method) []string {
+	var inits []string
+	exports := func(init string, target string) bool {
+		for _, imp := range t[init] {
+			if imp.Name == m.Name && ModuleFileMatches(init, imp.Module, target) {
+				return true
+			}
+		}
+		return false
+	}
+	targets := []string{m.Filename}
+	seen := map[string]bool{m.Filename: true}
+	for len(targets) > 0 {
+		target := targets[0]
+		targets = targets[1:]
+		for path := range t {
+			if filepath.Base(path) == "__init__.py" && !seen[path] && exports(path, target) {
+				seen[path] = true
+				inits = append(inits, path)
+				targets = append(targets, path)
+			}
+		}
+	}
+	sort.Strings(inits)
+	return inits
+}
+
+// providesMethod reports whether module, imported from importingFile, is the
+// file defining m or one of the packages re-exporting it
+func providesMethod(importingFile, module string, m Method, reexporters []string) bool {
+	if ModuleFileMatches(importingFile, module, m.Filename) {
+		return true
+	}
+	for _, init := range reexporters {
+		if ModuleFileMatches(importingFile, module, init) {
+			return true
+		}
+	}
+	return false
+}
+
+// scopeUsages drops bare calls made from files that import the name from a
+// module other than the one defining m, e.g. "from a.b import run" ties
+// every run() in that file to a/b.py. Imports through a package re-exporting
+// m are followed, and the re-export lines themselves are marked as such.
+func (t ImportTable) scopeUsages(usages []Usage, m Method, reexporters []string) []Usage {
+	scoped := usages[:0]
+	for _, u := range usages {
+		path := usagePath(u.Location)
+		if u.CallType == CallTypeFunction || u.CallType == CallTypeRead || u.Alias != "" {
+			local := m.Name
+			if u.Alias != "" {
+				local = u.Alias
+			}
+			if imp, ok := t.fromImportOf(path, local); ok && !providesMethod(path, imp.Module, m, reexporters) {
+				continue
+			}
+		}
+		if u.CallType == CallTypeImport && slices.ContainsFunc(reexporters, func(init string) bool {
+			return filepath.Clean(init) == filepath.Clean(path)
+		}) {
+			u.CallType = CallTypeReexport
+		}
+		scoped = append(scoped, u)
+	}
+	return scoped
+}
+
+// dynamicImportUsages returns the dynamic imports loading the module that
+// defines m. Only module-level functions are reachable through them.
+func (t ImportTable) dynamicImportUsages(m Method) []Usage {
+	if m.Kind != SymbolFunction || m.Class != "" || m.Parent != "" {
+		return nil
+	}
+	var usages []Usage
+	for path, imports := range t {
+		for _, imp := range imports {
+			if imp.Dynamic && ModuleFileMatches(path, imp.Module, m.Filename) {
+				usages = append(usages, Usage{
+					Location: fmt.Sprintf("%s:%d:%d", path, imp.Line, imp.Column),
+					CallType: CallTypeDynamicImport,
+					Context:  imp.Call,
+				})
+			}
+		}
+	}
+	sort.Slice(usages, func(i, j int) bool { return usages[i].Location < usages[j].Location })
+	return usages
+}
+
+// staticallyImported reports whether any import statement loads the module
+// defining m, or a name from it
+func (t ImportTable) staticallyImported(m Method) bool {
+	for path, imports := range t {
+		for _, imp := range imports {
+			if imp.Dynamic {
+				continue
+			}
+			if ModuleFileMatches(path, imp.Module, m.Filename) ||
+				(imp.Name != "" && ModuleFileMatches(path, imp.Module+"."+imp.Name, m.Filename)) {
+				return true
+			}
+		}
+	}
+	return false
+}
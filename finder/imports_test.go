@@ -0,0 +1,185 @@
+package finder
+
+import (
+	"context"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseImports(t *testing.T) {
+	src := `import os
+import numpy as np, sys
+from utils import calc as c; c()
+from .impl import (
+    run,
+    stop as halt,  # renamed
+)
+from pkg import *
+`
+	got := ParseImports(strings.Split(src, "\n"))
+	want := []Import{
+		{Module: "os", Alias: "os", Line: 1},
+		{Module: "numpy", Alias: "np", Line: 2},
+		{Module: "sys", Alias: "sys", Line: 2},
+		{Module: "utils", Name: "calc", Alias: "c", Line: 3},
+		{Module: ".impl", Name: "run", Alias: "run", Line: 4},
+		{Module: ".impl", Name: "stop", Alias: "halt", Line: 4},
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("ParseImports mismatch\n got: %#v\nwant: %#v", got, want)
+	}
+}
+
+func TestModuleFileMatches(t *testing.T) {
+	cases := []struct {
+		importing, module, path string
+		want                    bool
+	}{
+		{"/repo/app/main.py", "a.b", "/repo/a/b.py", true},
+		{"/repo/app/main.py", "a.b", "/repo/a/b/__init__.py", true},
+		{"/repo/app/main.py", "a.b", "/repo/c/b.py", false},
+		{"/repo/app/main.py", "a.b", "/repo/xa/b.py", false},
+		{"/repo/app/main.py", ".util", "/repo/app/util.py", true},
+		{"/repo/app/main.py", "..lib.util", "/repo/lib/util.py", true},
+		{"/repo/app/main.py", ".util", "/repo/lib/util.py", false},
+	}
+
+	for _, c := range cases {
+		if got := ModuleFileMatches(c.importing, c.module, c.path); got != c.want {
+			t.Errorf("ModuleFileMatches(%q, %q, %q) = %v, want %v", c.importing, c.module, c.path, got, c.want)
+		}
+	}
+}
+
+func TestDynamicImportUsages(t *testing.T) {
+	dir := t.TempDir()
+	plugin := writeTestFile(t, dir, "plugins/csv_export.py", "def export(rows):\n    return rows\n")
+	loader := writeTestFile(t, dir, "loader.py", `import importlib
+
+mod = importlib.import_module("plugins.csv_export")  # loaded by name
+legacy = __import__('plugins.csv_export', fromlist=["export"])
+other = importlib.import_module("plugins.json_export")
+`)
+	helper := writeTestFile(t, dir, "helper.py", "def export():\n    pass\n")
+
+	var files []File
+	for _, p := range []string{plugin, loader, helper} {
+		files = append(files, File{Dir: filepath.Dir(p), Base: filepath.Base(p), Path: p})
+	}
+	table := BuildImportTable(files, "")
+
+	usages := table.dynamicImportUsages(Method{Name: "export", Filename: plugin, Kind: SymbolFunction})
+	if len(usages) != 2 {
+		t.Fatalf("dynamicImportUsages() = %v, want 2 usages", usages)
+	}
+	if usages[0].Location != loader+":3:17" || usages[0].Context != `import_module("plugins.csv_export")` {
+		t.Errorf("first usage = %+v", usages[0])
+	}
+	if table.staticallyImported(Method{Name: "export", Filename: plugin}) {
+		t.Error("staticallyImported() = true for a module only loaded dynamically")
+	}
+	if got := table.dynamicImportUsages(Method{Name: "export", Filename: helper, Kind: SymbolFunction}); len(got) != 0 {
+		t.Errorf("dynamicImportUsages(helper) = %v, want none", got)
+	}
+	if got := table.dynamicImportUsages(Method{Name: "export", Filename: plugin, Kind: SymbolFunction, Class: "Plugin"}); len(got) != 0 {
+		t.Errorf("dynamicImportUsages(method) = %v, want none", got)
+	}
+}
+
+func TestScopeUsages_Reexports(t *testing.T) {
+	dir := t.TempDir()
+	impl := writeTestFile(t, dir, "pkg/impl.py", "def run():\n    pass\n")
+	init := writeTestFile(t, dir, "pkg/__init__.py", "from .impl import run\n")
+	top := writeTestFile(t, dir, "api/__init__.py", "from pkg import run\n")
+	app := writeTestFile(t, dir, "app.py", "from api import run\nrun()\n")
+	other := writeTestFile(t, dir, "other.py", "from elsewhere import run\nrun()\n")
+
+	var files []File
+	for _, p := range []string{impl, init, top, app, other} {
+		files = append(files, File{Dir: filepath.Dir(p), Base: filepath.Base(p), Path: p})
+	}
+	table := BuildImportTable(files, "")
+	m := Method{Name: "run", Filename: impl, Kind: SymbolFunction}
+
+	reexporters := table.reexporters(m)
+	if want := []string{top, init}; !reflect.DeepEqual(reexporters, want) {
+		t.Fatalf("reexporters() = %v, want %v", reexporters, want)
+	}
+
+	usages := table.scopeUsages([]Usage{
+		{Location: init + ":1:19", CallType: CallTypeImport},
+		{Location: app + ":1:17", CallType: CallTypeImport},
+		{Location: app + ":2:1", CallType: CallTypeFunction},
+		{Location: other + ":2:1", CallType: CallTypeFunction},
+	}, m, reexporters)
+
+	var got []CallType
+	for _, u := range usages {
+		got = append(got, u.CallType)
+	}
+	if want := []CallType{CallTypeReexport, CallTypeImport, CallTypeFunction}; !reflect.DeepEqual(got, want) {
+		t.Errorf("scopeUsages() call types = %v, want %v", got, want)
+	}
+}
+
+func TestScopeUsages_RelativeRoot(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, dir, "lib.py", "def run():\n    pass\n")
+	writeTestFile(t, dir, "other.py", "def run():\n    pass\n")
+	writeTestFile(t, dir, "use1.py", "from other import run\nrun()\n")
+	writeTestFile(t, dir, "use2.py", "from lib import run\nrun()\n")
+	t.Chdir(dir)
+
+	files, err := ReadDir(context.Background(), ".", DirFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	table := BuildImportTable(files, "")
+	m := Method{Name: "run", Filename: "lib.py", Kind: SymbolFunction}
+
+	// ripgrep and git grep report the files of --dir . as ./use1.py
+	usages := table.scopeUsages([]Usage{
+		{Location: "./use1.py:2:1", CallType: CallTypeFunction},
+		{Location: "./use2.py:2:1", CallType: CallTypeFunction},
+	}, m, nil)
+	if len(usages) != 1 || usages[0].Location != "./use2.py:2:1" {
+		t.Errorf("scopeUsages() = %v, want the call in use2.py only", usages)
+	}
+
+	if _, err := exec.LookPath("rg"); err != nil {
+		t.Skip("ripgrep is not installed")
+	}
+	methods := FindMethods(context.Background(), files, MethodFilter{Methods: []MethodSpec{{Name: "run"}}})
+	results := AnalyzeMethodUsages(context.Background(), methods, []string{"."}, FileFilter{Engine: EngineRipgrep})
+	for _, r := range results {
+		calls := r.UsagesByType[CallTypeFunction]
+		if calls != 1 {
+			t.Errorf("%s:%s has %d function calls, want 1: %v", r.Method.Filename, r.Method.Name, calls, r.Usages)
+		}
+	}
+}
+
+func TestScopeUsages_RelativeReexports(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, dir, "pkg/impl.py", "def run():\n    pass\n")
+	writeTestFile(t, dir, "pkg/__init__.py", "from .impl import run\n")
+	t.Chdir(dir)
+
+	files, err := ReadDir(context.Background(), ".", DirFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	table := BuildImportTable(files, "")
+	m := Method{Name: "run", Filename: filepath.Join("pkg", "impl.py"), Kind: SymbolFunction}
+	reexporters := table.reexporters(m)
+
+	// Reported as ./pkg/__init__.py by ripgrep, walked as pkg/__init__.py
+	usages := table.scopeUsages([]Usage{{Location: "./pkg/__init__.py:1:19", CallType: CallTypeImport}}, m, reexporters)
+	if len(usages) != 1 || usages[0].CallType != CallTypeReexport {
+		t.Errorf("scopeUsages() = %v, want a re-export", usages)
+	}
+}
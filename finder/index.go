@@ -0,0 +1,116 @@
+package finder
+
+import (
+	"context"
+	"os"
+	"sync"
+	"time"
+)
+
+// Index keeps the analysis of a tree in memory so that queries are answered
+// without searching again. Refresh rebuilds it when any searched file was
+// added, removed or modified.
+type Index struct {
+	Dirs         []string // Directories definitions are collected from
+	SearchDirs   []string // Directories usages are searched in, Dirs when empty
+	DirFilter    DirFilter
+	MethodFilter MethodFilter
+	FileFilter   FileFilter
+
+	mu      sync.RWMutex
+	mtimes  map[string]time.Time
+	results []MethodUsage
+	built   time.Time
+}
+
+func (ix *Index) searchDirs() []string {
+	if len(ix.SearchDirs) == 0 {
+		return ix.Dirs
+	}
+	return ix.SearchDirs
+}
+
+// snapshot returns the modification time of every searched file
+func (ix *Index) snapshot(ctx context.Context) (map[string]time.Time, error) {
+	files, err := ReadDirs(ctx, append(append([]string{}, ix.Dirs...), ix.searchDirs()...), ix.DirFilter)
+	if err != nil {
+		return nil, err
+	}
+	mtimes := make(map[string]time.Time, len(files))
+	for _, file := range files {
+		if info, err := os.Stat(file.Path); err == nil {
+			mtimes[file.Path] = info.ModTime()
+		}
+	}
+	return mtimes, nil
+}
+
+func sameSnapshot(a, b map[string]time.Time) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for path, mtime := range a {
+		if other, ok := b[path]; !ok || !other.Equal(mtime) {
+			return false
+		}
+	}
+	return true
+}
+
+// Refresh rebuilds the index when the searched files changed since the last
+// build, and reports whether it did. Queries keep being answered from the
+// previous build meanwhile, and a build cancelled through the context is
+// discarded.
+func (ix *Index) Refresh(ctx context.Context) (bool, error) {
+	mtimes, err := ix.snapshot(ctx)
+	if err != nil {
+		return false, err
+	}
+	ix.mu.RLock()
+	unchanged := ix.mtimes != nil && sameSnapshot(ix.mtimes, mtimes)
+	ix.mu.RUnlock()
+	if unchanged {
+		return false, nil
+	}
+
+	files, err := ReadDirs(ctx, ix.Dirs, ix.DirFilter)
+	if err != nil {
+		return false, err
+	}
+	var results []MethodUsage
+	if methods := FindMethods(ctx, files, ix.MethodFilter); len(methods) > 0 {
+		results = AnalyzeMethodUsages(ctx, methods, ix.searchDirs(), ix.FileFilter)
+	}
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+
+	ix.mu.Lock()
+	ix.mtimes, ix.results, ix.built = mtimes, results, time.Now()
+	ix.mu.Unlock()
+	return true, nil
+}
+
+// Built returns when the index was last rebuilt, zero before the first build
+func (ix *Index) Built() time.Time {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+	return ix.built
+}
+
+// Lookup returns the indexed results of the methods matching spec, or every
+// result when spec names no method
+func (ix *Index) Lookup(spec MethodSpec) []MethodUsage {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+	if spec.Name == "" {
+		return append([]MethodUsage(nil), ix.results...)
+	}
+	var found []MethodUsage
+	for _, r := range ix.results {
+		if spec.Matches(r.Method) {
+			found = append(found, r)
+		}
+	}
+	return found
+}
@@ -0,0 +1,49 @@
+package finder
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestIndexRefresh(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, dir, "lib.py", "def helper():\n    pass\n")
+	app := writeTestFile(t, dir, "app.py", "helper()\n")
+
+	ix := &Index{Dirs: []string{dir}, FileFilter: FileFilter{Engine: EngineNative}}
+	if rebuilt, err := ix.Refresh(context.Background()); err != nil || !rebuilt {
+		t.Fatalf("first Refresh = %v, %v, want a build", rebuilt, err)
+	}
+	if rebuilt, _ := ix.Refresh(context.Background()); rebuilt {
+		t.Error("Refresh rebuilt an unchanged tree")
+	}
+	usages := func() int {
+		found := ix.Lookup(ParseMethodSpec("helper"))
+		if len(found) != 1 {
+			t.Fatalf("Lookup(helper) = %d results, want 1", len(found))
+		}
+		return found[0].TotalUsages
+	}
+	if got := usages(); got != 2 {
+		t.Errorf("helper usages = %d, want 2", got)
+	}
+
+	if err := os.WriteFile(app, []byte("helper()\nhelper()\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	later := time.Now().Add(time.Second)
+	if err := os.Chtimes(app, later, later); err != nil {
+		t.Fatal(err)
+	}
+	if rebuilt, err := ix.Refresh(context.Background()); err != nil || !rebuilt {
+		t.Fatalf("Refresh after an edit = %v, %v, want a rebuild", rebuilt, err)
+	}
+	if got := usages(); got != 3 {
+		t.Errorf("helper usages after the edit = %d, want 3", got)
+	}
+	if got := len(ix.Lookup(MethodSpec{})); got != 1 {
+		t.Errorf("Lookup of every method = %d results, want 1", got)
+	}
+}
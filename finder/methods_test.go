@@ -0,0 +1,267 @@
+package finder
+
+import (
+	"context"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestFindMethods_Decorators(t *testing.T) {
+	dir := t.TempDir()
+	p := writeTestFile(t, dir, "app.py", `import pytest
+
+@pytest.fixture
+def client():
+    pass
+
+@app.route(
+    "/health",
+    methods=["GET"],
+)
+# health check
+def health():
+    pass
+
+@staticmethod
+def helper():
+    pass
+
+def plain():
+    pass
+`)
+	files := []File{{Dir: dir, Base: filepath.Base(p), Path: p}}
+
+	all := FindMethods(context.Background(), files, MethodFilter{})
+	decorators := make(map[string][]string)
+	for _, m := range all {
+		decorators[m.Name] = m.Decorators
+	}
+	if got := decorators["health"]; len(got) != 1 || got[0] != "app.route" {
+		t.Errorf("health decorators = %v, want [app.route]", got)
+	}
+	if got := decorators["plain"]; len(got) != 0 {
+		t.Errorf("plain decorators = %v, want none", got)
+	}
+
+	kept := FindMethods(context.Background(), files, MethodFilter{SkipDecorated: []string{"fixture", "app.route"}})
+	var names []string
+	for _, m := range kept {
+		names = append(names, m.Name)
+	}
+	if len(names) != 2 || names[0] != "helper" || names[1] != "plain" {
+		t.Errorf("FindMethods(context.Background(), SkipDecorated) = %v, want [helper plain]", names)
+	}
+}
+
+func TestFindMethods_Include(t *testing.T) {
+	dir := t.TempDir()
+	src := writeTestFile(t, dir, "src/app.py", "def run():\n    pass\n")
+	script := writeTestFile(t, dir, "scripts/tool.py", "def tool():\n    pass\n")
+	files := []File{
+		{Dir: filepath.Dir(src), Base: filepath.Base(src), Path: src, Root: dir},
+		{Dir: filepath.Dir(script), Base: filepath.Base(script), Path: script, Root: dir},
+	}
+
+	got := FindMethods(context.Background(), files, MethodFilter{Include: []string{"src/**/*.py"}})
+	if len(got) != 1 || got[0].Name != "run" {
+		t.Fatalf("FindMethods(context.Background(), Include) = %#v, want only run", got)
+	}
+}
+
+func TestFindMethods_MethodSpecs(t *testing.T) {
+	dir := t.TempDir()
+	p := writeTestFile(t, dir, "pkg/svc.py", `def run():
+    pass
+
+class Bar:
+    def baz(self):
+        pass
+
+    def run(self):
+        pass
+
+class Other:
+    def baz(self):
+        pass
+`)
+	files := []File{{Dir: filepath.Dir(p), Base: filepath.Base(p), Path: p}}
+
+	tests := []struct {
+		specs []string
+		want  []string
+	}{
+		{[]string{"run"}, []string{".run", "Bar.run"}},
+		{[]string{"Bar.baz"}, []string{"Bar.baz"}},
+		{[]string{"pkg/svc.py:Other.baz", "Bar.run"}, []string{"Bar.run", "Other.baz"}},
+		{[]string{"other.py:run"}, nil},
+	}
+	for _, tt := range tests {
+		var specs []MethodSpec
+		for _, s := range tt.specs {
+			specs = append(specs, ParseMethodSpec(s))
+		}
+		var got []string
+		for _, m := range FindMethods(context.Background(), files, MethodFilter{Methods: specs}) {
+			got = append(got, m.Class+"."+m.Name)
+		}
+		if len(got) != len(tt.want) {
+			t.Errorf("FindMethods(context.Background(), %v) = %v, want %v", tt.specs, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("FindMethods(context.Background(), %v) = %v, want %v", tt.specs, got, tt.want)
+				break
+			}
+		}
+	}
+}
+
+func TestFindMethods_EndLine(t *testing.T) {
+	dir := t.TempDir()
+	p := writeTestFile(t, dir, "app.py", `class Service:
+    def start(
+        self,
+    ):
+        if self.ready:
+            return True
+
+        return False
+
+    def stop(self): pass
+
+handler = lambda event: event
+`)
+	files := []File{{Dir: dir, Base: filepath.Base(p), Path: p}}
+
+	want := map[string][2]int{"start": {2, 8}, "stop": {10, 10}, "handler": {12, 12}}
+	for _, m := range FindMethods(context.Background(), files, MethodFilter{}) {
+		if got := [2]int{m.LineNo, m.EndLine}; got != want[m.Name] {
+			t.Errorf("%s range = %v, want %v", m.Name, got, want[m.Name])
+		}
+	}
+}
+
+func TestFindMethods_Nested(t *testing.T) {
+	dir := t.TempDir()
+	p := writeTestFile(t, dir, "app.py", `def outer():
+    def inner():
+        def innermost():
+            pass
+    return inner
+
+class Service:
+    def run(self):
+        def step():
+            pass
+`)
+	files := []File{{Dir: dir, Base: filepath.Base(p), Path: p}}
+
+	want := map[string]string{"outer": "", "inner": "outer", "innermost": "inner", "run": "", "step": "run"}
+	for _, m := range FindMethods(context.Background(), files, MethodFilter{}) {
+		if m.Parent != want[m.Name] {
+			t.Errorf("%s parent = %q, want %q", m.Name, m.Parent, want[m.Name])
+		}
+	}
+}
+
+func TestFindMethods_Overloads(t *testing.T) {
+	dir := t.TempDir()
+	p := writeTestFile(t, dir, "conv.py", `from typing import overload
+
+@overload
+def parse(value: int) -> int: ...
+@typing.overload
+def parse(value: str) -> str: ...
+def parse(value):
+    return value
+
+class Proto:
+    @overload
+    def get(self, key: int) -> int: ...
+    @overload
+    def get(self, key: str) -> str: ...
+`)
+	files := []File{{Dir: dir, Base: filepath.Base(p), Path: p}}
+
+	got := make(map[string]Method)
+	for _, m := range FindMethods(context.Background(), files, MethodFilter{}) {
+		if _, dup := got[m.Name]; dup {
+			t.Fatalf("%s reported more than once", m.Name)
+		}
+		got[m.Name] = m
+	}
+	if m := got["parse"]; m.LineNo != 7 || !slices.Equal(m.Overloads, []int{4, 6}) {
+		t.Errorf("parse = line %d overloads %v, want line 7 overloads [4 6]", m.LineNo, m.Overloads)
+	}
+	if m := got["get"]; m.LineNo != 12 || !slices.Equal(m.Overloads, []int{12, 14}) {
+		t.Errorf("get = line %d overloads %v, want line 12 overloads [12 14]", m.LineNo, m.Overloads)
+	}
+}
+
+func TestFindMethods_Binding(t *testing.T) {
+	dir := t.TempDir()
+	p := writeTestFile(t, dir, "shapes.py", `def area(shape):
+    return shape.area()
+
+class Circle:
+    def area(self):
+        return 3.14
+
+    @staticmethod
+    def unit():
+        return Circle()
+
+    @classmethod
+    def from_diameter(cls, d):
+        return cls()
+`)
+	files := []File{{Dir: dir, Base: filepath.Base(p), Path: p}}
+
+	got := make(map[string]Binding)
+	for _, m := range FindMethods(context.Background(), files, MethodFilter{}) {
+		got[m.Class+"."+m.Name] = m.Binding
+	}
+	want := map[string]Binding{
+		".area":                "",
+		"Circle.area":          BindingInstance,
+		"Circle.unit":          BindingStatic,
+		"Circle.from_diameter": BindingClass,
+	}
+	for name, binding := range want {
+		if got[name] != binding {
+			t.Errorf("%s binding = %q, want %q", name, got[name], binding)
+		}
+	}
+}
+
+func TestFindMethods_SkipsStrings(t *testing.T) {
+	dir := t.TempDir()
+	p := writeTestFile(t, dir, "lib.py", `"""Helpers.
+
+    def example():
+        pass
+"""
+
+# def commented():
+
+TEMPLATE = "def inline(): pass"
+
+
+def real():
+    """Call it as:
+
+    def usage(): real()
+    """
+`)
+	files := []File{{Dir: dir, Base: filepath.Base(p), Path: p}}
+
+	var names []string
+	for _, m := range FindMethods(context.Background(), files, MethodFilter{}) {
+		names = append(names, m.Name)
+	}
+	if !slices.Equal(names, []string{"real"}) {
+		t.Errorf("FindMethods() = %v, want [real]", names)
+	}
+}
@@ -0,0 +1,85 @@
+package finder
+
+import "strings"
+
+// Metrics are size and shape measures of a function body
+type Metrics struct {
+	Lines      int `json:"lines"`      // From the def line to the last line of code
+	Branches   int `json:"branches"`   // Decision points: if, elif, for, while, except, case, and, or
+	Nesting    int `json:"nesting"`    // Deepest block nesting inside the body
+	Complexity int `json:"complexity"` // Cyclomatic complexity, 1 + Branches
+}
+
+var branchKeywords = map[string]bool{
+	"if": true, "elif": true, "for": true, "while": true,
+	"except": true, "case": true, "and": true, "or": true,
+}
+
+// measureFunction computes the metrics of the def starting at lines[start]
+func measureFunction(lines []string, start int) *Metrics {
+	block, ok := extractFunction(lines, start)
+	if !ok {
+		return nil
+	}
+
+	metrics := &Metrics{}
+	var indents []int // Indentation of the open blocks
+	inString := false
+	parenDepth := 0
+	last := 0 // Index in the body of the last line of code
+	for i, line := range block.Body {
+		quotes := len(tripleQuoteRe.FindAllString(line, -1))
+		if inString || quotes > 0 {
+			if quotes%2 == 1 {
+				inString = !inString
+			}
+			last = i
+			continue
+		}
+		code := stripComment(line)
+		if strings.TrimSpace(code) == "" {
+			continue
+		}
+		last = i
+
+		// Lines continuing an open bracket do not open blocks
+		if parenDepth == 0 {
+			indent := indentOf(line)
+			for len(indents) > 0 && indent < indents[len(indents)-1] {
+				indents = indents[:len(indents)-1]
+			}
+			if len(indents) == 0 || indent > indents[len(indents)-1] {
+				indents = append(indents, indent)
+			}
+			metrics.Nesting = max(metrics.Nesting, len(indents)-1)
+		}
+		parenDepth += strings.Count(code, "(") + strings.Count(code, "[") + strings.Count(code, "{") -
+			strings.Count(code, ")") - strings.Count(code, "]") - strings.Count(code, "}")
+		parenDepth = max(parenDepth, 0)
+
+		for k, tok := range tokenRe.FindAllString(code, -1) {
+			// "case" is a soft keyword, only a match arm when it starts the line
+			if branchKeywords[tok] && (tok != "case" || k == 0) {
+				metrics.Branches++
+			}
+		}
+	}
+
+	metrics.Lines = block.HeaderLines
+	if !block.Inline && len(block.Body) > 0 {
+		metrics.Lines += last + 1
+	}
+	metrics.Complexity = 1 + metrics.Branches
+	return metrics
+}
+
+// FilterByComplexity keeps the results whose complexity is at least minComplexity
+func FilterByComplexity(results []MethodUsage, minComplexity int) []MethodUsage {
+	var filtered []MethodUsage
+	for _, result := range results {
+		if m := result.Method.Metrics; m != nil && m.Complexity >= minComplexity {
+			filtered = append(filtered, result)
+		}
+	}
+	return filtered
+}
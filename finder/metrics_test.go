@@ -0,0 +1,39 @@
+package finder
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMeasureFunction(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want Metrics
+	}{
+		{"inline", "def f(x): return x\n", Metrics{Lines: 1, Complexity: 1}},
+		{"straight", "def f(x):\n    \"\"\"Docs\n    if x:\n    \"\"\"\n    return x\n\n# trailing\ndef g():\n    pass\n", Metrics{Lines: 5, Complexity: 1}},
+		{"branches", `def f(items, flag):
+    for item in items:
+        if item and flag:
+            try:
+                call(
+                    item,
+                )
+            except ValueError:
+                pass
+        elif item or None:
+            pass
+    return [i for i in items if i]
+`, Metrics{Lines: 12, Branches: 8, Nesting: 3, Complexity: 9}},
+		{"match", "def f(case):\n    match case:\n        case 1:\n            return case\n        case _:\n            pass\n", Metrics{Lines: 6, Branches: 2, Nesting: 2, Complexity: 3}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := measureFunction(strings.Split(tt.src, "\n"), 0)
+			if got == nil || *got != tt.want {
+				t.Errorf("measureFunction() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
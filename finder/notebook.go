@@ -0,0 +1,89 @@
+package finder
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// sourceFile holds the lines analyzed for a file. For notebooks the lines are
+// the code cells concatenated, and cells maps each line back to its cell.
+type sourceFile struct {
+	lines []string
+	cells []int // 1-based cell index per line, nil for plain Python files
+}
+
+// cellOf returns the notebook cell of a 1-based line number, 0 for plain files
+func (s sourceFile) cellOf(lineNo int) int {
+	if lineNo < 1 || lineNo > len(s.cells) {
+		return 0
+	}
+	return s.cells[lineNo-1]
+}
+
+type notebookCell struct {
+	CellType string          `json:"cell_type"`
+	Source   json.RawMessage `json:"source"`
+}
+
+type notebookDoc struct {
+	Cells []notebookCell `json:"cells"`
+}
+
+func isNotebook(path string) bool {
+	return strings.HasSuffix(path, ".ipynb")
+}
+
+// notebookCellSource decodes a cell source, stored either as one string or as
+// a list of lines
+func notebookCellSource(raw json.RawMessage) (string, error) {
+	var text string
+	if err := json.Unmarshal(raw, &text); err == nil {
+		return text, nil
+	}
+	var parts []string
+	if err := json.Unmarshal(raw, &parts); err != nil {
+		return "", err
+	}
+	return strings.Join(parts, ""), nil
+}
+
+// parseNotebook extracts the code cells of a .ipynb document. IPython magics
+// and shell escapes are blanked so they neither match nor shift line numbers.
+func parseNotebook(data []byte) (sourceFile, error) {
+	var doc notebookDoc
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return sourceFile{}, fmt.Errorf("invalid notebook: %w", err)
+	}
+
+	var src sourceFile
+	for i, cell := range doc.Cells {
+		if cell.CellType != "code" {
+			continue
+		}
+		text, err := notebookCellSource(cell.Source)
+		if err != nil {
+			return sourceFile{}, fmt.Errorf("invalid notebook cell %d: %w", i+1, err)
+		}
+		for _, line := range strings.Split(strings.TrimSuffix(text, "\n"), "\n") {
+			if trimmed := strings.TrimSpace(line); strings.HasPrefix(trimmed, "%") || strings.HasPrefix(trimmed, "!") {
+				line = ""
+			}
+			src.lines = append(src.lines, line)
+			src.cells = append(src.cells, i+1)
+		}
+	}
+	return src, nil
+}
+
+// readSource reads the analyzable lines of a Python file or notebook
+func readSource(path, encoding string) (sourceFile, error) {
+	data, err := readEntireFile(path)
+	if err != nil {
+		return sourceFile{}, err
+	}
+	if isNotebook(path) {
+		return parseNotebook(data)
+	}
+	return sourceFile{lines: strings.Split(decodeSource(data, encoding), "\n")}, nil
+}
@@ -0,0 +1,31 @@
+package finder
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseNotebook(t *testing.T) {
+	data := []byte(`{"cells": [
+ {"cell_type": "markdown", "source": ["# Title\n", "run(1)"]},
+ {"cell_type": "code", "source": ["%matplotlib inline\n", "import lib\n", "lib.run(2)\n"]},
+ {"cell_type": "code", "source": "def helper():\n    return run(3)"}
+]}`)
+
+	src, err := parseNotebook(data)
+	if err != nil {
+		t.Fatalf("parseNotebook: %v", err)
+	}
+
+	wantLines := []string{"", "import lib", "lib.run(2)", "def helper():", "    return run(3)"}
+	wantCells := []int{2, 2, 2, 3, 3}
+	if !reflect.DeepEqual(src.lines, wantLines) {
+		t.Errorf("lines = %q, want %q", src.lines, wantLines)
+	}
+	if !reflect.DeepEqual(src.cells, wantCells) {
+		t.Errorf("cells = %v, want %v", src.cells, wantCells)
+	}
+	if got := src.cellOf(5); got != 3 {
+		t.Errorf("cellOf(5) = %d, want 3", got)
+	}
+}
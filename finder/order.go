@@ -0,0 +1,80 @@
+package finder
+
+import (
+	"cmp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Results are made independent of the order searches complete and backends
+// report matches in, so that two runs over the same tree print the same
+// output and can be diffed.
+
+// sortMatches orders vimgrep lines by file, line and column
+func sortMatches(lines []string) {
+	slices.SortStableFunc(lines, func(a, b string) int {
+		return compareLocations(matchLocation(a), matchLocation(b))
+	})
+}
+
+// sortUsages orders usages by location, then by call type
+func sortUsages(usages []Usage) {
+	slices.SortStableFunc(usages, func(a, b Usage) int {
+		return cmp.Or(compareLocations(a.Location, b.Location), strings.Compare(string(a.CallType), string(b.CallType)))
+	})
+}
+
+// matchLocation returns the "path:line:col" prefix of a vimgrep line
+func matchLocation(line string) string {
+	parts := strings.SplitN(line, ":", 4)
+	if len(parts) < 4 {
+		return line
+	}
+	return strings.Join(parts[:3], ":")
+}
+
+// compareLocations orders "path:line:col" locations, comparing line and
+// column numbers as numbers
+func compareLocations(a, b string) int {
+	return cmp.Or(
+		strings.Compare(usagePath(a), usagePath(b)),
+		cmp.Compare(usageLine(a), usageLine(b)),
+		cmp.Compare(usageColumn(a), usageColumn(b)),
+	)
+}
+
+// usageColumn extracts the column from a "path:line:col" location
+func usageColumn(location string) int {
+	i := strings.LastIndexByte(location, ':')
+	if i < 0 {
+		return 0
+	}
+	n, _ := strconv.Atoi(location[i+1:])
+	return n
+}
+
+// compareMethods orders definitions by where they are, as the last resort of
+// every sort so that no two results are left in an arbitrary order
+func compareMethods(a, b Method) int {
+	return cmp.Or(
+		strings.Compare(a.Filename, b.Filename),
+		cmp.Compare(a.LineNo, b.LineNo),
+		strings.Compare(string(a.Kind), string(b.Kind)),
+		strings.Compare(a.Class, b.Class),
+		strings.Compare(a.Name, b.Name),
+	)
+}
+
+// TopResults returns the n most used results, most used first, and the n
+// least used, least used first. A result is in one list at most, so the least
+// used are fewer than n when there are fewer than 2n results.
+func TopResults(results []MethodUsage, n int) (most, least []MethodUsage) {
+	sorted := slices.Clone(results)
+	SortResults(sorted, "usages", false)
+	n = min(n, len(sorted))
+	most = sorted[:n]
+	least = slices.Clone(sorted[max(n, len(sorted)-n):])
+	slices.Reverse(least)
+	return most, least
+}
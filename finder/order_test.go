@@ -0,0 +1,76 @@
+package finder
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestSortMatches(t *testing.T) {
+	lines := []string{
+		"b.py:2:1:load()",
+		"a.py:10:5:load()",
+		"a.py:9:12:x = load()",
+		"a.py:9:3:load(load())",
+	}
+	sortMatches(lines)
+	want := []string{
+		"a.py:9:3:load(load())",
+		"a.py:9:12:x = load()",
+		"a.py:10:5:load()",
+		"b.py:2:1:load()",
+	}
+	if !slices.Equal(lines, want) {
+		t.Errorf("sortMatches = %q, want %q", lines, want)
+	}
+}
+
+func TestSortResultsBreaksTies(t *testing.T) {
+	results := []MethodUsage{
+		{Method: Method{Name: "run", Filename: "b.py", LineNo: 1}, TotalUsages: 2},
+		{Method: Method{Name: "run", Filename: "a.py", LineNo: 7}, TotalUsages: 2},
+		{Method: Method{Name: "run", Filename: "a.py", LineNo: 3}, TotalUsages: 2},
+	}
+	for _, asc := range []bool{true, false} {
+		got := slices.Clone(results)
+		SortResults(got, "usages", asc)
+		var order []string
+		for _, r := range got {
+			order = append(order, r.Method.Filename)
+		}
+		want := []string{"a.py", "a.py", "b.py"}
+		if !asc {
+			want = []string{"b.py", "a.py", "a.py"}
+		}
+		if !slices.Equal(order, want) || (asc && got[0].Method.LineNo != 3) || (!asc && got[1].Method.LineNo != 7) {
+			t.Errorf("SortResults(asc=%v) = %+v", asc, got)
+		}
+	}
+}
+
+func TestTopResults(t *testing.T) {
+	var results []MethodUsage
+	for i, usages := range []int{3, 0, 7, 1, 5} {
+		results = append(results, MethodUsage{Method: Method{Name: string(rune('a' + i)), Filename: "a.py", LineNo: i + 1}, TotalUsages: usages})
+	}
+	names := func(results []MethodUsage) string {
+		var s string
+		for _, r := range results {
+			s += r.Method.Name
+		}
+		return s
+	}
+
+	for _, tc := range []struct {
+		n           int
+		most, least string
+	}{
+		{2, "ce", "bd"},
+		{3, "cea", "bd"},
+		{10, "ceadb", ""},
+	} {
+		most, least := TopResults(results, tc.n)
+		if names(most) != tc.most || names(least) != tc.least {
+			t.Errorf("TopResults(%d) = %q, %q, want %q, %q", tc.n, names(most), names(least), tc.most, tc.least)
+		}
+	}
+}
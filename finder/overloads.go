@@ -0,0 +1,42 @@
+package finder
+
+// overloadDecorators mark typing.overload signatures
+var overloadDecorators = []string{"overload"}
+
+// groupOverloads folds the @overload signatures of a file into the
+// implementation that follows them, so a name is reported once with the
+// overload lines attached. Signatures without an implementation, as in stubs
+// and protocols, are reported as their first signature.
+func groupOverloads(methods []Method) []Method {
+	type scopedName struct{ class, parent, name string }
+	pending := make(map[scopedName][]int)
+	first := make(map[scopedName]Method)
+	var order []scopedName
+
+	var grouped []Method
+	for _, m := range methods {
+		key := scopedName{m.Class, m.Parent, m.Name}
+		if m.Kind == SymbolFunction && hasDecorator(m.Decorators, overloadDecorators) {
+			if _, ok := pending[key]; !ok {
+				first[key] = m
+				order = append(order, key)
+			}
+			pending[key] = append(pending[key], m.LineNo)
+			continue
+		}
+		if lines, ok := pending[key]; ok {
+			m.Overloads = lines
+			delete(pending, key)
+		}
+		grouped = append(grouped, m)
+	}
+
+	for _, key := range order {
+		if lines, ok := pending[key]; ok {
+			m := first[key]
+			m.Overloads = lines
+			grouped = append(grouped, m)
+		}
+	}
+	return grouped
+}
@@ -0,0 +1,254 @@
+package finder
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// PackageSummary rolls the results of a Python package or module up
+type PackageSummary struct {
+	Package   string  `json:"package"`
+	Methods   int     `json:"total_methods"`
+	Unused    int     `json:"unused"`     // Methods reported unused, see IsUnused
+	Usages    int     `json:"usages"`     // Real usages of all its methods
+	Density   float64 `json:"density"`    // Real usages per method
+	DeadRatio float64 `json:"dead_ratio"` // Share of unused methods
+}
+
+// packageRoots caches the import roots of directories, see importRoot
+type packageRoots map[string]string
+
+// importRoot returns the directory the dotted names of the modules in dir
+// start from: the parent of the outermost directory of the chain of packages,
+// directories holding an __init__.py, ending at dir. It reports false when dir
+// is not a package.
+func (roots packageRoots) importRoot(dir string) (string, bool) {
+	if root, ok := roots[dir]; ok {
+		return root, root != ""
+	}
+	root := ""
+	if _, err := os.Stat(filepath.Join(dir, "__init__.py")); err == nil {
+		root = filepath.Dir(dir)
+		if root != dir {
+			if parent, ok := roots.importRoot(root); ok {
+				root = parent
+			}
+		}
+	}
+	roots[dir] = root
+	return root, root != ""
+}
+
+// dottedPath converts a path to a Python dotted name, relative to the import
+// root of its package, or to its root when not in a package
+func dottedPath(m Method, module bool, roots packageRoots) string {
+	rel := m.Filename
+	base := m.Root
+	if root, ok := roots.importRoot(filepath.Dir(m.Filename)); ok {
+		base = root
+	}
+	if base != "" {
+		if r, err := filepath.Rel(base, m.Filename); err == nil {
+			rel = r
+		}
+	}
+	if module && filepath.Base(rel) != "__init__.py" {
+		rel = strings.TrimSuffix(rel, filepath.Ext(rel))
+	} else {
+		rel = filepath.Dir(rel)
+	}
+	if rel == "." {
+		return "."
+	}
+	return strings.ReplaceAll(filepath.ToSlash(rel), "/", ".")
+}
+
+// ModuleName is the dotted name of the module defining m
+func ModuleName(m Method) string {
+	return dottedPath(m, true, packageRoots{})
+}
+
+func aggregate(results []MethodUsage, module bool) []PackageSummary {
+	byName := make(map[string]*PackageSummary)
+	roots := packageRoots{}
+	for _, result := range results {
+		name := dottedPath(result.Method, module, roots)
+		summary, ok := byName[name]
+		if !ok {
+			summary = &PackageSummary{Package: name}
+			byName[name] = summary
+		}
+		summary.Methods++
+		summary.Usages += RealUsages(result)
+		if IsUnused(result) {
+			summary.Unused++
+		}
+	}
+
+	summaries := make([]PackageSummary, 0, len(byName))
+	for _, summary := range byName {
+		summary.Density = float64(summary.Usages) / float64(summary.Methods)
+		summary.DeadRatio = float64(summary.Unused) / float64(summary.Methods)
+		summaries = append(summaries, *summary)
+	}
+	sort.Slice(summaries, func(i, j int) bool { return summaries[i].Package < summaries[j].Package })
+	return summaries
+}
+
+// FileSummary rolls the results of a defining file up, keeping the results
+type FileSummary struct {
+	File      string        `json:"file"`
+	Methods   int           `json:"total_methods"`
+	Unused    int           `json:"unused"`     // Methods reported unused, see IsUnused
+	Usages    int           `json:"usages"`     // Real usages of all its methods
+	DeadRatio float64       `json:"dead_ratio"` // Share of unused methods
+	Results   []MethodUsage `json:"results"`
+}
+
+// AggregateByFile groups results by the file defining them, sorted by path.
+// Results keep their order within a file.
+func AggregateByFile(results []MethodUsage) []FileSummary {
+	byFile := make(map[string]*FileSummary)
+	for _, result := range results {
+		summary, ok := byFile[result.Method.Filename]
+		if !ok {
+			summary = &FileSummary{File: result.Method.Filename}
+			byFile[result.Method.Filename] = summary
+		}
+		summary.Methods++
+		summary.Usages += RealUsages(result)
+		if IsUnused(result) {
+			summary.Unused++
+		}
+		summary.Results = append(summary.Results, result)
+	}
+
+	summaries := make([]FileSummary, 0, len(byFile))
+	for _, summary := range byFile {
+		summary.DeadRatio = float64(summary.Unused) / float64(summary.Methods)
+		summaries = append(summaries, *summary)
+	}
+	sort.Slice(summaries, func(i, j int) bool { return summaries[i].File < summaries[j].File })
+	return summaries
+}
+
+// AggregateByPackage groups results by the package defining them. Packages
+// are named from the outermost directory holding an __init__.py, so that
+// "src/app/models" is "app.models" in a src layout; directories that are not
+// packages are named from the root they were found in.
+func AggregateByPackage(results []MethodUsage) []PackageSummary {
+	return aggregate(results, false)
+}
+
+// AggregateByModule groups results by the module (file) defining them
+func AggregateByModule(results []MethodUsage) []PackageSummary {
+	return aggregate(results, true)
+}
+
+// ResultGroup is a section of the results, printed under its name
+type ResultGroup struct {
+	Name    string        `json:"name"`
+	Results []MethodUsage `json:"results"`
+}
+
+// GroupByCallType groups results by the call types they are used with,
+// definitions aside, so that methods only used as decorators or never used
+// at all end up together. Groups follow GetCallTypeOrder, the group of
+// methods without usages first; results keep their order within a group.
+func GroupByCallType(results []MethodUsage) []ResultGroup {
+	order := make(map[CallType]int)
+	for i, ct := range GetCallTypeOrder() {
+		order[ct] = i
+	}
+	type group struct {
+		ResultGroup
+		key []int
+	}
+	var groups []*group
+	byName := make(map[string]*group)
+	for _, result := range results {
+		var types []CallType
+		for ct, n := range result.UsagesByType {
+			if n > 0 && ct != CallTypeDefinition {
+				types = append(types, ct)
+			}
+		}
+		sort.Slice(types, func(i, j int) bool { return order[types[i]] < order[types[j]] })
+
+		name := "no usages"
+		key := make([]int, len(types))
+		if len(types) > 0 {
+			labels := make([]string, len(types))
+			for i, ct := range types {
+				labels[i] = string(ct)
+				key[i] = order[ct]
+			}
+			name = strings.Join(labels, " + ")
+		}
+		g, ok := byName[name]
+		if !ok {
+			g = &group{ResultGroup: ResultGroup{Name: name}, key: key}
+			byName[name] = g
+			groups = append(groups, g)
+		}
+		g.Results = append(g.Results, result)
+	}
+
+	sort.SliceStable(groups, func(i, j int) bool {
+		a, b := groups[i].key, groups[j].key
+		for k := 0; k < len(a) && k < len(b); k++ {
+			if a[k] != b[k] {
+				return a[k] < b[k]
+			}
+		}
+		return len(a) < len(b)
+	})
+	sections := make([]ResultGroup, len(groups))
+	for i, g := range groups {
+		sections[i] = g.ResultGroup
+	}
+	return sections
+}
+
+// GroupByDir groups results by the top-level directory of their source root
+// holding the defining file, sorted by path; files directly in a root are
+// grouped under the root. Results keep their order within a directory.
+func GroupByDir(results []MethodUsage) []ResultGroup {
+	byDir := make(map[string]*ResultGroup)
+	for _, result := range results {
+		dir := topLevelDir(result.Method)
+		g, ok := byDir[dir]
+		if !ok {
+			g = &ResultGroup{Name: dir}
+			byDir[dir] = g
+		}
+		g.Results = append(g.Results, result)
+	}
+
+	groups := make([]ResultGroup, 0, len(byDir))
+	for _, g := range byDir {
+		groups = append(groups, *g)
+	}
+	sort.Slice(groups, func(i, j int) bool { return groups[i].Name < groups[j].Name })
+	return groups
+}
+
+// topLevelDir returns the directory directly under the root of m holding its
+// file, the root itself for files at its top, or the directory of the file
+// when it has no root
+func topLevelDir(m Method) string {
+	if m.Root == "" {
+		return filepath.Dir(m.Filename)
+	}
+	rel, err := filepath.Rel(m.Root, m.Filename)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return filepath.Dir(m.Filename)
+	}
+	first, _, nested := strings.Cut(filepath.ToSlash(rel), "/")
+	if !nested {
+		return m.Root
+	}
+	return filepath.Join(m.Root, first)
+}
@@ -0,0 +1,182 @@
+package finder
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestAggregateByPackage(t *testing.T) {
+	root := filepath.FromSlash("/repo")
+	result := func(path string, types ...CallType) MethodUsage {
+		r := MethodUsage{
+			Method:       Method{Filename: filepath.Join(root, filepath.FromSlash(path)), Root: root},
+			UsagesByType: make(map[CallType]int),
+		}
+		for _, ct := range types {
+			r.Usages = append(r.Usages, Usage{CallType: ct})
+			r.UsagesByType[ct]++
+		}
+		return r
+	}
+	results := []MethodUsage{
+		result("app/models/user.py", CallTypeDefinition, CallTypeInstance, CallTypeInstance),
+		result("app/models/user.py", CallTypeDefinition),
+		result("app/models/__init__.py", CallTypeDefinition, CallTypeFunction),
+		result("manage.py", CallTypeDefinition, CallTypeImport),
+	}
+
+	want := []PackageSummary{
+		{Package: ".", Methods: 1, Unused: 1, Usages: 0, Density: 0, DeadRatio: 1},
+		{Package: "app.models", Methods: 3, Unused: 1, Usages: 3, Density: 1, DeadRatio: 1.0 / 3},
+	}
+	if got := AggregateByPackage(results); !reflect.DeepEqual(got, want) {
+		t.Errorf("AggregateByPackage() = %+v, want %+v", got, want)
+	}
+
+	// Implicitly used definitions and failed searches are not unused
+	dunder := result("manage.py", CallTypeDefinition)
+	dunder.Method.Name = "__repr__"
+	failed := result("manage.py", CallTypeDefinition)
+	failed.SearchError = "rg: exit status 2"
+	if got := AggregateByPackage([]MethodUsage{dunder, failed}); len(got) != 1 || got[0].Unused != 0 {
+		t.Errorf("AggregateByPackage(dunder, failed) = %+v, want no unused method", got)
+	}
+
+	var modules []string
+	for _, s := range AggregateByModule(results) {
+		modules = append(modules, s.Package)
+	}
+	if want := []string{"app.models", "app.models.user", "manage"}; !reflect.DeepEqual(modules, want) {
+		t.Errorf("AggregateByModule() packages = %v, want %v", modules, want)
+	}
+}
+
+func TestAggregateByPackageDetectsInitFiles(t *testing.T) {
+	root := t.TempDir()
+	for _, path := range []string{
+		"src/app/__init__.py",
+		"src/app/models/__init__.py",
+		"src/app/models/user.py",
+		"scripts/deploy.py",
+	} {
+		path = filepath.Join(root, filepath.FromSlash(path))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	result := func(path string) MethodUsage {
+		return MethodUsage{Method: Method{Filename: filepath.Join(root, filepath.FromSlash(path)), Root: root}}
+	}
+	results := []MethodUsage{result("src/app/models/user.py"), result("src/app/__init__.py"), result("scripts/deploy.py")}
+
+	var packages []string
+	for _, s := range AggregateByPackage(results) {
+		packages = append(packages, s.Package)
+	}
+	if want := []string{"app", "app.models", "scripts"}; !reflect.DeepEqual(packages, want) {
+		t.Errorf("AggregateByPackage() packages = %v, want %v", packages, want)
+	}
+	if got := ModuleName(results[0].Method); got != "app.models.user" {
+		t.Errorf("ModuleName() = %q, want app.models.user", got)
+	}
+}
+
+func TestAggregateByFile(t *testing.T) {
+	result := func(file, name string, types ...CallType) MethodUsage {
+		r := MethodUsage{Method: Method{Name: name, Filename: file}, UsagesByType: make(map[CallType]int)}
+		for _, ct := range types {
+			r.Usages = append(r.Usages, Usage{CallType: ct})
+			r.UsagesByType[ct]++
+		}
+		return r
+	}
+	results := []MethodUsage{
+		result("b.py", "load", CallTypeDefinition, CallTypeFunction, CallTypeFunction),
+		result("a.py", "save", CallTypeDefinition),
+		result("b.py", "dump", CallTypeDefinition),
+		result("b.py", "parse", CallTypeDefinition, CallTypeInstance),
+		result("b.py", "__repr__", CallTypeDefinition),
+	}
+
+	got := AggregateByFile(results)
+	if len(got) != 2 || got[0].File != "a.py" || got[1].File != "b.py" {
+		t.Fatalf("AggregateByFile() files = %+v, want a.py then b.py", got)
+	}
+	b := got[1]
+	// __repr__ is implicitly used
+	if b.Methods != 4 || b.Unused != 1 || b.Usages != 3 || b.DeadRatio != 1.0/4 {
+		t.Errorf("b.py = %d methods, %d unused, %d usages, %v dead, want 4, 1, 3, 0.25", b.Methods, b.Unused, b.Usages, b.DeadRatio)
+	}
+	var names []string
+	for _, r := range b.Results {
+		names = append(names, r.Method.Name)
+	}
+	if want := []string{"load", "dump", "parse", "__repr__"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("b.py results = %v, want %v", names, want)
+	}
+}
+
+func TestGroupByCallType(t *testing.T) {
+	result := func(name string, types ...CallType) MethodUsage {
+		r := MethodUsage{Method: Method{Name: name}, UsagesByType: make(map[CallType]int)}
+		for _, ct := range types {
+			r.UsagesByType[ct]++
+		}
+		return r
+	}
+	results := []MethodUsage{
+		result("load", CallTypeDefinition, CallTypeFunction, CallTypeInstance),
+		result("cached", CallTypeDefinition, CallTypeDecorator),
+		result("save", CallTypeDefinition),
+		result("parse", CallTypeDefinition, CallTypeInstance, CallTypeFunction),
+		result("retry", CallTypeDecorator),
+	}
+
+	var got []string
+	for _, g := range GroupByCallType(results) {
+		var names []string
+		for _, r := range g.Results {
+			names = append(names, r.Method.Name)
+		}
+		got = append(got, g.Name+": "+strings.Join(names, ","))
+	}
+	want := []string{"no usages: save", "instance + function: load,parse", "decorator: cached,retry"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("GroupByCallType() = %q, want %q", got, want)
+	}
+}
+
+func TestGroupByDir(t *testing.T) {
+	root := filepath.Join("src", "app")
+	method := func(path ...string) MethodUsage {
+		return MethodUsage{Method: Method{Filename: filepath.Join(append([]string{root}, path...)...), Root: root}}
+	}
+	results := []MethodUsage{
+		method("models", "user.py"),
+		method("main.py"),
+		method("api", "v1", "routes.py"),
+		method("models", "order.py"),
+		{Method: Method{Filename: filepath.Join("lib", "util.py")}},
+	}
+
+	var got []string
+	for _, g := range GroupByDir(results) {
+		got = append(got, fmt.Sprintf("%s=%d", g.Name, len(g.Results)))
+	}
+	want := []string{
+		"lib=1",
+		root + "=1",
+		filepath.Join(root, "api") + "=1",
+		filepath.Join(root, "models") + "=2",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("GroupByDir() = %v, want %v", got, want)
+	}
+}
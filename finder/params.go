@@ -0,0 +1,213 @@
+package finder
+
+import (
+	"regexp"
+	"strings"
+)
+
+// functionBlock is the source of a def statement split into its parameter
+// list and its body
+type functionBlock struct {
+	Params      string   // Text between the signature parentheses
+	Body        []string // Inline body after the colon, or every indented line
+	HeaderLines int      // Lines spanned by the def statement up to its colon
+	Inline      bool     // The body follows the colon on the header line
+}
+
+// extractFunction reads the def statement starting at lines[start]. The
+// signature may span several lines; the body ends at the first code line
+// indented no deeper than the def itself.
+func extractFunction(lines []string, start int) (functionBlock, bool) {
+	var block functionBlock
+	open := strings.Index(lines[start], "(")
+	if open == -1 {
+		return block, false
+	}
+
+	// Collect the parameter list up to its closing parenthesis
+	var params strings.Builder
+	depth := 0
+	end, rest := -1, ""
+	for i := start; i < len(lines) && end == -1; i++ {
+		line := lines[i]
+		from := 0
+		if i == start {
+			from = open
+		}
+		for j := from; j < len(line); j++ {
+			switch line[j] {
+			case '(', '[', '{':
+				depth++
+				if depth == 1 {
+					continue
+				}
+			case ')', ']', '}':
+				depth--
+				if depth == 0 {
+					end, rest = i, line[j+1:]
+				}
+			}
+			if end != -1 {
+				break
+			}
+			params.WriteByte(line[j])
+		}
+		params.WriteByte('\n')
+	}
+	if end == -1 {
+		return block, false
+	}
+	block.Params = params.String()
+	block.HeaderLines = end - start + 1
+
+	// A body may follow the header colon on the same line: def f(x): return x
+	if idx := headerColon(rest); idx != -1 {
+		if inline := strings.TrimSpace(rest[idx+1:]); inline != "" && !strings.HasPrefix(inline, "#") {
+			block.Body = append(block.Body, inline)
+			block.Inline = true
+			return block, true
+		}
+	}
+
+	defIndent := indentOf(lines[start])
+	inString := false
+	for _, line := range lines[end+1:] {
+		trimmed := strings.TrimSpace(line)
+		if !inString && trimmed != "" && !strings.HasPrefix(trimmed, "#") && indentOf(line) <= defIndent {
+			break
+		}
+		if len(tripleQuoteRe.FindAllString(line, -1))%2 == 1 {
+			inString = !inString
+		}
+		block.Body = append(block.Body, line)
+	}
+	return block, true
+}
+
+// headerColon returns the index of the colon closing a def header, skipping
+// any bracketed return annotation
+func headerColon(s string) int {
+	depth := 0
+	for i, c := range s {
+		switch c {
+		case '(', '[', '{':
+			depth++
+		case ')', ']', '}':
+			depth--
+		case ':':
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// paramNames returns the names declared in a parameter list, without
+// annotations, defaults or star prefixes
+func paramNames(params string) []string {
+	var names []string
+	depth, from := 0, 0
+	split := func(to int) {
+		param := params[from:to]
+		if idx := strings.IndexAny(param, ":="); idx != -1 {
+			param = param[:idx]
+		}
+		param = strings.TrimLeft(strings.TrimSpace(param), "*")
+		if identRe.MatchString(param) {
+			names = append(names, param)
+		}
+	}
+	for i := 0; i < len(params); i++ {
+		switch params[i] {
+		case '(', '[', '{':
+			depth++
+		case ')', ']', '}':
+			depth--
+		case ',':
+			if depth == 0 {
+				split(i)
+				from = i + 1
+			}
+		}
+	}
+	split(len(params))
+	return names
+}
+
+var identRe = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// placeholderBody reports whether a body does nothing but document or raise,
+// as in abstract methods and protocol stubs whose parameters are never used
+func placeholderBody(body []string) bool {
+	inString := false
+	for _, line := range body {
+		trimmed := strings.TrimSpace(line)
+		quotes := len(tripleQuoteRe.FindAllString(line, -1))
+		if inString || quotes > 0 {
+			if quotes%2 == 1 {
+				inString = !inString
+			}
+			continue
+		}
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") || trimmed == "pass" || trimmed == "..." ||
+			strings.HasPrefix(trimmed, "raise NotImplementedError") {
+			continue
+		}
+		return false
+	}
+	return true
+}
+
+// stripComment drops a trailing # comment that is not inside a string literal
+func stripComment(line string) string {
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case quote != 0:
+			if c == '\\' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '#':
+			return line[:i]
+		}
+	}
+	return line
+}
+
+// unusedParams returns the parameters of the def at lines[start] that its
+// body never mentions. self, cls and names starting with an underscore are
+// conventionally unused and never reported, and neither are the parameters of
+// placeholder bodies or of functions reading locals().
+func unusedParams(lines []string, start int) []string {
+	block, ok := extractFunction(lines, start)
+	if !ok || placeholderBody(block.Body) {
+		return nil
+	}
+
+	var code strings.Builder
+	for _, line := range block.Body {
+		code.WriteString(stripComment(line))
+		code.WriteByte('\n')
+	}
+	body := code.String()
+	if strings.Contains(body, "locals()") {
+		return nil
+	}
+
+	var unused []string
+	for _, name := range paramNames(block.Params) {
+		if name == "self" || name == "cls" || strings.HasPrefix(name, "_") {
+			continue
+		}
+		if !regexp.MustCompile(`\b` + name + `\b`).MatchString(body) {
+			unused = append(unused, name)
+		}
+	}
+	return unused
+}
@@ -0,0 +1,33 @@
+package finder
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestUnusedParams(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want []string
+	}{
+		{"all used", "def f(a, b):\n    return a + b\n", nil},
+		{"one unused", "def f(a, b):\n    return a\n", []string{"b"}},
+		{"self and underscore", "    def m(self, _ctx, value):\n        pass\n        print(1)\n", []string{"value"}},
+		{"annotations and defaults", "def f(a: dict[str, int] = {}, *args, key=None, **kwargs) -> int:\n    return key(*args)\n", []string{"a", "kwargs"}},
+		{"multi-line signature", "def f(\n    a,\n    b,\n):\n    # b is ignored\n    return a\n\ndef g(b):\n    return b\n", []string{"b"}},
+		{"inline body", "def f(a, b): return b\n", []string{"a"}},
+		{"placeholder", "def f(a):\n    \"\"\"Docs.\"\"\"\n    raise NotImplementedError\n", nil},
+		{"locals", "def f(a):\n    return locals()\n", nil},
+		{"keyword only", "def f(a, *, b, c=1, /):\n    return a + c\n", []string{"b"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := unusedParams(strings.Split(tt.src, "\n"), 0)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("unusedParams() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,55 @@
+package finder
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// RelocatePaths rewrites with relocate the paths of the definitions of
+// results, of their roots and of the locations of their usages
+func RelocatePaths(results []MethodUsage, relocate func(path string) string) {
+	for i := range results {
+		m := &results[i].Method
+		m.Filename = relocate(m.Filename)
+		if m.Root != "" {
+			m.Root = relocate(m.Root)
+		}
+		for j := range results[i].Usages {
+			u := &results[i].Usages[j]
+			path := usagePath(u.Location)
+			u.Location = relocate(path) + strings.TrimPrefix(u.Location, path)
+		}
+	}
+}
+
+// AbsolutePath makes path absolute, it is kept as is when that fails
+func AbsolutePath(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return path
+}
+
+// RelativeTo returns a relocate function of RelocatePaths making paths
+// relative to root. Paths are compared once symlinks are resolved, since git
+// reports resolved repository roots, and kept absolute when outside root.
+func RelativeTo(root string) func(path string) string {
+	root = AbsolutePath(root)
+	realRoot := realPath(root)
+	return func(path string) string {
+		abs := AbsolutePath(path)
+		if rel, err := filepath.Rel(root, abs); err == nil && !escapes(rel) {
+			return rel
+		}
+		if rel, err := filepath.Rel(realRoot, realPath(abs)); err == nil && !escapes(rel) {
+			return rel
+		}
+		return abs
+	}
+}
+
+// escapes reports whether a relative path leaves the directory it is
+// relative to
+func escapes(rel string) bool {
+	return rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
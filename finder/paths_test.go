@@ -0,0 +1,34 @@
+package finder
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestRelocatePaths(t *testing.T) {
+	root := t.TempDir()
+	file := filepath.Join(root, "pkg", "models.py")
+	outside := filepath.Join(filepath.Dir(root), "other.py")
+	results := []MethodUsage{{
+		Method: Method{Name: "save", Filename: file, Root: root},
+		Usages: []Usage{
+			{Location: file + ":3:5"},
+			{Location: outside + ":10:1"},
+		},
+	}}
+
+	RelocatePaths(results, RelativeTo(root))
+	r := results[0]
+	if want := filepath.Join("pkg", "models.py"); r.Method.Filename != want {
+		t.Errorf("Filename = %q, want %q", r.Method.Filename, want)
+	}
+	if r.Method.Root != "." {
+		t.Errorf("Root = %q, want .", r.Method.Root)
+	}
+	if want := filepath.Join("pkg", "models.py") + ":3:5"; r.Usages[0].Location != want {
+		t.Errorf("Location = %q, want %q", r.Usages[0].Location, want)
+	}
+	if want := outside + ":10:1"; r.Usages[1].Location != want {
+		t.Errorf("Location outside the root = %q, want %q", r.Usages[1].Location, want)
+	}
+}
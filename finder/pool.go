@@ -0,0 +1,22 @@
+package finder
+
+import "runtime"
+
+// workerPool bounds the goroutines, and so the open files and ripgrep
+// processes, of an analysis stage
+type workerPool chan struct{}
+
+// newWorkerPool admits up to jobs workers at once, runtime.NumCPU() when jobs
+// is not positive
+func newWorkerPool(jobs int) workerPool {
+	if jobs < 1 {
+		jobs = runtime.NumCPU()
+	}
+	return make(workerPool, jobs)
+}
+
+// acquire blocks until a worker slot is free
+func (p workerPool) acquire() { p <- struct{}{} }
+
+// release frees the slot taken by acquire
+func (p workerPool) release() { <-p }
@@ -0,0 +1,36 @@
+package finder
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestWorkerPool(t *testing.T) {
+	pool := newWorkerPool(2)
+	var running, peak atomic.Int32
+	var wg sync.WaitGroup
+	for range 20 {
+		wg.Add(1)
+		pool.acquire()
+		go func() {
+			defer wg.Done()
+			defer pool.release()
+			n := running.Add(1)
+			for {
+				p := peak.Load()
+				if n <= p || peak.CompareAndSwap(p, n) {
+					break
+				}
+			}
+			running.Add(-1)
+		}()
+	}
+	wg.Wait()
+	if p := peak.Load(); p > 2 {
+		t.Errorf("peak concurrency = %d, want at most 2", p)
+	}
+	if cap(newWorkerPool(0)) < 1 {
+		t.Error("newWorkerPool(0) admits no workers")
+	}
+}
@@ -0,0 +1,199 @@
+package finder
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/gob"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// refIndexVersion is bumped whenever the on-disk layout changes, discarding
+// older indexes
+const refIndexVersion = 1
+
+// RefIndex maps every identifier of the searched files to the lines it
+// appears on. Every usage pattern matches the method name as a whole word, so
+// a method is searched by reading only those lines instead of grepping the
+// tree. It is persisted between runs and a file is only scanned again when
+// its modification time or size changed and its contents hash differs.
+type RefIndex struct {
+	Version  int
+	Encoding string // Encoding the files were decoded with
+	Files    map[string]*IndexedFile
+
+	paths []string // Sorted keys of Files, searched in order
+}
+
+// IndexedFile is the index entry of one file
+type IndexedFile struct {
+	ModTime time.Time
+	Size    int64
+	Hash    [sha256.Size]byte
+	Words   map[string][]int // 1-based line numbers per identifier
+}
+
+// RefIndexPath is where the reference index of a search directory is kept
+func RefIndexPath(dir string) string {
+	if info, err := os.Stat(dir); err == nil && !info.IsDir() {
+		dir = filepath.Dir(dir)
+	}
+	return filepath.Join(dir, ".pybroom", "index")
+}
+
+// LoadRefIndex reads the index saved at path. A missing index is empty; an
+// unreadable or outdated one is empty too, along with the reason.
+func LoadRefIndex(path string) (*RefIndex, error) {
+	empty := &RefIndex{Version: refIndexVersion, Files: make(map[string]*IndexedFile)}
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return empty, nil
+	}
+	if err != nil {
+		return empty, err
+	}
+	defer f.Close()
+
+	var ix RefIndex
+	if err := gob.NewDecoder(f).Decode(&ix); err != nil {
+		return empty, err
+	}
+	if ix.Version != refIndexVersion {
+		return empty, fmt.Errorf("index version %d is outdated", ix.Version)
+	}
+	if ix.Files == nil {
+		ix.Files = make(map[string]*IndexedFile)
+	}
+	return &ix, nil
+}
+
+// Save writes the index to path, replacing the previous one atomically
+func (ix *RefIndex) Save(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".index-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if err := gob.NewEncoder(tmp).Encode(ix); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// update brings the index in line with files, scanning the new and modified
+// ones, and forgets the files no longer searched. It returns how many files
+// were scanned.
+func (ix *RefIndex) update(ctx context.Context, files []File, encoding string, jobs int) int {
+	if ix.Encoding != encoding {
+		ix.Encoding, ix.Files = encoding, make(map[string]*IndexedFile)
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	pool := newWorkerPool(jobs)
+	current := make(map[string]bool, len(files))
+	scanned := 0
+	for _, file := range files {
+		current[file.Path] = true
+		if ctx.Err() != nil {
+			break
+		}
+		wg.Add(1)
+		pool.acquire()
+		go func(path string) {
+			defer wg.Done()
+			defer pool.release()
+			mu.Lock()
+			entry := ix.Files[path]
+			mu.Unlock()
+
+			info, err := os.Stat(path)
+			if err != nil {
+				return
+			}
+			if entry != nil && entry.ModTime.Equal(info.ModTime()) && entry.Size == info.Size() {
+				return
+			}
+			data, err := readEntireFile(path)
+			if err != nil {
+				return
+			}
+			hash := sha256.Sum256(data)
+			fresh := &IndexedFile{ModTime: info.ModTime(), Size: info.Size(), Hash: hash}
+			touched := entry != nil && entry.Hash == hash
+			if touched {
+				fresh.Words = entry.Words
+			} else {
+				fresh.Words = wordLines(strings.Split(decodeSource(data, encoding), "\n"))
+			}
+
+			mu.Lock()
+			ix.Files[path] = fresh
+			if !touched {
+				scanned++
+			}
+			mu.Unlock()
+		}(file.Path)
+	}
+	wg.Wait()
+
+	ix.paths = ix.paths[:0]
+	for path := range ix.Files {
+		if !current[path] {
+			delete(ix.Files, path)
+			continue
+		}
+		ix.paths = append(ix.paths, path)
+	}
+	sort.Strings(ix.paths)
+	return scanned
+}
+
+// wordLines indexes the identifiers of a file by line
+func wordLines(lines []string) map[string][]int {
+	words := make(map[string][]int)
+	for i, line := range lines {
+		for _, word := range wordRe.FindAllString(line, -1) {
+			if n := words[word]; len(n) == 0 || n[len(n)-1] != i+1 {
+				words[word] = append(n, i+1)
+			}
+		}
+	}
+	return words
+}
+
+// search returns the vimgrep lines matching re on the lines holding name, in
+// every indexed file or only in the file at only when set
+func (ix *RefIndex) search(name string, re *regexp.Regexp, only string, load func(path string) []string) []string {
+	var out []string
+	for _, path := range ix.paths {
+		if only != "" && path != only {
+			continue
+		}
+		lineNos := ix.Files[path].Words[name]
+		if len(lineNos) == 0 {
+			continue
+		}
+		lines := load(path)
+		for _, n := range lineNos {
+			if n <= len(lines) {
+				out = append(out, matchLine(path, strconv.Itoa(n), lines[n-1], re)...)
+			}
+		}
+	}
+	return out
+}
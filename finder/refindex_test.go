@@ -0,0 +1,77 @@
+package finder
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestRefIndexUpdate(t *testing.T) {
+	dir := t.TempDir()
+	lib := writeTestFile(t, dir, "lib.py", "def helper(x):\n    return x\n")
+	app := writeTestFile(t, dir, "app.py", "from lib import helper\nhelper(1)\n")
+	files, err := ReadDirs(context.Background(), []string{dir}, DirFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := RefIndexPath(dir)
+	ix, err := LoadRefIndex(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := ix.update(context.Background(), files, "", 1); got != 2 {
+		t.Errorf("first update scanned %d files, want 2", got)
+	}
+	if got, want := ix.Files[app].Words["helper"], []int{1, 2}; !reflect.DeepEqual(got, want) {
+		t.Errorf("helper lines = %v, want %v", got, want)
+	}
+	if err := ix.Save(path); err != nil {
+		t.Fatal(err)
+	}
+
+	// A touched file is hashed again but not rescanned, an edited one is
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(lib, later, later); err != nil {
+		t.Fatal(err)
+	}
+	writeTestFile(t, dir, "app.py", "from lib import helper\n\nhelper(2)\n")
+	ix, err = LoadRefIndex(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := ix.update(context.Background(), files, "", 1); got != 1 {
+		t.Errorf("second update scanned %d files, want 1", got)
+	}
+	if got, want := ix.Files[app].Words["helper"], []int{1, 3}; !reflect.DeepEqual(got, want) {
+		t.Errorf("helper lines = %v, want %v", got, want)
+	}
+
+	// Files no longer searched are forgotten
+	ix.update(context.Background(), files[:1], "", 1)
+	if len(ix.Files) != 1 {
+		t.Errorf("index kept %d files, want 1", len(ix.Files))
+	}
+}
+
+func TestAnalyzeMethodUsages_RefIndex(t *testing.T) {
+	dir := t.TempDir()
+	lib := writeTestFile(t, dir, "lib.py", "def helper(x):\n    return x\n")
+	writeTestFile(t, dir, "app.py", "from lib import helper\n\nvalue = helper(1) + helper(2)\nhelpers = 1\n")
+
+	files := []File{{Dir: dir, Base: filepath.Base(lib), Path: lib, Root: dir}}
+	methods := FindMethods(context.Background(), files, MethodFilter{})
+	native := AnalyzeMethodUsages(context.Background(), methods, []string{dir}, FileFilter{Engine: EngineNative})
+
+	ix, err := LoadRefIndex(RefIndexPath(dir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	indexed := AnalyzeMethodUsages(context.Background(), methods, []string{dir}, FileFilter{RefIndex: ix})
+	if len(indexed) != 1 || !reflect.DeepEqual(indexed[0].Usages, native[0].Usages) {
+		t.Errorf("indexed usages = %+v, want %+v", indexed, native)
+	}
+}
@@ -0,0 +1,71 @@
+package finder
+
+import (
+	"context"
+	"regexp"
+	"strconv"
+	"sync"
+)
+
+// scanFiles searches decoded files for every method at once. Each file is
+// read a single time: the identifiers of a line are looked up among the
+// method names, and only the methods named there run their pattern on it, so
+// the cost grows with the lines and not with lines times methods. Matches are
+// vimgrep lines per method position, in the order of paths.
+func scanFiles(ctx context.Context, files map[string]sourceFile, paths []string, methods []Method, filters FileFilter) map[int][]string {
+	res := make([]*regexp.Regexp, len(methods))
+	byName := make(map[string][]int)
+	for i, m := range methods {
+		res[i] = regexp.MustCompile(searchPattern(m.Kind, m.Name, filters))
+		byName[m.Name] = append(byName[m.Name], i)
+	}
+
+	perPath := make([]map[int][]string, len(paths))
+	var wg sync.WaitGroup
+	pool := newWorkerPool(filters.Jobs)
+	for p, path := range paths {
+		if ctx.Err() != nil {
+			break
+		}
+		wg.Add(1)
+		pool.acquire()
+		go func(p int, path string) {
+			defer wg.Done()
+			defer pool.release()
+			found := make(map[int][]string)
+			for n, line := range files[path].lines {
+				var lineNo string
+				var notified map[int]bool
+				for _, word := range wordRe.FindAllString(line, -1) {
+					for _, i := range byName[word] {
+						if notified[i] {
+							continue
+						}
+						if notified == nil {
+							notified = make(map[int]bool)
+						}
+						notified[i] = true
+						// A nested function can only be reached from its own file
+						if filters.LocalNested && methods[i].Parent != "" && path != methods[i].Filename {
+							continue
+						}
+						if lineNo == "" {
+							lineNo = strconv.Itoa(n + 1)
+						}
+						found[i] = append(found[i], matchLine(path, lineNo, line, res[i])...)
+					}
+				}
+			}
+			perPath[p] = found
+		}(p, path)
+	}
+	wg.Wait()
+
+	matches := make(map[int][]string)
+	for _, found := range perPath {
+		for i, lines := range found {
+			matches[i] = append(matches[i], lines...)
+		}
+	}
+	return matches
+}
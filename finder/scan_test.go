@@ -0,0 +1,28 @@
+package finder
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestScanFiles(t *testing.T) {
+	methods := []Method{
+		{Name: "load", Filename: "a.py", Kind: SymbolFunction},
+		{Name: "save", Filename: "a.py", Kind: SymbolFunction},
+		{Name: "inner", Filename: "a.py", Parent: "outer", Kind: SymbolFunction},
+	}
+	files := map[string]sourceFile{
+		"a.py": {lines: []string{"save(load(x)); load(y)", "inner()"}},
+		"b.py": {lines: []string{"loader()", "inner()"}},
+	}
+	got := scanFiles(context.Background(), files, []string{"a.py", "b.py"}, methods, FileFilter{LocalNested: true})
+	want := map[int][]string{
+		0: {"a.py:1:6:save(load(x)); load(y)", "a.py:1:16:save(load(x)); load(y)"},
+		1: {"a.py:1:1:save(load(x)); load(y)"},
+		2: {"a.py:2:1:inner()"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("scanFiles = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,108 @@
+package finder
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	classDefRe = regexp.MustCompile(`^(\s*)class\s+([A-Za-z_][A-Za-z0-9_]*)\s*[(:]`)
+	funcDefRe  = regexp.MustCompile(`^(\s*)(?:async\s+)?def\s+([A-Za-z_][A-Za-z0-9_]*)\s*\(`)
+)
+
+// scopeFrame is a class or function body currently open while walking a file
+type scopeFrame struct {
+	Indent  int
+	Name    string
+	IsClass bool
+	Line    int
+}
+
+// scopeTracker follows class/def nesting line by line using indentation.
+// Lines inside multi-line strings and blank/comment lines never close a scope.
+type scopeTracker struct {
+	stack    []scopeFrame
+	inString bool
+}
+
+func indentOf(line string) int {
+	return len(line) - len(strings.TrimLeft(line, " \t"))
+}
+
+// update feeds the next line to the tracker. It returns false for lines that
+// carry no code (blank, comment or inside a multi-line string).
+func (s *scopeTracker) update(line string, lineNo int) bool {
+	wasInString := s.inString
+	if len(tripleQuoteRe.FindAllString(line, -1))%2 == 1 {
+		s.inString = !s.inString
+	}
+	if wasInString {
+		return false
+	}
+
+	trimmed := strings.TrimSpace(line)
+	if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+		return false
+	}
+
+	indent := indentOf(line)
+	for len(s.stack) > 0 && indent <= s.stack[len(s.stack)-1].Indent {
+		s.stack = s.stack[:len(s.stack)-1]
+	}
+
+	if m := classDefRe.FindStringSubmatch(line); m != nil {
+		s.stack = append(s.stack, scopeFrame{Indent: indent, Name: m[2], IsClass: true, Line: lineNo})
+	} else if m := funcDefRe.FindStringSubmatch(line); m != nil {
+		s.stack = append(s.stack, scopeFrame{Indent: indent, Name: m[2], Line: lineNo})
+	}
+	return true
+}
+
+// top returns the innermost open scope, if any
+func (s *scopeTracker) top() (scopeFrame, bool) {
+	if len(s.stack) == 0 {
+		return scopeFrame{}, false
+	}
+	return s.stack[len(s.stack)-1], true
+}
+
+// parent returns the scope enclosing the innermost one, if any
+func (s *scopeTracker) parent() (scopeFrame, bool) {
+	if len(s.stack) < 2 {
+		return scopeFrame{}, false
+	}
+	return s.stack[len(s.stack)-2], true
+}
+
+// classOf returns the class a definition opened on lineNo belongs to, or the
+// class whose body contains the line for other statements
+func (s *scopeTracker) classOf(lineNo int) string {
+	top, ok := s.top()
+	if !ok {
+		return ""
+	}
+	if top.IsClass {
+		if top.Line == lineNo {
+			return ""
+		}
+		return top.Name
+	}
+	if top.Line == lineNo {
+		if parent, ok := s.parent(); ok && parent.IsClass {
+			return parent.Name
+		}
+	}
+	return ""
+}
+
+// parentFunction returns the function a definition opened on lineNo is nested in
+func (s *scopeTracker) parentFunction(lineNo int) string {
+	top, ok := s.top()
+	if !ok || top.Line != lineNo {
+		return ""
+	}
+	if parent, ok := s.parent(); ok && !parent.IsClass {
+		return parent.Name
+	}
+	return ""
+}
@@ -0,0 +1,205 @@
+package finder
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestAnalyzeMethodUsages_NativeEngine(t *testing.T) {
+	dir := t.TempDir()
+	lib := writeTestFile(t, dir, "lib.py", "def helper(x):\n    return x\n")
+	app := writeTestFile(t, dir, "app.py", "from lib import helper\n\nvalue = helper(1) + helper(2)\n")
+	writeTestFile(t, dir, "tests/test_lib.py", "from lib import helper\nhelper(3)\n")
+
+	files := []File{{Dir: dir, Base: filepath.Base(lib), Path: lib, Root: dir}}
+	methods := FindMethods(context.Background(), files, MethodFilter{})
+	results := AnalyzeMethodUsages(context.Background(), methods, []string{dir}, FileFilter{SkipTests: true, Engine: EngineNative})
+	if len(results) != 1 {
+		t.Fatalf("got %d results, want 1", len(results))
+	}
+
+	got := make(map[string]CallType)
+	for _, u := range results[0].Usages {
+		got[u.Location] = u.CallType
+	}
+	want := map[string]CallType{
+		fmt.Sprintf("%s:1:5", lib):  CallTypeDefinition,
+		fmt.Sprintf("%s:3:9", app):  CallTypeFunction,
+		fmt.Sprintf("%s:3:21", app): CallTypeFunction,
+	}
+	if len(got) != len(want) {
+		t.Errorf("usages = %v, want %v", got, want)
+	}
+	for location, callType := range want {
+		if got[location] != callType {
+			t.Errorf("usage at %s = %q, want %q", location, got[location], callType)
+		}
+	}
+}
+
+func TestAnalyzeMethodUsages_ReceiversAndDecorators(t *testing.T) {
+	dir := t.TempDir()
+	lib := writeTestFile(t, dir, "lib.py", "class Svc:\n    def handle(self):\n        pass\n\n\ndef traced(fn):\n    return fn\n")
+	writeTestFile(t, dir, "app.py", "from lib import Svc, traced\n\n\n@traced\ndef main():\n    svc = Svc()\n    svc.handle()\n")
+
+	files := []File{{Dir: dir, Base: filepath.Base(lib), Path: lib, Root: dir}}
+	methods := FindMethods(context.Background(), files, MethodFilter{})
+	results := AnalyzeMethodUsages(context.Background(), methods, []string{dir}, FileFilter{Engine: EngineNative})
+	if len(results) != 2 {
+		t.Fatalf("got %d results, want 2", len(results))
+	}
+	for _, r := range results {
+		if IsUnused(r) {
+			t.Errorf("%s reported unused, usages %v", r.Method.Name, r.Usages)
+		}
+	}
+}
+
+func TestAnalyzeMethodUsages_SearchDirs(t *testing.T) {
+	dir := t.TempDir()
+	lib := writeTestFile(t, dir, "lib/lib.py", "def helper(x):\n    return x\n")
+	app := writeTestFile(t, dir, "app/main.py", "from lib import helper\n\nhelper(1)\nhelper(2)\n")
+	writeTestFile(t, dir, "docs/conf.py", "helper = None\n")
+	libDir, appDir, docsDir := filepath.Dir(lib), filepath.Dir(app), filepath.Join(dir, "docs")
+
+	// Definitions come from one root, usages are searched in others
+	files := []File{{Dir: libDir, Base: filepath.Base(lib), Path: lib, Root: libDir}}
+	methods := FindMethods(context.Background(), files, MethodFilter{})
+	tests := []struct {
+		searchDirs []string
+		want       map[string]CallType
+		byRoot     map[string]int
+	}{
+		{[]string{appDir}, map[string]CallType{
+			app + ":3:1": CallTypeFunction,
+			app + ":4:1": CallTypeFunction,
+		}, nil},
+		{[]string{libDir, appDir}, map[string]CallType{
+			lib + ":1:5": CallTypeDefinition,
+			app + ":3:1": CallTypeFunction,
+			app + ":4:1": CallTypeFunction,
+		}, map[string]int{libDir: 1, appDir: 2}},
+		{[]string{docsDir}, map[string]CallType{}, nil},
+	}
+	for _, tt := range tests {
+		results := AnalyzeMethodUsages(context.Background(), methods, tt.searchDirs, FileFilter{Engine: EngineNative})
+		if len(results) != 1 {
+			t.Fatalf("search in %v: got %d results, want 1", tt.searchDirs, len(results))
+		}
+		got := make(map[string]CallType)
+		for _, u := range results[0].Usages {
+			got[u.Location] = u.CallType
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("search in %v: usages = %v, want %v", tt.searchDirs, got, tt.want)
+		}
+		if !reflect.DeepEqual(results[0].UsagesByRoot, tt.byRoot) {
+			t.Errorf("search in %v: usages by root = %v, want %v", tt.searchDirs, results[0].UsagesByRoot, tt.byRoot)
+		}
+		if unused := len(tt.want) == 0; IsUnused(results[0]) != unused {
+			t.Errorf("search in %v: IsUnused() = %v, want %v", tt.searchDirs, !unused, unused)
+		}
+	}
+}
+
+func TestStreamMethodUsages(t *testing.T) {
+	dir := t.TempDir()
+	lib := writeTestFile(t, dir, "lib.py", "def load():\n    pass\n\ndef save():\n    load()\n")
+
+	files := []File{{Dir: dir, Base: filepath.Base(lib), Path: lib, Root: dir}}
+	methods := FindMethods(context.Background(), files, MethodFilter{})
+	totals := make(map[string]int)
+	StreamMethodUsages(context.Background(), methods, []string{dir}, FileFilter{Engine: EngineNative}, func(r MethodUsage) {
+		if _, dup := totals[r.Method.Name]; dup {
+			t.Errorf("%s emitted twice", r.Method.Name)
+		}
+		totals[r.Method.Name] = r.TotalUsages
+	})
+	if want := map[string]int{"load": 2, "save": 1}; !reflect.DeepEqual(totals, want) {
+		t.Errorf("streamed totals = %v, want %v", totals, want)
+	}
+}
+
+func TestAnalyzeMethodUsages_Cancelled(t *testing.T) {
+	dir := t.TempDir()
+	lib := writeTestFile(t, dir, "lib.py", "def load():\n    pass\n")
+	files := []File{{Dir: dir, Base: filepath.Base(lib), Path: lib, Root: dir}}
+	methods := FindMethods(context.Background(), files, MethodFilter{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if got := FindMethods(ctx, files, MethodFilter{}); len(got) != 0 {
+		t.Errorf("FindMethods after cancellation = %v, want none", got)
+	}
+	if got := AnalyzeMethodUsages(ctx, methods, []string{dir}, FileFilter{Engine: EngineNative}); len(got) != 0 {
+		t.Errorf("AnalyzeMethodUsages after cancellation = %d results, want none", len(got))
+	}
+	if _, err := ReadDir(ctx, dir, DirFilter{}); err != context.Canceled {
+		t.Errorf("ReadDir after cancellation = %v, want %v", err, context.Canceled)
+	}
+}
+
+func TestAnalyzeMethodUsages_DotRootOverloads(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, dir, "pkg/impl.py", `from typing import overload
+
+
+@overload
+def over(x: int) -> int: ...
+@overload
+def over(x: str) -> str: ...
+def over(x):
+    return x
+`)
+	t.Chdir(dir)
+
+	// ripgrep reports the files of . as ./pkg/impl.py, the walker as pkg/impl.py
+	for _, engine := range searchEngines(t) {
+		result, err := New(Options{Dirs: []string{"."}, Search: FileFilter{Engine: engine}}).Run(context.Background())
+		if err != nil {
+			t.Fatalf("%s: Run: %v", engine, err)
+		}
+		if len(result.Results) != 1 {
+			t.Fatalf("%s: got %d results, want 1", engine, len(result.Results))
+		}
+		want := map[CallType]int{CallTypeDefinition: 1, CallTypeOverload: 2}
+		if got := result.Results[0].UsagesByType; !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: usages by type = %v, want %v", engine, got, want)
+		}
+	}
+}
+
+func TestAnalyzeMethodUsages_DotRootStubs(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, dir, "pkg/native.pyi", "def native() -> int: ...\n")
+	writeTestFile(t, dir, "pkg/mod.py", "def run():\n    pass\n")
+	writeTestFile(t, dir, "pkg/mod.pyi", "def run() -> None: ...\n")
+	writeTestFile(t, dir, "app.py", "from pkg.mod import run\nrun()\n")
+	t.Chdir(dir)
+
+	// A stub-only definition is not a type hint of itself
+	for _, engine := range searchEngines(t) {
+		result, err := New(Options{
+			Dirs:   []string{"."},
+			Walk:   DirFilter{Stubs: true},
+			Search: FileFilter{Engine: engine, Stubs: true},
+		}).Run(context.Background())
+		if err != nil {
+			t.Fatalf("%s: Run: %v", engine, err)
+		}
+		got := make(map[string]map[CallType]int)
+		for _, r := range result.Results {
+			got[r.Method.Name] = r.UsagesByType
+		}
+		want := map[string]map[CallType]int{
+			"native": {CallTypeDefinition: 1},
+			"run":    {CallTypeDefinition: 1, CallTypeFunction: 1, CallTypeTypeHint: 1},
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: usages by type = %v, want %v", engine, got, want)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package finder
+
+import (
+	"fmt"
+	"hash/fnv"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Shard is one of Count deterministic slices of the definitions, so that
+// Count CI jobs can each analyze one and merge their outputs afterwards
+type Shard struct {
+	Index int // From 1 to Count
+	Count int
+}
+
+// ParseShard parses a --shard value such as "2/4"
+func ParseShard(s string) (Shard, error) {
+	i, n, ok := strings.Cut(s, "/")
+	if !ok {
+		return Shard{}, fmt.Errorf("invalid shard '%s', expected i/n such as 1/4", s)
+	}
+	index, err := strconv.Atoi(strings.TrimSpace(i))
+	if err != nil {
+		return Shard{}, fmt.Errorf("invalid shard index '%s'", i)
+	}
+	count, err := strconv.Atoi(strings.TrimSpace(n))
+	if err != nil {
+		return Shard{}, fmt.Errorf("invalid shard count '%s'", n)
+	}
+	if count < 1 || index < 1 || index > count {
+		return Shard{}, fmt.Errorf("invalid shard '%s', the index must be between 1 and %d", s, count)
+	}
+	return Shard{Index: index, Count: count}, nil
+}
+
+// Select keeps the methods of the shard. A method always lands in the same
+// shard, whatever directory the repository was checked out in.
+func (s Shard) Select(methods []Method) []Method {
+	if s.Count <= 1 {
+		return methods
+	}
+	var selected []Method
+	for _, m := range methods {
+		if int(shardHash(m)%uint32(s.Count)) == s.Index-1 {
+			selected = append(selected, m)
+		}
+	}
+	return selected
+}
+
+func shardHash(m Method) uint32 {
+	path := m.Filename
+	if rel, err := filepath.Rel(m.Root, m.Filename); err == nil && m.Root != "" {
+		path = rel
+	}
+	h := fnv.New32a()
+	fmt.Fprintf(h, "%s:%d:%s:%s", filepath.ToSlash(path), m.LineNo, m.Kind, m.Name)
+	return h.Sum32()
+}
+
+// MergeResults combines the results of several shards, keeping the first
+// result of a definition reported by more than one
+func MergeResults(sets ...[]MethodUsage) []MethodUsage {
+	var merged []MethodUsage
+	seen := make(map[string]bool)
+	for _, results := range sets {
+		for _, r := range results {
+			key := fmt.Sprintf("%s:%d:%s:%s", r.Method.Filename, r.Method.LineNo, r.Method.Kind, r.Method.Name)
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			merged = append(merged, r)
+		}
+	}
+	return merged
+}
@@ -0,0 +1,48 @@
+package finder
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestParseShard(t *testing.T) {
+	if got, err := ParseShard("2/4"); err != nil || got != (Shard{Index: 2, Count: 4}) {
+		t.Errorf("ParseShard(2/4) = %v, %v", got, err)
+	}
+	for _, bad := range []string{"2", "0/4", "5/4", "a/4", "1/0"} {
+		if _, err := ParseShard(bad); err == nil {
+			t.Errorf("ParseShard(%q) succeeded, want an error", bad)
+		}
+	}
+}
+
+func TestShardSelect(t *testing.T) {
+	var methods []Method
+	for i := range 50 {
+		methods = append(methods, Method{Name: fmt.Sprintf("f%d", i), Filename: "/ci/a/pkg/mod.py", Root: "/ci/a", LineNo: i + 1})
+	}
+
+	// Every method lands in exactly one shard
+	seen := make(map[string]int)
+	for i := 1; i <= 3; i++ {
+		for _, m := range (Shard{Index: i, Count: 3}).Select(methods) {
+			seen[m.Name]++
+		}
+	}
+	for _, m := range methods {
+		if seen[m.Name] != 1 {
+			t.Errorf("%s selected by %d shards, want 1", m.Name, seen[m.Name])
+		}
+	}
+
+	// The same shard wherever the repository was checked out
+	moved := make([]Method, len(methods))
+	for i, m := range methods {
+		m.Filename, m.Root = "/home/dev/pkg/mod.py", "/home/dev"
+		moved[i] = m
+	}
+	shard := Shard{Index: 2, Count: 3}
+	if a, b := len(shard.Select(methods)), len(shard.Select(moved)); a != b {
+		t.Errorf("shard holds %d methods in one checkout and %d in another", a, b)
+	}
+}
@@ -0,0 +1,90 @@
+package finder
+
+import (
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// MethodSpec names a method to analyze: "name", "Class.name", optionally
+// prefixed by the defining file as in "pkg/mod.py:Class.name".
+type MethodSpec struct {
+	File  string
+	Class string
+	Name  string
+}
+
+// ParseMethodSpec parses a --method value
+func ParseMethodSpec(s string) MethodSpec {
+	var spec MethodSpec
+	if idx := strings.LastIndex(s, ":"); idx != -1 {
+		spec.File = s[:idx]
+		s = s[idx+1:]
+	}
+	if idx := strings.LastIndex(s, "."); idx != -1 {
+		spec.Class = s[:idx]
+		s = s[idx+1:]
+	}
+	spec.Name = s
+	return spec
+}
+
+// FullySpecified reports whether the spec names its defining file, so that
+// it can be resolved without walking any directory
+func (s MethodSpec) FullySpecified() bool {
+	return s.File != ""
+}
+
+// Matches reports whether a discovered definition is the one named by the spec
+func (s MethodSpec) Matches(m Method) bool {
+	if s.Name != m.Name {
+		return false
+	}
+	if s.Class != "" && s.Class != m.Class {
+		return false
+	}
+	if s.File != "" {
+		file, path := filepath.Clean(s.File), filepath.Clean(m.Filename)
+		if file != path && !strings.HasSuffix(path, string(filepath.Separator)+file) {
+			return false
+		}
+	}
+	return true
+}
+
+// MatchName matches a definition against a glob of its name or, when the
+// glob holds a '.', of its Class.name. "*" and "?" stay within a name.
+func MatchName(glob string, m Method) bool {
+	if !strings.Contains(glob, ".") {
+		return globMatch(glob, m.Name)
+	}
+	if m.Class == "" {
+		return false
+	}
+	// Dots separate the names like slashes separate directories
+	glob = strings.ReplaceAll(glob, ".", "/")
+	return globMatch(glob, strings.ReplaceAll(m.Class, ".", "/")+"/"+m.Name)
+}
+
+// selectMethods keeps the definitions matching at least one spec of the
+// filter and none of its excluded names
+func selectMethods(methods []Method, filters MethodFilter) []Method {
+	if len(filters.ExcludeNames) > 0 {
+		methods = slices.DeleteFunc(methods, func(m Method) bool {
+			return slices.ContainsFunc(filters.ExcludeNames, func(glob string) bool { return MatchName(glob, m) })
+		})
+	}
+	if len(filters.Methods) == 0 {
+		return methods
+	}
+	selected := methods[:0]
+	for _, m := range methods {
+		for _, spec := range filters.Methods {
+			if spec.Matches(m) {
+				selected = append(selected, m)
+				break
+			}
+		}
+	}
+	return selected
+}
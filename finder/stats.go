@@ -0,0 +1,133 @@
+package finder
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// Stats accumulates what the usage analysis did: the time it spent searching
+// files and classifying matches, and how much work it could skip. Per-method
+// work is summed over every worker, so the times can exceed the wall time of a
+// concurrent run. Nil stats record nothing.
+type Stats struct {
+	search      atomic.Int64
+	classify    atomic.Int64
+	files       atomic.Int64
+	searches    atomic.Int64
+	cacheHits   atomic.Int64
+	cacheMisses atomic.Int64
+	indexHits   atomic.Int64
+	indexMisses atomic.Int64
+}
+
+// Search returns the time spent in the search backend or the native engine
+func (s *Stats) Search() time.Duration { return time.Duration(s.search.Load()) }
+
+// Classify returns the time spent parsing, classifying and scoping matches
+func (s *Stats) Classify() time.Duration { return time.Duration(s.classify.Load()) }
+
+// Files returns how many files were searched for usages
+func (s *Stats) Files() int { return int(s.files.Load()) }
+
+// Searches returns how many search backend processes were run
+func (s *Stats) Searches() int { return int(s.searches.Load()) }
+
+// CacheHits returns how many times the scopes of a file were reused instead of
+// reading it again, and how many times it had to be read
+func (s *Stats) CacheHits() (hits, misses int) {
+	return int(s.cacheHits.Load()), int(s.cacheMisses.Load())
+}
+
+// IndexHits returns how many files the reference index answered for unchanged,
+// and how many it had to scan again
+func (s *Stats) IndexHits() (hits, misses int) {
+	return int(s.indexHits.Load()), int(s.indexMisses.Load())
+}
+
+// addSearch records a search that began at start
+func (s *Stats) addSearch(start time.Time) {
+	if s != nil {
+		s.search.Add(int64(time.Since(start)))
+	}
+}
+
+// addClassify records a classification that began at start
+func (s *Stats) addClassify(start time.Time) {
+	if s != nil {
+		s.classify.Add(int64(time.Since(start)))
+	}
+}
+
+func (s *Stats) addFiles(n int) {
+	if s != nil {
+		s.files.Add(int64(n))
+	}
+}
+
+func (s *Stats) addBackendRun() {
+	if s != nil {
+		s.searches.Add(1)
+	}
+}
+
+func (s *Stats) addCacheLookup(hit bool) {
+	switch {
+	case s == nil:
+	case hit:
+		s.cacheHits.Add(1)
+	default:
+		s.cacheMisses.Add(1)
+	}
+}
+
+func (s *Stats) addIndexUpdate(files, scanned int) {
+	if s != nil {
+		s.indexHits.Add(int64(files - scanned))
+		s.indexMisses.Add(int64(scanned))
+	}
+}
+
+// HitRate is the share of hits among the lookups, 0 without any
+func HitRate(hits, misses int) float64 {
+	if hits+misses == 0 {
+		return 0
+	}
+	return float64(hits) / float64(hits+misses)
+}
+
+// RunStats summarizes a run, so its cost can be tracked over time
+type RunStats struct {
+	Files         int        `json:"files"`          // Python files definitions were looked for in
+	Methods       int        `json:"methods"`        // Definitions analyzed
+	SearchedFiles int        `json:"searched_files"` // Files searched for usages
+	Searches      int        `json:"searches"`       // Search backend processes run
+	CacheHitRate  float64    `json:"cache_hit_rate"` // Share of file reads saved by the source cache
+	IndexHitRate  float64    `json:"index_hit_rate"` // Share of files the reference index answered for
+	Elapsed       StageTimes `json:"elapsed_ms"`
+}
+
+// StageTimes are the milliseconds spent per stage. Search and Classify are
+// summed over the workers of the analysis.
+type StageTimes struct {
+	Walk     float64 `json:"walk"`
+	Find     float64 `json:"find"`
+	Analyze  float64 `json:"analyze"`
+	Search   float64 `json:"search"`
+	Classify float64 `json:"classify"`
+	Total    float64 `json:"total"`
+}
+
+// Collect fills in what the analysis recorded in s, which took analyze
+func (r *RunStats) Collect(s *Stats, analyze time.Duration) {
+	r.SearchedFiles, r.Searches = s.Files(), s.Searches()
+	r.CacheHitRate = HitRate(s.CacheHits())
+	r.IndexHitRate = HitRate(s.IndexHits())
+	r.Elapsed.Analyze = Millis(analyze)
+	r.Elapsed.Search, r.Elapsed.Classify = Millis(s.Search()), Millis(s.Classify())
+	r.Elapsed.Total = r.Elapsed.Walk + r.Elapsed.Find + r.Elapsed.Analyze
+}
+
+// Millis converts d to milliseconds, to the microsecond
+func Millis(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
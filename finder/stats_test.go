@@ -0,0 +1,35 @@
+package finder
+
+import (
+	"testing"
+	"time"
+)
+
+func TestStats(t *testing.T) {
+	s := &Stats{}
+	cache := newSourceCache(map[string]sourceFile{"shop.py": {lines: []string{"def total(self):"}}}, "")
+	cache.stats = s
+	cache.load("shop.py")
+	cache.load("shop.py")
+	cache.load("shop.py")
+	s.addIndexUpdate(4, 1)
+	s.addBackendRun()
+	s.addFiles(4)
+
+	var stats RunStats
+	stats.Elapsed.Walk = 1
+	stats.Collect(s, 2*time.Millisecond)
+	want := RunStats{SearchedFiles: 4, Searches: 1, CacheHitRate: 2.0 / 3, IndexHitRate: 0.75,
+		Elapsed: StageTimes{Walk: 1, Analyze: 2, Total: 3}}
+	if stats != want {
+		t.Errorf("stats = %+v, want %+v", stats, want)
+	}
+
+	// Nil stats record nothing
+	var none *Stats
+	none.addBackendRun()
+	none.addCacheLookup(true)
+	if got := HitRate(0, 0); got != 0 {
+		t.Errorf("HitRate(0, 0) = %v, want 0", got)
+	}
+}
@@ -0,0 +1,46 @@
+package finder
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+func isStubFile(path string) bool {
+	return strings.HasSuffix(path, ".pyi")
+}
+
+// implementationKey identifies a definition by module path and name, so that
+// pkg/mod.pyi and pkg/mod.py definitions of the same name share a key
+func implementationKey(m Method) string {
+	module := strings.TrimSuffix(strings.TrimSuffix(m.Filename, ".pyi"), ".py")
+	return filepath.Clean(module) + ":" + m.Name
+}
+
+// resolveStubs drops stub definitions that describe an implementation also
+// being analyzed and marks the remaining ones as stub only.
+func resolveStubs(methods []Method) []Method {
+	implemented := make(map[string]bool)
+	hasStubs := false
+	for _, m := range methods {
+		if isStubFile(m.Filename) {
+			hasStubs = true
+		} else {
+			implemented[implementationKey(m)] = true
+		}
+	}
+	if !hasStubs {
+		return methods
+	}
+
+	resolved := methods[:0]
+	for _, m := range methods {
+		if isStubFile(m.Filename) {
+			if implemented[implementationKey(m)] {
+				continue
+			}
+			m.StubOnly = true
+		}
+		resolved = append(resolved, m)
+	}
+	return resolved
+}
@@ -0,0 +1,25 @@
+package finder
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestResolveStubs(t *testing.T) {
+	methods := []Method{
+		{Name: "run", Filename: "pkg/mod.py", LineNo: 1},
+		{Name: "run", Filename: "pkg/mod.pyi", LineNo: 1},
+		{Name: "native", Filename: "pkg/mod.pyi", LineNo: 2},
+		{Name: "run", Filename: "other/mod.pyi", LineNo: 1},
+	}
+
+	got := resolveStubs(methods)
+	want := []Method{
+		{Name: "run", Filename: "pkg/mod.py", LineNo: 1},
+		{Name: "native", Filename: "pkg/mod.pyi", LineNo: 2, StubOnly: true},
+		{Name: "run", Filename: "other/mod.pyi", LineNo: 1, StubOnly: true},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("resolveStubs mismatch\n got: %#v\nwant: %#v", got, want)
+	}
+}
@@ -0,0 +1,190 @@
+package finder
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// truncateUsages keeps at most max usages of a result, definitions first,
+// recording how many were dropped. Counts by type and root are left intact.
+func truncateUsages(result MethodUsage, max int) MethodUsage {
+	if max <= 0 || len(result.Usages) <= max {
+		return result
+	}
+	// A fresh slice lets the dropped usages be garbage collected
+	kept := make([]Usage, 0, max)
+	for _, u := range result.Usages {
+		if u.CallType == CallTypeDefinition && len(kept) < max {
+			kept = append(kept, u)
+		}
+	}
+	for _, u := range result.Usages {
+		if u.CallType != CallTypeDefinition && len(kept) < max {
+			kept = append(kept, u)
+		}
+	}
+	result.TruncatedCount = len(result.Usages) - len(kept)
+	result.Usages = kept
+	return result
+}
+
+// capMatches keeps the first limit vimgrep lines of a method, plus its own
+// definition wherever it is, and reports whether any were dropped
+func capMatches(lines []string, m Method, limit int) ([]string, bool) {
+	if limit <= 0 || len(lines) <= limit {
+		return lines, false
+	}
+	definition := fmt.Sprintf("%s:%d:", m.Filename, m.LineNo)
+	kept := lines[:limit:limit]
+	for _, line := range lines[limit:] {
+		if strings.HasPrefix(line, definition) {
+			kept = append(kept, line)
+		}
+	}
+	return kept, true
+}
+
+// Buckets of methods by total usages
+const (
+	BucketUnused = "unused"
+	BucketLow    = "low"
+	BucketMedium = "medium"
+	BucketHigh   = "high"
+)
+
+// UsageBuckets sorts methods by total usages: none are unused, up to Low are
+// low, up to Medium medium and any more high. A nil *UsageBuckets stands for
+// DefaultBuckets.
+type UsageBuckets struct {
+	Low    int      `json:"low"`
+	Medium int      `json:"medium"`
+	Labels []string `json:"labels,omitempty"` // Names of the unused, low, medium and high buckets
+}
+
+// DefaultBuckets are the buckets used unless configured otherwise
+var DefaultBuckets = UsageBuckets{Low: 2, Medium: 5, Labels: []string{"Unused", "Low usage", "Medium", "High usage"}}
+
+// ParseUsageBuckets reads buckets from the "LOW,MEDIUM" upper bounds of the
+// low and medium buckets and, when given, the four labels of the buckets
+func ParseUsageBuckets(bounds string, labels []string) (UsageBuckets, error) {
+	b := DefaultBuckets
+	if bounds != "" {
+		low, medium, ok := strings.Cut(bounds, ",")
+		var err1, err2 error
+		b.Low, err1 = strconv.Atoi(strings.TrimSpace(low))
+		b.Medium, err2 = strconv.Atoi(strings.TrimSpace(medium))
+		if !ok || err1 != nil || err2 != nil || b.Low < 1 || b.Medium <= b.Low {
+			return UsageBuckets{}, fmt.Errorf("invalid usage buckets '%s', want LOW,MEDIUM with 1 <= LOW < MEDIUM", bounds)
+		}
+	}
+	if labels != nil {
+		if len(labels) != 4 {
+			return UsageBuckets{}, fmt.Errorf("want 4 usage bucket labels (unused, low, medium, high), got %d", len(labels))
+		}
+		b.Labels = labels
+	}
+	return b, nil
+}
+
+func (b *UsageBuckets) orDefault() *UsageBuckets {
+	if b == nil {
+		return &DefaultBuckets
+	}
+	return b
+}
+
+// Bucket returns the bucket of a method with total usages
+func (b *UsageBuckets) Bucket(total int) string {
+	b = b.orDefault()
+	switch {
+	case total == 0:
+		return BucketUnused
+	case total <= b.Low:
+		return BucketLow
+	case total <= b.Medium:
+		return BucketMedium
+	default:
+		return BucketHigh
+	}
+}
+
+// Label returns the name of bucket and the range of usages it spans, such as
+// "Low usage (1-2 usages)"
+func (b *UsageBuckets) Label(bucket string) string {
+	b = b.orDefault()
+	labels := b.Labels
+	if len(labels) != 4 {
+		labels = DefaultBuckets.Labels
+	}
+	switch bucket {
+	case BucketUnused:
+		return labels[0] + " (0 usages)"
+	case BucketLow:
+		return fmt.Sprintf("%s (%s usages)", labels[1], usageRange(1, b.Low))
+	case BucketMedium:
+		return fmt.Sprintf("%s (%s usages)", labels[2], usageRange(b.Low+1, b.Medium))
+	default:
+		return fmt.Sprintf("%s (%d+ usages)", labels[3], b.Medium+1)
+	}
+}
+
+func usageRange(from, to int) string {
+	if from == to {
+		return strconv.Itoa(from)
+	}
+	return fmt.Sprintf("%d-%d", from, to)
+}
+
+// Summary aggregates the statistics of results one at a time, so they can be
+// computed while streaming without keeping every result in memory
+type Summary struct {
+	Methods      int              `json:"total_methods"`
+	Unused       int              `json:"unused"` // Methods by total usages, see Buckets
+	Low          int              `json:"low"`
+	Medium       int              `json:"medium"`
+	High         int              `json:"high"`
+	UsagesByType map[CallType]int `json:"usages_by_type"`
+	Buckets      *UsageBuckets    `json:"buckets,omitempty"` // DefaultBuckets when nil
+}
+
+// Add accounts for one more result
+func (s *Summary) Add(result MethodUsage) {
+	s.Methods++
+	switch s.Buckets.Bucket(result.TotalUsages) {
+	case BucketUnused:
+		s.Unused++
+	case BucketLow:
+		s.Low++
+	case BucketMedium:
+		s.Medium++
+	default:
+		s.High++
+	}
+	if s.UsagesByType == nil {
+		s.UsagesByType = make(map[CallType]int)
+	}
+	for callType, count := range result.UsagesByType {
+		s.UsagesByType[callType] += count
+	}
+}
+
+// Summarize aggregates the statistics of results
+func Summarize(results []MethodUsage) Summary {
+	var s Summary
+	for _, r := range results {
+		s.Add(r)
+	}
+	return s
+}
+
+// HistoryEntry is a run recorded in the history of a project
+type HistoryEntry struct {
+	ID         int       `json:"id"`
+	RecordedAt time.Time `json:"recorded_at"`
+	Commit     string    `json:"commit,omitempty"`
+	Label      string    `json:"label,omitempty"` // Release or tag the run was recorded for
+	Methods    int       `json:"methods"`         // Definitions analyzed
+	Unused     int       `json:"unused"`          // As counted by FilterUnused
+}
@@ -0,0 +1,100 @@
+package finder
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTruncateUsages(t *testing.T) {
+	result := MethodUsage{
+		Usages: []Usage{
+			{Location: "a.py:3:1", CallType: CallTypeFunction},
+			{Location: "a.py:7:1", CallType: CallTypeFunction},
+			{Location: "lib.py:1:5", CallType: CallTypeDefinition},
+		},
+		UsagesByType: map[CallType]int{CallTypeFunction: 2, CallTypeDefinition: 1},
+		TotalUsages:  3,
+	}
+
+	if got := truncateUsages(result, 0); len(got.Usages) != 3 || got.TruncatedCount != 0 {
+		t.Errorf("truncateUsages(0) kept %d usages, truncated %d, want no limit", len(got.Usages), got.TruncatedCount)
+	}
+	got := truncateUsages(result, 2)
+	var kept []string
+	for _, u := range got.Usages {
+		kept = append(kept, u.Location)
+	}
+	if want := []string{"lib.py:1:5", "a.py:3:1"}; !reflect.DeepEqual(kept, want) {
+		t.Errorf("truncateUsages(2) kept %v, want %v", kept, want)
+	}
+	if got.TruncatedCount != 1 || got.TotalUsages != 3 || RealUsages(got) != 2 {
+		t.Errorf("truncateUsages(2) = truncated %d, total %d, real %d, want 1, 3, 2",
+			got.TruncatedCount, got.TotalUsages, RealUsages(got))
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	result := func(total int) MethodUsage {
+		return MethodUsage{TotalUsages: total, UsagesByType: map[CallType]int{CallTypeFunction: total}}
+	}
+	got := Summarize([]MethodUsage{result(0), result(2), result(4), result(9), result(1)})
+	want := Summary{
+		Methods: 5, Unused: 1, Low: 2, Medium: 1, High: 1,
+		UsagesByType: map[CallType]int{CallTypeFunction: 16},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Summarize() = %+v, want %+v", got, want)
+	}
+}
+
+func TestCapMatches(t *testing.T) {
+	m := Method{Name: "get", Filename: "lib.py", LineNo: 10}
+	lines := []string{"a.py:1:1:get()", "a.py:2:1:get()", "b.py:5:3:x.get()", "lib.py:10:5:def get():"}
+
+	if got, capped := capMatches(lines, m, 0); len(got) != 4 || capped {
+		t.Errorf("capMatches(0) = %d lines, capped %v, want no limit", len(got), capped)
+	}
+	got, capped := capMatches(lines, m, 2)
+	if want := []string{"a.py:1:1:get()", "a.py:2:1:get()", "lib.py:10:5:def get():"}; !reflect.DeepEqual(got, want) || !capped {
+		t.Errorf("capMatches(2) = %q, capped %v, want %q, capped", got, capped, want)
+	}
+}
+
+func TestUsageBuckets(t *testing.T) {
+	b, err := ParseUsageBuckets("1,10", []string{"dead", "rare", "some", "hot"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, total := range []int{0, 1, 2, 10, 11} {
+		got = append(got, b.Bucket(total))
+	}
+	if want := []string{BucketUnused, BucketLow, BucketMedium, BucketMedium, BucketHigh}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Bucket() = %v, want %v", got, want)
+	}
+	if got, want := b.Label(BucketLow), "rare (1 usages)"; got != want {
+		t.Errorf("Label(low) = %q, want %q", got, want)
+	}
+	if got, want := b.Label(BucketHigh), "hot (11+ usages)"; got != want {
+		t.Errorf("Label(high) = %q, want %q", got, want)
+	}
+
+	var defaults *UsageBuckets
+	if got, want := defaults.Label(BucketMedium), "Medium (3-5 usages)"; got != want {
+		t.Errorf("nil Label(medium) = %q, want %q", got, want)
+	}
+	s := Summary{Buckets: &b}
+	s.Add(MethodUsage{TotalUsages: 4})
+	if s.Medium != 1 {
+		t.Errorf("Summary.Add(4) with buckets 1,10 = %+v, want medium", s)
+	}
+
+	for _, bounds := range []string{"5", "0,3", "4,4", "a,b"} {
+		if _, err := ParseUsageBuckets(bounds, nil); err == nil {
+			t.Errorf("ParseUsageBuckets(%q) succeeded, want an error", bounds)
+		}
+	}
+	if _, err := ParseUsageBuckets("", []string{"a", "b"}); err == nil {
+		t.Error("ParseUsageBuckets() with 2 labels succeeded, want an error")
+	}
+}
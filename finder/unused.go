@@ -0,0 +1,143 @@
+package finder
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// ImplicitDecorators mark functions invoked by a framework or the language
+// rather than by name, so they are never reported as unused
+var ImplicitDecorators = []string{
+	"property", "setter", "getter", "deleter", "cached_property",
+	"overload", "override", "abstractmethod",
+	"fixture", "route", "get", "post", "put", "patch", "delete", "websocket",
+	"receiver", "task", "shared_task", "command", "group", "callback",
+	"validator", "field_validator", "model_validator", "root_validator",
+	"register", "hookimpl",
+}
+
+// implicitNames are called by the interpreter or the standard test runners
+var implicitNames = map[string]bool{
+	"main":          true,
+	"setUp":         true,
+	"tearDown":      true,
+	"setUpClass":    true,
+	"tearDownClass": true,
+	"setUpModule":   true,
+	"asyncSetUp":    true,
+	"asyncTearDown": true,
+}
+
+// IsImplicitlyUsed reports whether a definition is reached without being
+// referenced by name: dunder methods, test entry points and framework hooks,
+// or is declared so with an ignore comment
+func IsImplicitlyUsed(m Method) bool {
+	if m.Ignored || isDunder(m.Name) || implicitNames[m.Name] {
+		return true
+	}
+	if m.Kind == SymbolFunction && strings.HasPrefix(m.Name, "test") {
+		return true
+	}
+	return hasDecorator(m.Decorators, ImplicitDecorators)
+}
+
+// RealUsages counts the usages that keep a definition alive. Definitions,
+// imports and package re-exports, comments, documentation and stub references do not.
+// Counts by type are used, so usages left out by MaxStoredUsages are included.
+func RealUsages(result MethodUsage) int {
+	count := 0
+	for callType, n := range result.UsagesByType {
+		switch callType {
+		case CallTypeDefinition, CallTypeOverload, CallTypeImport, CallTypeReexport, CallTypeComment, CallTypeDocReference, CallTypeTypeHint:
+			continue
+		}
+		count += n
+	}
+	return count
+}
+
+// IsUnused reports whether a result has no real usages and is not implicitly
+// used. Methods whose search failed are not, their usages are unknown.
+func IsUnused(result MethodUsage) bool {
+	return !IsImplicitlyUsed(result.Method) && RealUsages(result) == 0 && result.SearchError == ""
+}
+
+// FilterUnused keeps the results IsUnused reports
+func FilterUnused(results []MethodUsage) []MethodUsage {
+	var unused []MethodUsage
+	for _, result := range results {
+		if IsUnused(result) {
+			unused = append(unused, result)
+		}
+	}
+	return unused
+}
+
+// FilterProblems keeps the results needing attention: the unused ones kept by
+// FilterUnused and, unless minUsages is negative, those with fewer total
+// usages that are not implicitly used. Capped counts are lower bounds and are
+// never below it.
+func FilterProblems(results []MethodUsage, minUsages int) []MethodUsage {
+	var problems []MethodUsage
+	for _, result := range results {
+		if IsImplicitlyUsed(result.Method) || result.SearchError != "" {
+			continue
+		}
+		below := minUsages >= 0 && result.TotalUsages < minUsages && !result.Capped
+		if RealUsages(result) > 0 && !below {
+			continue
+		}
+		problems = append(problems, result)
+	}
+	return problems
+}
+
+// IgnoreComment on the line of a definition keeps it from being reported unused
+const IgnoreComment = "# pybr: ignore"
+
+var ignoreCommentRe = regexp.MustCompile(`#.*\bpybr:\s*ignore\b`)
+
+// HasIgnoreComment reports whether a definition line carries IgnoreComment
+func HasIgnoreComment(line string) bool {
+	return strings.Contains(line, "pybr") && ignoreCommentRe.MatchString(line)
+}
+
+// AddIgnoreComment appends IgnoreComment to a definition line
+func AddIgnoreComment(line string) string {
+	if HasIgnoreComment(line) {
+		return line
+	}
+	code, cr := strings.CutSuffix(line, "\r")
+	line = strings.TrimRight(code, " \t") + "  " + IgnoreComment
+	if cr {
+		line += "\r"
+	}
+	return line
+}
+
+// UnusedBudget caps the unused definitions of a run, as counted by
+// FilterUnused. Negative limits are disabled.
+type UnusedBudget struct {
+	FailOnUnused bool    // Any unused definition exceeds the budget
+	Max          int     // Unused definitions allowed
+	MaxPercent   float64 // Share of the analyzed definitions allowed unused, 0-100
+}
+
+// Exceeded describes how unused out of analyzed definitions exceed the
+// budget, empty when they do not
+func (b UnusedBudget) Exceeded(unused, analyzed int) string {
+	percent := 0.0
+	if analyzed > 0 {
+		percent = float64(unused) * 100 / float64(analyzed)
+	}
+	switch {
+	case b.FailOnUnused && unused > 0:
+		return fmt.Sprintf("%d unused definitions found", unused)
+	case b.Max >= 0 && unused > b.Max:
+		return fmt.Sprintf("%d unused definitions exceed the maximum of %d", unused, b.Max)
+	case b.MaxPercent >= 0 && percent > b.MaxPercent:
+		return fmt.Sprintf("%.1f%% of the definitions are unused (%d/%d), above the maximum of %g%%", percent, unused, analyzed, b.MaxPercent)
+	}
+	return ""
+}
@@ -0,0 +1,91 @@
+package finder
+
+import "testing"
+
+func TestFilterUnused(t *testing.T) {
+	result := func(m Method, types ...CallType) MethodUsage {
+		r := MethodUsage{Method: m, UsagesByType: make(map[CallType]int)}
+		for _, ct := range types {
+			r.Usages = append(r.Usages, Usage{CallType: ct})
+			r.UsagesByType[ct]++
+		}
+		return r
+	}
+	fn := func(name string, decorators ...string) Method {
+		return Method{Name: name, Kind: SymbolFunction, Decorators: decorators}
+	}
+
+	results := []MethodUsage{
+		result(fn("dead"), CallTypeDefinition, CallTypeImport, CallTypeComment),
+		result(fn("called"), CallTypeDefinition, CallTypeFunction),
+		result(fn("__repr__"), CallTypeDefinition),
+		result(fn("test_parse"), CallTypeDefinition),
+		result(fn("name", "property"), CallTypeDefinition),
+		result(fn("health", "app.get"), CallTypeDefinition),
+		result(fn("documented"), CallTypeDefinition, CallTypeDocReference),
+	}
+
+	var got []string
+	for _, r := range FilterUnused(results) {
+		got = append(got, r.Method.Name)
+	}
+	if len(got) != 2 || got[0] != "dead" || got[1] != "documented" {
+		t.Errorf("FilterUnused() = %v, want [dead documented]", got)
+	}
+}
+
+func TestFilterProblems(t *testing.T) {
+	result := func(name string, total int, types ...CallType) MethodUsage {
+		r := MethodUsage{Method: Method{Name: name, Kind: SymbolFunction}, UsagesByType: make(map[CallType]int), TotalUsages: total}
+		for _, ct := range types {
+			r.UsagesByType[ct]++
+		}
+		return r
+	}
+	capped := result("popular", 2, CallTypeDefinition, CallTypeFunction)
+	capped.Capped = true
+	results := []MethodUsage{
+		result("dead", 1, CallTypeDefinition),
+		result("rare", 2, CallTypeDefinition, CallTypeFunction),
+		result("used", 4, CallTypeDefinition, CallTypeFunction, CallTypeFunction, CallTypeInstance),
+		result("__eq__", 1, CallTypeDefinition),
+		capped,
+	}
+
+	names := func(results []MethodUsage) []string {
+		var got []string
+		for _, r := range results {
+			got = append(got, r.Method.Name)
+		}
+		return got
+	}
+	if got := names(FilterProblems(results, -1)); len(got) != 1 || got[0] != "dead" {
+		t.Errorf("FilterProblems(-1) = %v, want [dead]", got)
+	}
+	if got := names(FilterProblems(results, 3)); len(got) != 2 || got[0] != "dead" || got[1] != "rare" {
+		t.Errorf("FilterProblems(3) = %v, want [dead rare]", got)
+	}
+}
+
+func TestUnusedBudget(t *testing.T) {
+	off := UnusedBudget{Max: -1, MaxPercent: -1}
+	tests := []struct {
+		budget           UnusedBudget
+		unused, analyzed int
+		exceeded         bool
+	}{
+		{off, 5, 10, false},
+		{UnusedBudget{FailOnUnused: true, Max: -1, MaxPercent: -1}, 0, 10, false},
+		{UnusedBudget{FailOnUnused: true, Max: -1, MaxPercent: -1}, 1, 10, true},
+		{UnusedBudget{Max: 3, MaxPercent: -1}, 3, 10, false},
+		{UnusedBudget{Max: 3, MaxPercent: -1}, 4, 10, true},
+		{UnusedBudget{Max: -1, MaxPercent: 25}, 1, 4, false},
+		{UnusedBudget{Max: -1, MaxPercent: 25}, 2, 4, true},
+		{UnusedBudget{Max: -1, MaxPercent: 0}, 0, 0, false},
+	}
+	for _, tt := range tests {
+		if got := tt.budget.Exceeded(tt.unused, tt.analyzed); (got != "") != tt.exceeded {
+			t.Errorf("%+v.Exceeded(%d, %d) = %q, want exceeded %v", tt.budget, tt.unused, tt.analyzed, got, tt.exceeded)
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package finder
+
+import (
+	"log"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+var (
+	// NAME = ..., NAME: type = ... at module level (column 0)
+	moduleAssignRe = regexp.MustCompile(`^([A-Za-z_][A-Za-z0-9_]*(?:\s*,\s*[A-Za-z_][A-Za-z0-9_]*)*)\s*(?::[^=]+)?=[^=]`)
+	tripleQuoteRe  = regexp.MustCompile(`"""|'''`)
+)
+
+func isDunder(name string) bool {
+	return len(name) > 4 && strings.HasPrefix(name, "__") && strings.HasSuffix(name, "__")
+}
+
+// FindVariables finds module-level assignments (constants, config dicts, ...).
+// Dunder names such as __all__ or __version__ are skipped since they are read
+// by the interpreter and tooling rather than by code.
+func FindVariables(files []File, filters MethodFilter) []Method {
+	varsChan := make(chan []Method, len(files))
+	var wg sync.WaitGroup
+	pool := newWorkerPool(filters.Jobs)
+
+	for _, pyFile := range files {
+		if skipFile(pyFile, filters) {
+			continue
+		}
+
+		wg.Add(1)
+		pool.acquire()
+		go func(file File) {
+			defer wg.Done()
+			defer pool.release()
+
+			var fileVars []Method
+			src, err := readSource(file.Path, filters.Encoding)
+			if err != nil {
+				log.Printf("Error reading file %s: %v", file.Path, err)
+				varsChan <- fileVars
+				return
+			}
+
+			inString := false
+			seen := make(map[string]bool)
+			for lineNo, line := range src.lines {
+				wasInString := inString
+				if len(tripleQuoteRe.FindAllString(line, -1))%2 == 1 {
+					inString = !inString
+				}
+				if wasInString {
+					continue
+				}
+
+				matches := moduleAssignRe.FindStringSubmatch(line)
+				if matches == nil {
+					continue
+				}
+				for _, name := range strings.Split(matches[1], ",") {
+					name = strings.TrimSpace(name)
+					if isDunder(name) || seen[name] {
+						continue
+					}
+					if filters.skipName(name) {
+						continue
+					}
+					seen[name] = true
+					fileVars = append(fileVars, Method{
+						Name:     name,
+						Filename: file.Path,
+						LineNo:   lineNo + 1,
+						Kind:     SymbolVariable,
+						Root:     file.Root,
+						Cell:     src.cellOf(lineNo + 1),
+						Ignored:  HasIgnoreComment(line),
+					})
+				}
+			}
+			varsChan <- fileVars
+		}(pyFile)
+	}
+
+	go func() {
+		wg.Wait()
+		close(varsChan)
+	}()
+
+	var allVars []Method
+	for vars := range varsChan {
+		allVars = append(allVars, vars...)
+	}
+
+	return selectMethods(allVars, filters)
+}
+
+func variablePattern(name string) string {
+	return `\b` + regexp.QuoteMeta(name) + `\b`
+}
+
+// classifyVariableUsage tells apart module-level (re)assignments, imports and reads
+func classifyVariableUsage(line string, name string, filters FileFilter) (CallType, bool) {
+	trimmed := strings.TrimSpace(line)
+	wordRe := regexp.MustCompile(variablePattern(name))
+
+	if strings.HasPrefix(trimmed, "#") {
+		if filters.IncludeComments && wordRe.MatchString(trimmed) {
+			return CallTypeComment, true
+		}
+		return "", false
+	}
+
+	if idx := strings.Index(line, "#"); idx != -1 {
+		if !wordRe.MatchString(line[:idx]) {
+			if filters.IncludeComments && wordRe.MatchString(line[idx:]) {
+				return CallTypeComment, true
+			}
+			return "", false
+		}
+		line = line[:idx]
+	}
+
+	if isImportLine(line) {
+		return CallTypeImport, true
+	}
+
+	if m := moduleAssignRe.FindStringSubmatch(line); m != nil {
+		for _, target := range strings.Split(m[1], ",") {
+			if strings.TrimSpace(target) == name {
+				return CallTypeDefinition, true
+			}
+		}
+	}
+
+	write := regexp.MustCompile(`^\s*(?:global\s+.*\b` + regexp.QuoteMeta(name) + `\b|` +
+		regexp.QuoteMeta(name) + `\s*(?:[-+*/%&|^@]|//|\*\*|<<|>>)?=[^=])`)
+	if write.MatchString(line) {
+		return CallTypeWrite, true
+	}
+
+	return CallTypeRead, true
+}
@@ -0,0 +1,57 @@
+package finder
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestFindVariables(t *testing.T) {
+	dir := t.TempDir()
+	p := writeTestFile(t, dir, "settings.py", `DEBUG = True
+TIMEOUT: int = 30
+A, B = 1, 2
+__all__ = ["DEBUG"]
+"""
+NOT_A_VAR = 1
+"""
+def f():
+    local = 1
+`)
+
+	got := FindVariables([]File{{Dir: dir, Base: filepath.Base(p), Path: p}}, MethodFilter{})
+	want := []Method{
+		{Name: "DEBUG", Filename: p, LineNo: 1, Kind: SymbolVariable},
+		{Name: "TIMEOUT", Filename: p, LineNo: 2, Kind: SymbolVariable},
+		{Name: "A", Filename: p, LineNo: 3, Kind: SymbolVariable},
+		{Name: "B", Filename: p, LineNo: 3, Kind: SymbolVariable},
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("FindVariables mismatch\n got: %#v\nwant: %#v", got, want)
+	}
+}
+
+func TestClassifyVariableUsage(t *testing.T) {
+	cases := []struct {
+		line  string
+		want  CallType
+		valid bool
+	}{
+		{"DEBUG = True", CallTypeDefinition, true},
+		{"from settings import DEBUG", CallTypeImport, true},
+		{"if DEBUG:", CallTypeRead, true},
+		{"    DEBUG = False", CallTypeWrite, true},
+		{"    DEBUG += 1", CallTypeWrite, true},
+		{"    global DEBUG", CallTypeWrite, true},
+		{"    if DEBUG == 1:", CallTypeRead, true},
+		{"# DEBUG is on", "", false},
+	}
+
+	for _, c := range cases {
+		got, valid := classifyVariableUsage(c.line, "DEBUG", FileFilter{})
+		if got != c.want || valid != c.valid {
+			t.Errorf("classifyVariableUsage(%q) = (%q, %v), want (%q, %v)", c.line, got, valid, c.want, c.valid)
+		}
+	}
+}
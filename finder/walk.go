@@ -0,0 +1,150 @@
+package finder
+
+import (
+	"context"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// dirWalker collects the files of one root, applying a DirFilter
+type dirWalker struct {
+	ctx     context.Context
+	root    string
+	filter  DirFilter
+	exts    []string
+	ignore  *ignoreMatcher
+	visited map[any]bool // Directories already walked, only when following symlinks
+	files   []File
+}
+
+// ReadDir collects the files of rootDir passing the filter. The walk stops
+// with the context's error once it is cancelled.
+func ReadDir(ctx context.Context, rootDir string, filter DirFilter) ([]File, error) {
+	w := &dirWalker{
+		ctx:    ctx,
+		root:   rootDir,
+		filter: filter,
+		exts:   extensions(filter.Extensions, filter.Stubs),
+	}
+	if !filter.NoIgnore {
+		w.ignore, _ = newIgnoreMatcher(rootDir)
+	}
+	if filter.FollowSymlinks {
+		w.visited = make(map[any]bool)
+	}
+
+	err := filepath.WalkDir(rootDir, w.visit)
+	return w.files, err
+}
+
+// dirKey identifies a directory independently of the path used to reach it
+func dirKey(path string, info fs.FileInfo) any {
+	if id, ok := fileID(info); ok {
+		return id
+	}
+	if real, err := filepath.EvalSymlinks(path); err == nil {
+		if abs, err := filepath.Abs(real); err == nil {
+			return abs
+		}
+	}
+	return path
+}
+
+// walkLink walks the directory a symlink points to, reporting paths below
+// the link itself, as ripgrep --follow does.
+func (w *dirWalker) walkLink(link string) error {
+	target, err := filepath.EvalSymlinks(link)
+	if err != nil {
+		log.Printf("Error following symlink %s: %v", link, err)
+		return nil
+	}
+	return filepath.WalkDir(target, func(path string, entry fs.DirEntry, err error) error {
+		rel, relErr := filepath.Rel(target, path)
+		if relErr != nil {
+			return relErr
+		}
+		return w.visit(filepath.Join(link, rel), entry, err)
+	})
+}
+
+func (w *dirWalker) visit(path string, entry fs.DirEntry, err error) error {
+	if err != nil {
+		return err
+	}
+	if err := w.ctx.Err(); err != nil {
+		return err
+	}
+
+	if w.ignore != nil && path != w.root {
+		if abs, err := filepath.Abs(path); err == nil && w.ignore.ignored(abs, entry.IsDir()) {
+			if entry.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+	}
+
+	if len(w.filter.Exclude) > 0 && path != w.root {
+		if rel, err := filepath.Rel(w.root, path); err == nil && matchAnyGlob(w.filter.Exclude, rel) {
+			if entry.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+	}
+
+	// Depth as in ripgrep: entries directly under the root are at depth 1
+	if w.filter.MaxDepth > 0 && path != w.root {
+		if rel, err := filepath.Rel(w.root, path); err == nil {
+			depth := strings.Count(filepath.ToSlash(rel), "/") + 1
+			if depth > w.filter.MaxDepth {
+				if entry.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+		}
+	}
+
+	if w.visited != nil && entry.Type()&fs.ModeSymlink != 0 {
+		if info, err := os.Stat(path); err == nil && info.IsDir() {
+			return w.walkLink(path)
+		}
+	}
+
+	if entry.IsDir() {
+		name := entry.Name()
+		if name == ".git" || name == "__pycache__" || name == ".venv" || name == "venv" || name == "node_modules" {
+			return filepath.SkipDir
+		}
+		if w.visited != nil {
+			info, err := entry.Info()
+			if err != nil {
+				return err
+			}
+			key := dirKey(path, info)
+			if w.visited[key] {
+				return filepath.SkipDir
+			}
+			w.visited[key] = true
+		}
+		if w.ignore != nil {
+			if abs, err := filepath.Abs(path); err == nil {
+				w.ignore.load(filepath.Join(path, ".gitignore"), abs)
+			}
+		}
+	}
+	if !entry.IsDir() && hasExtension(path, w.exts) {
+		pyFile := File{
+			Dir:  filepath.Dir(path),
+			Base: filepath.Base(path),
+			Path: path,
+			Root: w.root,
+		}
+		w.files = append(w.files, pyFile)
+	}
+	return nil
+}
@@ -0,0 +1,270 @@
+package finder
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func relPaths(t *testing.T, files []File) []string {
+	t.Helper()
+	var paths []string
+	for _, f := range files {
+		paths = append(paths, filepath.ToSlash(f.RelPath()))
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+func TestReadDir_Gitignore(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, ".git", "info"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	writeTestFile(t, dir, ".git/info/exclude", "scratch.py\n")
+	writeTestFile(t, dir, ".gitignore", "build/\n*_pb2.py\n!keep_pb2.py\n")
+	writeTestFile(t, dir, "pkg/.gitignore", "/local.py\n")
+	writeTestFile(t, dir, "main.py", "")
+	writeTestFile(t, dir, "scratch.py", "")
+	writeTestFile(t, dir, "build/lib/gen.py", "")
+	writeTestFile(t, dir, "api_pb2.py", "")
+	writeTestFile(t, dir, "keep_pb2.py", "")
+	writeTestFile(t, dir, "pkg/local.py", "")
+	writeTestFile(t, dir, "pkg/sub/local.py", "")
+
+	files, err := ReadDir(context.Background(), dir, DirFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := relPaths(t, files)
+	want := []string{"keep_pb2.py", "main.py", "pkg/sub/local.py"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("ReadDir = %v, want %v", got, want)
+	}
+
+	all, err := ReadDir(context.Background(), dir, DirFilter{NoIgnore: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 7 {
+		t.Fatalf("ReadDir(context.Background(), NoIgnore) found %d files, want 7: %v", len(all), relPaths(t, all))
+	}
+}
+
+func TestReadDir_MalformedGitignore(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, ".git"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	// The malformed line is skipped, the others still apply
+	writeTestFile(t, dir, ".gitignore", "[z-a].py\n[]\n*_pb2.py\n")
+	writeTestFile(t, dir, "main.py", "")
+	writeTestFile(t, dir, "api_pb2.py", "")
+
+	files, err := ReadDir(context.Background(), dir, DirFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := relPaths(t, files), []string{"main.py"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ReadDir = %v, want %v", got, want)
+	}
+}
+
+func TestReadDir_Exclude(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, dir, "app/models.py", "")
+	writeTestFile(t, dir, "app/migrations/0001_initial.py", "")
+	writeTestFile(t, dir, "api/generated_client.py", "")
+	writeTestFile(t, dir, "api/client.py", "")
+
+	files, err := ReadDir(context.Background(), dir, DirFilter{Exclude: []string{"**/migrations/**", "**/generated_*.py"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := relPaths(t, files)
+	want := []string{"api/client.py", "app/models.py"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("ReadDir = %v, want %v", got, want)
+	}
+}
+
+func TestReadDir_FollowSymlinks(t *testing.T) {
+	dir := t.TempDir()
+	shared := t.TempDir()
+	writeTestFile(t, dir, "app/main.py", "")
+	writeTestFile(t, shared, "lib/util.py", "")
+	if err := os.Symlink(filepath.Join(shared, "lib"), filepath.Join(dir, "vendor")); err != nil {
+		t.Skipf("symlinks not supported: %v", err)
+	}
+	// A loop back to the root must neither hang nor duplicate files
+	if err := os.Symlink(dir, filepath.Join(dir, "app", "loop")); err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := ReadDir(context.Background(), dir, DirFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := relPaths(t, files), []string{"app/main.py"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("ReadDir = %v, want %v", got, want)
+	}
+
+	files, err = ReadDir(context.Background(), dir, DirFilter{FollowSymlinks: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := relPaths(t, files), []string{"app/main.py", "vendor/util.py"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("ReadDir(context.Background(), FollowSymlinks) = %v, want %v", got, want)
+	}
+}
+
+func TestReadDir_MaxDepth(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, dir, "top.py", "")
+	writeTestFile(t, dir, "svc/api.py", "")
+	writeTestFile(t, dir, "svc/internal/db.py", "")
+
+	files, err := ReadDir(context.Background(), dir, DirFilter{MaxDepth: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := relPaths(t, files), []string{"svc/api.py", "top.py"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("ReadDir(context.Background(), MaxDepth=2) = %v, want %v", got, want)
+	}
+}
+
+func TestReadIgnoreFile(t *testing.T) {
+	dir := t.TempDir()
+	path := writeTestFile(t, dir, IgnoreFileName, "# generated\n*_pb2.py\n/src/legacy/\nsrc/*/vendor\nmethod: deprecated_*\nmethod:Admin*.get_*\n")
+	f, err := ReadIgnoreFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"deprecated_*", "Admin*.get_*"}; !reflect.DeepEqual(f.Methods, want) {
+		t.Errorf("Methods = %v, want %v", f.Methods, want)
+	}
+	for root, want := range map[string][]string{
+		dir:                              {"*_pb2.py", "/src/legacy", "/src/*/vendor"},
+		filepath.Join(dir, "src"):        {"*_pb2.py", "/legacy", "/*/vendor"},
+		filepath.Join(dir, "src", "pkg"): {"*_pb2.py", "/vendor"},
+		filepath.Join(dir, "docs"):       {"*_pb2.py"},
+		filepath.Dir(dir):                {filepath.Base(dir) + "/**/*_pb2.py", filepath.Base(dir) + "/src/legacy", filepath.Base(dir) + "/src/*/vendor"},
+	} {
+		if got := f.Excludes(root); !reflect.DeepEqual(got, want) {
+			t.Errorf("Excludes(%s) = %v, want %v", root, got, want)
+		}
+	}
+
+	writeTestFile(t, dir, "negated/"+IgnoreFileName, "!keep.py\n")
+	if _, err := ReadIgnoreFile(filepath.Join(dir, "negated", IgnoreFileName)); err == nil {
+		t.Error("ReadIgnoreFile() of a negated pattern succeeded")
+	}
+	for name, content := range map[string]string{"bracket": "[z-a].py\n", "method": "method: get_[]\n"} {
+		path := writeTestFile(t, dir, name+"/"+IgnoreFileName, content)
+		if _, err := ReadIgnoreFile(path); err == nil {
+			t.Errorf("ReadIgnoreFile() of a malformed %s glob succeeded", name)
+		}
+	}
+}
+
+func TestFilesFromPaths(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, dir, "src/pkg/a.py", "")
+	writeTestFile(t, dir, "src/pkg/b.py", "")
+	writeTestFile(t, dir, "scripts/manage", "")
+	writeTestFile(t, dir, "other/c.py", "")
+	t.Chdir(dir)
+	src, err := filepath.Abs("src")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		paths    []string
+		roots    []string
+		wantRoot []string
+	}{
+		{"relative paths and root", []string{"src/pkg/a.py"}, []string{"src"}, []string{"src"}},
+		{"absolute path, relative root", []string{filepath.Join(src, "pkg", "a.py")}, []string{"src"}, []string{"src"}},
+		{"relative path, absolute root", []string{"src/pkg/b.py"}, []string{src}, []string{src}},
+		{"first containing root", []string{"src/pkg/a.py"}, []string{"other", "src/pkg", "src"}, []string{"src/pkg"}},
+		{"outside every root", []string{"other/c.py"}, []string{"src"}, []string{"other"}},
+		// Files named explicitly are taken whatever their extension
+		{"script without extension", []string{"scripts/manage"}, []string{"."}, []string{"."}},
+	}
+	for _, tt := range tests {
+		files, err := FilesFromPaths(tt.paths, tt.roots)
+		if err != nil {
+			t.Errorf("%s: FilesFromPaths() = %v", tt.name, err)
+			continue
+		}
+		var roots []string
+		for i, f := range files {
+			if f.Path != tt.paths[i] || f.Dir != filepath.Dir(f.Path) || f.Base != filepath.Base(f.Path) {
+				t.Errorf("%s: file %+v does not keep the path as given", tt.name, f)
+			}
+			roots = append(roots, filepath.ToSlash(f.Root))
+		}
+		want := make([]string, len(tt.wantRoot))
+		for i, root := range tt.wantRoot {
+			want[i] = filepath.ToSlash(root)
+		}
+		if !reflect.DeepEqual(roots, want) {
+			t.Errorf("%s: roots = %v, want %v", tt.name, roots, want)
+		}
+	}
+
+	for _, paths := range [][]string{{"src/pkg/missing.py"}, {"src/pkg"}, {"src/pkg/a.py", "nope.py"}} {
+		if files, err := FilesFromPaths(paths, []string{"."}); err == nil {
+			t.Errorf("FilesFromPaths(%v) = %v, want an error", paths, files)
+		}
+	}
+}
+
+func TestReadDirs_OverlappingRoots(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, dir, "main.py", "")
+	writeTestFile(t, dir, "pkg/a.py", "")
+	writeTestFile(t, dir, "pkg/sub/b.py", "")
+	t.Chdir(dir)
+
+	// Each file is reported once, tagged with the first root listing it
+	tests := []struct {
+		roots []string
+		want  map[string]string // Root by path relative to dir
+	}{
+		{[]string{".", "./pkg"}, map[string]string{"main.py": ".", "pkg/a.py": ".", "pkg/sub/b.py": "."}},
+		{[]string{"./pkg", "."}, map[string]string{"main.py": ".", "pkg/a.py": "./pkg", "pkg/sub/b.py": "./pkg"}},
+		{[]string{"pkg/sub", dir}, map[string]string{"main.py": dir, "pkg/a.py": dir, "pkg/sub/b.py": "pkg/sub"}},
+		{[]string{"pkg", "pkg/"}, map[string]string{"pkg/a.py": "pkg", "pkg/sub/b.py": "pkg"}},
+	}
+	for _, tt := range tests {
+		files, err := ReadDirs(context.Background(), tt.roots, DirFilter{})
+		if err != nil {
+			t.Fatalf("ReadDirs(%v) = %v", tt.roots, err)
+		}
+		got := make(map[string]string)
+		for _, f := range files {
+			abs, err := filepath.Abs(f.Path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			rel, err := filepath.Rel(dir, abs)
+			if err != nil {
+				t.Fatal(err)
+			}
+			rel = filepath.ToSlash(rel)
+			if _, dup := got[rel]; dup {
+				t.Errorf("ReadDirs(%v) reported %s twice", tt.roots, rel)
+			}
+			got[rel] = f.Root
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ReadDirs(%v) roots = %v, want %v", tt.roots, got, tt.want)
+		}
+	}
+}
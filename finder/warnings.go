@@ -0,0 +1,81 @@
+package finder
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+)
+
+// Warning is a problem met by an analysis that did not stop it, such as a
+// file the search backend could not read
+type Warning struct {
+	Method  string `json:"method,omitempty"` // Method being searched, empty when shared by several
+	Message string `json:"message"`
+}
+
+func (w Warning) String() string {
+	if w.Method == "" {
+		return w.Message
+	}
+	return fmt.Sprintf("searching for %s: %s", w.Method, w.Message)
+}
+
+// Warnings collects the warnings of an analysis so they can be reported after
+// its results. A nil collector logs them as they happen instead.
+type Warnings struct {
+	mu   sync.Mutex
+	seen map[Warning]bool
+	list []Warning
+}
+
+func (w *Warnings) add(method, format string, args ...any) {
+	warning := Warning{Method: method, Message: fmt.Sprintf(format, args...)}
+	if w == nil {
+		log.Print(warning)
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.seen[warning] {
+		return
+	}
+	if w.seen == nil {
+		w.seen = make(map[Warning]bool)
+	}
+	w.seen[warning] = true
+	w.list = append(w.list, warning)
+}
+
+// List returns the warnings collected so far, in the order they happened
+func (w *Warnings) List() []Warning {
+	if w == nil {
+		return nil
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return append([]Warning(nil), w.list...)
+}
+
+// searchWarning is returned along with the matches of a search that skipped
+// some files, such as unreadable ones. Its lines are the tool's complaints.
+type searchWarning struct {
+	lines []string
+}
+
+func (e *searchWarning) Error() string {
+	return strings.Join(e.lines, "; ")
+}
+
+// addSearchWarning records the complaints of a partial search, one per line
+func (w *Warnings) addSearchWarning(method string, err error) bool {
+	var partial *searchWarning
+	if !errors.As(err, &partial) {
+		return false
+	}
+	for _, line := range partial.lines {
+		w.add(method, "%s", line)
+	}
+	return true
+}
@@ -0,0 +1,30 @@
+package finder
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestWarnings(t *testing.T) {
+	w := &Warnings{}
+	partial := &searchWarning{lines: []string{"rg: a.py: Permission denied", "rg: b.py: Permission denied"}}
+	if !w.addSearchWarning("", partial) {
+		t.Fatal("addSearchWarning ignored a partial search")
+	}
+	// Every search of the batch or method hits the same unreadable files
+	w.addSearchWarning("", partial)
+	if w.addSearchWarning("load", errors.New("rg: regex parse error")) {
+		t.Error("addSearchWarning took a failed search for a partial one")
+	}
+	w.add("load", "%v", "rg: regex parse error")
+
+	want := []Warning{
+		{Message: "rg: a.py: Permission denied"},
+		{Message: "rg: b.py: Permission denied"},
+		{Method: "load", Message: "rg: regex parse error"},
+	}
+	if got := w.List(); !reflect.DeepEqual(got, want) {
+		t.Errorf("List = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/sanchezhs/py-broom/finder"
+	"github.com/spf13/cobra"
+)
+
+// fixOptions select what the fix command removes and how
+type fixOptions struct {
+	unused      bool // Delete the unused functions and methods
+	imports     bool // Also delete the imports only they used
+	dryRun      bool // Print a unified diff instead of editing the files
+	interactive bool // Ask before removing each definition
+}
+
+func newFixCmd(opts *options) *cobra.Command {
+	fix := &fixOptions{}
+	cmd := &cobra.Command{
+		Use:   "fix --unused [file.py ...]",
+		Short: "Delete unused functions and methods from the sources",
+		Long: "Delete unused functions and methods from the sources.\n\n" +
+			"The definitions the unused command reports are removed with their decorators,\n" +
+			"and blocks left empty get a pass statement. --imports also drops the names\n" +
+			"of single-line imports only the removed code used. --dry-run prints the\n" +
+			"changes as a unified diff instead, and --interactive asks for each definition.",
+		SilenceUsage: true,
+		Args:         cobra.ArbitraryArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if !fix.unused {
+				return fmt.Errorf("%s: nothing to fix, pass --unused", programName)
+			}
+			if opts.stream || opts.summaryOnly {
+				return fmt.Errorf("%s: fix can not be combined with --stream or --summary-only", programName)
+			}
+			opts.fix = fix
+			unusedDefaults(cmd, opts)
+			return runAnalysis(cmd, opts, finder.SymbolFunction, args)
+		},
+	}
+	cmd.Flags().BoolVar(&fix.unused, "unused", false, "Delete the functions and methods without real usages")
+	cmd.Flags().BoolVar(&fix.imports, "imports", false, "Also delete the imported names only the removed definitions used")
+	cmd.Flags().BoolVar(&fix.dryRun, "dry-run", false, "Print the changes as a unified diff (to --output if set) without editing any file")
+	cmd.Flags().BoolVar(&fix.interactive, "interactive", false, "Show each removal and ask before making it")
+	return cmd
+}
+
+// memberReferences returns where the names of the definitions of results
+// are used as .name or @name in the directories analyzed or searched, which
+// the fix and clean commands never delete
+func memberReferences(ctx context.Context, opts *options, results []finder.MethodUsage, searchDirs []string, encoding string) (map[string]string, error) {
+	methods := make([]finder.Method, len(results))
+	for i, r := range results {
+		methods[i] = r.Method
+	}
+	refs, err := finder.MemberReferences(ctx, methods, append(slices.Clone(opts.dirs), searchDirs...), newDirFilter(opts), encoding)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", programName, err)
+	}
+	return refs, nil
+}
+
+// unremovable explains why the fix and clean commands keep m, empty when
+// they may delete it
+func unremovable(m finder.Method, refs map[string]string) string {
+	if reason := finder.Unremovable(m); reason != "" {
+		return reason
+	}
+	if ref := refs[m.Name]; ref != "" {
+		return fmt.Sprintf("referenced as an attribute or decorator at %s", ref)
+	}
+	return ""
+}
+
+// fixResults deletes the definitions of results from their files, or prints
+// the diff doing it with --dry-run. The definitions refs mentions are kept.
+func fixResults(opts *options, results []finder.MethodUsage, refs map[string]string) error {
+	// Messages go to stderr when stdout carries the diff
+	report := io.Writer(os.Stdout)
+	verb := "Removed"
+	if opts.fix.dryRun {
+		verb = "Would remove"
+		if opts.output == "" {
+			report = os.Stderr
+		}
+	}
+
+	byFile := make(map[string][]finder.Method)
+	var files []string
+	for _, r := range results {
+		m := r.Method
+		if reason := unremovable(m, refs); reason != "" {
+			fmt.Fprintf(os.Stderr, "%s: skipping %s at %s:%d, %s\n", programName, methodLabel(m), m.Filename, m.LineNo, reason)
+			continue
+		}
+		if byFile[m.Filename] == nil {
+			files = append(files, m.Filename)
+		}
+		byFile[m.Filename] = append(byFile[m.Filename], m)
+	}
+	sort.Strings(files)
+
+	var prompt *bufio.Reader
+	if opts.fix.interactive {
+		prompt = bufio.NewReader(os.Stdin)
+	}
+	var diff strings.Builder
+	removed, changed := 0, 0
+	for _, path := range files {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("%s: %w", programName, err)
+		}
+		if bytes.HasPrefix(data, []byte{0xff, 0xfe}) || bytes.HasPrefix(data, []byte{0xfe, 0xff}) {
+			fmt.Fprintf(os.Stderr, "%s: skipping %s, UTF-16 sources are not edited\n", programName, path)
+			continue
+		}
+		lines := strings.Split(string(data), "\n")
+		newline := lines[len(lines)-1] == ""
+		if newline {
+			lines = lines[:len(lines)-1]
+		}
+
+		methods := byFile[path]
+		sort.Slice(methods, func(i, j int) bool { return methods[i].LineNo < methods[j].LineNo })
+		quit := false
+		if prompt != nil {
+			methods, quit = confirmRemovals(prompt, path, lines, methods, opts.fix.imports)
+		}
+		edits, deleted := finder.RemoveDefinitions(lines, methods, opts.fix.imports)
+		for _, m := range methods {
+			if !slices.ContainsFunc(deleted, func(d finder.Method) bool { return d.LineNo == m.LineNo }) {
+				fmt.Fprintf(os.Stderr, "%s: skipping %s at %s:%d, its name is still used in the file\n", programName, methodLabel(m), path, m.LineNo)
+			}
+		}
+		if len(edits) > 0 {
+			if opts.fix.dryRun {
+				diff.WriteString(finder.UnifiedDiff(path, lines, edits))
+			} else {
+				fixed := strings.Join(finder.ApplyEdits(lines, edits), "\n")
+				if newline {
+					fixed += "\n"
+				}
+				err := replaceFile(path, func(w io.Writer) error {
+					_, err := io.WriteString(w, fixed)
+					return err
+				})
+				if err != nil {
+					return fmt.Errorf("%s: %w", programName, err)
+				}
+			}
+			for _, m := range deleted {
+				fmt.Fprintf(report, "%s %s (%s:%d)\n", verb, methodLabel(m), m.Filename, m.LineNo)
+			}
+			removed += len(deleted)
+			changed++
+		}
+		if quit {
+			break
+		}
+	}
+
+	if opts.fix.dryRun {
+		err := writeOutput(opts, func(w io.Writer) error {
+			_, err := io.WriteString(w, diff.String())
+			return err
+		})
+		if err != nil {
+			return err
+		}
+	}
+	fmt.Fprintf(report, "%s: %s %d unused definitions from %d files\n", programName, verb, removed, changed)
+	return nil
+}
+
+// confirmRemovals shows the removal of each method of a file and keeps the
+// ones accepted. quit reports that no other file should be asked about.
+func confirmRemovals(prompt *bufio.Reader, path string, lines []string, methods []finder.Method, imports bool) (accepted []finder.Method, quit bool) {
+	for _, m := range methods {
+		edits, deleted := finder.RemoveDefinitions(lines, []finder.Method{m}, imports)
+		if len(deleted) == 0 {
+			// Reported as skipped once the file is fixed
+			accepted = append(accepted, m)
+			continue
+		}
+		fmt.Print(finder.UnifiedDiff(path, lines, edits))
+		fmt.Printf("Remove %s (%s:%d)? [y/n/q] ", methodLabel(m), path, m.LineNo)
+		answer, err := prompt.ReadString('\n')
+		switch strings.ToLower(strings.TrimSpace(answer)) {
+		case "y", "yes":
+			accepted = append(accepted, m)
+		case "q", "quit":
+			return accepted, true
+		}
+		if err != nil {
+			// Input ended, the remaining definitions are kept
+			fmt.Println()
+			return accepted, true
+		}
+	}
+	return accepted, false
+}
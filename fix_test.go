@@ -0,0 +1,121 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fixProject is a package whose unused code fix --unused removes. The other
+// definitions are used in ways the usage search does not resolve, or are
+// only shown in strings, and must survive for app.py to run.
+var fixProject = map[string]string{
+	"lib.py": `"""Helpers.
+
+Example:
+
+    def example():
+        return 1
+"""
+
+
+class Svc:
+    def handle(self):
+        return "handled"
+
+    def on_event(self):
+        return "event"
+
+    def dead(self):
+        return "dead"
+
+
+def decor(fn):
+    return fn
+
+
+def unused_helper():
+    return 1
+`,
+	"app.py": `from lib import Svc, decor
+
+
+@decor
+def main():
+    svc = Svc()
+    callbacks = [svc.on_event]
+    return svc.handle() + " " + callbacks[0]()
+
+
+if __name__ == "__main__":
+    print(main())
+`,
+}
+
+func TestFixKeepsCodeInUse(t *testing.T) {
+	python := lookPython(t)
+	dir := writeProject(t, fixProject)
+
+	cmd := newRootCmd()
+	cmd.SetArgs([]string{"fix", "--unused", "--no-config", "--search-backend", "native", "--dir", dir})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("fix --unused: %v", err)
+	}
+	checkFixedProject(t, python, dir)
+}
+
+// lookPython returns the python3 interpreter, skipping the test without one
+func lookPython(t *testing.T) string {
+	t.Helper()
+	python, err := exec.LookPath("python3")
+	if err != nil {
+		t.Skip("python3 is not installed")
+	}
+	return python
+}
+
+// writeProject writes files to a new directory and returns it
+func writeProject(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, src := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(src), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+// checkFixedProject checks that only the unused code of fixProject is gone
+// from dir and that app.py still runs
+func checkFixedProject(t *testing.T, python, dir string) {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(dir, "lib.py"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	lib := string(data)
+	for _, gone := range []string{"def dead", "def unused_helper"} {
+		if strings.Contains(lib, gone) {
+			t.Errorf("lib.py still has %q:\n%s", gone, lib)
+		}
+	}
+	for _, kept := range []string{"def example", "def handle", "def on_event", "def decor"} {
+		if !strings.Contains(lib, kept) {
+			t.Errorf("lib.py lost %q:\n%s", kept, lib)
+		}
+	}
+
+	run := exec.Command(python, "app.py")
+	run.Dir = dir
+	run.Env = append(os.Environ(), "PYTHONDONTWRITEBYTECODE=1")
+	out, err := run.CombinedOutput()
+	if err != nil {
+		t.Fatalf("python3 app.py: %v\n%s", err, out)
+	}
+	if got := strings.TrimSpace(string(out)); got != "handled event" {
+		t.Errorf("python3 app.py printed %q, want %q", got, "handled event")
+	}
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/sanchezhs/py-broom/finder"
+	"github.com/sanchezhs/py-broom/printers"
+	"github.com/spf13/cobra"
+)
+
+// historySchema creates the table of the recorded runs on first use
+const historySchema = `CREATE TABLE IF NOT EXISTS runs (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	recorded_at TEXT NOT NULL,
+	commit_sha TEXT NOT NULL DEFAULT '',
+	label TEXT NOT NULL DEFAULT '',
+	methods INTEGER NOT NULL,
+	unused INTEGER NOT NULL
+);
+`
+
+// historyOptions are the flags of the history commands
+type historyOptions struct {
+	db    string // SQLite database, .pybroom/history.db in the first --dir by default
+	label string // Release or tag the recorded run stands for
+	from  string // JSON output of a run to record instead of analyzing
+	last  int    // Runs shown, 0 for all
+}
+
+func newHistoryCmd(opts *options) *cobra.Command {
+	history := &historyOptions{}
+	cmd := &cobra.Command{
+		Use:   "history",
+		Short: "Record runs in a local SQLite database and show how dead code evolves",
+		Long: "Record runs in a local SQLite database and show how dead code evolves.\n\n" +
+			"The database is written with the sqlite3 command line shell, which must be\n" +
+			"installed, at --db or .pybroom/history.db in the first --dir.",
+	}
+	cmd.PersistentFlags().StringVar(&history.db, "db", "", "History database (default .pybroom/history.db in the first --dir)")
+
+	record := &cobra.Command{
+		Use:   "record [file.py ...]",
+		Short: "Analyze the project and append its definition and unused counts to the history",
+		Long: "Analyze the project and append its definition and unused counts to the history.\n\n" +
+			"Definitions are counted as unused like the unused command does. --from records\n" +
+			"the --format json output of an earlier run instead, and --label names the run,\n" +
+			"e.g. after a release. The commit checked out in the first --dir is kept too.",
+		SilenceUsage: true,
+		Args:         cobra.ArbitraryArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if history.from != "" {
+				results, err := readResults(history.from)
+				if err != nil {
+					return fmt.Errorf("%s: %s: %w", programName, history.from, err)
+				}
+				opts.analyzedCount, opts.unusedCount = len(results), len(finder.FilterUnused(results))
+				return recordHistory(cmd.Context(), opts, history)
+			}
+			if opts.stream || opts.summaryOnly {
+				return fmt.Errorf("%s: history record can not be combined with --stream or --summary-only", programName)
+			}
+			unusedDefaults(cmd, opts)
+			// Every definition is counted, whether unused or not
+			opts.unused = false
+			opts.history = history
+			return runAnalysis(cmd, opts, finder.SymbolFunction, args)
+		},
+	}
+	record.Flags().StringVar(&history.label, "label", "", "Name of the run, such as a release tag")
+	record.Flags().StringVar(&history.from, "from", "", "Record this --format json output instead of analyzing the project")
+
+	show := &cobra.Command{
+		Use:   "show",
+		Short: "Show the recorded runs and the trends of the definition and unused counts",
+		Long: "Show the recorded runs and the trends of the definition and unused counts, in the\n" +
+			"console, table, markdown or json format.",
+		SilenceUsage: true,
+		Args:         cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			pr, err := newPrinter(cmd, opts, nil, nil)
+			if pr == nil {
+				return err
+			}
+			hp, ok := pr.(printers.HistoryPrinter)
+			if !ok {
+				return fmt.Errorf("%s: format '%s' does not support history show", programName, opts.format)
+			}
+			entries, err := readHistory(cmd.Context(), historyDB(opts, history), history.last)
+			if err != nil {
+				return err
+			}
+			return writeOutput(opts, func(w io.Writer) error { return hp.PrintHistory(w, entries) })
+		},
+	}
+	show.Flags().IntVar(&history.last, "last", 0, "Only show the last N runs (0 = all)")
+
+	cmd.AddCommand(record, show)
+	return cmd
+}
+
+// historyDB returns the path of the history database
+func historyDB(opts *options, history *historyOptions) string {
+	if history.db != "" {
+		return history.db
+	}
+	dir := "."
+	if len(opts.dirs) > 0 {
+		dir = opts.dirs[0]
+	}
+	return filepath.Join(dir, ".pybroom", "history.db")
+}
+
+// recordHistory appends the counts of the run to the history database
+func recordHistory(ctx context.Context, opts *options, history *historyOptions) error {
+	db := historyDB(opts, history)
+	if err := os.MkdirAll(filepath.Dir(db), 0o755); err != nil {
+		return fmt.Errorf("%s: %w", programName, err)
+	}
+	dir := "."
+	if len(opts.dirs) > 0 {
+		dir = opts.dirs[0]
+	}
+	commit, _ := finder.HeadCommit(dir)
+	insert := fmt.Sprintf("INSERT INTO runs (recorded_at, commit_sha, label, methods, unused) VALUES (%s, %s, %s, %d, %d);\n",
+		sqlQuote(time.Now().UTC().Format(time.RFC3339)), sqlQuote(commit), sqlQuote(history.label), opts.analyzedCount, opts.unusedCount)
+	if _, err := runSQLite(ctx, db, historySchema+insert); err != nil {
+		return err
+	}
+	// Like the other status messages, kept out of the output of the run
+	fmt.Fprintf(os.Stderr, "%s: recorded %d definitions, %d unused, in %s\n", programName, opts.analyzedCount, opts.unusedCount, db)
+	return nil
+}
+
+// readHistory returns the last runs recorded in db, all of them when last
+// is 0, oldest first
+func readHistory(ctx context.Context, db string, last int) ([]finder.HistoryEntry, error) {
+	if _, err := os.Stat(db); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("%s: no history at %s, record runs with 'history record' first", programName, db)
+		}
+		return nil, fmt.Errorf("%s: %w", programName, err)
+	}
+	query := `SELECT id, recorded_at, commit_sha AS "commit", label, methods, unused FROM runs ORDER BY id`
+	if last > 0 {
+		query = fmt.Sprintf(`SELECT * FROM (%s DESC LIMIT %d) ORDER BY id`, query, last)
+	}
+	out, err := runSQLite(ctx, db, historySchema+query+";\n", "-json")
+	if err != nil {
+		return nil, err
+	}
+	var entries []finder.HistoryEntry
+	if len(bytes.TrimSpace(out)) == 0 {
+		// No row, sqlite3 prints nothing rather than []
+		return entries, nil
+	}
+	if err := json.Unmarshal(out, &entries); err != nil {
+		return nil, fmt.Errorf("%s: reading %s: %w", programName, db, err)
+	}
+	return entries, nil
+}
+
+// runSQLite runs statements against db with the sqlite3 shell and returns
+// what it printed
+func runSQLite(ctx context.Context, db, statements string, args ...string) ([]byte, error) {
+	if _, err := exec.LookPath("sqlite3"); err != nil {
+		return nil, fmt.Errorf("%s: the history needs the sqlite3 command line shell: %w", programName, err)
+	}
+	cmd := exec.CommandContext(ctx, "sqlite3", append(append([]string{"-batch", "-bail"}, args...), db)...)
+	cmd.Stdin = strings.NewReader(statements)
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("%s: sqlite3 %s: %s", programName, db, msg)
+		}
+		return nil, fmt.Errorf("%s: sqlite3 %s: %w", programName, db, err)
+	}
+	return out, nil
+}
+
+// sqlQuote writes s as an SQL string literal
+func sqlQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
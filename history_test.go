@@ -0,0 +1,80 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/sanchezhs/py-broom/finder"
+)
+
+func TestHistoryRoundTrip(t *testing.T) {
+	if _, err := exec.LookPath("sqlite3"); err != nil {
+		t.Skip("sqlite3 is not installed")
+	}
+	dir := writeProject(t, map[string]string{
+		"lib.py": "def used():\n    return 1\n\n\ndef unused():\n    return 2\n",
+		"app.py": "from lib import used\n\nused()\n",
+	})
+	db := filepath.Join(t.TempDir(), "history.db")
+	run := func(args ...string) {
+		t.Helper()
+		cmd := newRootCmd()
+		cmd.SetArgs(append(args, "--no-config", "--dir", dir, "--db", db))
+		if err := cmd.Execute(); err != nil {
+			t.Fatalf("%v: %v", args, err)
+		}
+	}
+
+	// The confirmation of record stays out of the output of the run
+	stdout := redirectStdout(t)
+	for _, label := range []string{"v1.0", "it's v2.0", ""} {
+		run("history", "record", "--label", label, "--search-backend", "native")
+	}
+	if out := stdout(); out != "" {
+		t.Errorf("history record printed %q to stdout", out)
+	}
+
+	out := filepath.Join(t.TempDir(), "history.json")
+	run("history", "show", "--last", "2", "--format", "json", "-o", out)
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var entries []finder.HistoryEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		t.Fatalf("invalid JSON: %v\n%s", err, data)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("got %d runs, want the last 2:\n%s", len(entries), data)
+	}
+	for i, label := range []string{"it's v2.0", ""} {
+		e := entries[i]
+		if e.ID != i+2 || e.Label != label || e.Methods != 2 || e.Unused != 1 || e.RecordedAt.IsZero() {
+			t.Errorf("run %d = %+v, want id %d labeled %q with 2 definitions, 1 unused", i, e, i+2, label)
+		}
+	}
+}
+
+// redirectStdout sends os.Stdout to a file until the returned function reads
+// back what was written
+func redirectStdout(t *testing.T) func() string {
+	t.Helper()
+	f, err := os.Create(filepath.Join(t.TempDir(), "stdout"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	saved := os.Stdout
+	os.Stdout = f
+	t.Cleanup(func() { os.Stdout = saved })
+	return func() string {
+		os.Stdout = saved
+		data, err := os.ReadFile(f.Name())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(data)
+	}
+}
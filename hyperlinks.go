@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+
+	"github.com/sanchezhs/py-broom/printers"
+)
+
+// Values of --hyperlinks
+const (
+	hyperlinksAuto   = "auto"
+	hyperlinksAlways = "always"
+	hyperlinksNever  = "never"
+)
+
+// hyperlinkTerminals are the $TERM_PROGRAM and $TERM values of terminals
+// known to support OSC 8 hyperlinks
+var hyperlinkTerminals = []string{
+	"iTerm.app", "WezTerm", "vscode", "ghostty", "Hyper", "Tabby", "rio",
+	"xterm-kitty", "xterm-ghostty", "foot", "foot-extra", "alacritty", "wezterm",
+}
+
+// linkTemplate returns the URL template of the hyperlinked locations selected
+// by --hyperlinks and --hyperlink-format, empty when locations are plain text
+func linkTemplate(opts *options) (string, error) {
+	switch opts.hyperlinks {
+	case hyperlinksNever:
+		return "", nil
+	case hyperlinksAlways:
+		return opts.hyperlinkFormat, nil
+	case hyperlinksAuto:
+		if opts.output == "" && isTerminal(os.Stdout) && supportsHyperlinks() {
+			return opts.hyperlinkFormat, nil
+		}
+		return "", nil
+	}
+	return "", fmt.Errorf("%s: invalid --hyperlinks '%s', valid values are auto, always, never", programName, opts.hyperlinks)
+}
+
+// supportsHyperlinks guesses from the environment whether the terminal
+// renders OSC 8 hyperlinks, as terminals can not be asked
+func supportsHyperlinks() bool {
+	if os.Getenv("TERM") == "dumb" {
+		return false
+	}
+	if slices.Contains(hyperlinkTerminals, os.Getenv("TERM_PROGRAM")) || slices.Contains(hyperlinkTerminals, os.Getenv("TERM")) {
+		return true
+	}
+	for _, env := range []string{"WT_SESSION", "KITTY_WINDOW_ID", "KONSOLE_VERSION", "DOMTERM"} {
+		if os.Getenv(env) != "" {
+			return true
+		}
+	}
+	// GNOME Terminal, Tilix and other VTE terminals since 0.50
+	vte, err := strconv.Atoi(os.Getenv("VTE_VERSION"))
+	return err == nil && vte >= 5000
+}
+
+// defaultHyperlinkFormat opens locations as files, unless $PYBR_HYPERLINK_FORMAT
+// sets an editor URL such as vscode://file{path}:{line}:{col}
+func defaultHyperlinkFormat() string {
+	if format := os.Getenv("PYBR_HYPERLINK_FORMAT"); format != "" {
+		return format
+	}
+	return printers.DefaultLinks
+}
@@ -2,10 +2,21 @@ package main
 
 import (
 	"bufio"
+	"context"
+	"errors"
 	"fmt"
+	"io"
 	"log"
 	"os"
 	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/sanchezhs/py-broom/finder"
 	"github.com/sanchezhs/py-broom/printers"
@@ -14,160 +25,1229 @@ import (
 
 const programName = "pybr"
 
+// options holds the flags shared by the root command and its subcommands
+type options struct {
+	dirs            []string
+	searchDirs      []string
+	output          string
+	verbose         bool
+	format          string
+	skipImports     bool
+	skipPrivate     bool
+	skipDecorated   []string
+	nameRegex       string
+	methods         []string
+	skipTests       bool
+	testGlobs       []string
+	skipDefinitions bool
+	noIgnore        bool
+	exclude         []string
+	excludeMethods  []string
+	include         []string
+	stubs           bool
+	extensions      []string
+	followSymlinks  bool
+	maxDepth        int
+	docReferences   bool
+	includeComments bool
+	attrReferences  bool
+	strReferences   bool
+	localNested     bool
+	encoding        string
+	context         int
+	changedSince    string
+	engine          string
+	rgArgs          []string
+	refIndex        bool
+	jobs            int
+	searchProcs     int
+	stream          bool
+	summary         bool
+	summaryOnly     bool
+	maxStored       int
+	usageCap        int
+	stats           bool
+	shard           string
+	groupBy         string
+	noColor         bool
+	noHighlight     bool
+	compact         bool // One line per method in the console format
+	usageBuckets    string
+	usageLabels     []string
+	buckets         *finder.UsageBuckets // Parsed from --usage-buckets and --usage-labels, nil for the defaults
+	hyperlinks      string
+	hyperlinkFormat string
+	paths           string // How printed paths are written: as found, relative or absolute
+	pathsRoot       string
+	csvMode         string
+	csvMeta         bool
+	browse          bool   // Set by the tui command
+	marks           string // File the tui command saves marked methods to
+	minUsages       int
+	maxUsages       int
+	minComplexity   int
+	minConfidence   string
+	sortBy          string
+	asc             bool
+	top             int
+	report          string
+	totalMethods    int                   // Definitions analyzed, for the json format
+	started         time.Time             // Start of the analysis, for the duration of structured reports
+	graph           printers.GraphOptions // Style of --format graphviz, set by the graph command
+	focus           string                // Only draw the graph around this method or module
+	focusDepth      int
+	unused          bool                // Report only definitions without real usages
+	onlyProblems    bool                // Report only unused definitions and those below --min-usages
+	fix             *fixOptions         // Set by the fix command, editing the results out of the sources
+	clean           *cleanOptions       // Set by the clean command, walking through the results
+	history         *historyOptions     // Set by history record, storing the counts of the run
+	baseline        string              // File listing the definitions accepted as unused
+	accepted        *finder.Baseline    // Loaded from --baseline
+	budget          finder.UnusedBudget // Unused definitions failing the run with exitBudget
+	unusedCount     int                 // Unused definitions among the analyzed ones, for the budget
+	analyzedCount   int
+	duplicates      bool    // Compare function bodies instead of searching usages
+	similarity      float64 // Minimum body overlap reported by the duplicates command
+	timeout         time.Duration
+	cpuProfile      string
+	memProfile      string
+	traceFile       string
+	config          string // Configuration file holding defaults of the flags
+	noConfig        bool
+}
+
+// errUnusedFound makes the unused command exit with a non-zero status
+var errUnusedFound = errors.New("unused definitions found")
+
+// Exit status of runs whose unused definitions exceed --fail-on-unused,
+// --max-unused or --max-unused-percent
+const exitBudget = 2
+
+// budgetError ends a run exceeding the unused budget, its report printed
+type budgetError struct{ msg string }
+
+func (e *budgetError) Error() string { return e.msg }
+
 func main() {
-	if err := newRootCmd().Execute(); err != nil {
+	// Ctrl-C cancels the analysis, stopping running ripgrep processes
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	go func() {
+		// The results gathered so far are still printed; a second Ctrl-C
+		// kills the process at once, output files are only replaced whole
+		<-ctx.Done()
+		stop()
+	}()
+	err := newRootCmd().ExecuteContext(ctx)
+	stop()
+	if err != nil {
+		var partial *interruptedError
+		var budget *budgetError
+		switch {
+		case errors.Is(err, errUnusedFound):
+		case errors.As(err, &partial):
+			fmt.Fprintln(os.Stderr, partial)
+			os.Exit(partial.exitCode())
+		case errors.As(err, &budget):
+			fmt.Fprintln(os.Stderr, budget)
+			os.Exit(exitBudget)
+		default:
+			fmt.Fprintln(os.Stderr, "Error:", err)
+		}
 		os.Exit(1)
 	}
 }
 
 func newRootCmd() *cobra.Command {
-	var (
-		dir             string
-		output          string
-		verbose         bool
-		format          string
-		skipImports     bool
-		skipPrivate     bool
-		skipTests       bool
-		skipDefinitions bool
-		noColor         bool
-		minUsages       int
-		maxUsages       int
-		sortBy          string
-		asc             bool
-	)
+	opts := &options{}
 
 	rootCmd := &cobra.Command{
-		Use:          programName,
-		Short:        "Analyze Python method usages across a repository",
-		SilenceUsage: true,         // Do not print usage on handled errors
-		Args:         cobra.NoArgs, // No unexpected positional args allowed
+		Use:   programName + " [file.py ...]",
+		Short: "Analyze Python method usages across a repository",
+		Long: "Analyze Python method usages across a repository.\n\n" +
+			"When files are given, only the functions defined in them are analyzed,\n" +
+			"while usages are still searched for in every --dir (or --search-dir).",
+		Version:       toolVersion(),
+		SilenceUsage:  true, // Do not print usage on handled errors
+		SilenceErrors: true, // Printed by main, which knows which errors are findings
+		Args:          cobra.ArbitraryArgs,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			if asc && !cmd.Flags().Changed("sort-by") {
-				return fmt.Errorf("--asc flag can only be used together with --sort-by")
-			}
-			// Verbose banner
-			if verbose {
-				log.Printf("Searching for Python files in: %s\n", dir)
-			}
+			return runAnalysis(cmd, opts, finder.SymbolFunction, args)
+		},
+	}
 
-			// Check ripgrep
-			if _, err := exec.LookPath("rg"); err != nil {
-				fmt.Printf("%s: Error ripgrep (rg) is not installed. Please install it first.\n", programName)
-				return nil
-			}
+	flags := rootCmd.PersistentFlags()
+	flags.StringSliceVarP(&opts.dirs, "dir", "d", []string{"."}, "Directory to search for Python files (repeatable or comma separated)")
+	flags.StringSliceVar(&opts.searchDirs, "search-dir", nil, "Directory to search for usages, defaults to --dir (repeatable or comma separated, counts are reported per directory)")
+	flags.StringVarP(&opts.output, "output", "o", "", "Output file (optional, defaults to stdout)")
+	flags.BoolVarP(&opts.verbose, "verbose", "v", false, "Show detailed information during execution")
+	flags.StringVar(&opts.format, "format", "console", fmt.Sprintf("How to output results, valid types are %v", printers.GetKinds()))
+	flags.BoolVar(&opts.skipImports, "skip-imports", false, "Skip import statements in usage results")
+	flags.BoolVar(&opts.skipPrivate, "skip-private", false, "Skip private methods (starting with _)")
+	flags.StringVar(&opts.nameRegex, "name-regex", "", "Only analyze names matching this regular expression (e.g. '^handle_')")
+	flags.StringArrayVar(&opts.methods, "method", nil, "Only analyze this method: name, Class.name or file.py:Class.name (repeatable)")
+	flags.StringSliceVar(&opts.skipDecorated, "skip-decorated", nil, "Skip methods carrying any of these decorators (e.g. pytest.fixture,app.route)")
+	flags.BoolVar(&opts.skipTests, "skip-tests", true, "Skip methods definitions in tests files")
+	flags.StringArrayVar(&opts.testGlobs, "test-glob", finder.DefaultTestGlobs, "Glob identifying test files (repeatable, e.g. 'tests/**')")
+	flags.BoolVar(&opts.skipDefinitions, "skip-definitions", false, "Skip methods definitions")
+	flags.BoolVar(&opts.noIgnore, "no-ignore", false, "Do not respect .gitignore and .git/info/exclude files")
+	flags.StringArrayVar(&opts.exclude, "exclude", nil, "Glob of paths to exclude from discovery and usage search (repeatable, e.g. 'migrations/**')")
+	flags.StringArrayVar(&opts.excludeMethods, "exclude-method", nil, "Glob of names, or Class.names, of definitions never analyzed (repeatable, e.g. 'legacy_*', '*Admin.get_*')")
+	flags.StringArrayVar(&opts.include, "include", nil, "Only collect definitions from paths matching this glob (repeatable, e.g. 'src/**/*.py')")
+	flags.StringSliceVar(&opts.extensions, "ext", finder.DefaultExtensions, "File extensions to analyze (e.g. .py,.pyx,.pyi)")
+	flags.BoolVar(&opts.followSymlinks, "follow-symlinks", false, "Descend into symlinked directories (each directory is visited once)")
+	flags.IntVar(&opts.maxDepth, "max-depth", 0, "Limit discovery and search to N directory levels, counting the root as 1 (0 = no limit)")
+	flags.BoolVar(&opts.stubs, "stubs", false, "Include .pyi type stubs; stub references count as type-hint usages")
+	flags.BoolVar(&opts.docReferences, "doc-references", false, "Count Sphinx cross-references (:func:, :meth:, ...) in docstrings as usages")
+	flags.BoolVar(&opts.includeComments, "include-comments", false, "Report mentions inside comments as their own usage type")
+	flags.BoolVar(&opts.attrReferences, "attribute-references", false, "Count methods passed without being called (callbacks such as map(parse, rows)) as usages")
+	flags.BoolVar(&opts.strReferences, "string-references", false, "Count dotted string paths (\"myapp.views.handler\" in settings or registries) as usages")
+	flags.BoolVar(&opts.localNested, "local-nested", false, "Only search the defining file for usages of functions nested in other functions")
+	flags.StringVar(&opts.shard, "shard", "", "Only analyze slice i of n of the definitions (e.g. 2/4), to split a run across CI jobs; see the merge command")
+	flags.StringVar(&opts.changedSince, "changed-since", "", "Only collect definitions from files changed since this git ref (e.g. main); usages are still searched everywhere")
+	flags.StringVar(&opts.encoding, "encoding", "", "Source file encoding: utf-8, latin-1, utf-16le, utf-16be (default: detected from BOM or coding cookie)")
+	flags.IntVarP(&opts.jobs, "jobs", "j", runtime.NumCPU(), "Number of files or methods processed concurrently")
+	flags.IntVar(&opts.searchProcs, "search-procs", 0, "Maximum search backend processes running at once (default: --jobs)")
+	flags.StringVar(&opts.engine, "search-backend", "", "Usage search backend: rg, gitgrep, ugrep, native (default: rg when installed, native otherwise)")
+	flags.StringVar(&opts.engine, "engine", "", "Usage search backend")
+	flags.MarkDeprecated("engine", "use --search-backend instead")
+	flags.BoolVar(&opts.refIndex, "index", false, "Keep a reference index under .pybroom/index in the first search directory and answer usage searches from it")
+	flags.StringArrayVar(&opts.rgArgs, "rg-arg", nil, "Extra argument appended to every ripgrep invocation (repeatable, e.g. --rg-arg=--threads=4)")
+	flags.IntVar(&opts.context, "context", 0, "Show N lines of source before and after each usage")
+	flags.BoolVar(&opts.noColor, "no-color", false, "Disable colored output")
+	flags.BoolVar(&opts.noHighlight, "no-highlight", false, "Disable the syntax highlighting of the source lines of usages in the console format")
+	flags.StringVar(&opts.usageBuckets, "usage-buckets", "", "Upper bounds LOW,MEDIUM of the low and medium usage counts, coloring counts, bucketing the summary and reporting low usage in sarif and codequality (default 2,5)")
+	flags.StringSliceVar(&opts.usageLabels, "usage-labels", nil, "Names of the unused, low, medium and high usage buckets in the summary (default Unused,Low usage,Medium,High usage)")
+	flags.BoolVar(&opts.compact, "compact", false, "Print one line per method with its location, total and usages per call type, without the usages (console format)")
+	flags.StringVar(&opts.paths, "paths", "", "Print paths relative to --paths-root (relative) or absolute (absolute) instead of as --dir gave them")
+	flags.StringVar(&opts.pathsRoot, "paths-root", "", "Directory relative paths start from (default: the git repository of the first --dir, else the current directory)")
+	flags.StringVar(&opts.hyperlinks, "hyperlinks", hyperlinksAuto, "Make console and table locations clickable OSC 8 hyperlinks: auto (supporting terminals), always, never")
+	flags.StringVar(&opts.hyperlinkFormat, "hyperlink-format", defaultHyperlinkFormat(), "URL of hyperlinked locations, with {path}, {line} and {col} placeholders (e.g. vscode://file{path}:{line}:{col})")
+	flags.BoolVar(&opts.csvMeta, "csv-meta", false, "Start --format csv with '# name: value' comment lines describing the run")
+	flags.StringVar(&opts.csvMode, "csv-mode", printers.CSVUsages, "Rows of --format csv: usages (one per usage) or summary (one per method)")
+	flags.IntVar(&opts.minUsages, "min-usages", -1, "Filter methods with at least N usages (-1 = no filter)")
+	flags.BoolVar(&opts.budget.FailOnUnused, "fail-on-unused", false, "Exit with status 2 when any definition is unused, after printing the report")
+	flags.IntVar(&opts.budget.Max, "max-unused", -1, "Exit with status 2 when more than N definitions are unused, after printing the report (-1 = no limit)")
+	flags.Float64Var(&opts.budget.MaxPercent, "max-unused-percent", -1, "Exit with status 2 when more than P percent of the analyzed definitions are unused, after printing the report (-1 = no limit)")
+	flags.StringVar(&opts.baseline, "baseline", "", "Leave out the definitions listed in this baseline file, written by the clean command")
+	flags.BoolVar(&opts.onlyProblems, "only-problems", false, "Print only unused methods and, with --min-usages, those below it")
+	flags.IntVar(&opts.maxUsages, "max-usages", -1, "Filter methods with at most N usages (-1 = no filter)")
+	flags.StringVar(&opts.minConfidence, "min-confidence", string(finder.ConfidenceLow), "Only count usages at least this likely to refer to the method: low, medium, high")
+	flags.IntVar(&opts.minComplexity, "min-complexity", 0, "Only report methods with a cyclomatic complexity of at least N (0 = no filter)")
+	flags.StringVar(&opts.groupBy, "group-by", "", "Summarize results per file, package or module instead of per method, or print them in sections per calltype (call types used) or dir (top-level directory)")
+	flags.DurationVar(&opts.timeout, "timeout", 0, "Stop the analysis after this long and print the partial results (e.g. 5m, 0 = no limit)")
+	flags.IntVar(&opts.usageCap, "max-usages-per-method", 0, "Stop collecting usages of a method after N matches, reporting its count as a lower bound (0 = no limit)")
+	flags.BoolVar(&opts.stats, "stats", false, "Print the statistics of the run along with the results (json and sarif formats)")
+	flags.IntVar(&opts.maxStored, "max-stored-usages", 0, "Keep at most N usages per method in memory, counts still include every usage (0 = no limit)")
+	flags.BoolVar(&opts.summary, "summary", false, "Print usage statistics after the results; json, jsonl, pb, sarif, junit and tap embed them")
+	flags.BoolVar(&opts.summaryOnly, "summary-only", false, "Print only usage statistics, aggregated as results complete without keeping them in memory")
+	flags.BoolVar(&opts.stream, "stream", false, "Print each result as soon as it is analyzed, in completion order (console, jsonl, vimgrep and grep formats)")
+	flags.StringVar(&opts.sortBy, "sort-by", "file", "Sort results by: name, file, usages, complexity; a -asc or -desc suffix sets the direction (e.g. usages-desc)")
+	flags.BoolVar(&opts.asc, "asc", false, "Sort ascending (only valid when --sort-by is used)")
+	flags.IntVar(&opts.top, "top", 0, "Only report the first N results once sorted (0 = all)")
+
+	flags.StringVar(&opts.cpuProfile, "cpuprofile", "", "Write a CPU profile of the run to this file (go tool pprof)")
+	flags.StringVar(&opts.memProfile, "memprofile", "", "Write a memory profile at the end of the run to this file (go tool pprof)")
+	flags.StringVar(&opts.traceFile, "trace", "", "Write an execution trace of the run to this file (go tool trace)")
+	flags.StringVar(&opts.config, "config", "", "Configuration file: .pybroom.yaml, pybroom.toml or pyproject.toml (default: the closest one from the first --dir up)")
+	flags.BoolVar(&opts.noConfig, "no-config", false, "Ignore configuration and .pybroomignore files")
+	rootCmd.PersistentPreRunE = func(cmd *cobra.Command, args []string) error {
+		if err := applyConfig(cmd, opts); err != nil {
+			return err
+		}
+		if err := applyIgnoreFile(opts); err != nil {
+			return err
+		}
+		if err := checkGlobs(opts); err != nil {
+			return err
+		}
+		stop, err := startProfiling(opts)
+		if err != nil {
+			return err
+		}
+		// Finalizers run once the command returns, even with an error
+		cobra.OnFinalize(stop)
+		return nil
+	}
+
+	rootCmd.AddCommand(newVarsCmd(opts))
+	rootCmd.AddCommand(newAttrsCmd(opts))
+	rootCmd.AddCommand(newUnusedCmd(opts))
+	rootCmd.AddCommand(newDuplicatesCmd(opts))
+	rootCmd.AddCommand(newGraphCmd(opts))
+	rootCmd.AddCommand(newDaemonCmd(opts))
+	rootCmd.AddCommand(newMCPCmd(opts))
+	rootCmd.AddCommand(newFixCmd(opts))
+	rootCmd.AddCommand(newCleanCmd(opts))
+	rootCmd.AddCommand(newBenchCmd(opts))
+	rootCmd.AddCommand(newMergeCmd(opts))
+	rootCmd.AddCommand(newDiffCmd(opts))
+	rootCmd.AddCommand(newHistoryCmd(opts))
+	rootCmd.AddCommand(newTUICmd(opts))
+	rootCmd.AddCommand(newReportCmd(opts))
+	rootCmd.AddCommand(newSchemaCmd())
 
-			// Read python files
-			files, err := finder.ReadDir(dir)
-			if err != nil {
-				fmt.Printf("%s: Error reading directory: %v\n", programName, err)
-				return nil
+	return rootCmd
+}
+
+func newVarsCmd(opts *options) *cobra.Command {
+	return &cobra.Command{
+		Use:          "vars [file.py ...]",
+		Short:        "Analyze module-level variable and constant usages",
+		SilenceUsage: true,
+		Args:         cobra.ArbitraryArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runAnalysis(cmd, opts, finder.SymbolVariable, args)
+		},
+	}
+}
+
+func newAttrsCmd(opts *options) *cobra.Command {
+	return &cobra.Command{
+		Use:          "attrs [file.py ...]",
+		Short:        "Analyze class attribute reads and writes",
+		SilenceUsage: true,
+		Args:         cobra.ArbitraryArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runAnalysis(cmd, opts, finder.SymbolAttribute, args)
+		},
+	}
+}
+
+func newUnusedCmd(opts *options) *cobra.Command {
+	return &cobra.Command{
+		Use:   "unused [file.py ...]",
+		Short: "Report methods without any real usage",
+		Long: "Report methods without any real usage.\n\n" +
+			"Imports, definitions, comments and doc references do not count as usages,\n" +
+			"and dunder methods, test functions and framework hooks (properties, routes,\n" +
+			"fixtures, ...) are never reported. Exits with status 1 when any is found.",
+		SilenceUsage: true,
+		Args:         cobra.ArbitraryArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			unusedDefaults(cmd, opts)
+			return runAnalysis(cmd, opts, finder.SymbolFunction, args)
+		},
+	}
+}
+
+// unusedDefaults selects the unused definitions and turns on the usage
+// searches that keep a definition alive, unless the flags say otherwise
+func unusedDefaults(cmd *cobra.Command, opts *options) {
+	opts.unused = true
+	if !cmd.Flags().Changed("skip-imports") {
+		opts.skipImports = true
+	}
+	if !cmd.Flags().Changed("skip-definitions") {
+		opts.skipDefinitions = true
+	}
+	if !cmd.Flags().Changed("attribute-references") {
+		opts.attrReferences = true
+	}
+	if !cmd.Flags().Changed("string-references") {
+		opts.strReferences = true
+	}
+}
+
+func newDuplicatesCmd(opts *options) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "duplicates [file.py ...]",
+		Short: "Report functions with identical or highly similar bodies",
+		Long: "Report functions with identical or highly similar bodies.\n\n" +
+			"Bodies are compared after normalizing local names, literals, comments and\n" +
+			"docstrings, so copies that were only renamed are still reported as duplicates.",
+		SilenceUsage: true,
+		Args:         cobra.ArbitraryArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if opts.similarity <= 0 || opts.similarity > 1 {
+				return fmt.Errorf("%s: --similarity must be in (0, 1]", programName)
 			}
-			if verbose {
-				log.Printf("Found %d Python files\n", len(files))
+			opts.duplicates = true
+			return runAnalysis(cmd, opts, finder.SymbolFunction, args)
+		},
+	}
+	cmd.Flags().Float64Var(&opts.similarity, "similarity", finder.DefaultSimilarity, "Minimum body overlap, from 0 to 1, to report two functions as similar")
+	return cmd
+}
+
+func newGraphCmd(opts *options) *cobra.Command {
+	var kind string
+	cmd := &cobra.Command{
+		Use:   "graph [file.py ...]",
+		Short: "Draw the method call graph or the module import graph",
+		Long: "Draw the method call graph or the module import graph.\n\n" +
+			"Graphs are written in Graphviz DOT unless --format selects another graph format (mermaid, graph-json).",
+		SilenceUsage: true,
+		Args:         cobra.ArbitraryArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if !cmd.Flags().Changed("format") {
+				opts.format = string(printers.KindGraphviz)
 			}
-			if len(files) == 0 {
-				fmt.Printf("%s: No Python files found in the specified directory\n", programName)
-				return nil
+			opts.graph.RankDir = strings.ToUpper(opts.graph.RankDir)
+			if !slices.Contains(printers.RankDirs, opts.graph.RankDir) {
+				return fmt.Errorf("%s: invalid --rankdir '%s', valid values are %s", programName, opts.graph.RankDir, strings.Join(printers.RankDirs, ", "))
 			}
-
-			// Find methods
-			methodFilters := finder.MethodFilter{
-				SkipPrivate: skipPrivate,
+			if opts.focusDepth < 0 {
+				return fmt.Errorf("%s: --focus-depth must not be negative", programName)
 			}
-			methods := finder.FindMethods(files, methodFilters)
-			if verbose {
-				log.Printf("Found %d methods\n", len(methods))
+			switch kind {
+			case "calls":
+				return runAnalysis(cmd, opts, finder.SymbolFunction, args)
+			case "imports":
+				return runImportGraph(cmd, opts)
 			}
-			if len(methods) == 0 {
-				fmt.Printf("%s: No method definitions found\n", programName)
-				return nil
+			return fmt.Errorf("%s: invalid --kind '%s', valid values are calls, imports", programName, kind)
+		},
+	}
+	cmd.Flags().StringVar(&kind, "kind", "calls", "Graph to draw: calls (method call graph) or imports (module dependencies)")
+	cmd.Flags().StringVar(&opts.graph.RankDir, "rankdir", "LR", "Direction of the graphviz layout: LR, TB, RL or BT")
+	cmd.Flags().BoolVar(&opts.graph.Cluster, "cluster", false, "Group the graphviz nodes of each package in a box")
+	cmd.Flags().BoolVar(&opts.graph.EdgeLabels, "edge-labels", false, "Label graphviz edges with their usage or import counts")
+	cmd.Flags().BoolVar(&opts.graph.Unused, "color-unused", false, "Color the graphviz nodes of methods without real usages red")
+	cmd.Flags().StringVar(&opts.focus, "focus", "", "Only draw the nodes around this method (name or Class.name) or module")
+	cmd.Flags().IntVar(&opts.focusDepth, "focus-depth", 1, "Edges between the --focus node and the farthest node drawn")
+	return cmd
+}
+
+// checkGlobs rejects the malformed globs of the flags, the configuration and
+// the ignore file, which would otherwise match nothing
+func checkGlobs(opts *options) error {
+	flags := []struct {
+		name  string
+		globs []string
+	}{
+		{"--exclude", opts.exclude},
+		{"--include", opts.include},
+		{"--test-glob", opts.testGlobs},
+		{"--exclude-method", opts.excludeMethods},
+	}
+	for _, flag := range flags {
+		for _, glob := range flag.globs {
+			if err := finder.CheckGlob(glob); err != nil {
+				return fmt.Errorf("%s: %s: %w", programName, flag.name, err)
 			}
+		}
+	}
+	return nil
+}
+
+// newDirFilter returns the directory walk options shared by every command
+func newDirFilter(opts *options) finder.DirFilter {
+	return finder.DirFilter{
+		NoIgnore:       opts.noIgnore,
+		Exclude:        opts.exclude,
+		Stubs:          opts.stubs,
+		Extensions:     opts.extensions,
+		FollowSymlinks: opts.followSymlinks,
+		MaxDepth:       opts.maxDepth,
+	}
+}
+
+// newMethodFilter returns the definition discovery options of the flags
+func newMethodFilter(opts *options, encoding string) finder.MethodFilter {
+	return finder.MethodFilter{
+		SkipPrivate:   opts.skipPrivate,
+		SkipDecorated: opts.skipDecorated,
+		SkipTests:     opts.skipTests,
+		TestGlobs:     opts.testGlobs,
+		Include:       opts.include,
+		ExcludeNames:  opts.excludeMethods,
+		Encoding:      encoding,
+		Jobs:          opts.jobs,
+	}
+}
+
+// defaultEngine is ripgrep when it is installed, the native engine otherwise
+func defaultEngine() string {
+	if _, err := exec.LookPath("rg"); err != nil {
+		return finder.EngineNative
+	}
+	return finder.EngineRipgrep
+}
 
-			// Analyze usages
-			if verbose {
-				log.Println("Analyzing method usages...")
+// newFileFilter returns the usage search options of the flags
+func newFileFilter(opts *options, encoding, engine string) finder.FileFilter {
+	return finder.FileFilter{
+		SkipImports:         opts.skipImports,
+		SkipTests:           opts.skipTests,
+		TestGlobs:           opts.testGlobs,
+		NoIgnore:            opts.noIgnore,
+		Exclude:             opts.exclude,
+		Stubs:               opts.stubs,
+		Extensions:          opts.extensions,
+		FollowSymlinks:      opts.followSymlinks,
+		MaxDepth:            opts.maxDepth,
+		MaxStoredUsages:     opts.maxStored,
+		UsageCap:            opts.usageCap,
+		SkipDefinitions:     opts.skipDefinitions,
+		DocReferences:       opts.docReferences,
+		IncludeComments:     opts.includeComments,
+		AttributeReferences: opts.attrReferences,
+		StringReferences:    opts.strReferences,
+		LocalNested:         opts.localNested,
+		Encoding:            encoding,
+		Context:             opts.context,
+		Engine:              engine,
+		RgArgs:              opts.rgArgs,
+		Jobs:                opts.jobs,
+		SearchProcs:         opts.searchProcs,
+	}
+}
+
+// runImportGraph prints the module dependency graph of every --dir
+func runImportGraph(cmd *cobra.Command, opts *options) error {
+	var encoding string
+	if opts.encoding != "" {
+		var err error
+		if encoding, err = finder.ParseEncoding(opts.encoding); err != nil {
+			return fmt.Errorf("%s: --encoding: %w", programName, err)
+		}
+	}
+
+	pr, err := newPrinter(cmd, opts, nil, nil)
+	if pr == nil {
+		return err
+	}
+	gp, ok := pr.(printers.GraphPrinter)
+	if !ok {
+		return fmt.Errorf("%s: format '%s' can not draw graphs", programName, opts.format)
+	}
+
+	files, err := finder.ReadDirs(cmd.Context(), opts.dirs, newDirFilter(opts))
+	if err != nil {
+		return fmt.Errorf("%s: error reading directory: %w", programName, err)
+	}
+	if opts.changedSince != "" {
+		files, err = finder.ChangedSince(files, opts.changedSince)
+		if err != nil {
+			return fmt.Errorf("%s: --changed-since: %w", programName, err)
+		}
+		if len(files) == 0 {
+			fmt.Printf("%s: No Python files changed since %s\n", programName, opts.changedSince)
+			return nil
+		}
+	}
+	if opts.verbose {
+		log.Printf("Found %d Python files\n", len(files))
+	}
+
+	graph, err := focusGraph(opts, finder.BuildImportGraph(files, finder.BuildImportTable(files, encoding)))
+	if err != nil {
+		return err
+	}
+	return writeOutput(opts, func(w io.Writer) error { return gp.PrintGraph(w, graph) })
+}
+
+// focusGraph keeps the part of g around --focus when set
+func focusGraph(opts *options, g finder.Graph) (finder.Graph, error) {
+	if opts.focus == "" {
+		return g, nil
+	}
+	focused, ok := finder.FocusGraph(g, opts.focus, opts.focusDepth)
+	if !ok {
+		return g, fmt.Errorf("%s: --focus '%s' is not in the graph", programName, opts.focus)
+	}
+	return focused, nil
+}
+
+// runAnalysis runs the discovery, usage analysis, filter, sort and print pipeline
+// for the given kind of symbol. Definitions come from paths when given, from
+// every --dir otherwise.
+func runAnalysis(cmd *cobra.Command, opts *options, kind finder.SymbolKind, paths []string) error {
+	opts.started = time.Now()
+	if err := checkAnalysisFlags(cmd, opts); err != nil {
+		return err
+	}
+	ctx := cmd.Context()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if opts.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.timeout)
+		defer cancel()
+	}
+
+	engine, err := searchEngine(opts)
+	if engine == "" {
+		return err
+	}
+	analyzerOpts, err := analyzerOptions(opts, kind, paths, engine)
+	if err != nil {
+		return err
+	}
+	defer printWarnings(cmd.ErrOrStderr(), analyzerOpts.Search.Warnings)
+	analyzer := finder.New(analyzerOpts)
+
+	if opts.summaryOnly {
+		return summarizeResults(ctx, cmd, opts, analyzer)
+	}
+	if opts.stream {
+		return streamResults(ctx, cmd, opts, analyzer)
+	}
+	result, err := analyzer.Run(ctx)
+	opts.analyzedCount, opts.unusedCount = result.Analyzed, result.Unused
+	partial, err := analysisError(ctx, opts, err)
+	if err != nil {
+		return err
+	}
+	return reportResults(ctx, cmd, opts, analyzerOpts, result, partial)
+}
+
+// checkAnalysisFlags rejects invalid flags and combinations of them, and
+// resolves the flags derived from others such as the suffix of --sort-by
+func checkAnalysisFlags(cmd *cobra.Command, opts *options) error {
+	if opts.asc && !cmd.Flags().Changed("sort-by") {
+		return fmt.Errorf("--asc flag can only be used together with --sort-by")
+	}
+	if key, ok := strings.CutSuffix(opts.sortBy, "-desc"); ok {
+		opts.sortBy, opts.asc = key, false
+	} else if key, ok := strings.CutSuffix(opts.sortBy, "-asc"); ok {
+		opts.sortBy, opts.asc = key, true
+	}
+	if opts.top < 0 {
+		return fmt.Errorf("%s: --top must not be negative", programName)
+	}
+	if opts.top > 0 && (opts.stream || opts.summaryOnly) {
+		return fmt.Errorf("%s: --top can not be combined with --stream or --summary-only", programName)
+	}
+	if opts.timeout < 0 {
+		return fmt.Errorf("%s: --timeout must not be negative", programName)
+	}
+
+	switch opts.groupBy {
+	case "", "file", "package", "module", "calltype", "dir":
+	default:
+		return fmt.Errorf("%s: invalid --group-by '%s', valid values are file, package, module, calltype, dir", programName, opts.groupBy)
+	}
+	if opts.summaryOnly && (opts.stream || opts.groupBy != "") {
+		return fmt.Errorf("%s: --summary-only can not be combined with --stream or --group-by", programName)
+	}
+	if opts.summary && !isSectionGrouping(opts.groupBy) && opts.groupBy != "" {
+		return fmt.Errorf("%s: --summary can not be combined with --group-by %s", programName, opts.groupBy)
+	}
+	if opts.usageBuckets != "" || opts.usageLabels != nil {
+		b, err := finder.ParseUsageBuckets(opts.usageBuckets, opts.usageLabels)
+		if err != nil {
+			return fmt.Errorf("%s: %w", programName, err)
+		}
+		opts.buckets = &b
+	}
+	if opts.maxStored < 0 {
+		return fmt.Errorf("%s: --max-stored-usages must not be negative", programName)
+	}
+	if opts.usageCap < 0 {
+		return fmt.Errorf("%s: --max-usages-per-method must not be negative", programName)
+	}
+	if opts.stream && (cmd.Flags().Changed("sort-by") || opts.groupBy != "") {
+		return fmt.Errorf("%s: --stream prints results unsorted and ungrouped, it can not be combined with --sort-by or --group-by", programName)
+	}
+	if opts.jobs < 1 {
+		return fmt.Errorf("%s: --jobs must be at least 1", programName)
+	}
+	if opts.searchProcs < 0 {
+		return fmt.Errorf("%s: --search-procs must not be negative", programName)
+	}
+	if opts.shard != "" && opts.duplicates {
+		return fmt.Errorf("%s: duplicates compares every definition with the others, it can not be sharded", programName)
+	}
+	if opts.duplicates && (opts.budget.FailOnUnused || opts.budget.Max >= 0 || opts.budget.MaxPercent >= 0) {
+		return fmt.Errorf("%s: duplicates reports no unused definitions, it can not be combined with --fail-on-unused, --max-unused or --max-unused-percent", programName)
+	}
+	if opts.context < 0 {
+		return fmt.Errorf("%s: --context must not be negative", programName)
+	}
+	return nil
+}
+
+// searchEngine returns the search backend selected by --search-backend,
+// falling back to the native engine when ripgrep is missing. It returns an
+// empty engine once it told the user to install a missing backend.
+func searchEngine(opts *options) (string, error) {
+	engine := opts.engine
+	switch backend := finder.Backend(engine); {
+	case engine == "":
+		engine = defaultEngine()
+		if engine == finder.EngineNative && opts.verbose {
+			log.Printf("ripgrep (rg) is not installed, using the native search engine\n")
+		}
+	case backend != nil:
+		if _, err := exec.LookPath(backend.Command()); err != nil && !opts.duplicates {
+			fmt.Printf("%s: Error %s is not installed. Please install it first or use --search-backend native.\n", programName, backend.Command())
+			return "", nil
+		}
+	case engine != finder.EngineNative:
+		return "", fmt.Errorf("%s: invalid --search-backend '%s', valid values are %s", programName, opts.engine, strings.Join(finder.Engines, ", "))
+	}
+	if len(opts.rgArgs) > 0 && engine != finder.EngineRipgrep && opts.verbose {
+		log.Printf("--rg-arg is ignored by the %s search backend\n", engine)
+	}
+	return engine, nil
+}
+
+// analyzerOptions returns the options of the analyzer run by the flags,
+// searching usages with engine
+func analyzerOptions(opts *options, kind finder.SymbolKind, paths []string, engine string) (finder.Options, error) {
+	var shard finder.Shard
+	if opts.shard != "" {
+		var err error
+		if shard, err = finder.ParseShard(opts.shard); err != nil {
+			return finder.Options{}, fmt.Errorf("%s: --shard: %w", programName, err)
+		}
+	}
+	minConfidence, err := finder.ParseConfidence(opts.minConfidence)
+	if err != nil {
+		return finder.Options{}, fmt.Errorf("%s: --min-confidence: %w", programName, err)
+	}
+	var encoding string
+	if opts.encoding != "" {
+		if encoding, err = finder.ParseEncoding(opts.encoding); err != nil {
+			return finder.Options{}, fmt.Errorf("%s: --encoding: %w", programName, err)
+		}
+	}
+	var specs []finder.MethodSpec
+	for _, m := range opts.methods {
+		specs = append(specs, finder.ParseMethodSpec(m))
+	}
+	var nameRe *regexp.Regexp
+	if opts.nameRegex != "" {
+		if nameRe, err = regexp.Compile(opts.nameRegex); err != nil {
+			return finder.Options{}, fmt.Errorf("%s: invalid --name-regex: %w", programName, err)
+		}
+	}
+
+	relocate, err := pathRelocation(opts)
+	if err != nil {
+		return finder.Options{}, err
+	}
+	if opts.fix != nil || opts.clean != nil {
+		// Sources are edited where they were found
+		relocate = nil
+	}
+	if opts.baseline != "" {
+		if opts.accepted, err = finder.LoadBaseline(opts.baseline); err != nil {
+			return finder.Options{}, fmt.Errorf("%s: baseline %s: %w", programName, opts.baseline, err)
+		}
+	}
+
+	searchDirs := opts.searchDirs
+	if len(searchDirs) == 0 {
+		searchDirs = opts.dirs
+	}
+	fileFilters := newFileFilter(opts, encoding, engine)
+	fileFilters.MinConfidence = minConfidence
+	fileFilters.Warnings = &finder.Warnings{}
+	analyzerOpts := finder.Options{
+		Dirs:         opts.dirs,
+		SearchDirs:   searchDirs,
+		Paths:        paths,
+		Kind:         kind,
+		Methods:      specs,
+		NameRegex:    nameRe,
+		ChangedSince: opts.changedSince,
+		Shard:        shard,
+		Duplicates:   opts.duplicates,
+		Similarity:   opts.similarity,
+		Walk:         newDirFilter(opts),
+		Find:         newMethodFilter(opts, encoding),
+		Search:       fileFilters,
+		Baseline:     opts.accepted,
+		Filter:       func(results []finder.MethodUsage) []finder.MethodUsage { return filterResults(opts, results) },
+		Relocate:     relocate,
+	}
+	if opts.refIndex {
+		analyzerOpts.RefIndex = finder.RefIndexPath(searchDirs[0])
+	}
+	if !opts.stream && !opts.duplicates {
+		analyzerOpts.SortBy, analyzerOpts.Asc = opts.sortBy, opts.asc
+	}
+	if opts.report == "" {
+		analyzerOpts.Top = opts.top
+	}
+	if opts.verbose {
+		analyzerOpts.Log = func(format string, args ...any) { log.Printf(format+"\n", args...) }
+	}
+	return analyzerOpts, nil
+}
+
+// reportResults prints the results of a run of the analyzer configured by
+// analyzerOpts, or hands them to history record, fix or clean, then checks
+// the budget. partial is set when the run was stopped early.
+func reportResults(ctx context.Context, cmd *cobra.Command, opts *options, analyzerOpts finder.Options, result finder.AnalysisResult, partial *finder.PartialError) error {
+	noun := "method"
+	switch analyzerOpts.Kind {
+	case finder.SymbolVariable:
+		noun = "variable"
+	case finder.SymbolAttribute:
+		noun = "attribute"
+	}
+
+	if opts.history != nil {
+		// Recorded even when the filters leave nothing to print
+		if partial != nil {
+			return interrupted(ctx, opts, partial.Analyzed, partial.Total, noun)
+		}
+		return recordHistory(ctx, opts, opts.history)
+	}
+	if empty := noResults(opts, noun, result); empty != "" {
+		fmt.Printf("%s: %s\n", programName, empty)
+		if partial != nil {
+			return interrupted(ctx, opts, partial.Analyzed, partial.Total, noun)
+		}
+		return checkBudget(opts)
+	}
+	if opts.verbose && !opts.duplicates {
+		log.Printf("Results sorted by: %s\n", opts.sortBy)
+		logStats(*result.Stats)
+	}
+
+	var stats *finder.RunStats
+	if opts.stats && !opts.duplicates {
+		stats = result.Stats
+	}
+	opts.totalMethods = result.TotalMethods
+	if opts.fix != nil || opts.clean != nil {
+		if partial != nil {
+			return interrupted(ctx, opts, partial.Analyzed, partial.Total, noun)
+		}
+		// Names used as .name or @name may be calls the search did not resolve
+		refs, err := memberReferences(ctx, opts, result.Results, analyzerOpts.SearchDirs, analyzerOpts.Find.Encoding)
+		if err != nil {
+			return err
+		}
+		if opts.fix != nil {
+			return fixResults(opts, result.Results, refs)
+		}
+		return cleanResults(opts, result.Results, refs)
+	}
+	if err := printResults(cmd, opts, result.Results, stats); err != nil {
+		return err
+	}
+	if partial != nil {
+		return interrupted(ctx, opts, partial.Analyzed, partial.Total, noun)
+	}
+	if err := checkBudget(opts); err != nil {
+		return err
+	}
+	if opts.unused {
+		return errUnusedFound
+	}
+	return nil
+}
+
+// analysisError turns the error of an analyzer into the one of the command.
+// Runs stopped while analyzing usages have partial results to print first.
+func analysisError(ctx context.Context, opts *options, err error) (*finder.PartialError, error) {
+	var partial *finder.PartialError
+	switch {
+	case errors.As(err, &partial) && partial.Total == 0:
+		return nil, cancelled(ctx, opts, partial.Stage)
+	case err != nil && partial == nil:
+		return nil, fmt.Errorf("%s: %w", programName, err)
+	}
+	return partial, nil
+}
+
+// noResults explains why a run has nothing to print, empty when it has
+func noResults(opts *options, noun string, result finder.AnalysisResult) string {
+	switch {
+	case result.Stats.Files == 0 && opts.changedSince != "":
+		return fmt.Sprintf("No Python files changed since %s", opts.changedSince)
+	case result.Stats.Files == 0:
+		return "No Python files found in the specified directory"
+	case result.TotalMethods == 0:
+		return fmt.Sprintf("No %s definitions found", noun)
+	case len(result.Results) > 0:
+		return ""
+	case opts.duplicates:
+		return fmt.Sprintf("No duplicate %ss found", noun)
+	case opts.unused:
+		return fmt.Sprintf("No unused %ss found", noun)
+	case opts.onlyProblems:
+		return fmt.Sprintf("No unused or rarely used %ss found", noun)
+	}
+	return fmt.Sprintf("No %ss found matching the filter criteria", noun)
+}
+
+// logStats reports the statistics of the run in verbose mode
+func logStats(stats finder.RunStats) {
+	e := stats.Elapsed
+	log.Printf("Stages: walk %.1fms, find %.1fms, analyze %.1fms (search %.1fms, classify %.1fms summed over workers)\n",
+		e.Walk, e.Find, e.Analyze, e.Search, e.Classify)
+	log.Printf("Searched %d files with %d backend runs, source cache hit rate %.0f%%, reference index hit rate %.0f%%\n",
+		stats.SearchedFiles, stats.Searches, stats.CacheHitRate*100, stats.IndexHitRate*100)
+}
+
+// printWarnings lists the problems met by the analysis after its results,
+// rather than interleaving them with the output
+func printWarnings(w io.Writer, warnings *finder.Warnings) {
+	list := warnings.List()
+	if len(list) == 0 {
+		return
+	}
+	noun := "warnings"
+	if len(list) == 1 {
+		noun = "warning"
+	}
+	fmt.Fprintf(w, "%s: %d %s during the analysis:\n", programName, len(list), noun)
+	for _, warning := range list {
+		fmt.Fprintf(w, "  %s\n", warning)
+	}
+}
+
+// Exit statuses of runs stopped early, as shells and timeout(1) report them
+const (
+	exitInterrupted = 130
+	exitTimedOut    = 124
+)
+
+// interruptedError ends a run stopped by Ctrl-C or --timeout. Whatever was
+// printed before it is partial.
+type interruptedError struct {
+	msg      string
+	timedOut bool
+}
+
+func (e *interruptedError) Error() string { return e.msg }
+
+// exitCode is the status the process exits with
+func (e *interruptedError) exitCode() int {
+	if e.timedOut {
+		return exitTimedOut
+	}
+	return exitInterrupted
+}
+
+// cancelled reports a run stopped by Ctrl-C or --timeout while doing step
+func cancelled(ctx context.Context, opts *options, step string) error {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return &interruptedError{msg: fmt.Sprintf("%s: timed out after %s while %s, results are partial", programName, opts.timeout, step), timedOut: true}
+	}
+	return &interruptedError{msg: fmt.Sprintf("%s: cancelled while %s, results are partial", programName, step)}
+}
+
+// interrupted reports an analysis stopped by Ctrl-C or --timeout once the
+// in-flight definitions were classified, analyzed out of total
+func interrupted(ctx context.Context, opts *options, analyzed, total int, noun string) error {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return &interruptedError{
+			msg:      fmt.Sprintf("%s: analysis timed out after %s with %d/%d %ss analyzed, results are partial", programName, opts.timeout, analyzed, total, noun),
+			timedOut: true,
+		}
+	}
+	return &interruptedError{msg: fmt.Sprintf("%s: analysis interrupted after %d/%d %ss, results are partial", programName, analyzed, total, noun)}
+}
+
+// pathRelocation returns the rewrite of printed paths selected by --paths,
+// nil to print them as found
+func pathRelocation(opts *options) (func(string) string, error) {
+	switch opts.paths {
+	case "":
+		if opts.pathsRoot != "" {
+			return nil, fmt.Errorf("%s: --paths-root needs --paths relative", programName)
+		}
+		return nil, nil
+	case "absolute":
+		return finder.AbsolutePath, nil
+	case "relative":
+		root := opts.pathsRoot
+		if root == "" {
+			var err error
+			if root, err = finder.RepoRoot(opts.dirs[0]); err != nil {
+				root = "."
 			}
+		}
+		return finder.RelativeTo(root), nil
+	}
+	return nil, fmt.Errorf("%s: invalid --paths '%s', valid values are relative, absolute", programName, opts.paths)
+}
+
+// filterResults applies the unused, usage count and complexity filters of the
+// flags. Usages below --min-confidence are already dropped by the analysis.
+// With --only-problems, --min-usages is the count methods are reported below.
+func filterResults(opts *options, results []finder.MethodUsage) []finder.MethodUsage {
+	minUsages := opts.minUsages
+	if opts.onlyProblems {
+		results = finder.FilterProblems(results, minUsages)
+		minUsages = -1
+	}
+	if opts.unused {
+		results = finder.FilterUnused(results)
+	}
+	if minUsages >= 0 || opts.maxUsages >= 0 {
+		results = finder.FilterByUsageCount(results, minUsages, opts.maxUsages)
+	}
+	if opts.minComplexity > 0 {
+		results = finder.FilterByComplexity(results, opts.minComplexity)
+	}
+	return results
+}
+
+// streamResults prints every result passing the filters as soon as its
+// analysis completes, in completion order
+func streamResults(ctx context.Context, cmd *cobra.Command, opts *options, analyzer *finder.Analyzer) error {
+	var summary *finder.Summary
+	if opts.summary {
+		summary = &finder.Summary{Buckets: opts.buckets}
+	}
+	pr, err := newPrinter(cmd, opts, nil, summary)
+	if pr == nil {
+		return err
+	}
+	sp, ok := pr.(printers.StreamPrinter)
+	if !ok {
+		return fmt.Errorf("%s: format '%s' does not support --stream", programName, opts.format)
+	}
 
-			fileFilters := finder.FileFilter{
-				SkipImports:     skipImports,
-				SkipTests:       skipTests,
-				SkipDefinitions: skipDefinitions,
+	var result finder.AnalysisResult
+	var analysisErr error
+	found := 0
+	err = writeOutput(opts, func(w io.Writer) error {
+		var printErr error
+		result, analysisErr = analyzer.Stream(ctx, func(r finder.MethodUsage) {
+			if printErr != nil {
+				return
 			}
-			results := finder.AnalyzeMethodUsages(methods, dir, fileFilters)
-
-			// Filter by usages
-			if minUsages >= 0 || maxUsages >= 0 {
-				results = finder.FilterByUsageCount(results, minUsages, maxUsages)
-				if verbose {
-					log.Printf("Filtered to %d methods based on usage count\n", len(results))
-				}
+			found++
+			if summary != nil {
+				summary.Add(r)
 			}
-			if len(results) == 0 {
-				fmt.Printf("%s: No methods found matching the filter criteria\n", programName)
-				return nil
+			printErr = sp.PrintResult(w, r)
+			if f, ok := w.(interface{ Flush() error }); ok && printErr == nil {
+				printErr = f.Flush()
 			}
+		})
+		if printErr == nil && summary != nil && summary.Methods > 0 {
+			printErr = pr.(printers.SummaryPrinter).PrintSummary(w, *summary)
+		}
+		if mp, ok := pr.(printers.MetaPrinter); ok && printErr == nil {
+			printErr = mp.PrintMeta(w)
+		}
+		return printErr
+	})
+	if err != nil {
+		return err
+	}
+	opts.analyzedCount, opts.unusedCount = result.Analyzed, result.Unused
+	partial, err := analysisError(ctx, opts, analysisErr)
+	if err != nil {
+		return err
+	}
+	if result.TotalMethods == 0 {
+		fmt.Printf("%s: %s\n", programName, noResults(opts, "method", result))
+		return nil
+	}
+	if opts.verbose {
+		logStats(*result.Stats)
+	}
+	if partial != nil {
+		return interrupted(ctx, opts, partial.Analyzed, partial.Total, "method")
+	}
+	if err := checkBudget(opts); err != nil {
+		return err
+	}
+	if opts.unused && found > 0 {
+		return errUnusedFound
+	}
+	return nil
+}
 
-			// Sort
-			finder.SortResults(results, sortBy, asc)
-			if verbose {
-				log.Printf("Results sorted by: %s\n", sortBy)
-			}
+// summarizeResults prints the statistics of the results passing the filters,
+// aggregated as each completes so that no result is kept in memory
+func summarizeResults(ctx context.Context, cmd *cobra.Command, opts *options, analyzer *finder.Analyzer) error {
+	pr, err := newPrinter(cmd, opts, nil, nil)
+	if pr == nil {
+		return err
+	}
+	sp, ok := pr.(printers.SummaryPrinter)
+	if !ok {
+		return fmt.Errorf("%s: format '%s' does not support --summary-only", programName, opts.format)
+	}
 
-			if format == "--help" {
-				_ = cmd.Usage()
-				return nil
-			}
+	summary := finder.Summary{Buckets: opts.buckets}
+	result, err := analyzer.Stream(ctx, summary.Add)
+	opts.analyzedCount, opts.unusedCount = result.Analyzed, result.Unused
+	partial, err := analysisError(ctx, opts, err)
+	if err != nil {
+		return err
+	}
+	if result.TotalMethods == 0 {
+		fmt.Printf("%s: %s\n", programName, noResults(opts, "method", result))
+		return nil
+	}
+	if opts.verbose {
+		logStats(*result.Stats)
+	}
+	write := func(w io.Writer) error {
+		if err := sp.PrintSummary(w, summary); err != nil {
+			return err
+		}
+		if mp, ok := pr.(printers.MetaPrinter); ok {
+			return mp.PrintMeta(w)
+		}
+		return nil
+	}
+	if err := writeOutput(opts, write); err != nil {
+		return err
+	}
+	if partial != nil {
+		return interrupted(ctx, opts, partial.Analyzed, partial.Total, "method")
+	}
+	return checkBudget(opts)
+}
 
-			// Console printer
-			kind := printers.OutputKinds[format]
-			if kind == "" {
-				_ = cmd.Usage()
-				return fmt.Errorf("%s: invalid output format '%s'", programName, format)
-			}
+// checkBudget fails a run whose unused definitions exceed the budget set by
+// --fail-on-unused, --max-unused and --max-unused-percent
+func checkBudget(opts *options) error {
+	if msg := opts.budget.Exceeded(opts.unusedCount, opts.analyzedCount); msg != "" {
+		return &budgetError{msg: fmt.Sprintf("%s: %s", programName, msg)}
+	}
+	return nil
+}
 
-			pr := printers.New(kind, printers.Options{NoColor: noColor})
-
-			if output != "" {
-				f, err := os.Create(output)
-				if err != nil {
-					return fmt.Errorf("error saving results: %w", err)
-				}
-
-				defer f.Close()
-
-				w := bufio.NewWriter(f)
-				err = pr.Print(w, results)
-				if err != nil {
-					return fmt.Errorf("error saving results: %w", err)
-				}
-				if err := w.Flush(); err != nil {
-					return err
-				}
-				f.Sync()
-			} else {
-				err = pr.Print(os.Stdout, results)
-				if err != nil {
-					return fmt.Errorf("error saving results: %w", err)
-				}
-			}
-			return nil
-		},
+// printResults writes results with the printer selected by --format, to
+// --output when set and stdout otherwise. Formats that carry them also print
+// stats when not nil, and the summary of results with --summary.
+func printResults(cmd *cobra.Command, opts *options, results []finder.MethodUsage, stats *finder.RunStats) error {
+	if opts.browse {
+		return browseResults(cmd, opts, results)
+	}
+	if opts.report == "top" {
+		return printTop(cmd, opts, results)
+	}
+	var summary *finder.Summary
+	if opts.summary && !opts.duplicates {
+		summary = &finder.Summary{Buckets: opts.buckets}
+		for _, r := range results {
+			summary.Add(r)
+		}
+	}
+	pr, err := newPrinter(cmd, opts, stats, summary)
+	if pr == nil {
+		return err
 	}
 
-	rootCmd.Flags().StringVarP(&dir, "dir", "d", ".", "Directory to search for Python files")
-	rootCmd.Flags().StringVarP(&output, "output", "o", "", "Output file (optional, defaults to stdout)")
-	rootCmd.Flags().BoolVarP(&verbose, "verbose", "v", false, "Show detailed information during execution")
-	rootCmd.Flags().StringVar(&format, "format", "console", fmt.Sprintf("How to output results, valid types are %v", printers.GetKinds()))
-	rootCmd.Flags().BoolVar(&skipImports, "skip-imports", false, "Skip import statements in usage results")
-	rootCmd.Flags().BoolVar(&skipPrivate, "skip-private", false, "Skip private methods (starting with _)")
-	rootCmd.Flags().BoolVar(&skipTests, "skip-tests", true, "Skip methods definitions in tests files")
-	rootCmd.Flags().BoolVar(&skipDefinitions, "skip-definitions", false, "Skip methods definitions")
-	rootCmd.Flags().BoolVar(&noColor, "no-color", false, "Disable colored output")
-	rootCmd.Flags().IntVar(&minUsages, "min-usages", -1, "Filter methods with at least N usages (-1 = no filter)")
-	rootCmd.Flags().IntVar(&maxUsages, "max-usages", -1, "Filter methods with at most N usages (-1 = no filter)")
-	rootCmd.Flags().StringVar(&sortBy, "sort-by", "file", "Sort results by: name, file, usages")
-	rootCmd.Flags().BoolVar(&asc, "asc", false, "Sort ascending (only valid when --sort-by is used)")
+	write := func(w io.Writer) error { return pr.Print(w, results) }
+	if opts.focus != "" {
+		gp, ok := pr.(printers.GraphPrinter)
+		if !ok {
+			return fmt.Errorf("%s: format '%s' can not draw graphs", programName, opts.format)
+		}
+		graph, err := focusGraph(opts, finder.BuildCallGraph(results))
+		if err != nil {
+			return err
+		}
+		write = func(w io.Writer) error { return gp.PrintGraph(w, graph) }
+	}
+	if isSectionGrouping(opts.groupBy) {
+		gp, ok := pr.(printers.GroupPrinter)
+		if !ok {
+			return fmt.Errorf("%s: format '%s' does not support --group-by %s", programName, opts.format, opts.groupBy)
+		}
+		groups := finder.GroupByDir(results)
+		if opts.groupBy == "calltype" {
+			groups = finder.GroupByCallType(results)
+		}
+		write = func(w io.Writer) error { return gp.PrintGroups(w, groups) }
+	} else if opts.groupBy == "file" {
+		fp, ok := pr.(printers.FilePrinter)
+		if !ok {
+			return fmt.Errorf("%s: format '%s' does not support --group-by file", programName, opts.format)
+		}
+		summaries := finder.AggregateByFile(results)
+		write = func(w io.Writer) error { return fp.PrintFiles(w, summaries) }
+	} else if opts.groupBy != "" {
+		pp, ok := pr.(printers.PackagePrinter)
+		if !ok {
+			return fmt.Errorf("%s: format '%s' does not support --group-by", programName, opts.format)
+		}
+		summaries := finder.AggregateByPackage(results)
+		if opts.groupBy == "module" {
+			summaries = finder.AggregateByModule(results)
+		}
+		write = func(w io.Writer) error { return pp.PrintPackages(w, summaries) }
+	}
+	return writeOutput(opts, write)
+}
 
-	return rootCmd
+// isSectionGrouping reports whether --group-by lays the results out in
+// sections instead of summarizing them
+func isSectionGrouping(groupBy string) bool {
+	return groupBy == "calltype" || groupBy == "dir"
+}
+
+// newPrinter returns the printer selected by --format, or nil when the usage
+// was printed instead. The printer prints summary, when not nil, after the
+// results.
+func newPrinter(cmd *cobra.Command, opts *options, stats *finder.RunStats, summary *finder.Summary) (printers.Printer, error) {
+	if opts.format == "--help" {
+		_ = cmd.Usage()
+		return nil, nil
+	}
+
+	printerKind := printers.OutputKinds[opts.format]
+	if printerKind == "" {
+		_ = cmd.Usage()
+		return nil, fmt.Errorf("%s: invalid output format '%s'", programName, opts.format)
+	}
+	if !slices.Contains(printers.CSVModes, opts.csvMode) {
+		return nil, fmt.Errorf("%s: invalid --csv-mode '%s', valid values are %s", programName, opts.csvMode, strings.Join(printers.CSVModes, ", "))
+	}
+	links, err := linkTemplate(opts)
+	if err != nil {
+		return nil, err
+	}
+	if opts.compact && printerKind != printers.KindConsole {
+		return nil, fmt.Errorf("%s: --compact is only supported by the console format", programName)
+	}
+	pr := printers.New(printerKind, printers.Options{NoColor: opts.noColor, Compact: opts.compact, Highlight: !opts.noHighlight, Buckets: opts.buckets, Stats: stats, Summary: summary, CSVMode: opts.csvMode, CSVMeta: opts.csvMeta, Graph: opts.graph, Links: links, Meta: reportMeta(cmd, opts)})
+	if _, ok := pr.(printers.SummaryPrinter); summary != nil && !ok {
+		return nil, fmt.Errorf("%s: format '%s' does not support --summary", programName, opts.format)
+	}
+	return pr, nil
+}
+
+// writeOutput runs write against --output when set, stdout otherwise
+func writeOutput(opts *options, write func(w io.Writer) error) error {
+	if opts.output != "" {
+		if err := replaceFile(opts.output, write); err != nil {
+			return fmt.Errorf("error saving results: %w", err)
+		}
+	} else {
+		err := write(os.Stdout)
+		if err != nil {
+			return fmt.Errorf("error saving results: %w", err)
+		}
+	}
+	return nil
+}
+
+// replaceFile writes path aside and renames it into place, so an interrupted
+// run never leaves a truncated file behind. The mode of an existing file is kept.
+func replaceFile(path string, write func(w io.Writer) error) error {
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+	mode := os.FileMode(0o644)
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode().Perm()
+	}
+	if err := f.Chmod(mode); err != nil {
+		return err
+	}
+
+	w := bufio.NewWriter(f)
+	if err := write(w); err != nil {
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	f.Sync()
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
 }
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestOnlyProblemsExitStatus(t *testing.T) {
+	dir := writeProject(t, map[string]string{"lib.py": "def unused_helper():\n    return 1\n"})
+	out := filepath.Join(t.TempDir(), "report.txt")
+	args := []string{"--only-problems", "--no-config", "--search-backend", "native", "--dir", dir, "-o", out}
+
+	// Reporting problems is not a failure, the budget flags decide that
+	cmd := newRootCmd()
+	cmd.SetArgs(args)
+	if err := cmd.Execute(); err != nil {
+		t.Errorf("--only-problems: %v, want no error", err)
+	}
+
+	cmd = newRootCmd()
+	cmd.SetArgs(append(args, "--fail-on-unused"))
+	var budget *budgetError
+	if err := cmd.Execute(); !errors.As(err, &budget) {
+		t.Errorf("--only-problems --fail-on-unused: %v, want a budget error", err)
+	}
+}
+
+func TestMalformedGlobFlags(t *testing.T) {
+	dir := writeProject(t, map[string]string{"lib.py": "def helper():\n    return 1\n"})
+	for _, args := range [][]string{
+		{"--exclude", "[z-a].py"},
+		{"--test-glob", "[]"},
+		{"unused", "--exclude-method", "get_[9-0]"},
+	} {
+		cmd := newRootCmd()
+		cmd.SetArgs(append(args, "--no-config", "--dir", dir))
+		cmd.SetErr(io.Discard)
+		if err := cmd.Execute(); err == nil || !strings.Contains(err.Error(), "invalid glob") {
+			t.Errorf("%v: %v, want an invalid glob error", args, err)
+		}
+	}
+}
@@ -0,0 +1,240 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"slices"
+
+	"github.com/sanchezhs/py-broom/finder"
+	"github.com/spf13/cobra"
+)
+
+// mcpProtocolVersions are the Model Context Protocol revisions the server
+// speaks, the first one being offered to clients asking for another
+var mcpProtocolVersions = []string{"2025-06-18", "2025-03-26", "2024-11-05"}
+
+// JSON-RPC error codes
+const (
+	rpcParseError     = -32700
+	rpcMethodNotFound = -32601
+	rpcInvalidParams  = -32602
+)
+
+// rpcRequest is a JSON-RPC 2.0 request, a notification when ID is empty
+type rpcRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+type rpcResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  any             `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+// mcpTool describes a tool in the answer to tools/list
+type mcpTool struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description"`
+	InputSchema map[string]any `json:"inputSchema"`
+}
+
+// mcpContent is a block of a tool result
+type mcpContent struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+// mcpToolResult is the answer to tools/call. Failures of the tool itself,
+// such as an unknown method, are results with IsError set.
+type mcpToolResult struct {
+	Content []mcpContent `json:"content"`
+	IsError bool         `json:"isError,omitempty"`
+}
+
+// stringParam is the schema of a tool taking a single string argument
+func stringParam(name, description string, required bool) map[string]any {
+	schema := map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			name: map[string]any{"type": "string", "description": description},
+		},
+	}
+	if required {
+		schema["required"] = []string{name}
+	}
+	return schema
+}
+
+var mcpTools = []mcpTool{
+	{
+		Name: "find_usages",
+		Description: "Find the usages of a Python function, method, variable or attribute across the project: " +
+			"every call, reference and import, classified by call type with its caller.",
+		InputSchema: stringParam("method", "Definition to look up: name, Class.name or file.py:Class.name", true),
+	},
+	{
+		Name: "list_unused",
+		Description: "List the definitions of the project without real usages, which are candidates for removal. " +
+			"Dunder methods, tests and framework hooks are never listed.",
+		InputSchema: stringParam("method", "Only list definitions matching this name, Class.name or file.py:Class.name", false),
+	},
+	{
+		Name: "call_graph",
+		Description: "Return the call graph around a module: the functions it defines, the functions calling them " +
+			"and the functions they call, with the number of calls of each edge.",
+		InputSchema: stringParam("module", "Dotted module name (app.models), package (app) or file name without extension (models)", true),
+	},
+}
+
+func newMCPCmd(opts *options) *cobra.Command {
+	return &cobra.Command{
+		Use:   "mcp",
+		Short: "Answer usage queries of coding assistants over the Model Context Protocol",
+		Long: "Answer usage queries of coding assistants over the Model Context Protocol.\n\n" +
+			"The server speaks JSON-RPC on stdin and stdout. It indexes --dir once and\n" +
+			"refreshes the index before each tool call when files changed, offering the\n" +
+			"find_usages, list_unused and call_graph tools. Register it in an assistant as\n" +
+			"the command `pybr mcp --dir /path/to/project`.",
+		SilenceUsage: true,
+		Args:         cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+			index, err := buildIndex(ctx, opts)
+			if err != nil {
+				return err
+			}
+			return serveMCP(ctx, index, os.Stdin, os.Stdout)
+		},
+	}
+}
+
+// serveMCP answers the JSON-RPC messages of r, one per line, until r ends
+func serveMCP(ctx context.Context, index *finder.Index, r io.Reader, w io.Writer) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 16<<20)
+	encoder := json.NewEncoder(w)
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var req rpcRequest
+		resp := rpcResponse{JSONRPC: "2.0"}
+		if err := json.Unmarshal(scanner.Bytes(), &req); err != nil {
+			resp.ID = json.RawMessage("null")
+			resp.Error = &rpcError{Code: rpcParseError, Message: err.Error()}
+		} else {
+			result, rpcErr := handleMCP(ctx, index, req)
+			if req.ID == nil {
+				// Notifications are not answered
+				continue
+			}
+			resp.ID, resp.Result, resp.Error = req.ID, result, rpcErr
+		}
+		if err := encoder.Encode(resp); err != nil {
+			return fmt.Errorf("%s: %w", programName, err)
+		}
+	}
+	return scanner.Err()
+}
+
+// handleMCP answers a request with its result or a protocol error
+func handleMCP(ctx context.Context, index *finder.Index, req rpcRequest) (any, *rpcError) {
+	switch req.Method {
+	case "initialize":
+		var params struct {
+			ProtocolVersion string `json:"protocolVersion"`
+		}
+		json.Unmarshal(req.Params, &params)
+		version := mcpProtocolVersions[0]
+		if slices.Contains(mcpProtocolVersions, params.ProtocolVersion) {
+			version = params.ProtocolVersion
+		}
+		return map[string]any{
+			"protocolVersion": version,
+			"capabilities":    map[string]any{"tools": map[string]any{}},
+			"serverInfo":      map[string]string{"name": programName, "version": toolVersion()},
+		}, nil
+	case "ping":
+		return map[string]any{}, nil
+	case "tools/list":
+		return map[string]any{"tools": mcpTools}, nil
+	case "tools/call":
+		var params struct {
+			Name      string            `json:"name"`
+			Arguments map[string]string `json:"arguments"`
+		}
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return nil, &rpcError{Code: rpcInvalidParams, Message: err.Error()}
+		}
+		return callMCPTool(ctx, index, params.Name, params.Arguments)
+	}
+	if req.ID == nil {
+		// notifications/initialized and the like
+		return nil, nil
+	}
+	return nil, &rpcError{Code: rpcMethodNotFound, Message: fmt.Sprintf("unknown method '%s'", req.Method)}
+}
+
+// callMCPTool runs a tool against the index, refreshed first so that the
+// answers follow the edits of the assistant
+func callMCPTool(ctx context.Context, index *finder.Index, name string, args map[string]string) (any, *rpcError) {
+	i := slices.IndexFunc(mcpTools, func(t mcpTool) bool { return t.Name == name })
+	if i < 0 {
+		return nil, &rpcError{Code: rpcInvalidParams, Message: fmt.Sprintf("unknown tool '%s'", name)}
+	}
+	required, _ := mcpTools[i].InputSchema["required"].([]string)
+	for _, arg := range required {
+		if args[arg] == "" {
+			return nil, &rpcError{Code: rpcInvalidParams, Message: fmt.Sprintf("%s: missing argument '%s'", name, arg)}
+		}
+	}
+	if _, err := index.Refresh(ctx); err != nil {
+		log.Printf("Error refreshing index: %v", err)
+	}
+
+	var answer any
+	switch name {
+	case "find_usages":
+		results := index.Lookup(finder.ParseMethodSpec(args["method"]))
+		if len(results) == 0 {
+			return toolError("no definition named '%s' found", args["method"]), nil
+		}
+		answer = results
+	case "list_unused":
+		unused := finder.FilterUnused(index.Lookup(finder.ParseMethodSpec(args["method"])))
+		if unused == nil {
+			unused = []finder.MethodUsage{}
+		}
+		answer = unused
+	case "call_graph":
+		graph, ok := finder.FocusModule(finder.BuildCallGraph(index.Lookup(finder.MethodSpec{})), args["module"])
+		if !ok {
+			return toolError("no function of module '%s' found", args["module"]), nil
+		}
+		answer = graph
+	}
+	data, err := json.Marshal(answer)
+	if err != nil {
+		return toolError("%v", err), nil
+	}
+	return mcpToolResult{Content: []mcpContent{{Type: "text", Text: string(data)}}}, nil
+}
+
+// toolError is the result of a failed tool call, read by the assistant
+func toolError(format string, args ...any) mcpToolResult {
+	return mcpToolResult{Content: []mcpContent{{Type: "text", Text: fmt.Sprintf(format, args...)}}, IsError: true}
+}
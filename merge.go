@@ -0,0 +1,105 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/sanchezhs/py-broom/finder"
+	"github.com/spf13/cobra"
+)
+
+func newMergeCmd(opts *options) *cobra.Command {
+	return &cobra.Command{
+		Use:   "merge results.json ...",
+		Short: "Merge the JSON outputs of sharded runs into one report",
+		Long: "Merge the JSON outputs of sharded runs into one report.\n\n" +
+			"Each file holds the --format json or jsonl output of a run with --shard i/n.\n" +
+			"The merged results are sorted and printed like the ones of a single run, in\n" +
+			"any --format. Empty files, left by shards without results, are skipped.",
+		SilenceUsage: true,
+		Args:         cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var sets [][]finder.MethodUsage
+			for _, path := range args {
+				results, err := readResults(path)
+				if err != nil {
+					return fmt.Errorf("%s: %s: %w", programName, path, err)
+				}
+				sets = append(sets, results)
+			}
+			results := finder.MergeResults(sets...)
+			if len(results) == 0 {
+				fmt.Printf("%s: No results to merge\n", programName)
+				return nil
+			}
+			finder.SortResults(results, opts.sortBy, opts.asc)
+			return printResults(cmd, opts, results, nil)
+		},
+	}
+}
+
+// readResults decodes a JSON array of results, one result per line, or the
+// object printed with --stats
+func readResults(path string) ([]finder.MethodUsage, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	first, err := peekNonSpace(r)
+	if err == io.EOF {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	dec := json.NewDecoder(r)
+	var results []finder.MethodUsage
+	if first == '[' {
+		err := dec.Decode(&results)
+		return results, err
+	}
+	for {
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err == io.EOF {
+			return results, nil
+		} else if err != nil {
+			return nil, err
+		}
+		var report finder.AnalysisResult
+		if err := json.Unmarshal(raw, &report); err == nil && report.Results != nil {
+			results = append(results, report.Results...)
+			continue
+		}
+		// The summary and metadata lines closing jsonl outputs hold no result
+		var trailer struct{ Summary, Meta json.RawMessage }
+		if err := json.Unmarshal(raw, &trailer); err == nil && (trailer.Summary != nil || trailer.Meta != nil) {
+			continue
+		}
+		var result finder.MethodUsage
+		if err := json.Unmarshal(raw, &result); err != nil {
+			return nil, err
+		}
+		results = append(results, result)
+	}
+}
+
+// peekNonSpace returns the first byte of r that is not white space, leaving it unread
+func peekNonSpace(r *bufio.Reader) (byte, error) {
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		if !bytes.ContainsRune([]byte(" \t\r\n"), rune(b)) {
+			return b, r.UnreadByte()
+		}
+	}
+}
@@ -2,11 +2,28 @@
 package printers
 
 import (
+	"bufio"
+	"cmp"
+	"crypto/sha256"
+	_ "embed"
+	"encoding/binary"
+	"encoding/csv"
+	"encoding/hex"
 	"encoding/json"
+	"encoding/xml"
 	"fmt"
+	"html"
 	"io"
+	"maps"
+	"math"
+	"net/url"
 	"path/filepath"
+	"reflect"
+	"slices"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/sanchezhs/py-broom/colors"
 	"github.com/sanchezhs/py-broom/finder"
@@ -16,32 +33,181 @@ type Printer interface {
 	Print(w io.Writer, methodUsage []finder.MethodUsage) error
 }
 
+// StreamPrinter is implemented by printers able to write each result as soon
+// as its analysis completes
+type StreamPrinter interface {
+	PrintResult(w io.Writer, result finder.MethodUsage) error
+}
+
+// MetaPrinter is implemented by stream printers closing their output with the
+// metadata of the run
+type MetaPrinter interface {
+	PrintMeta(w io.Writer) error
+}
+
+// SummaryPrinter is implemented by printers able to write usage statistics
+type SummaryPrinter interface {
+	PrintSummary(w io.Writer, summary finder.Summary) error
+}
+
+// GraphPrinter is implemented by printers able to draw dependency graphs
+type GraphPrinter interface {
+	PrintGraph(w io.Writer, g finder.Graph) error
+}
+
+// TopPrinter is implemented by printers able to show the most and least used
+// methods side by side
+type TopPrinter interface {
+	PrintTop(w io.Writer, most, least []finder.MethodUsage) error
+}
+
+// DiffPrinter is implemented by printers able to compare the results of two runs
+type DiffPrinter interface {
+	PrintDiff(w io.Writer, d finder.ResultDiff) error
+}
+
+// HistoryPrinter is implemented by printers able to show the recorded runs
+// of a project, oldest first
+type HistoryPrinter interface {
+	PrintHistory(w io.Writer, entries []finder.HistoryEntry) error
+}
+
+// PackagePrinter is implemented by printers able to output package-level summaries
+type PackagePrinter interface {
+	PrintPackages(w io.Writer, summaries []finder.PackageSummary) error
+}
+
+// FilePrinter is implemented by printers able to output results grouped by
+// defining file
+type FilePrinter interface {
+	PrintFiles(w io.Writer, summaries []finder.FileSummary) error
+}
+
+// GroupPrinter is implemented by printers able to output results in sections,
+// see finder.GroupByCallType and finder.GroupByDir
+type GroupPrinter interface {
+	PrintGroups(w io.Writer, groups []finder.ResultGroup) error
+}
+
 // ================================================================================
 // Console
 // ================================================================================
 
 type ConsolePrinter struct {
-	NoColor bool
+	NoColor   bool
+	Compact   bool                 // One line per method, without its usages
+	Highlight bool                 // Syntax highlight the source lines of usages, unless NoColor
+	Buckets   *finder.UsageBuckets // Color usage counts by bucket, finder.DefaultBuckets when nil
+	Summary   *finder.Summary      // Printed after the results when set
+	Links     string               // URL template of hyperlinked locations, see LocationURL
+}
+
+// DefaultLinks is the URL template of locations opening the file
+const DefaultLinks = "file://{path}"
+
+// LocationURL fills the {path}, {line} and {col} placeholders of template
+// for a location. The path is made absolute and escaped as in file URLs.
+func LocationURL(template, file string, line, col int) string {
+	if abs, err := filepath.Abs(file); err == nil {
+		file = abs
+	}
+	return strings.NewReplacer(
+		"{path}", (&url.URL{Path: filepath.ToSlash(file)}).EscapedPath(),
+		"{line}", strconv.Itoa(max(line, 1)),
+		"{col}", strconv.Itoa(max(col, 1)),
+	).Replace(template)
+}
+
+// link makes text a hyperlink to a location when p.Links is set
+func (p ConsolePrinter) link(text, file string, line, col int) string {
+	if p.Links == "" {
+		return text
+	}
+	return colors.Hyperlink(text, LocationURL(p.Links, file, line, col))
 }
 
 func (p ConsolePrinter) Print(w io.Writer, results []finder.MethodUsage) error {
+	showRoot := hasMultipleRoots(results)
 	for _, result := range results {
-		if err := p.printMethodUsage(w, result); err != nil {
+		if err := p.printMethodUsage(w, result, showRoot); err != nil {
 			return err
 		}
 	}
+	if p.Summary != nil {
+		return p.PrintSummary(w, *p.Summary)
+	}
 	return nil
 }
 
-func (p ConsolePrinter) printMethodUsage(w io.Writer, mu finder.MethodUsage) error {
+// PrintResult writes a single result, repository roots are not shown
+func (p ConsolePrinter) PrintResult(w io.Writer, result finder.MethodUsage) error {
+	return p.printMethodUsage(w, result, false)
+}
+
+// hasMultipleRoots reports whether results come from more than one source root
+func hasMultipleRoots(results []finder.MethodUsage) bool {
+	for _, r := range results {
+		if r.Method.Root != results[0].Method.Root {
+			return true
+		}
+	}
+	return false
+}
+
+func (p ConsolePrinter) printMethodUsage(w io.Writer, mu finder.MethodUsage, showRoot bool) error {
+	if p.Compact {
+		return p.printCompact(w, mu)
+	}
 	methodName := colors.Colorize(mu.Method.Name, colors.ColorBold+colors.ColorCyan, p.NoColor)
-	fmt.Fprintf(w, "Method: %s\n", methodName)
+	label := "Method"
+	switch mu.Method.Kind {
+	case finder.SymbolVariable:
+		label = "Variable"
+	case finder.SymbolAttribute:
+		label = "Attribute"
+		if mu.Method.Class != "" {
+			methodName = colors.Colorize(mu.Method.Class+".", colors.ColorCyan, p.NoColor) + methodName
+		}
+	}
+	fmt.Fprintf(w, "%s: %s\n", label, methodName)
 
 	location := fmt.Sprintf("%s:%d", mu.Method.Filename, mu.Method.LineNo)
-	fmt.Fprintf(w, "Defined in: %s\n", colors.Colorize(location, colors.ColorBlue, p.NoColor))
+	if mu.Method.EndLine > mu.Method.LineNo {
+		location += fmt.Sprintf("-%d", mu.Method.EndLine)
+	}
+	if mu.Method.Cell > 0 {
+		location += fmt.Sprintf(" (cell %d)", mu.Method.Cell)
+	}
+	if mu.Method.StubOnly {
+		location += " (stub only)"
+	}
+	if mu.Method.DynamicallyLoaded {
+		location += " (loaded dynamically)"
+	}
+	if mu.Method.Ignored {
+		location += " (ignored)"
+	}
+	fmt.Fprintf(w, "Defined in: %s\n", p.link(colors.Colorize(location, colors.ColorBlue, p.NoColor), mu.Method.Filename, mu.Method.LineNo, 0))
+	if showRoot {
+		fmt.Fprintf(w, "Root: %s\n", colors.Colorize(mu.Method.Root, colors.ColorBlue, p.NoColor))
+	}
+	if mu.Method.Parent != "" {
+		fmt.Fprintf(w, "Nested in: %s\n", colors.Colorize(mu.Method.Parent, colors.ColorCyan, p.NoColor))
+	}
+	if m := mu.Method.Metrics; m != nil {
+		fmt.Fprintf(w, "Complexity: %d (%d lines, nesting %d)\n", m.Complexity, m.Lines, m.Nesting)
+	}
+	if len(mu.Method.UnusedParams) > 0 {
+		params := strings.Join(mu.Method.UnusedParams, ", ")
+		fmt.Fprintf(w, "Unused parameters: %s\n", colors.Colorize(params, colors.ColorYellow, p.NoColor))
+	}
 
 	usageColor := p.getUsageCountColor(mu.TotalUsages)
-	totalUsages := colors.Colorize(fmt.Sprintf("%d", mu.TotalUsages), usageColor, p.NoColor)
+	count := fmt.Sprintf("%d", mu.TotalUsages)
+	if mu.Capped {
+		count += "+" // The search stopped early
+	}
+	totalUsages := colors.Colorize(count, usageColor, p.NoColor)
 	fmt.Fprintf(w, "Total usages: %s\n", totalUsages)
 
 	if mu.TotalUsages == 0 {
@@ -65,6 +231,21 @@ func (p ConsolePrinter) printMethodUsage(w io.Writer, mu finder.MethodUsage) err
 		}
 	}
 
+	if len(mu.UsagesByRoot) > 0 {
+		fmt.Fprintln(w, colors.Colorize("Usages by repository:", colors.ColorBold, p.NoColor))
+
+		roots := make([]string, 0, len(mu.UsagesByRoot))
+		for root := range mu.UsagesByRoot {
+			roots = append(roots, root)
+		}
+		sort.Strings(roots)
+		for _, root := range roots {
+			count := mu.UsagesByRoot[root]
+			fmt.Fprintf(w, "  - %s: %s\n", colors.Colorize(root, colors.ColorBlue, p.NoColor),
+				colors.Colorize(fmt.Sprintf("%d", count), p.getUsageCountColor(count), p.NoColor))
+		}
+	}
+
 	usagesByType := make(map[finder.CallType][]finder.Usage)
 	for _, usage := range mu.Usages {
 		usagesByType[usage.CallType] = append(usagesByType[usage.CallType], usage)
@@ -81,10 +262,38 @@ func (p ConsolePrinter) printMethodUsage(w io.Writer, mu finder.MethodUsage) err
 		fmt.Fprintf(w, "\n%s\n", header)
 
 		for _, usage := range usages {
-			fmt.Fprintf(w, "  - %s\n", colors.Colorize(usage.Location, colors.ColorWhite, p.NoColor))
-			fmt.Fprintf(w, "    %s\n", usage.Context)
+			file, line, col := splitLocation(usage.Location)
+			location := p.link(colors.Colorize(usage.Location, colors.ColorWhite, p.NoColor), file, line, col)
+			if usage.Cell > 0 {
+				location += fmt.Sprintf(" (cell %d)", usage.Cell)
+			}
+			if usage.Alias != "" {
+				location += fmt.Sprintf(" (as %s)", usage.Alias)
+			}
+			if caller := callerLabel(usage); caller != "" {
+				location += fmt.Sprintf(" (in %s)", caller)
+			}
+			if usage.Suspicious != "" {
+				location += colors.Colorize(" (suspicious: "+usage.Suspicious+")", colors.ColorRed, p.NoColor)
+			}
+			if usage.Confidence == finder.ConfidenceLow {
+				location += " (low confidence)"
+			}
+			if usage.CallType == finder.CallTypeSimilar {
+				location += fmt.Sprintf(" (%.0f%% similar)", usage.Similarity*100)
+			}
+			fmt.Fprintf(w, "  - %s\n", location)
+			if len(usage.Before) > 0 || len(usage.After) > 0 {
+				p.printWithContext(w, usage)
+				continue
+			}
+			fmt.Fprintf(w, "    %s\n", p.source(&pythonHighlighter{}, usage.Context))
 		}
 	}
+	if mu.TruncatedCount > 0 {
+		more := fmt.Sprintf("\n  ... %d more usages not stored", mu.TruncatedCount)
+		fmt.Fprintln(w, colors.Colorize(more, colors.ColorYellow, p.NoColor))
+	}
 
 	// Separator
 	separator := colors.Colorize(strings.Repeat("-", 80), colors.ColorReset, p.NoColor)
@@ -93,22 +302,115 @@ func (p ConsolePrinter) printMethodUsage(w io.Writer, mu finder.MethodUsage) err
 	return nil
 }
 
-func (p ConsolePrinter) getUsageCountColor(count int) string {
+// compactLabels abbreviates the call types of compact lines, the others are
+// written as is
+var compactLabels = map[finder.CallType]string{
+	finder.CallTypeInstance:           "inst",
+	finder.CallTypeClass:              "cls",
+	finder.CallTypeFunction:           "fn",
+	finder.CallTypeDecorator:          "deco",
+	finder.CallTypeAttributeReference: "ref",
+	finder.CallTypeStringReference:    "str",
+	finder.CallTypeDocReference:       "doc",
+}
+
+// printCompact writes mu on a single line: name, location, total and the
+// count of each call type other than the definition
+func (p ConsolePrinter) printCompact(w io.Writer, mu finder.MethodUsage) error {
+	location := fmt.Sprintf("%s:%d", mu.Method.Filename, mu.Method.LineNo)
+	count := strconv.Itoa(mu.TotalUsages)
+	if mu.Capped {
+		count += "+"
+	}
+	line := fmt.Sprintf("%s  %s  %s",
+		colors.Colorize(qualifiedName(mu.Method), colors.ColorBold+colors.ColorCyan, p.NoColor),
+		p.link(colors.Colorize(location, colors.ColorBlue, p.NoColor), mu.Method.Filename, mu.Method.LineNo, 0),
+		colors.Colorize(count, p.getUsageCountColor(mu.TotalUsages), p.NoColor))
+
+	var types []string
+	for _, ct := range finder.GetCallTypeOrder() {
+		n := mu.UsagesByType[ct]
+		if n == 0 || ct == finder.CallTypeDefinition {
+			continue
+		}
+		label, ok := compactLabels[ct]
+		if !ok {
+			label = string(ct)
+		}
+		types = append(types, colors.Colorize(fmt.Sprintf("%s:%d", label, n), p.getCallTypeColor(ct), p.NoColor))
+	}
+	if len(types) > 0 {
+		line += " (" + strings.Join(types, " ") + ")"
+	}
+	_, err := fmt.Fprintln(w, line)
+	return err
+}
+
+// printWithContext prints the usage line highlighted between the source lines
+// captured around it
+func (p ConsolePrinter) printWithContext(w io.Writer, u finder.Usage) {
+	lineNo := 0
+	if parts := strings.Split(u.Location, ":"); len(parts) >= 3 {
+		lineNo, _ = strconv.Atoi(parts[len(parts)-2])
+	}
+	h := &pythonHighlighter{}
+	line := func(n int, text string) {
+		fmt.Fprintf(w, "     %5d | %s\n", n, p.source(h, text))
+	}
+	first := lineNo - len(u.Before)
+	for i, text := range u.Before {
+		line(first+i, text)
+	}
+	if p.highlighting() {
+		// The marker stands out, as the line is colored by its syntax
+		fmt.Fprintf(w, "%s | %s\n", colors.Colorize(fmt.Sprintf("    >%5d", lineNo), colors.ColorBold, p.NoColor), p.source(h, u.Context))
+	} else {
+		fmt.Fprintln(w, colors.Colorize(fmt.Sprintf("    >%5d | %s", lineNo, u.Context), colors.ColorBold, p.NoColor))
+	}
+	for i, text := range u.After {
+		line(lineNo+1+i, text)
+	}
+}
+
+func (p ConsolePrinter) highlighting() bool {
+	return p.Highlight && !p.NoColor
+}
+
+// source returns a source line, highlighted with h when enabled
+func (p ConsolePrinter) source(h *pythonHighlighter, line string) string {
+	if !p.highlighting() {
+		return line
+	}
+	return h.highlight(line)
+}
+
+// callerLabel names the scope a usage sits in: Class.method, function or Class
+func callerLabel(u finder.Usage) string {
 	switch {
-	case count == 0:
-		return colors.ColorYellow
-	case count <= 2:
-		return colors.ColorRed
-	case count <= 5:
-		return colors.ColorYellow
+	case u.CallerClass != "" && u.Caller != "":
+		return u.CallerClass + "." + u.Caller
+	case u.CallerClass != "":
+		return u.CallerClass
 	default:
-		return colors.ColorGreen
+		return u.Caller
 	}
 }
 
+// bucketColors color the usage counts of each bucket of finder.UsageBuckets
+var bucketColors = map[string]string{
+	finder.BucketUnused: colors.ColorYellow,
+	finder.BucketLow:    colors.ColorRed,
+	finder.BucketMedium: colors.ColorYellow,
+	finder.BucketHigh:   colors.ColorGreen,
+}
+
+func (p ConsolePrinter) getUsageCountColor(count int) string {
+	return bucketColors[p.Buckets.Bucket(count)]
+}
+
 func (p ConsolePrinter) getCallTypeColor(ct finder.CallType) string {
 	switch ct {
-	case finder.CallTypeDefinition:
+	case finder.CallTypeDefinition, finder.CallTypeOverload:
 		return colors.ColorPurple
 	case finder.CallTypeInstance:
 		return colors.ColorGreen
@@ -120,39 +422,289 @@ func (p ConsolePrinter) getCallTypeColor(ct finder.CallType) string {
 		return colors.ColorYellow
 	case finder.CallTypeDecorator:
 		return colors.ColorPurple
+	case finder.CallTypePartial:
+		return colors.ColorYellow
+	case finder.CallTypeAttributeReference:
+		return colors.ColorYellow
+	case finder.CallTypeStringReference, finder.CallTypeDynamicImport:
+		return colors.ColorCyan
+	case finder.CallTypeTypeHint:
+		return colors.ColorBlue
+	case finder.CallTypeDocReference:
+		return colors.ColorCyan
+	case finder.CallTypeComment:
+		return colors.ColorWhite
+	case finder.CallTypeRead:
+		return colors.ColorGreen
+	case finder.CallTypeWrite:
+		return colors.ColorRed
+	case finder.CallTypeImport, finder.CallTypeReexport:
+		return colors.ColorBlue
+	case finder.CallTypeDuplicate:
+		return colors.ColorRed
+	case finder.CallTypeSimilar:
+		return colors.ColorYellow
 	default:
 		return colors.ColorWhite
 	}
 }
 
-func (p ConsolePrinter) PrintSummary(w io.Writer, results []finder.MethodUsage) error {
-	totalMethods := len(results)
+func (p ConsolePrinter) PrintPackages(w io.Writer, summaries []finder.PackageSummary) error {
+	width := len("Package")
+	for _, s := range summaries {
+		width = max(width, len(s.Package))
+	}
+
+	header := fmt.Sprintf("%-*s  %7s  %6s  %6s  %7s  %6s", width, "Package", "Methods", "Unused", "Usages", "Density", "Dead")
+	fmt.Fprintln(w, colors.Colorize(header, colors.ColorBold, p.NoColor))
+	for _, s := range summaries {
+		unused := colors.Colorize(fmt.Sprintf("%6d", s.Unused), colors.ColorYellow, p.NoColor)
+		if s.Unused == 0 {
+			unused = colors.Colorize(fmt.Sprintf("%6d", s.Unused), colors.ColorGreen, p.NoColor)
+		}
+		fmt.Fprintf(w, "%s  %7d  %s  %6d  %7.2f  %5.1f%%\n",
+			colors.Colorize(fmt.Sprintf("%-*s", width, s.Package), colors.ColorCyan, p.NoColor),
+			s.Methods, unused, s.Usages, s.Density, s.DeadRatio*100)
+	}
+	return nil
+}
 
-	var unused, lowUsage, mediumUsage, highUsage int
-	totalInstanceCalls := 0
-	totalClassCalls := 0
-	totalStaticCalls := 0
-	totalFunctionCalls := 0
-	totalDecoratorCalls := 0
+// PrintFiles writes a section per file, its statistics followed by a line per
+// method with its total usages
+func (p ConsolePrinter) PrintFiles(w io.Writer, summaries []finder.FileSummary) error {
+	for i, s := range summaries {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		fmt.Fprintln(w, colors.Colorize(s.File, colors.ColorBold+colors.ColorBlue, p.NoColor))
+		dead := colors.Colorize(fmt.Sprintf("%.1f%%", s.DeadRatio*100), colors.ColorGreen, p.NoColor)
+		if s.Unused > 0 {
+			dead = colors.Colorize(fmt.Sprintf("%.1f%%", s.DeadRatio*100), colors.ColorYellow, p.NoColor)
+		}
+		if _, err := fmt.Fprintf(w, "Methods: %d  Unused: %d  Usages: %d  Dead code: %s\n", s.Methods, s.Unused, s.Usages, dead); err != nil {
+			return err
+		}
 
-	for _, result := range results {
-		switch {
-		case result.TotalUsages == 0:
-			unused++
-		case result.TotalUsages <= 2:
-			lowUsage++
-		case result.TotalUsages <= 5:
-			mediumUsage++
-		default:
-			highUsage++
+		width := 0
+		for _, r := range s.Results {
+			width = max(width, len(qualifiedName(r.Method)))
+		}
+		for _, r := range s.Results {
+			total := strconv.Itoa(r.TotalUsages)
+			if r.Capped {
+				total += "+"
+			}
+			if _, err := fmt.Fprintf(w, "  %5d  %s  %s\n", r.Method.LineNo,
+				colors.Colorize(fmt.Sprintf("%-*s", width, qualifiedName(r.Method)), colors.ColorCyan, p.NoColor),
+				colors.Colorize(total, p.getUsageCountColor(finder.RealUsages(r)), p.NoColor)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// PrintGroups writes a header per group, its name and number of methods,
+// followed by its results as Print writes them
+func (p ConsolePrinter) PrintGroups(w io.Writer, groups []finder.ResultGroup) error {
+	var all []finder.MethodUsage
+	for _, g := range groups {
+		all = append(all, g.Results...)
+	}
+	showRoot := hasMultipleRoots(all)
+	for i, g := range groups {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		header := fmt.Sprintf("== %s (%d methods) ==", g.Name, len(g.Results))
+		if _, err := fmt.Fprintln(w, colors.Colorize(header, colors.ColorBold+colors.ColorBlue, p.NoColor)); err != nil {
+			return err
+		}
+		for _, result := range g.Results {
+			if err := p.printMethodUsage(w, result, showRoot); err != nil {
+				return err
+			}
+		}
+	}
+	if p.Summary != nil {
+		return p.PrintSummary(w, *p.Summary)
+	}
+	return nil
+}
+
+// PrintTop writes two columns, the most used methods on the left and the least
+// used on the right, each with its total usages
+func (p ConsolePrinter) PrintTop(w io.Writer, most, least []finder.MethodUsage) error {
+	cell := func(r finder.MethodUsage) string {
+		total := strconv.Itoa(r.TotalUsages)
+		if r.Capped {
+			total += "+"
+		}
+		return fmt.Sprintf("%6s  %s", total, qualifiedName(r.Method))
+	}
+	width := len("Most used")
+	for _, r := range most {
+		width = max(width, len(cell(r)))
+	}
+
+	header := fmt.Sprintf("%-*s    %s", width, "Most used", "Least used")
+	if _, err := fmt.Fprintln(w, colors.Colorize(header, colors.ColorBold, p.NoColor)); err != nil {
+		return err
+	}
+	for i := range max(len(most), len(least)) {
+		left, right := "", ""
+		if i < len(most) {
+			left = colors.Colorize(fmt.Sprintf("%-*s", width, cell(most[i])), colors.ColorGreen, p.NoColor)
+		} else {
+			left = strings.Repeat(" ", width)
+		}
+		if i < len(least) {
+			right = colors.Colorize(cell(least[i]), p.getUsageCountColor(finder.RealUsages(least[i])), p.NoColor)
+		}
+		if _, err := fmt.Fprintln(w, strings.TrimRight(left+"    "+right, " ")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// PrintDiff writes the totals of both runs, then the definitions that became
+// unused, were added or removed, and whose usages changed significantly
+func (p ConsolePrinter) PrintDiff(w io.Writer, d finder.ResultDiff) error {
+	bold := func(text string) string { return colors.Colorize(text, colors.ColorBold, p.NoColor) }
+	location := func(m finder.Method) string {
+		return p.link(fmt.Sprintf("%s:%d", m.Filename, m.LineNo), m.Filename, m.LineNo, 0)
+	}
+	name := func(m finder.Method, color string) string { return colors.Colorize(qualifiedName(m), color, p.NoColor) }
+	fmt.Fprintf(w, "%s %d -> %d (%+d)\n", bold("Definitions:"), d.MethodsBefore, d.MethodsAfter, d.MethodsAfter-d.MethodsBefore)
+	fmt.Fprintf(w, "%s %d -> %d (%+d)\n", bold("Unused:"), d.UnusedBefore, d.UnusedAfter, d.UnusedAfter-d.UnusedBefore)
+	section := func(title string, n int) { fmt.Fprintf(w, "\n%s\n", bold(fmt.Sprintf("%s (%d):", title, n))) }
+
+	if len(d.NewlyUnused) > 0 {
+		section("Newly unused", len(d.NewlyUnused))
+		for _, c := range d.NewlyUnused {
+			fmt.Fprintf(w, "  %s  %s  (%d usages before)\n", name(c.Method, colors.ColorRed), location(c.Method), c.Before)
+		}
+	}
+	if len(d.Added) > 0 {
+		section("Added", len(d.Added))
+		for _, r := range d.Added {
+			usages := fmt.Sprintf("%d usages", finder.RealUsages(r))
+			if finder.IsUnused(r) {
+				usages = colors.Colorize("unused", colors.ColorRed, p.NoColor)
+			}
+			fmt.Fprintf(w, "  %s  %s  %s\n", name(r.Method, colors.ColorCyan), location(r.Method), usages)
 		}
+	}
+	if len(d.Removed) > 0 {
+		section("Removed", len(d.Removed))
+		for _, r := range d.Removed {
+			fmt.Fprintf(w, "  %s  %s:%d\n", name(r.Method, colors.ColorCyan), r.Method.Filename, r.Method.LineNo)
+		}
+	}
+	if len(d.Changed) > 0 {
+		section("Usage changes", len(d.Changed))
+		for _, c := range d.Changed {
+			color := colors.ColorGreen
+			if c.After < c.Before {
+				color = colors.ColorYellow
+			}
+			change := colors.Colorize(fmt.Sprintf("%d -> %d (%+d)", c.Before, c.After, c.After-c.Before), color, p.NoColor)
+			fmt.Fprintf(w, "  %s  %s  %s\n", name(c.Method, colors.ColorCyan), location(c.Method), change)
+		}
+	}
+	if len(d.NewlyUnused)+len(d.Added)+len(d.Removed)+len(d.Changed) == 0 {
+		fmt.Fprintln(w, "\nNo definition changed")
+	}
+	return nil
+}
+
+// sparkBlocks draw sparklines, from the lowest value to the highest
+var sparkBlocks = []rune("▁▂▃▄▅▆▇█")
+
+// sparkline draws values as a line of blocks scaled between their minimum and maximum
+func sparkline(values []int) string {
+	if len(values) == 0 {
+		return ""
+	}
+	lo, hi := slices.Min(values), slices.Max(values)
+	var b strings.Builder
+	for _, v := range values {
+		level := 0
+		if hi > lo {
+			level = (v - lo) * (len(sparkBlocks) - 1) / (hi - lo)
+		}
+		b.WriteRune(sparkBlocks[level])
+	}
+	return b.String()
+}
+
+// historyRun names a recorded run by its label, or else its short commit
+func historyRun(e finder.HistoryEntry) string {
+	switch {
+	case e.Label != "":
+		return e.Label
+	case len(e.Commit) > 7:
+		return e.Commit[:7]
+	}
+	return e.Commit
+}
 
-		totalInstanceCalls += result.UsagesByType[finder.CallTypeInstance]
-		totalClassCalls += result.UsagesByType[finder.CallTypeClass]
-		totalStaticCalls += result.UsagesByType[finder.CallTypeStatic]
-		totalFunctionCalls += result.UsagesByType[finder.CallTypeFunction]
-		totalDecoratorCalls += result.UsagesByType[finder.CallTypeDecorator]
+// PrintHistory writes a row per recorded run with the changes since the
+// previous one, then the trends of the definitions and unused ones
+func (p ConsolePrinter) PrintHistory(w io.Writer, entries []finder.HistoryEntry) error {
+	if len(entries) == 0 {
+		fmt.Fprintln(w, "No runs recorded")
+		return nil
+	}
+	runWidth := len("Run")
+	for _, e := range entries {
+		runWidth = max(runWidth, len(historyRun(e)))
+	}
+	// Fewer unused definitions is progress, fewer definitions neither
+	delta := func(n int, color bool) string {
+		text := fmt.Sprintf("%+6d", n)
+		switch {
+		case n == 0:
+			return strings.Repeat(" ", len(text))
+		case !color:
+			return text
+		case n > 0:
+			return colors.Colorize(text, colors.ColorRed, p.NoColor)
+		}
+		return colors.Colorize(text, colors.ColorGreen, p.NoColor)
 	}
+	header := fmt.Sprintf("%-16s  %-*s  %8s  %6s  %7s  %6s  %7s", "Recorded", runWidth, "Run", "Methods", "", "Unused", "", "Unused%")
+	fmt.Fprintln(w, colors.Colorize(header, colors.ColorBold, p.NoColor))
+	methods, unused := make([]int, len(entries)), make([]int, len(entries))
+	for i, e := range entries {
+		methods[i], unused[i] = e.Methods, e.Unused
+		dm, du := 0, 0
+		if i > 0 {
+			dm, du = e.Methods-entries[i-1].Methods, e.Unused-entries[i-1].Unused
+		}
+		percent := 0.0
+		if e.Methods > 0 {
+			percent = float64(e.Unused) * 100 / float64(e.Methods)
+		}
+		fmt.Fprintf(w, "%-16s  %-*s  %8d  %s  %7d  %s  %6.1f%%\n",
+			e.RecordedAt.Local().Format("2006-01-02 15:04"), runWidth, historyRun(e), e.Methods, delta(dm, false), e.Unused, delta(du, true), percent)
+	}
+
+	first, last := entries[0], entries[len(entries)-1]
+	fmt.Fprintf(w, "\n%s %s  %d -> %d (%+d)\n", colors.Colorize("Methods:", colors.ColorBold, p.NoColor), sparkline(methods), first.Methods, last.Methods, last.Methods-first.Methods)
+	fmt.Fprintf(w, "%s %s  %d -> %d (%+d)\n", colors.Colorize("Unused: ", colors.ColorBold, p.NoColor), sparkline(unused), first.Unused, last.Unused, last.Unused-first.Unused)
+	return nil
+}
+
+func (p ConsolePrinter) PrintSummary(w io.Writer, summary finder.Summary) error {
+	totalMethods := summary.Methods
+	unused, lowUsage, mediumUsage, highUsage := summary.Unused, summary.Low, summary.Medium, summary.High
+	totalInstanceCalls := summary.UsagesByType[finder.CallTypeInstance]
+	totalClassCalls := summary.UsagesByType[finder.CallTypeClass]
+	totalStaticCalls := summary.UsagesByType[finder.CallTypeStatic]
+	totalFunctionCalls := summary.UsagesByType[finder.CallTypeFunction]
+	totalDecoratorCalls := summary.UsagesByType[finder.CallTypeDecorator]
 
 	separator := colors.Colorize(strings.Repeat("=", 80), colors.ColorBold, p.NoColor)
 	title := colors.Colorize("SUMMARY", colors.ColorBold+colors.ColorCyan, p.NoColor)
@@ -166,33 +718,24 @@ func (p ConsolePrinter) PrintSummary(w io.Writer, results []finder.MethodUsage)
 
 	fmt.Fprintln(w, colors.Colorize("Methods by usage count:", colors.ColorBold, p.NoColor))
 
-	// Unused - red
-	unusedPct := float64(unused) / float64(totalMethods) * 100
-	fmt.Fprintf(w, "  - %s: %s (%s)\n",
-		colors.Colorize("Unused (0 usages)", colors.ColorYellow, p.NoColor),
-		colors.Colorize(fmt.Sprintf("%d", unused), colors.ColorYellow, p.NoColor),
-		colors.Colorize(fmt.Sprintf("%.1f%%", unusedPct), colors.ColorYellow, p.NoColor))
-
-	// Low usage - yellow
-	lowPct := float64(lowUsage) / float64(totalMethods) * 100
-	fmt.Fprintf(w, "  - %s: %s (%s)\n",
-		colors.Colorize("Low usage (1-2 usages)", colors.ColorRed, p.NoColor),
-		colors.Colorize(fmt.Sprintf("%d", lowUsage), colors.ColorRed, p.NoColor),
-		colors.Colorize(fmt.Sprintf("%.1f%%", lowPct), colors.ColorRed, p.NoColor))
-
-	// Medium usage - cyan
-	medPct := float64(mediumUsage) / float64(totalMethods) * 100
-	fmt.Fprintf(w, "  - %s: %s (%s)\n",
-		colors.Colorize("Medium (3-5 usages)", colors.ColorYellow, p.NoColor),
-		colors.Colorize(fmt.Sprintf("%d", mediumUsage), colors.ColorYellow, p.NoColor),
-		colors.Colorize(fmt.Sprintf("%.1f%%", medPct), colors.ColorYellow, p.NoColor))
-
-	// High usage - green
-	highPct := float64(highUsage) / float64(totalMethods) * 100
-	fmt.Fprintf(w, "  - %s: %s (%s)\n\n",
-		colors.Colorize("High usage (6+ usages)", colors.ColorGreen, p.NoColor),
-		colors.Colorize(fmt.Sprintf("%d", highUsage), colors.ColorGreen, p.NoColor),
-		colors.Colorize(fmt.Sprintf("%.1f%%", highPct), colors.ColorGreen, p.NoColor))
+	buckets := []struct {
+		name  string
+		count int
+	}{
+		{finder.BucketUnused, unused},
+		{finder.BucketLow, lowUsage},
+		{finder.BucketMedium, mediumUsage},
+		{finder.BucketHigh, highUsage},
+	}
+	for _, b := range buckets {
+		color := bucketColors[b.name]
+		pct := float64(b.count) / float64(totalMethods) * 100
+		fmt.Fprintf(w, "  - %s: %s (%s)\n",
+			colors.Colorize(summary.Buckets.Label(b.name), color, p.NoColor),
+			colors.Colorize(fmt.Sprintf("%d", b.count), color, p.NoColor),
+			colors.Colorize(fmt.Sprintf("%.1f%%", pct), color, p.NoColor))
+	}
+	fmt.Fprintln(w)
 
 	fmt.Fprintln(w, colors.Colorize("Call type distribution:", colors.ColorBold, p.NoColor))
 	fmt.Fprintf(w, "  - %s: %s\n",
@@ -217,124 +760,1894 @@ func (p ConsolePrinter) PrintSummary(w io.Writer, results []finder.MethodUsage)
 }
 
 //================================================================================
-// Json
+// Syntax highlighting
 //================================================================================
 
-type JSONPrinter struct {
-	Indent bool
+// pythonKeywords are colored as keywords in highlighted source lines
+var pythonKeywords = map[string]bool{
+	"False": true, "None": true, "True": true, "and": true, "as": true, "assert": true,
+	"async": true, "await": true, "break": true, "class": true, "continue": true, "def": true,
+	"del": true, "elif": true, "else": true, "except": true, "finally": true, "for": true,
+	"from": true, "global": true, "if": true, "import": true, "in": true, "is": true,
+	"lambda": true, "nonlocal": true, "not": true, "or": true, "pass": true, "raise": true,
+	"return": true, "try": true, "while": true, "with": true, "yield": true,
+	"match": true, "case": true,
 }
 
-func (p JSONPrinter) Print(w io.Writer, results []finder.MethodUsage) error {
-	enc := json.NewEncoder(w)
-	if p.Indent {
-		enc.SetIndent("", "  ")
-	}
-	enc.SetIndent("", "  ")
-	return enc.Encode(results)
+// pythonBuiltins are the most common builtins, colored apart from plain names
+var pythonBuiltins = map[string]bool{
+	"self": true, "cls": true, "print": true, "len": true, "range": true, "isinstance": true,
+	"super": true, "str": true, "int": true, "float": true, "bool": true, "list": true,
+	"dict": true, "set": true, "tuple": true, "type": true, "object": true, "open": true,
+	"enumerate": true, "zip": true, "map": true, "filter": true, "sorted": true, "getattr": true,
+	"setattr": true, "hasattr": true, "Exception": true, "ValueError": true, "TypeError": true,
+	"KeyError": true, "property": true, "staticmethod": true, "classmethod": true,
 }
 
-//================================================================================
-// Vim grep
-//================================================================================
+// Colors of the tokens of highlighted source lines
+const (
+	colorKeyword   = colors.ColorPurple
+	colorBuiltin   = colors.ColorCyan
+	colorString    = colors.ColorGreen
+	colorComment   = colors.ColorGray
+	colorNumber    = colors.ColorYellow
+	colorDecorator = colors.ColorYellow
+	colorDefName   = colors.ColorBlue
+)
 
-type VimPrinter struct{}
+// pythonHighlighter colors Python source lines for the terminal. Lines are
+// highlighted in order, so that triple-quoted strings spanning several of
+// them are followed.
+type pythonHighlighter struct {
+	open string // Delimiter of the triple-quoted string the next line continues
+}
 
-func (VimPrinter) Print(w io.Writer, results []finder.MethodUsage) error {
-	for _, r := range results {
-		for _, u := range r.Usages {
-			loc := strings.TrimSpace(u.Location) // expected "path:line:col"
-			ctx := sanitizeContext(u.Context)
+// highlight returns line with its keywords, builtins, strings, comments,
+// numbers, decorators and defined names colored
+func (h *pythonHighlighter) highlight(line string) string {
+	var b strings.Builder
+	emit := func(text, color string) {
+		if color == "" {
+			b.WriteString(text)
+			return
+		}
+		b.WriteString(colors.Colorize(text, color, false))
+	}
 
-			if ctx == "" {
-				if u.CallType != "" {
-					ctx = fmt.Sprintf("%s [%s]", r.Method.Name, string(u.CallType))
-				} else {
-					ctx = r.Method.Name
-				}
-			}
+	i := 0
+	if h.open != "" {
+		end := strings.Index(line, h.open)
+		if end < 0 {
+			emit(line, colorString)
+			return b.String()
+		}
+		i = end + len(h.open)
+		emit(line[:i], colorString)
+		h.open = ""
+	}
 
-			if _, err := fmt.Fprintf(w, "%s:%s\n", loc, ctx); err != nil {
-				return err
+	afterDef := false
+	for i < len(line) {
+		c := line[i]
+		switch {
+		case c == '#':
+			emit(line[i:], colorComment)
+			return b.String()
+		case c == '"' || c == '\'':
+			end := h.stringEnd(line, i)
+			emit(line[i:end], colorString)
+			i = end
+		case isDigit(c) || c == '.' && i+1 < len(line) && isDigit(line[i+1]):
+			end := i + 1
+			for end < len(line) && (isIdentByte(line[end]) || line[end] == '.') {
+				end++
+			}
+			emit(line[i:end], colorNumber)
+			i = end
+		case c == '@' && strings.TrimSpace(line[:i]) == "":
+			end := i + 1
+			for end < len(line) && (isIdentByte(line[end]) || line[end] == '.') {
+				end++
 			}
+			emit(line[i:end], colorDecorator)
+			i = end
+		case isIdentByte(c):
+			end := i + 1
+			for end < len(line) && isIdentByte(line[end]) {
+				end++
+			}
+			word := line[i:end]
+			if end < len(line) && (line[end] == '"' || line[end] == '\'') && isStringPrefix(word) {
+				// A prefixed string such as f"..." or rb'...'
+				end = h.stringEnd(line, end)
+				emit(line[i:end], colorString)
+				i = end
+				continue
+			}
+			switch {
+			case afterDef:
+				emit(word, colorDefName)
+			case pythonKeywords[word]:
+				emit(word, colorKeyword)
+			case pythonBuiltins[word]:
+				emit(word, colorBuiltin)
+			default:
+				emit(word, "")
+			}
+			afterDef = word == "def" || word == "class"
+			i = end
+		default:
+			if c != ' ' && c != '\t' {
+				afterDef = false
+			}
+			emit(line[i:i+1], "")
+			i++
 		}
 	}
-	return nil
+	return b.String()
 }
 
-func sanitizeContext(s string) string {
-	s = strings.ReplaceAll(s, "\r\n", " ")
-	s = strings.ReplaceAll(s, "\n", " ")
-	s = strings.ReplaceAll(s, "\t", " ")
-	s = strings.TrimLeft(s, " ")
-	s = strings.Join(strings.Fields(s), " ")
-	return s
+// stringEnd returns the index right after the string literal opening at
+// start, the end of line for unterminated ones. Triple-quoted strings left
+// open continue on the next line.
+func (h *pythonHighlighter) stringEnd(line string, start int) int {
+	quote := line[start : start+1]
+	if strings.HasPrefix(line[start:], quote+quote+quote) {
+		delim := quote + quote + quote
+		if end := strings.Index(line[start+3:], delim); end >= 0 {
+			return start + 3 + end + 3
+		}
+		h.open = delim
+		return len(line)
+	}
+	for i := start + 1; i < len(line); i++ {
+		switch line[i] {
+		case '\\':
+			i++
+		case quote[0]:
+			return i + 1
+		}
+	}
+	return len(line)
 }
 
-//================================================================================
-// Graphviz
-//================================================================================
-
-type GraphvizPrinter struct {
-	opts Options
-}
+func isDigit(c byte) bool { return c >= '0' && c <= '9' }
 
-func NewGraphvizPrinter(opts Options) *GraphvizPrinter {
-	return &GraphvizPrinter{opts: opts}
+// isIdentByte reports whether c can be part of an identifier; bytes of
+// multi-byte runes are, which keeps non-ASCII names whole
+func isIdentByte(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || isDigit(c) || c >= 0x80
 }
 
-func extractPathFromUsage(s string) string {
-	parts := strings.Split(s, ":")
-	if len(parts) >= 4 {
-		return strings.Join(parts[:len(parts)-3], ":")
+// isStringPrefix reports whether word prefixes a string literal, like f or rb
+func isStringPrefix(word string) bool {
+	if len(word) > 2 {
+		return false
 	}
-	if len(parts) == 3 {
-		return parts[0]
+	for _, c := range strings.ToLower(word) {
+		if !strings.ContainsRune("rbuf", c) {
+			return false
+		}
 	}
-	return s
+	return true
 }
 
-func (GraphvizPrinter) Print(w io.Writer, results []finder.MethodUsage) error {
-	fmt.Fprintln(w, "digraph G {")
-	fmt.Fprintln(w, `  rankdir=LR;`)
-	fmt.Fprintln(w, `  node [shape=box, fontsize=10];`)
+//================================================================================
+// Table
+//================================================================================
 
-	nodes := make(map[string]struct{})
-	edges := make(map[string]struct{})
+// TablePrinter writes one aligned row per method, with its total and a
+// column per call type found in the results, for quick triage of many methods
+type TablePrinter struct {
+	NoColor bool
+	Buckets *finder.UsageBuckets // Color usage counts by bucket, finder.DefaultBuckets when nil
+	Summary *finder.Summary      // Printed after the table when set
+	Links   string               // URL template of hyperlinked locations, see LocationURL
+}
 
-	normalizeNode := func(filePath, funcName string) string {
-		base := filepath.Base(filePath)
-		ext := filepath.Ext(base)
-		if len(ext) > 0 {
-			base = strings.TrimSuffix(base, ext)
+func (p TablePrinter) Print(w io.Writer, results []finder.MethodUsage) error {
+	var types []finder.CallType
+	for _, ct := range finder.GetCallTypeOrder() {
+		for _, r := range results {
+			if r.UsagesByType[ct] > 0 {
+				types = append(types, ct)
+				break
+			}
 		}
-		return base + ":" + funcName
 	}
 
-	for _, r := range results {
-		callee := normalizeNode(r.Method.Filename, r.Method.Name)
-		nodes[callee] = struct{}{}
+	names := make([]string, len(results))
+	locations := make([]string, len(results))
+	nameWidth, locationWidth := len("Method"), len("Location")
+	for i, r := range results {
+		names[i] = qualifiedName(r.Method)
+		locations[i] = fmt.Sprintf("%s:%d", r.Method.Filename, r.Method.LineNo)
+		nameWidth = max(nameWidth, len(names[i]))
+		locationWidth = max(locationWidth, len(locations[i]))
+	}
 
-		for _, u := range r.Usages {
-			useFile := extractPathFromUsage(u.Context)
-			if useFile == "" {
-				continue
-			}
-			caller := normalizeNode(useFile, "<module>")
-			nodes[caller] = struct{}{}
+	header := fmt.Sprintf("%-*s  %-*s  %6s", nameWidth, "Method", locationWidth, "Location", "Total")
+	for _, ct := range types {
+		header += fmt.Sprintf("  %*s", len(ct), ct)
+	}
+	if _, err := fmt.Fprintln(w, colors.Colorize(header, colors.ColorBold, p.NoColor)); err != nil {
+		return err
+	}
+	console := ConsolePrinter{NoColor: p.NoColor, Buckets: p.Buckets, Links: p.Links}
+	for i, r := range results {
+		total := fmt.Sprintf("%6d", r.TotalUsages)
+		if r.Capped {
+			total = fmt.Sprintf("%6s", strconv.Itoa(r.TotalUsages)+"+")
+		}
+		// Padded outside of the link, so that only the location is clickable
+		location := console.link(locations[i], r.Method.Filename, r.Method.LineNo, 0) +
+			strings.Repeat(" ", locationWidth-len(locations[i]))
+		row := fmt.Sprintf("%s  %s  %s",
+			colors.Colorize(fmt.Sprintf("%-*s", nameWidth, names[i]), colors.ColorCyan, p.NoColor),
+			location,
+			colors.Colorize(total, console.getUsageCountColor(finder.RealUsages(r)), p.NoColor))
+		for _, ct := range types {
+			row += fmt.Sprintf("  %*d", len(ct), r.UsagesByType[ct])
+		}
+		if _, err := fmt.Fprintln(w, row); err != nil {
+			return err
+		}
+	}
+	if p.Summary != nil {
+		return p.PrintSummary(w, *p.Summary)
+	}
+	return nil
+}
+
+func (p TablePrinter) PrintSummary(w io.Writer, summary finder.Summary) error {
+	return ConsolePrinter{NoColor: p.NoColor}.PrintSummary(w, summary)
+}
+
+func (p TablePrinter) PrintFiles(w io.Writer, summaries []finder.FileSummary) error {
+	return ConsolePrinter{NoColor: p.NoColor, Buckets: p.Buckets}.PrintFiles(w, summaries)
+}
+
+func (p TablePrinter) PrintTop(w io.Writer, most, least []finder.MethodUsage) error {
+	return ConsolePrinter{NoColor: p.NoColor, Buckets: p.Buckets}.PrintTop(w, most, least)
+}
+
+func (p TablePrinter) PrintHistory(w io.Writer, entries []finder.HistoryEntry) error {
+	return ConsolePrinter{NoColor: p.NoColor}.PrintHistory(w, entries)
+}
+
+func (p TablePrinter) PrintDiff(w io.Writer, d finder.ResultDiff) error {
+	return ConsolePrinter{NoColor: p.NoColor, Links: p.Links}.PrintDiff(w, d)
+}
+
+//================================================================================
+// Json
+//================================================================================
+
+// JSONPrinter writes the results in a JSONReport envelope, described by
+// JSONSchema
+type JSONPrinter struct {
+	Indent bool
+	Meta   Meta
+	// Added to the report when set
+	Stats   *finder.RunStats
+	Summary *finder.Summary
+}
+
+// SchemaVersion is the version of the schema of the json format. Its major
+// number changes when fields are removed or change meaning, its minor number
+// when fields are added.
+const SchemaVersion = "1.1"
+
+// SchemaID identifies the schema of the json format
+const SchemaID = "https://github.com/sanchezhs/py-broom/schema/report-v1.json"
+
+// Meta describes the run behind a report
+type Meta struct {
+	Version      string         // Version of pybr
+	GeneratedAt  time.Time      // The current time when zero
+	Config       map[string]any // Effective value of every flag
+	TotalMethods int            // Definitions analyzed, the number of results when 0
+	Commit       string         // Git commit analyzed, empty outside repositories
+	Started      time.Time      // Start of the run, its duration is unknown when zero
+}
+
+// RunMeta is the metadata block of the structured formats
+type RunMeta struct {
+	SchemaVersion string         `json:"schema_version"`
+	Tool          JSONTool       `json:"tool"`
+	GeneratedAt   time.Time      `json:"generated_at"`
+	Config        map[string]any `json:"config"`
+	Commit        string         `json:"commit,omitempty"`
+	DurationMs    float64        `json:"duration_ms"`
+}
+
+// Block returns the metadata block of m, with its defaults filled in
+func (m Meta) Block() RunMeta {
+	block := RunMeta{
+		SchemaVersion: SchemaVersion,
+		Tool:          JSONTool{Name: "pybr", Version: cmp.Or(m.Version, "dev")},
+		GeneratedAt:   m.GeneratedAt,
+		Config:        m.Config,
+		Commit:        m.Commit,
+	}
+	if block.GeneratedAt.IsZero() {
+		block.GeneratedAt = time.Now().UTC().Truncate(time.Second)
+	}
+	if block.Config == nil {
+		block.Config = map[string]any{}
+	}
+	if !m.Started.IsZero() {
+		block.DurationMs = float64(time.Since(m.Started).Microseconds()) / 1000
+	}
+	return block
+}
+
+// JSONReport is the document written by the json format
+type JSONReport struct {
+	SchemaVersion string               `json:"schema_version"`
+	Tool          JSONTool             `json:"tool"`
+	GeneratedAt   time.Time            `json:"generated_at"`
+	Config        map[string]any       `json:"config"`
+	Commit        string               `json:"commit,omitempty"` // Since 1.1
+	DurationMs    float64              `json:"duration_ms"`      // Since 1.1
+	TotalMethods  int                  `json:"total_methods"`
+	Results       []finder.MethodUsage `json:"results"`
+	Stats         *finder.RunStats     `json:"stats,omitempty"`
+	Summary       *finder.Summary      `json:"summary,omitempty"`
+}
+
+// JSONTool names the program writing a report
+type JSONTool struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+func (p JSONPrinter) Print(w io.Writer, results []finder.MethodUsage) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	meta := p.Meta.Block()
+	report := JSONReport{
+		SchemaVersion: meta.SchemaVersion,
+		Tool:          meta.Tool,
+		GeneratedAt:   meta.GeneratedAt,
+		Config:        meta.Config,
+		Commit:        meta.Commit,
+		DurationMs:    meta.DurationMs,
+		TotalMethods:  cmp.Or(p.Meta.TotalMethods, len(results)),
+		Results:       results,
+		Stats:         p.Stats,
+		Summary:       p.Summary,
+	}
+	if report.Results == nil {
+		report.Results = []finder.MethodUsage{}
+	}
+	return enc.Encode(report)
+}
+
+func (p JSONPrinter) PrintSummary(w io.Writer, summary finder.Summary) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(summary)
+}
+
+func (p JSONPrinter) PrintFiles(w io.Writer, summaries []finder.FileSummary) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(summaries)
+}
+
+func (p JSONPrinter) PrintTop(w io.Writer, most, least []finder.MethodUsage) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	// Empty lists are printed as [] rather than null
+	return enc.Encode(struct {
+		Most  []finder.MethodUsage `json:"most_used"`
+		Least []finder.MethodUsage `json:"least_used"`
+	}{append([]finder.MethodUsage{}, most...), append([]finder.MethodUsage{}, least...)})
+}
+
+func (p JSONPrinter) PrintDiff(w io.Writer, d finder.ResultDiff) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(d)
+}
+
+func (p JSONPrinter) PrintHistory(w io.Writer, entries []finder.HistoryEntry) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(append([]finder.HistoryEntry{}, entries...))
+}
+
+func (p JSONPrinter) PrintPackages(w io.Writer, summaries []finder.PackageSummary) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(summaries)
+}
+
+//================================================================================
+// JSON Schema
+//================================================================================
+
+// JSONSchema returns the JSON Schema (draft 2020-12) of the reports of the
+// json format, generated from JSONReport so that both never drift apart.
+// Objects accept unknown properties, so that a report still validates
+// against the schema of an older minor version.
+func JSONSchema() map[string]any {
+	g := schemaGenerator{defs: make(map[string]any)}
+	schema := g.object(reflect.TypeFor[JSONReport]())
+	schema["$schema"] = "https://json-schema.org/draft/2020-12/schema"
+	schema["$id"] = SchemaID
+	schema["title"] = "pybr report"
+	schema["description"] = fmt.Sprintf("Usages of Python definitions found by pybr, schema version %s", SchemaVersion)
+	schema["$defs"] = g.defs
+	return schema
+}
+
+type schemaGenerator struct {
+	defs map[string]any // Schemas of the named structs, by name
+}
+
+// of returns the schema of the JSON encoding of values of t
+func (g schemaGenerator) of(t reflect.Type) map[string]any {
+	if t == reflect.TypeFor[time.Time]() {
+		return map[string]any{"type": "string", "format": "date-time"}
+	}
+	switch t.Kind() {
+	case reflect.Pointer:
+		return g.of(t.Elem())
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		// Nil slices are encoded as null
+		return map[string]any{"type": []string{"array", "null"}, "items": g.of(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": []string{"object", "null"}, "additionalProperties": g.of(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.object(t)
+		}
+		if _, ok := g.defs[t.Name()]; !ok {
+			g.defs[t.Name()] = nil // Stops the recursion of self-referencing types
+			g.defs[t.Name()] = g.object(t)
+		}
+		return map[string]any{"$ref": "#/$defs/" + t.Name()}
+	}
+	return map[string]any{} // Any value
+}
+
+// object returns the schema of a struct, whose fields without omitempty are
+// required
+func (g schemaGenerator) object(t reflect.Type) map[string]any {
+	properties := make(map[string]any)
+	required := []string{}
+	for i := range t.NumField() {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if !f.IsExported() || tag == "-" {
+			continue
+		}
+		name, options, _ := strings.Cut(tag, ",")
+		if name == "" {
+			name = f.Name
+		}
+		properties[name] = g.of(f.Type)
+		if !slices.Contains(strings.Split(options, ","), "omitempty") {
+			required = append(required, name)
+		}
+	}
+	return map[string]any{"type": "object", "properties": properties, "required": required}
+}
+
+//================================================================================
+// JSON Lines
+//================================================================================
+
+// JSONLPrinter writes one compact JSON object per result and line, closed
+// by a {"meta": ...} line describing the run
+type JSONLPrinter struct {
+	Meta    Meta
+	Summary *finder.Summary // Written as a {"summary": ...} line before the metadata when set
+}
+
+func (p JSONLPrinter) Print(w io.Writer, results []finder.MethodUsage) error {
+	for _, r := range results {
+		if err := p.PrintResult(w, r); err != nil {
+			return err
+		}
+	}
+	if p.Summary != nil {
+		if err := p.PrintSummary(w, *p.Summary); err != nil {
+			return err
+		}
+	}
+	return p.PrintMeta(w)
+}
+
+func (JSONLPrinter) PrintResult(w io.Writer, result finder.MethodUsage) error {
+	return json.NewEncoder(w).Encode(result)
+}
+
+// PrintSummary writes the summary wrapped in an object, so that it can be
+// told apart from the results
+func (JSONLPrinter) PrintSummary(w io.Writer, summary finder.Summary) error {
+	return json.NewEncoder(w).Encode(struct {
+		Summary finder.Summary `json:"summary"`
+	}{summary})
+}
+
+// PrintMeta writes the metadata of the run wrapped in an object
+func (p JSONLPrinter) PrintMeta(w io.Writer) error {
+	return json.NewEncoder(w).Encode(struct {
+		Meta RunMeta `json:"meta"`
+	}{p.Meta.Block()})
+}
+
+//================================================================================
+// Vim grep
+//================================================================================
+
+type VimPrinter struct{}
+
+func (p VimPrinter) Print(w io.Writer, results []finder.MethodUsage) error {
+	for _, r := range results {
+		if err := p.PrintResult(w, r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (VimPrinter) PrintResult(w io.Writer, r finder.MethodUsage) error {
+	for _, u := range r.Usages {
+		loc := strings.TrimSpace(u.Location) // expected "path:line:col"
+		ctx := sanitizeContext(u.Context)
+
+		if ctx == "" {
+			if u.CallType != "" {
+				ctx = fmt.Sprintf("%s [%s]", r.Method.Name, string(u.CallType))
+			} else {
+				ctx = r.Method.Name
+			}
+		}
+
+		if _, err := fmt.Fprintf(w, "%s:%s\n", loc, ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func sanitizeContext(s string) string {
+	s = strings.ReplaceAll(s, "\r\n", " ")
+	s = strings.ReplaceAll(s, "\n", " ")
+	s = strings.ReplaceAll(s, "\t", " ")
+	s = strings.TrimLeft(s, " ")
+	s = strings.Join(strings.Fields(s), " ")
+	return s
+}
+
+//================================================================================
+// Grep
+//================================================================================
+
+// GrepPrinter writes a classic "file:line:col: message" line per usage, as
+// parsed by Emacs compilation-mode and grep-mode and the error parsers of
+// most editors
+type GrepPrinter struct{}
+
+func (p GrepPrinter) Print(w io.Writer, results []finder.MethodUsage) error {
+	for _, r := range results {
+		if err := p.PrintResult(w, r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (GrepPrinter) PrintResult(w io.Writer, r finder.MethodUsage) error {
+	name := qualifiedName(r.Method)
+	for _, u := range r.Usages {
+		file, line, col := splitLocation(strings.TrimSpace(u.Location))
+		message := fmt.Sprintf("%s %s", u.CallType, name)
+		if context := strings.TrimSpace(u.Context); context != "" {
+			message += ": " + context
+		}
+		if _, err := fmt.Fprintf(w, "%s:%d:%d: %s\n", file, line, col, message); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//================================================================================
+// Ctags
+//================================================================================
+
+// CtagsPrinter writes a tags file in the extended format of Exuberant and
+// Universal Ctags, so vim and emacs jump to the definitions found. Each tag
+// carries its usage counts in the usages and real_usages extension fields.
+// Classes are not tagged, only their methods and attributes.
+type CtagsPrinter struct{}
+
+func (CtagsPrinter) Print(w io.Writer, results []finder.MethodUsage) error {
+	lines := make([]string, 0, len(results))
+	for _, r := range results {
+		m := r.Method
+		kind := "f"
+		switch {
+		case m.Kind == finder.SymbolVariable || m.Kind == finder.SymbolAttribute:
+			kind = "v"
+		case m.Class != "":
+			kind = "m"
+		}
+		line := fmt.Sprintf("%s\t%s\t%d;\"\t%s\tline:%d", m.Name, m.Filename, m.LineNo, kind, m.LineNo)
+		if m.Class != "" {
+			line += "\tclass:" + m.Class
+		}
+		usages := strconv.Itoa(r.TotalUsages)
+		if r.Capped {
+			usages += "+"
+		}
+		lines = append(lines, line+fmt.Sprintf("\tusages:%s\treal_usages:%d", usages, finder.RealUsages(r)))
+	}
+	// Sorted by name, and then by the rest of the line, for binary search
+	slices.Sort(lines)
+
+	header := "!_TAG_FILE_FORMAT\t2\t/extended format/\n" +
+		"!_TAG_FILE_SORTED\t1\t/0=unsorted, 1=sorted, 2=foldcase/\n" +
+		"!_TAG_PROGRAM_NAME\tpybr\t//\n" +
+		"!_TAG_PROGRAM_URL\thttps://github.com/sanchezhs/py-broom\t//\n"
+	if _, err := io.WriteString(w, header); err != nil {
+		return err
+	}
+	for _, line := range lines {
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//================================================================================
+// Graphviz
+//================================================================================
+
+// GraphvizPrinter writes a graph in Graphviz DOT, styled by the Graph field of
+// its options
+type GraphvizPrinter struct {
+	opts Options
+}
+
+// GraphOptions style the Graphviz output
+type GraphOptions struct {
+	RankDir    string // Direction of the layout: LR (default), TB, RL or BT
+	Cluster    bool   // Draw the nodes of each package in a box
+	EdgeLabels bool   // Label edges with their usage or import counts
+	Unused     bool   // Color the definitions without real usages red
+}
+
+// RankDirs are the directions of a Graphviz layout
+var RankDirs = []string{"LR", "TB", "RL", "BT"}
+
+func NewGraphvizPrinter(opts Options) *GraphvizPrinter {
+	return &GraphvizPrinter{opts: opts}
+}
+
+func (p GraphvizPrinter) Print(w io.Writer, results []finder.MethodUsage) error {
+	return p.PrintGraph(w, finder.BuildCallGraph(results))
+}
+
+func (p GraphvizPrinter) PrintGraph(w io.Writer, g finder.Graph) error {
+	style := p.opts.Graph
+	rankDir := cmp.Or(style.RankDir, "LR")
+	fmt.Fprintln(w, "digraph G {")
+	fmt.Fprintf(w, "  rankdir=%s;\n", rankDir)
+	fmt.Fprintln(w, `  node [shape=box, fontsize=10];`)
+
+	node := func(indent string, n finder.GraphNode) {
+		if style.Unused && n.Unused {
+			fmt.Fprintf(w, "%s%q [color=red, fontcolor=red];\n", indent, n.ID)
+		} else {
+			fmt.Fprintf(w, "%s%q;\n", indent, n.ID)
+		}
+	}
+	if style.Cluster {
+		var groups []string
+		members := make(map[string][]finder.GraphNode)
+		for _, n := range g.Nodes {
+			if _, ok := members[n.Group]; !ok {
+				groups = append(groups, n.Group)
+			}
+			members[n.Group] = append(members[n.Group], n)
+		}
+		slices.Sort(groups)
+		for i, group := range groups {
+			if group == "" {
+				continue
+			}
+			fmt.Fprintf(w, "  subgraph cluster_%d {\n", i)
+			fmt.Fprintf(w, "    label=%q;\n    style=rounded;\n", group)
+			for _, n := range members[group] {
+				node("    ", n)
+			}
+			fmt.Fprintln(w, "  }")
+		}
+		for _, n := range members[""] {
+			node("  ", n)
+		}
+	} else {
+		for _, n := range g.Nodes {
+			node("  ", n)
+		}
+	}
+	for _, e := range g.Edges {
+		if style.EdgeLabels {
+			fmt.Fprintf(w, "  %q->%q [label=\"%d\"];\n", e.From, e.To, e.Weight)
+		} else {
+			fmt.Fprintf(w, "  %q->%q;\n", e.From, e.To)
+		}
+	}
+
+	fmt.Fprintln(w, "}")
+	return nil
+}
+
+//================================================================================
+// Mermaid
+//================================================================================
+
+type MermaidPrinter struct{}
+
+func (p MermaidPrinter) Print(w io.Writer, results []finder.MethodUsage) error {
+	return p.PrintGraph(w, finder.BuildCallGraph(results))
+}
+
+// PrintGraph writes a Mermaid flowchart. Mermaid ids can not hold dots or
+// colons, so nodes get positional ids and keep their name as label.
+func (MermaidPrinter) PrintGraph(w io.Writer, g finder.Graph) error {
+	fmt.Fprintln(w, "flowchart LR")
+
+	ids := make(map[string]string, len(g.Nodes))
+	for i, n := range g.Nodes {
+		ids[n.ID] = fmt.Sprintf("n%d", i)
+		label := strings.ReplaceAll(n.Label, `"`, "#quot;")
+		fmt.Fprintf(w, "  %s[\"%s\"]\n", ids[n.ID], label)
+	}
+	for _, e := range g.Edges {
+		fmt.Fprintf(w, "  %s --> %s\n", ids[e.From], ids[e.To])
+	}
+	return nil
+}
+
+//================================================================================
+// Graph JSON
+//================================================================================
+
+// GraphJSONPrinter writes a graph as {"nodes": [...], "edges": [...]}, with
+// the usage or import counts as edge weights. Nodes have an id and edges a
+// source and target, as D3 and Cytoscape.js expect. The run is described
+// under "meta".
+type GraphJSONPrinter struct {
+	Meta Meta
+}
+
+func (p GraphJSONPrinter) Print(w io.Writer, results []finder.MethodUsage) error {
+	return p.PrintGraph(w, finder.BuildCallGraph(results))
+}
+
+func (p GraphJSONPrinter) PrintGraph(w io.Writer, g finder.Graph) error {
+	if g.Nodes == nil {
+		g.Nodes = []finder.GraphNode{}
+	}
+	if g.Edges == nil {
+		g.Edges = []finder.GraphEdge{}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+	return enc.Encode(struct {
+		finder.Graph
+		Meta RunMeta `json:"meta"`
+	}{g, p.Meta.Block()})
+}
+
+//================================================================================
+// Protocol Buffers
+//================================================================================
+
+// ReportProto is the protobuf schema of the reports of the pb format
+//
+//go:embed report.proto
+var ReportProto string
+
+// ProtoPrinter writes a single Report message of ReportProto in the protobuf
+// binary encoding, compact and quick to parse for reports too large for JSON
+type ProtoPrinter struct {
+	Meta    Meta
+	Summary *finder.Summary // Embedded in the report when set
+}
+
+func (p ProtoPrinter) Print(w io.Writer, results []finder.MethodUsage) error {
+	meta := p.Meta.Block()
+	config := make(map[string]string, len(meta.Config))
+	for name, value := range meta.Config {
+		data, err := json.Marshal(value)
+		if err != nil {
+			return err
+		}
+		config[name] = string(data)
+	}
+
+	var b protoBuffer
+	b.stringField(1, SchemaVersion)
+	b.message(2, func(b *protoBuffer) {
+		b.stringField(1, "pybr")
+		b.stringField(2, meta.Tool.Version)
+	})
+	b.stringField(3, meta.GeneratedAt.Format(time.RFC3339))
+	for _, name := range slices.Sorted(maps.Keys(config)) {
+		b.message(4, func(b *protoBuffer) {
+			b.stringField(1, name)
+			b.stringField(2, config[name])
+		})
+	}
+	b.intField(5, cmp.Or(p.Meta.TotalMethods, len(results)))
+	for _, r := range results {
+		b.message(6, func(b *protoBuffer) { protoMethodUsage(b, r) })
+	}
+	if p.Summary != nil {
+		b.message(7, func(b *protoBuffer) { protoSummary(b, *p.Summary) })
+	}
+	b.stringField(8, meta.Commit)
+	b.doubleField(9, meta.DurationMs)
+	_, err := w.Write(b.data)
+	return err
+}
+
+// PrintSummary writes a Report holding only summary
+func (p ProtoPrinter) PrintSummary(w io.Writer, summary finder.Summary) error {
+	var b protoBuffer
+	b.stringField(1, SchemaVersion)
+	b.message(7, func(b *protoBuffer) { protoSummary(b, summary) })
+	_, err := w.Write(b.data)
+	return err
+}
+
+func protoMethodUsage(b *protoBuffer, r finder.MethodUsage) {
+	b.message(1, func(b *protoBuffer) { protoMethod(b, r.Method) })
+	for _, u := range r.Usages {
+		b.message(2, func(b *protoBuffer) { protoUsage(b, u) })
+	}
+	protoCounts(b, 3, r.UsagesByType)
+	protoCounts(b, 4, r.UsagesByRoot)
+	b.intField(5, r.TotalUsages)
+	b.intField(6, r.TruncatedCount)
+	b.boolField(7, r.Capped)
+	b.stringField(8, r.SearchError)
+}
+
+func protoMethod(b *protoBuffer, m finder.Method) {
+	b.stringField(1, m.Name)
+	b.stringField(2, m.Filename)
+	b.intField(3, m.LineNo)
+	b.intField(4, m.EndLine)
+	b.stringField(5, string(m.Kind))
+	b.stringField(6, m.Class)
+	b.stringField(7, m.Parent)
+	b.stringField(8, string(m.Binding))
+	b.repeatedStrings(9, m.Decorators)
+	b.stringField(10, m.Root)
+	b.intField(11, m.Cell)
+	b.boolField(12, m.StubOnly)
+	b.packedInts(13, m.Overloads)
+	b.boolField(14, m.DynamicallyLoaded)
+	b.repeatedStrings(15, m.UnusedParams)
+	if m.Metrics != nil {
+		b.message(16, func(b *protoBuffer) {
+			b.intField(1, m.Metrics.Lines)
+			b.intField(2, m.Metrics.Branches)
+			b.intField(3, m.Metrics.Nesting)
+			b.intField(4, m.Metrics.Complexity)
+		})
+	}
+	b.boolField(17, m.Ignored)
+}
+
+func protoUsage(b *protoBuffer, u finder.Usage) {
+	b.stringField(1, u.Location)
+	b.stringField(2, string(u.CallType))
+	b.stringField(3, u.Context)
+	b.stringField(4, u.Alias)
+	b.stringField(5, u.Root)
+	b.intField(6, u.Cell)
+	b.stringField(7, u.Caller)
+	b.stringField(8, u.CallerClass)
+	b.doubleField(9, u.Similarity)
+	b.stringField(10, string(u.Confidence))
+	b.stringField(11, u.Suspicious)
+	b.repeatedStrings(12, u.Before)
+	b.repeatedStrings(13, u.After)
+	b.intField(14, u.Collapsed)
+}
+
+func protoSummary(b *protoBuffer, s finder.Summary) {
+	b.intField(1, s.Methods)
+	b.intField(2, s.Unused)
+	b.intField(3, s.Low)
+	b.intField(4, s.Medium)
+	b.intField(5, s.High)
+	protoCounts(b, 6, s.UsagesByType)
+	if s.Buckets != nil {
+		b.message(7, func(b *protoBuffer) {
+			b.intField(1, s.Buckets.Low)
+			b.intField(2, s.Buckets.Medium)
+			b.repeatedStrings(3, s.Buckets.Labels)
+		})
+	}
+}
+
+// Wire types of the protobuf encoding
+const (
+	protoVarint  = 0
+	protoFixed64 = 1
+	protoBytes   = 2
+)
+
+// protoBuffer appends fields in the protobuf binary encoding. Like proto3,
+// it leaves out fields holding their zero value.
+type protoBuffer struct {
+	data []byte
+}
+
+func (b *protoBuffer) tag(field, wireType int) {
+	b.data = binary.AppendUvarint(b.data, uint64(field)<<3|uint64(wireType))
+}
+
+func (b *protoBuffer) intField(field, v int) {
+	if v != 0 {
+		b.tag(field, protoVarint)
+		b.data = binary.AppendUvarint(b.data, uint64(v))
+	}
+}
+
+func (b *protoBuffer) boolField(field int, v bool) {
+	if v {
+		b.intField(field, 1)
+	}
+}
+
+func (b *protoBuffer) doubleField(field int, v float64) {
+	if v != 0 {
+		b.tag(field, protoFixed64)
+		b.data = binary.LittleEndian.AppendUint64(b.data, math.Float64bits(v))
+	}
+}
+
+func (b *protoBuffer) stringField(field int, v string) {
+	if v != "" {
+		b.tag(field, protoBytes)
+		b.data = binary.AppendUvarint(b.data, uint64(len(v)))
+		b.data = append(b.data, v...)
+	}
+}
+
+// repeatedStrings writes every value of a repeated string field, empty ones
+// included
+func (b *protoBuffer) repeatedStrings(field int, values []string) {
+	for _, v := range values {
+		b.tag(field, protoBytes)
+		b.data = binary.AppendUvarint(b.data, uint64(len(v)))
+		b.data = append(b.data, v...)
+	}
+}
+
+// packedInts writes a packed repeated field
+func (b *protoBuffer) packedInts(field int, values []int) {
+	if len(values) == 0 {
+		return
+	}
+	var packed protoBuffer
+	for _, v := range values {
+		packed.data = binary.AppendUvarint(packed.data, uint64(v))
+	}
+	b.tag(field, protoBytes)
+	b.data = binary.AppendUvarint(b.data, uint64(len(packed.data)))
+	b.data = append(b.data, packed.data...)
+}
+
+// message writes the embedded message written by fields, even when empty
+func (b *protoBuffer) message(field int, fields func(b *protoBuffer)) {
+	var m protoBuffer
+	fields(&m)
+	b.tag(field, protoBytes)
+	b.data = binary.AppendUvarint(b.data, uint64(len(m.data)))
+	b.data = append(b.data, m.data...)
+}
+
+// protoCounts writes a map<string, int64> field, its entries sorted by key
+func protoCounts[K ~string](b *protoBuffer, field int, m map[K]int) {
+	for _, key := range slices.Sorted(maps.Keys(m)) {
+		b.message(field, func(b *protoBuffer) {
+			b.stringField(1, string(key))
+			b.intField(2, m[key])
+		})
+	}
+}
+
+//================================================================================
+// Treemap
+//================================================================================
+
+// TreemapPrinter writes a self-contained HTML page with a treemap of the
+// packages: the area of each rectangle is its number of methods and the color
+// its share of unused ones, from green (none) to red (all).
+type TreemapPrinter struct{}
+
+// Size of the treemap in pixels
+const (
+	treemapWidth  = 1200
+	treemapHeight = 800
+)
+
+type tile struct {
+	x, y, w, h float64
+}
 
-			edgeKey := `"` + caller + `"->"` + callee + `"`
-			edges[edgeKey] = struct{}{}
+func (p TreemapPrinter) Print(w io.Writer, results []finder.MethodUsage) error {
+	return p.PrintPackages(w, finder.AggregateByPackage(results))
+}
+
+// PrintPackages draws a rectangle per summary, so that --group-by module
+// draws modules instead of packages
+func (TreemapPrinter) PrintPackages(w io.Writer, summaries []finder.PackageSummary) error {
+	summaries = slices.Clone(summaries)
+	slices.SortStableFunc(summaries, func(a, b finder.PackageSummary) int {
+		return cmp.Compare(b.Methods, a.Methods)
+	})
+	total := 0
+	for _, s := range summaries {
+		total += s.Methods
+	}
+	areas := make([]float64, len(summaries))
+	for i, s := range summaries {
+		areas[i] = float64(s.Methods) * treemapWidth * treemapHeight / float64(total)
+	}
+
+	var b strings.Builder
+	b.WriteString("<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n<title>pybr dead code treemap</title>\n")
+	b.WriteString("<style>body{font-family:sans-serif}text{font-size:12px;pointer-events:none}</style>\n</head>\n<body>\n")
+	fmt.Fprintf(&b, "<p>Area: number of methods. Color: share of unused methods, from green (0%%) to red (100%%).</p>\n")
+	fmt.Fprintf(&b, "<svg xmlns=\"http://www.w3.org/2000/svg\" width=\"%d\" height=\"%d\">\n", treemapWidth, treemapHeight)
+	for i, t := range squarify(areas, tile{0, 0, treemapWidth, treemapHeight}) {
+		s := summaries[i]
+		name := html.EscapeString(s.Package)
+		// Hue 120 is green, 0 red
+		fmt.Fprintf(&b, "<rect x=\"%.1f\" y=\"%.1f\" width=\"%.1f\" height=\"%.1f\" fill=\"hsl(%.0f,70%%,50%%)\" stroke=\"white\">",
+			t.x, t.y, t.w, t.h, 120*(1-s.DeadRatio))
+		fmt.Fprintf(&b, "<title>%s: %d methods, %d unused (%.1f%%)</title></rect>\n", name, s.Methods, s.Unused, s.DeadRatio*100)
+		if t.w > 60 && t.h > 16 {
+			fmt.Fprintf(&b, "<text x=\"%.1f\" y=\"%.1f\">%s</text>\n", t.x+4, t.y+14, name)
+		}
+	}
+	b.WriteString("</svg>\n</body>\n</html>\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// squarify lays areas, sorted from largest to smallest, out in r as rows of
+// rectangles as close to squares as possible (Bruls, Huizing and van Wijk)
+func squarify(areas []float64, r tile) []tile {
+	tiles := make([]tile, 0, len(areas))
+	for len(areas) > 0 {
+		side := min(r.w, r.h)
+		n := 1
+		for n < len(areas) && worstRatio(areas[:n+1], side) <= worstRatio(areas[:n], side) {
+			n++
+		}
+		row := areas[:n]
+		sum := 0.0
+		for _, a := range row {
+			sum += a
+		}
+		if r.w >= r.h {
+			// A column along the left side
+			width, y := sum/r.h, r.y
+			for _, a := range row {
+				tiles = append(tiles, tile{r.x, y, width, a / width})
+				y += a / width
+			}
+			r.x, r.w = r.x+width, r.w-width
+		} else {
+			// A row along the top side
+			height, x := sum/r.w, r.x
+			for _, a := range row {
+				tiles = append(tiles, tile{x, r.y, a / height, height})
+				x += a / height
+			}
+			r.y, r.h = r.y+height, r.h-height
 		}
+		areas = areas[n:]
 	}
+	return tiles
+}
 
-	for n := range nodes {
-		fmt.Fprintf(w, "  %q;\n", n)
+// worstRatio is the highest aspect ratio of the rectangles of row laid along
+// a side of the given length
+func worstRatio(row []float64, side float64) float64 {
+	sum := 0.0
+	for _, a := range row {
+		sum += a
 	}
-	for e := range edges {
-		fmt.Fprintf(w, "  %s;\n", e)
+	worst := 0.0
+	for _, a := range row {
+		worst = max(worst, side*side*a/(sum*sum), sum*sum/(side*side*a))
 	}
+	return worst
+}
 
-	fmt.Fprintln(w, "}")
+//================================================================================
+// CSV
+//================================================================================
+
+// CSV modes
+const (
+	CSVUsages  = "usages"  // One row per usage
+	CSVSummary = "summary" // One row per method
+)
+
+// CSVModes are the values of --csv-mode
+var CSVModes = []string{CSVUsages, CSVSummary}
+
+// CSVPrinter writes a header and one row per usage, or per method in summary
+// mode. Methods without usages still get a row, with the usage columns empty.
+type CSVPrinter struct {
+	Mode     string // CSVUsages when empty
+	Meta     Meta
+	WithMeta bool // Write Meta as "# name: value" comment lines before the header
+}
+
+func (p CSVPrinter) Print(w io.Writer, results []finder.MethodUsage) error {
+	if p.WithMeta {
+		bw := bufio.NewWriter(w)
+		writeTAPComments(bw, metaProperties(p.Meta.Block()))
+		if err := bw.Flush(); err != nil {
+			return err
+		}
+	}
+	cw := csv.NewWriter(w)
+	if p.Mode == CSVSummary {
+		_ = cw.Write([]string{"method", "kind", "defined_file", "defined_line", "total_usages", "real_usages", "complexity"})
+		for _, r := range results {
+			complexity := ""
+			if r.Method.Metrics != nil {
+				complexity = strconv.Itoa(r.Method.Metrics.Complexity)
+			}
+			_ = cw.Write([]string{qualifiedName(r.Method), string(r.Method.Kind), r.Method.Filename, strconv.Itoa(r.Method.LineNo),
+				strconv.Itoa(r.TotalUsages), strconv.Itoa(finder.RealUsages(r)), complexity})
+		}
+	} else {
+		_ = cw.Write([]string{"method", "defined_file", "defined_line", "usage_file", "usage_line", "call_type", "context"})
+		for _, r := range results {
+			method := []string{qualifiedName(r.Method), r.Method.Filename, strconv.Itoa(r.Method.LineNo)}
+			if len(r.Usages) == 0 {
+				_ = cw.Write(append(method, "", "", "", ""))
+			}
+			for _, u := range r.Usages {
+				file, line, _ := splitLocation(u.Location)
+				_ = cw.Write(append(slices.Clip(method), file, strconv.Itoa(line), string(u.CallType), sanitizeContext(u.Context)))
+			}
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+//================================================================================
+// JUnit XML
+//================================================================================
+
+// JUnitPrinter writes a JUnit XML report for CI test panels: every method is
+// a test case, grouped in a suite per module, and unused methods are failures.
+// Methods whose search failed are errors.
+type JUnitPrinter struct {
+	Meta    Meta            // Added as properties of the report
+	Summary *finder.Summary // Added as properties of the report when set
+}
+
+type junitSuites struct {
+	XMLName    xml.Name        `xml:"testsuites"`
+	Name       string          `xml:"name,attr"`
+	Tests      int             `xml:"tests,attr"`
+	Failures   int             `xml:"failures,attr"`
+	Errors     int             `xml:"errors,attr"`
+	Time       string          `xml:"time,attr,omitempty"` // Seconds the run took
+	Timestamp  string          `xml:"timestamp,attr"`
+	Properties []junitProperty `xml:"properties>property,omitempty"`
+	Suites     []junitSuite    `xml:"testsuite"`
+}
+
+type junitProperty struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value,attr"`
+}
+
+type junitSuite struct {
+	Name     string      `xml:"name,attr"`
+	Tests    int         `xml:"tests,attr"`
+	Failures int         `xml:"failures,attr"`
+	Errors   int         `xml:"errors,attr"`
+	Cases    []junitCase `xml:"testcase"`
+}
+
+type junitCase struct {
+	Name      string        `xml:"name,attr"`
+	Classname string        `xml:"classname,attr"`
+	File      string        `xml:"file,attr"`
+	Line      int           `xml:"line,attr"`
+	Failure   *junitProblem `xml:"failure,omitempty"`
+	Error     *junitProblem `xml:"error,omitempty"`
+}
+
+type junitProblem struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",chardata"`
+}
+
+func (p JUnitPrinter) Print(w io.Writer, results []finder.MethodUsage) error {
+	report := p.suites()
+	if p.Summary != nil {
+		report.Properties = append(report.Properties, junitProperties(*p.Summary)...)
+	}
+	suiteOf := make(map[string]int)
+	for _, r := range results {
+		module := finder.ModuleName(r.Method)
+		i, ok := suiteOf[module]
+		if !ok {
+			i = len(report.Suites)
+			suiteOf[module] = i
+			report.Suites = append(report.Suites, junitSuite{Name: module})
+		}
+		suite := &report.Suites[i]
+
+		location := fmt.Sprintf("%s:%d", r.Method.Filename, r.Method.LineNo)
+		tc := junitCase{
+			Name:      qualifiedName(r.Method),
+			Classname: module,
+			File:      r.Method.Filename,
+			Line:      r.Method.LineNo,
+		}
+		switch {
+		case r.SearchError != "":
+			tc.Error = &junitProblem{Message: "search failed", Type: "search-error", Text: r.SearchError}
+			suite.Errors++
+		case !finder.IsImplicitlyUsed(r.Method) && finder.RealUsages(r) == 0:
+			message := fmt.Sprintf("%s %s is never used", kindLabel(r.Method), tc.Name)
+			tc.Failure = &junitProblem{Message: message, Type: "unused", Text: message + " (" + location + ")"}
+			suite.Failures++
+		}
+		suite.Tests++
+		suite.Cases = append(suite.Cases, tc)
+	}
+	for _, suite := range report.Suites {
+		report.Tests += suite.Tests
+		report.Failures += suite.Failures
+		report.Errors += suite.Errors
+	}
+	return writeJUnit(w, report)
+}
+
+// PrintSummary writes a report without test cases, holding the summary as
+// properties
+func (p JUnitPrinter) PrintSummary(w io.Writer, summary finder.Summary) error {
+	report := p.suites()
+	report.Properties = append(report.Properties, junitProperties(summary)...)
+	return writeJUnit(w, report)
+}
+
+// suites returns an empty report describing the run
+func (p JUnitPrinter) suites() junitSuites {
+	meta := p.Meta.Block()
+	report := junitSuites{Name: "pybr", Timestamp: meta.GeneratedAt.Format(time.RFC3339), Properties: metaProperties(meta)}
+	if !p.Meta.Started.IsZero() {
+		report.Time = strconv.FormatFloat(meta.DurationMs/1000, 'f', 3, 64)
+	}
+	return report
+}
+
+// metaProperties flattens the metadata of a run, its config as JSON
+func metaProperties(meta RunMeta) []junitProperty {
+	config, _ := json.Marshal(meta.Config)
+	props := []junitProperty{
+		{"schema_version", meta.SchemaVersion},
+		{"tool.version", meta.Tool.Version},
+		{"generated_at", meta.GeneratedAt.Format(time.RFC3339)},
+	}
+	if meta.Commit != "" {
+		props = append(props, junitProperty{"commit", meta.Commit})
+	}
+	return append(props,
+		junitProperty{"duration_ms", strconv.FormatFloat(meta.DurationMs, 'f', -1, 64)},
+		junitProperty{"config", string(config)})
+}
+
+// junitProperties flattens a summary, usages by call type under "usages."
+func junitProperties(summary finder.Summary) []junitProperty {
+	props := []junitProperty{
+		{"total_methods", strconv.Itoa(summary.Methods)},
+		{"unused", strconv.Itoa(summary.Unused)},
+		{"low", strconv.Itoa(summary.Low)},
+		{"medium", strconv.Itoa(summary.Medium)},
+		{"high", strconv.Itoa(summary.High)},
+	}
+	for _, ct := range finder.GetCallTypeOrder() {
+		if count, ok := summary.UsagesByType[ct]; ok {
+			props = append(props, junitProperty{"usages." + string(ct), strconv.Itoa(count)})
+		}
+	}
+	return props
+}
+
+func writeJUnit(w io.Writer, report junitSuites) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(report); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+//================================================================================
+// TAP
+//================================================================================
+
+// TAPPrinter writes a TAP version 13 stream for harnesses like prove: every
+// method is a test point and unused methods fail, with YAML diagnostics.
+// Methods whose search failed are skipped.
+type TAPPrinter struct {
+	Meta    Meta            // Written as comments before the plan
+	Summary *finder.Summary // Written as comments after the test points when set
+}
+
+func (p TAPPrinter) Print(w io.Writer, results []finder.MethodUsage) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, "TAP version 13")
+	writeTAPComments(bw, metaProperties(p.Meta.Block()))
+	fmt.Fprintf(bw, "1..%d\n", len(results))
+	for i, r := range results {
+		name := qualifiedName(r.Method)
+		if module := finder.ModuleName(r.Method); module != "" {
+			name = module + "." + name
+		}
+		switch {
+		case r.SearchError != "":
+			fmt.Fprintf(bw, "ok %d - %s # SKIP search failed: %s\n", i+1, name, tapEscape(r.SearchError))
+		case !finder.IsImplicitlyUsed(r.Method) && finder.RealUsages(r) == 0:
+			fmt.Fprintf(bw, "not ok %d - %s\n", i+1, name)
+			fmt.Fprintf(bw, "  ---\n  message: %s\n  severity: fail\n  at:\n    file: %s\n    line: %d\n  usages: %d\n  ...\n",
+				strconv.Quote(fmt.Sprintf("%s %s is never used", kindLabel(r.Method), qualifiedName(r.Method))),
+				strconv.Quote(r.Method.Filename), r.Method.LineNo, r.TotalUsages)
+		default:
+			fmt.Fprintf(bw, "ok %d - %s\n", i+1, name)
+		}
+	}
+	if p.Summary != nil {
+		writeTAPComments(bw, junitProperties(*p.Summary))
+	}
+	return bw.Flush()
+}
+
+// PrintSummary writes an empty plan followed by the summary as comments
+func (p TAPPrinter) PrintSummary(w io.Writer, summary finder.Summary) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, "TAP version 13")
+	writeTAPComments(bw, metaProperties(p.Meta.Block()))
+	fmt.Fprintln(bw, "1..0 # SKIP summary only")
+	writeTAPComments(bw, junitProperties(summary))
+	return bw.Flush()
+}
+
+// writeTAPComments writes a "# name: value" comment per property
+func writeTAPComments(w io.Writer, props []junitProperty) {
+	for _, prop := range props {
+		fmt.Fprintf(w, "# %s: %s\n", prop.Name, prop.Value)
+	}
+}
+
+// tapEscape escapes the characters ending or splitting a test point
+// directive
+func tapEscape(s string) string {
+	return strings.NewReplacer("\\", "\\\\", "#", "\\#", "\n", " ").Replace(s)
+}
+
+//================================================================================
+// SARIF
+//================================================================================
+
+// SARIFPrinter writes a SARIF 2.1.0 log, the format read by GitHub code
+// scanning and IDE viewers. Unused and rarely used definitions, and duplicate
+// bodies, are its results; well used definitions are left out.
+type SARIFPrinter struct {
+	Meta Meta // Written as the invocation of the run
+	// Added to the properties of the run when set
+	Stats   *finder.RunStats
+	Summary *finder.Summary
+	// The low bucket is the most real usages of low-usage results,
+	// finder.DefaultBuckets when nil
+	Buckets *finder.UsageBuckets
+}
+
+// Rules of the SARIF results
+const (
+	ruleUnused    = "unused"
+	ruleLowUsage  = "low-usage"
+	ruleDuplicate = "duplicate"
+	ruleSimilar   = "similar"
+)
+
+// lowUsages is the most real usages a definition reported as low-usage has,
+// the "low" bucket of the summary
+func lowUsages(buckets *finder.UsageBuckets) int {
+	if buckets == nil {
+		return finder.DefaultBuckets.Low
+	}
+	return buckets.Low
+}
+
+var sarifRules = []sarifRule{
+	{ID: ruleUnused, Name: "UnusedDefinition", Level: "warning",
+		Description: "Definition without any real usage"},
+	{ID: ruleLowUsage, Name: "LowUsageDefinition", Level: "note",
+		Description: "Definition with at most %d real usages"}, // Formatted with lowUsages
+	{ID: ruleDuplicate, Name: "DuplicateBody", Level: "warning",
+		Description: "Function with the same normalized body as another"},
+	{ID: ruleSimilar, Name: "SimilarBody", Level: "note",
+		Description: "Function with a highly similar body to another"},
+}
+
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool        sarifTool         `json:"tool"`
+	Invocations []sarifInvocation `json:"invocations"`
+	Results     []sarifResult     `json:"results"`
+	Properties  *sarifRunProps    `json:"properties,omitempty"`
+}
+
+type sarifRunProps struct {
+	Commit  string           `json:"commit,omitempty"`
+	Stats   *finder.RunStats `json:"stats,omitempty"`
+	Summary *finder.Summary  `json:"summary,omitempty"`
+}
+
+type sarifInvocation struct {
+	ExecutionSuccessful bool      `json:"executionSuccessful"`
+	EndTimeUTC          string    `json:"endTimeUtc"`
+	Properties          sarifMeta `json:"properties"`
+}
+
+// sarifMeta holds the metadata of the run not covered by SARIF itself
+type sarifMeta struct {
+	Config     map[string]any `json:"config"`
+	DurationMs float64        `json:"duration_ms"`
+}
+
+type sarifTool struct {
+	Driver struct {
+		Name           string          `json:"name"`
+		Version        string          `json:"version"`
+		InformationURI string          `json:"informationUri"`
+		Rules          []sarifRuleDesc `json:"rules"`
+	} `json:"driver"`
+}
+
+type sarifRule struct {
+	ID, Name, Level, Description string
+}
+
+type sarifRuleDesc struct {
+	ID                   string       `json:"id"`
+	Name                 string       `json:"name"`
+	ShortDescription     sarifMessage `json:"shortDescription"`
+	DefaultConfiguration struct {
+		Level string `json:"level"`
+	} `json:"defaultConfiguration"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID           string          `json:"ruleId"`
+	RuleIndex        int             `json:"ruleIndex"`
+	Level            string          `json:"level"`
+	Message          sarifMessage    `json:"message"`
+	Locations        []sarifLocation `json:"locations"`
+	RelatedLocations []sarifLocation `json:"relatedLocations,omitempty"`
+}
+
+type sarifLocation struct {
+	ID               int `json:"id,omitempty"`
+	PhysicalLocation struct {
+		ArtifactLocation struct {
+			URI string `json:"uri"`
+		} `json:"artifactLocation"`
+		Region sarifRegion `json:"region"`
+	} `json:"physicalLocation"`
+	Message *sarifMessage `json:"message,omitempty"`
+}
+
+type sarifRegion struct {
+	StartLine   int `json:"startLine"`
+	StartColumn int `json:"startColumn,omitempty"`
+	EndLine     int `json:"endLine,omitempty"`
+}
+
+func (p SARIFPrinter) Print(w io.Writer, results []finder.MethodUsage) error {
+	return p.print(w, results, p.Summary)
+}
+
+// PrintSummary writes a log without results, holding the summary in the
+// properties of the run
+func (p SARIFPrinter) PrintSummary(w io.Writer, summary finder.Summary) error {
+	return p.print(w, nil, &summary)
+}
+
+func (p SARIFPrinter) print(w io.Writer, results []finder.MethodUsage, summary *finder.Summary) error {
+	meta := p.Meta.Block()
+	run := sarifRun{Results: []sarifResult{}}
+	run.Tool.Driver.Name = "pybr"
+	run.Tool.Driver.Version = meta.Tool.Version
+	run.Tool.Driver.InformationURI = "https://github.com/sanchezhs/py-broom"
+	low := lowUsages(p.Buckets)
+	for _, rule := range sarifRules {
+		description := rule.Description
+		if rule.ID == ruleLowUsage {
+			description = fmt.Sprintf(description, low)
+		}
+		desc := sarifRuleDesc{ID: rule.ID, Name: rule.Name, ShortDescription: sarifMessage{description}}
+		desc.DefaultConfiguration.Level = rule.Level
+		run.Tool.Driver.Rules = append(run.Tool.Driver.Rules, desc)
+	}
+	invocation := sarifInvocation{
+		ExecutionSuccessful: true,
+		EndTimeUTC:          meta.GeneratedAt.Format(time.RFC3339),
+		Properties:          sarifMeta{Config: meta.Config, DurationMs: meta.DurationMs},
+	}
+	run.Invocations = []sarifInvocation{invocation}
+	if meta.Commit != "" || p.Stats != nil || summary != nil {
+		run.Properties = &sarifRunProps{Commit: meta.Commit, Stats: p.Stats, Summary: summary}
+	}
+	for _, r := range results {
+		run.Results = append(run.Results, sarifResults(r, low)...)
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(sarifLog{
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Version: "2.1.0",
+		Runs:    []sarifRun{run},
+	})
+}
+
+// finding is a problem with a definition reported by the code scanning
+// formats
+type finding struct {
+	rule    string
+	message string
+	related []finder.Usage // The other copies of a duplicate body
+}
+
+// findingsOf returns the problems of a result, none for a definition in use
+// or whose search failed. Definitions with at most low real usages are
+// reported as low-usage.
+func findingsOf(r finder.MethodUsage, low int) []finding {
+	name := qualifiedName(r.Method)
+
+	// Results of duplicates hold the other copies as usages
+	var duplicates, similar []finder.Usage
+	for _, u := range r.Usages {
+		switch u.CallType {
+		case finder.CallTypeDuplicate:
+			duplicates = append(duplicates, u)
+		case finder.CallTypeSimilar:
+			similar = append(similar, u)
+		}
+	}
+	if len(duplicates)+len(similar) > 0 {
+		var found []finding
+		for _, group := range []struct {
+			rule, what string
+			usages     []finder.Usage
+		}{{ruleDuplicate, "the same body as", duplicates}, {ruleSimilar, "a body similar to", similar}} {
+			if len(group.usages) > 0 {
+				found = append(found, finding{
+					rule:    group.rule,
+					message: fmt.Sprintf("%s %s has %s %d other function(s)", kindLabel(r.Method), name, group.what, len(group.usages)),
+					related: group.usages,
+				})
+			}
+		}
+		return found
+	}
+
+	if r.SearchError != "" || finder.IsImplicitlyUsed(r.Method) {
+		return nil
+	}
+	switch real := finder.RealUsages(r); {
+	case real == 0:
+		return []finding{{rule: ruleUnused, message: fmt.Sprintf("%s %s is never used", kindLabel(r.Method), name)}}
+	case real <= low && !r.Capped:
+		return []finding{{rule: ruleLowUsage, message: fmt.Sprintf("%s %s is used only %d time(s)", kindLabel(r.Method), name, real)}}
+	}
+	return nil
+}
+
+// sarifResults converts the findings of a result
+func sarifResults(r finder.MethodUsage, low int) []sarifResult {
+	var results []sarifResult
+	for _, f := range findingsOf(r, low) {
+		index := slices.IndexFunc(sarifRules, func(sr sarifRule) bool { return sr.ID == f.rule })
+		loc := sarifLocationOf(r.Method.Filename, r.Method.LineNo, 0)
+		if r.Method.EndLine > r.Method.LineNo {
+			loc.PhysicalLocation.Region.EndLine = r.Method.EndLine
+		}
+		res := sarifResult{
+			RuleID:    f.rule,
+			RuleIndex: index,
+			Level:     sarifRules[index].Level,
+			Message:   sarifMessage{f.message},
+			Locations: []sarifLocation{loc},
+		}
+		for i, u := range f.related {
+			file, line, col := splitLocation(u.Location)
+			related := sarifLocationOf(file, line, col)
+			related.ID = i + 1
+			related.Message = &sarifMessage{strings.TrimSpace(u.Context)}
+			res.RelatedLocations = append(res.RelatedLocations, related)
+		}
+		results = append(results, res)
+	}
+	return results
+}
+
+func sarifLocationOf(file string, line, col int) sarifLocation {
+	var loc sarifLocation
+	uri := filepath.ToSlash(filepath.Clean(file))
+	if filepath.IsAbs(file) {
+		uri = (&url.URL{Scheme: "file", Path: uri}).String()
+	}
+	loc.PhysicalLocation.ArtifactLocation.URI = uri
+	loc.PhysicalLocation.Region = sarifRegion{StartLine: max(line, 1), StartColumn: col}
+	return loc
+}
+
+// splitLocation splits a "path:line:col" location
+func splitLocation(location string) (file string, line, col int) {
+	parts := strings.Split(location, ":")
+	if len(parts) < 3 {
+		return location, 0, 0
+	}
+	line, _ = strconv.Atoi(parts[len(parts)-2])
+	col, _ = strconv.Atoi(parts[len(parts)-1])
+	return strings.Join(parts[:len(parts)-2], ":"), line, col
+}
+
+// qualifiedName prefixes the name of a definition with its class
+func qualifiedName(m finder.Method) string {
+	if m.Class != "" {
+		return m.Class + "." + m.Name
+	}
+	return m.Name
+}
+
+// kindLabel names the kind of a definition at the start of a sentence
+func kindLabel(m finder.Method) string {
+	switch m.Kind {
+	case finder.SymbolVariable:
+		return "Variable"
+	case finder.SymbolAttribute:
+		return "Attribute"
+	}
+	if m.Class != "" {
+		return "Method"
+	}
+	return "Function"
+}
+
+//================================================================================
+// GitLab Code Quality
+//================================================================================
+
+// CodeQualityPrinter writes a GitLab Code Quality report, the Code Climate
+// JSON array shown by the merge request widget. Its findings are those of
+// SARIF. Fingerprints leave out line numbers, so moving a definition does not
+// make it a new finding.
+type CodeQualityPrinter struct {
+	Buckets *finder.UsageBuckets // Sets the most real usages of low-usage issues, see SARIFPrinter
+}
+
+// codeQualitySeverities map the rules to Code Quality severities
+var codeQualitySeverities = map[string]string{
+	ruleUnused:    "minor",
+	ruleLowUsage:  "info",
+	ruleDuplicate: "minor",
+	ruleSimilar:   "info",
+}
+
+type codeQualityIssue struct {
+	Description string `json:"description"`
+	CheckName   string `json:"check_name"`
+	Fingerprint string `json:"fingerprint"`
+	Severity    string `json:"severity"`
+	Location    struct {
+		Path  string `json:"path"`
+		Lines struct {
+			Begin int `json:"begin"`
+			End   int `json:"end,omitempty"`
+		} `json:"lines"`
+	} `json:"location"`
+}
+
+func (p CodeQualityPrinter) Print(w io.Writer, results []finder.MethodUsage) error {
+	issues := []codeQualityIssue{}
+	low := lowUsages(p.Buckets)
+	for _, r := range results {
+		path := filepath.ToSlash(filepath.Clean(r.Method.Filename))
+		for _, f := range findingsOf(r, low) {
+			issue := codeQualityIssue{
+				Description: f.message,
+				CheckName:   f.rule,
+				Severity:    codeQualitySeverities[f.rule],
+			}
+			sum := sha256.Sum256([]byte(strings.Join([]string{f.rule, path, string(r.Method.Kind), qualifiedName(r.Method)}, "\x00")))
+			issue.Fingerprint = hex.EncodeToString(sum[:16])
+			issue.Location.Path = path
+			issue.Location.Lines.Begin = r.Method.LineNo
+			if r.Method.EndLine > r.Method.LineNo {
+				issue.Location.Lines.End = r.Method.EndLine
+			}
+			issues = append(issues, issue)
+		}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(issues)
+}
+
+//================================================================================
+// Markdown
+//================================================================================
+
+// MarkdownPrinter writes GitHub flavored Markdown tables, for pull request
+// comments and CI job summaries
+type MarkdownPrinter struct{}
+
+// mdCode writes text as inline code in a table cell
+func mdCode(text string) string {
+	return "`" + strings.ReplaceAll(text, "|", `\|`) + "`"
+}
+
+// mdTable writes a table with a header row, right aligning the columns of right
+func mdTable(w io.Writer, header []string, right map[int]bool, rows [][]string) {
+	fmt.Fprintf(w, "| %s |\n|", strings.Join(header, " | "))
+	for i := range header {
+		if right[i] {
+			fmt.Fprint(w, "---:|")
+		} else {
+			fmt.Fprint(w, "---|")
+		}
+	}
+	fmt.Fprintln(w)
+	for _, row := range rows {
+		fmt.Fprintf(w, "| %s |\n", strings.Join(row, " | "))
+	}
+}
+
+func (MarkdownPrinter) Print(w io.Writer, results []finder.MethodUsage) error {
+	var rows [][]string
+	for _, r := range results {
+		total := strconv.Itoa(r.TotalUsages)
+		if r.Capped {
+			total += "+"
+		}
+		usages := strconv.Itoa(finder.RealUsages(r))
+		if finder.IsUnused(r) {
+			usages = "**unused**"
+		}
+		rows = append(rows, []string{mdCode(qualifiedName(r.Method)), mdCode(fmt.Sprintf("%s:%d", r.Method.Filename, r.Method.LineNo)), total, usages})
+	}
+	mdTable(w, []string{"Definition", "Location", "Total", "Real usages"}, map[int]bool{2: true, 3: true}, rows)
+	return nil
+}
+
+// PrintDiff writes a table of the totals of both runs, then one per kind of change
+func (MarkdownPrinter) PrintDiff(w io.Writer, d finder.ResultDiff) error {
+	change := func(before, after int) []string {
+		return []string{strconv.Itoa(before), strconv.Itoa(after), fmt.Sprintf("%+d", after-before)}
+	}
+	mdTable(w, []string{"", "Before", "After", "Change"}, map[int]bool{1: true, 2: true, 3: true}, [][]string{
+		append([]string{"Definitions"}, change(d.MethodsBefore, d.MethodsAfter)...),
+		append([]string{"Unused"}, change(d.UnusedBefore, d.UnusedAfter)...),
+	})
+	location := func(m finder.Method) string { return mdCode(fmt.Sprintf("%s:%d", m.Filename, m.LineNo)) }
+	section := func(title string, n int) { fmt.Fprintf(w, "\n#### %s (%d)\n\n", title, n) }
+
+	if len(d.NewlyUnused) > 0 {
+		section("Newly unused", len(d.NewlyUnused))
+		var rows [][]string
+		for _, c := range d.NewlyUnused {
+			rows = append(rows, []string{mdCode(qualifiedName(c.Method)), location(c.Method), strconv.Itoa(c.Before)})
+		}
+		mdTable(w, []string{"Definition", "Location", "Usages before"}, map[int]bool{2: true}, rows)
+	}
+	if len(d.Added) > 0 {
+		section("Added", len(d.Added))
+		var rows [][]string
+		for _, r := range d.Added {
+			usages := strconv.Itoa(finder.RealUsages(r))
+			if finder.IsUnused(r) {
+				usages = "**unused**"
+			}
+			rows = append(rows, []string{mdCode(qualifiedName(r.Method)), location(r.Method), usages})
+		}
+		mdTable(w, []string{"Definition", "Location", "Usages"}, map[int]bool{2: true}, rows)
+	}
+	if len(d.Removed) > 0 {
+		section("Removed", len(d.Removed))
+		var rows [][]string
+		for _, r := range d.Removed {
+			rows = append(rows, []string{mdCode(qualifiedName(r.Method)), location(r.Method)})
+		}
+		mdTable(w, []string{"Definition", "Location"}, nil, rows)
+	}
+	if len(d.Changed) > 0 {
+		section("Usage changes", len(d.Changed))
+		var rows [][]string
+		for _, c := range d.Changed {
+			rows = append(rows, append([]string{mdCode(qualifiedName(c.Method)), location(c.Method)}, change(c.Before, c.After)...))
+		}
+		mdTable(w, []string{"Definition", "Location", "Before", "After", "Change"}, map[int]bool{2: true, 3: true, 4: true}, rows)
+	}
+	return nil
+}
+
+// PrintHistory writes a table of the recorded runs, then the trends of the
+// definitions and unused ones
+func (MarkdownPrinter) PrintHistory(w io.Writer, entries []finder.HistoryEntry) error {
+	var rows [][]string
+	methods, unused := make([]int, len(entries)), make([]int, len(entries))
+	for i, e := range entries {
+		methods[i], unused[i] = e.Methods, e.Unused
+		run := historyRun(e)
+		if run != "" {
+			run = mdCode(run)
+		}
+		rows = append(rows, []string{e.RecordedAt.UTC().Format("2006-01-02 15:04"), run, strconv.Itoa(e.Methods), strconv.Itoa(e.Unused)})
+	}
+	mdTable(w, []string{"Recorded (UTC)", "Run", "Methods", "Unused"}, map[int]bool{2: true, 3: true}, rows)
+	if len(entries) > 1 {
+		first, last := entries[0], entries[len(entries)-1]
+		fmt.Fprintf(w, "\nMethods %s %+d, unused %s %+d over %d runs.\n",
+			sparkline(methods), last.Methods-first.Methods, sparkline(unused), last.Unused-first.Unused, len(entries))
+	}
 	return nil
 }
 
@@ -345,22 +2658,60 @@ func (GraphvizPrinter) Print(w io.Writer, results []finder.MethodUsage) error {
 type Kind string
 
 const (
-	KindConsole  Kind = "console"
-	KindJSON     Kind = "json"
-	KindVimGrep  Kind = "vimgrep"
-	KindGraphviz Kind = "graphviz"
+	KindConsole     Kind = "console"
+	KindJSON        Kind = "json"
+	KindJSONL       Kind = "jsonl"
+	KindVimGrep     Kind = "vimgrep"
+	KindGraphviz    Kind = "graphviz"
+	KindMermaid     Kind = "mermaid"
+	KindSARIF       Kind = "sarif"
+	KindCSV         Kind = "csv"
+	KindJUnit       Kind = "junit"
+	KindCodeQuality Kind = "codequality"
+	KindGraphJSON   Kind = "graph-json"
+	KindGrep        Kind = "grep"
+	KindTable       Kind = "table"
+	KindTreemap     Kind = "treemap"
+	KindCtags       Kind = "ctags"
+	KindProto       Kind = "pb"
+	KindTAP         Kind = "tap"
+	KindMarkdown    Kind = "markdown"
 )
 
 var OutputKinds = map[string]Kind{
-	"console":  KindConsole,
-	"json":     KindJSON,
-	"vimgrep":  KindVimGrep,
-	"graphviz": KindGraphviz,
+	"console":     KindConsole,
+	"json":        KindJSON,
+	"jsonl":       KindJSONL,
+	"vimgrep":     KindVimGrep,
+	"graphviz":    KindGraphviz,
+	"mermaid":     KindMermaid,
+	"sarif":       KindSARIF,
+	"csv":         KindCSV,
+	"junit":       KindJUnit,
+	"codequality": KindCodeQuality,
+	"graph-json":  KindGraphJSON,
+	"grep":        KindGrep,
+	"table":       KindTable,
+	"treemap":     KindTreemap,
+	"ctags":       KindCtags,
+	"pb":          KindProto,
+	"tap":         KindTAP,
+	"markdown":    KindMarkdown,
 }
 
 type Options struct {
-	NoColor bool
-	Indent  bool
+	NoColor   bool
+	Indent    bool
+	Compact   bool                 // One line per method in the console format
+	Highlight bool                 // Syntax highlight the source lines of the console format
+	Buckets   *finder.UsageBuckets // Usage count buckets coloring console and table and setting the low-usage findings, see finder.UsageBuckets
+	Stats     *finder.RunStats     // Printed by the formats that carry them
+	Summary   *finder.Summary      // Printed after or along with the results by SummaryPrinter formats
+	CSVMode   string               // One of CSVModes
+	CSVMeta   bool                 // Comment lines describing the run before the csv header
+	Graph     GraphOptions         // Style of the graphviz format
+	Links     string               // URL template of the hyperlinked locations of console and table, none when empty
+	Meta      Meta                 // Run described by the structured formats
 }
 
 func GetKinds() string {
@@ -374,14 +2725,42 @@ func GetKinds() string {
 func New(kind Kind, opts Options) Printer {
 	switch kind {
 	case KindJSON:
-		return JSONPrinter{Indent: opts.Indent}
+		return JSONPrinter{Indent: opts.Indent, Meta: opts.Meta, Stats: opts.Stats, Summary: opts.Summary}
+	case KindJSONL:
+		return JSONLPrinter{Meta: opts.Meta, Summary: opts.Summary}
 	case KindVimGrep:
 		return VimPrinter{}
 	case KindGraphviz:
-		return GraphvizPrinter{}
+		return GraphvizPrinter{opts: opts}
+	case KindMermaid:
+		return MermaidPrinter{}
+	case KindSARIF:
+		return SARIFPrinter{Meta: opts.Meta, Stats: opts.Stats, Summary: opts.Summary, Buckets: opts.Buckets}
+	case KindCSV:
+		return CSVPrinter{Mode: opts.CSVMode, Meta: opts.Meta, WithMeta: opts.CSVMeta}
+	case KindJUnit:
+		return JUnitPrinter{Meta: opts.Meta, Summary: opts.Summary}
+	case KindCodeQuality:
+		return CodeQualityPrinter{Buckets: opts.Buckets}
+	case KindGraphJSON:
+		return GraphJSONPrinter{Meta: opts.Meta}
+	case KindGrep:
+		return GrepPrinter{}
+	case KindTAP:
+		return TAPPrinter{Meta: opts.Meta, Summary: opts.Summary}
+	case KindProto:
+		return ProtoPrinter{Meta: opts.Meta, Summary: opts.Summary}
+	case KindCtags:
+		return CtagsPrinter{}
+	case KindMarkdown:
+		return MarkdownPrinter{}
+	case KindTreemap:
+		return TreemapPrinter{}
+	case KindTable:
+		return TablePrinter{NoColor: opts.NoColor, Buckets: opts.Buckets, Summary: opts.Summary, Links: opts.Links}
 	case KindConsole:
 		fallthrough
 	default:
-		return ConsolePrinter{NoColor: opts.NoColor}
+		return ConsolePrinter{NoColor: opts.NoColor, Compact: opts.Compact, Highlight: opts.Highlight, Buckets: opts.Buckets, Summary: opts.Summary, Links: opts.Links}
 	}
 }
@@ -0,0 +1,716 @@
+package printers
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"maps"
+	"math"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sanchezhs/py-broom/finder"
+)
+
+// testMeta is the metadata of the reports written by the tests
+var testMeta = Meta{
+	Version:     "1.2.3",
+	GeneratedAt: time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+	Config:      map[string]any{"format": "test"},
+	Commit:      "0123456789abcdef0123456789abcdef01234567",
+}
+
+// testResult builds the result of m with a usage of each call type, on the
+// lines following its definition in app/main.py
+func testResult(m finder.Method, types ...finder.CallType) finder.MethodUsage {
+	if m.Kind == "" {
+		m.Kind = finder.SymbolFunction
+	}
+	r := finder.MethodUsage{Method: m, UsagesByType: make(map[finder.CallType]int)}
+	for i, ct := range types {
+		u := finder.Usage{CallType: ct, Location: fmt.Sprintf("app/main.py:%d:5", i+1), Context: fmt.Sprintf("    %s()", m.Name)}
+		if ct == finder.CallTypeDefinition {
+			u.Location = fmt.Sprintf("%s:%d:5", m.Filename, m.LineNo)
+			u.Context = fmt.Sprintf("def %s():", m.Name)
+		}
+		r.Usages = append(r.Usages, u)
+		r.UsagesByType[ct]++
+	}
+	r.TotalUsages = len(r.Usages)
+	return r
+}
+
+// testResults are an unused function, a method used once, a function in
+// use, a dunder method and a failed search, in that order
+func testResults() []finder.MethodUsage {
+	return []finder.MethodUsage{
+		testResult(finder.Method{Name: "load", Filename: "app/io.py", LineNo: 3, EndLine: 5},
+			finder.CallTypeDefinition),
+		testResult(finder.Method{Name: "handle", Class: "Svc", Filename: "app/svc.py", LineNo: 10, EndLine: 12},
+			finder.CallTypeDefinition, finder.CallTypeInstance),
+		testResult(finder.Method{Name: "parse", Filename: "app/io.py", LineNo: 8, EndLine: 9},
+			finder.CallTypeDefinition, finder.CallTypeFunction, finder.CallTypeFunction, finder.CallTypeFunction),
+		testResult(finder.Method{Name: "__repr__", Class: "Svc", Filename: "app/svc.py", LineNo: 14, EndLine: 15},
+			finder.CallTypeDefinition),
+		{
+			Method:      finder.Method{Name: "broken", Kind: finder.SymbolFunction, Filename: "app/io.py", LineNo: 20, EndLine: 21},
+			SearchError: "rg: exit status 2",
+		},
+	}
+}
+
+func TestSARIFPrinter(t *testing.T) {
+	results := testResults()
+	// A duplicate body, reported with the other copy as related location
+	dup := testResult(finder.Method{Name: "save", Filename: filepath.FromSlash("app/store.py"), LineNo: 4, EndLine: 6})
+	dup.Usages = []finder.Usage{{CallType: finder.CallTypeDuplicate, Location: "app/io.py:30:5", Context: "  def save_all():  "}}
+	results = append(results, dup)
+
+	var buf bytes.Buffer
+	if err := (SARIFPrinter{Meta: testMeta}).Print(&buf, results); err != nil {
+		t.Fatal(err)
+	}
+	var log sarifLog
+	if err := json.Unmarshal(buf.Bytes(), &log); err != nil {
+		t.Fatalf("invalid JSON: %v\n%s", err, buf.String())
+	}
+
+	if log.Version != "2.1.0" || log.Schema != "https://json.schemastore.org/sarif-2.1.0.json" || len(log.Runs) != 1 {
+		t.Fatalf("log = version %q, schema %q, %d runs, want a single SARIF 2.1.0 run", log.Version, log.Schema, len(log.Runs))
+	}
+	run := log.Runs[0]
+	if run.Tool.Driver.Name != "pybr" || run.Tool.Driver.Version != "1.2.3" {
+		t.Errorf("driver = %s %s, want pybr 1.2.3", run.Tool.Driver.Name, run.Tool.Driver.Version)
+	}
+	var ruleIDs []string
+	for _, rule := range run.Tool.Driver.Rules {
+		ruleIDs = append(ruleIDs, rule.ID)
+	}
+	if want := []string{"unused", "low-usage", "duplicate", "similar"}; !reflect.DeepEqual(ruleIDs, want) {
+		t.Errorf("rules = %v, want %v", ruleIDs, want)
+	}
+	if len(run.Invocations) != 1 || !run.Invocations[0].ExecutionSuccessful || run.Invocations[0].EndTimeUTC != "2024-05-01T12:00:00Z" {
+		t.Errorf("invocations = %+v, want one successful run ending 2024-05-01T12:00:00Z", run.Invocations)
+	}
+	if run.Properties == nil || run.Properties.Commit != testMeta.Commit {
+		t.Errorf("run properties = %+v, want commit %s", run.Properties, testMeta.Commit)
+	}
+
+	type want struct {
+		rule, level, message, uri string
+		start, end, related       int
+	}
+	wants := []want{
+		{"unused", "warning", "Function load is never used", "app/io.py", 3, 5, 0},
+		{"low-usage", "note", "Method Svc.handle is used only 1 time(s)", "app/svc.py", 10, 12, 0},
+		{"duplicate", "warning", "Function save has the same body as 1 other function(s)", "app/store.py", 4, 6, 1},
+	}
+	if len(run.Results) != len(wants) {
+		t.Fatalf("got %d results, want %d:\n%s", len(run.Results), len(wants), buf.String())
+	}
+	for i, w := range wants {
+		r := run.Results[i]
+		loc := r.Locations[0].PhysicalLocation
+		got := want{r.RuleID, r.Level, r.Message.Text, loc.ArtifactLocation.URI, loc.Region.StartLine, loc.Region.EndLine, len(r.RelatedLocations)}
+		if got != w {
+			t.Errorf("result %d = %+v, want %+v", i, got, w)
+		}
+		if rule := run.Tool.Driver.Rules[r.RuleIndex]; rule.ID != r.RuleID {
+			t.Errorf("result %d: ruleIndex %d points to %s, want %s", i, r.RuleIndex, rule.ID, r.RuleID)
+		}
+	}
+	related := run.Results[2].RelatedLocations[0]
+	if related.ID != 1 || related.Message.Text != "def save_all():" ||
+		related.PhysicalLocation.ArtifactLocation.URI != "app/io.py" || related.PhysicalLocation.Region != (sarifRegion{StartLine: 30, StartColumn: 5}) {
+		t.Errorf("related location = %+v", related)
+	}
+
+	// Absolute paths become file URIs
+	abs, err := filepath.Abs(filepath.FromSlash("app/store.py"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if uri := sarifLocationOf(abs, 1, 0).PhysicalLocation.ArtifactLocation.URI; !strings.HasPrefix(uri, "file:") || !strings.HasSuffix(uri, "/app/store.py") {
+		t.Errorf("URI of %s = %q, want a file URI", abs, uri)
+	}
+
+	// A run without findings still has an empty results array
+	buf.Reset()
+	if err := (SARIFPrinter{Meta: testMeta}).Print(&buf, nil); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(buf.Bytes(), []byte(`"results": []`)) {
+		t.Errorf("empty log has no results array:\n%s", buf.String())
+	}
+}
+
+func TestCSVPrinter(t *testing.T) {
+	results := testResults()[:2]
+	results[0].Method.Metrics = &finder.Metrics{Complexity: 3}
+	// Quotes and commas are quoted, line breaks and runs of spaces collapsed
+	results[1].Usages[1].Context = "\tsvc.handle(\"a, b\",\n    c)"
+
+	tests := []struct {
+		name    string
+		printer CSVPrinter
+		want    string
+	}{
+		{
+			name:    "usages",
+			printer: CSVPrinter{},
+			want: `method,defined_file,defined_line,usage_file,usage_line,call_type,context
+load,app/io.py,3,app/io.py,3,definition,def load():
+Svc.handle,app/svc.py,10,app/svc.py,10,definition,def handle():
+Svc.handle,app/svc.py,10,app/main.py,2,instance,"svc.handle(""a, b"", c)"
+`,
+		},
+		{
+			name:    "summary",
+			printer: CSVPrinter{Mode: CSVSummary},
+			want: `method,kind,defined_file,defined_line,total_usages,real_usages,complexity
+load,function,app/io.py,3,1,0,3
+Svc.handle,function,app/svc.py,10,2,1,
+`,
+		},
+		{
+			name:    "meta",
+			printer: CSVPrinter{Mode: CSVSummary, Meta: testMeta, WithMeta: true},
+			want: `# schema_version: ` + SchemaVersion + `
+# tool.version: 1.2.3
+# generated_at: 2024-05-01T12:00:00Z
+# commit: 0123456789abcdef0123456789abcdef01234567
+# duration_ms: 0
+# config: {"format":"test"}
+method,kind,defined_file,defined_line,total_usages,real_usages,complexity
+load,function,app/io.py,3,1,0,3
+Svc.handle,function,app/svc.py,10,2,1,
+`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := tt.printer.Print(&buf, results); err != nil {
+				t.Fatal(err)
+			}
+			if got := buf.String(); got != tt.want {
+				t.Errorf("Print() =\n%s\nwant\n%s", got, tt.want)
+			}
+			// Readable back, comments included, with a column per header
+			r := csv.NewReader(&buf)
+			r.Comment = '#'
+			records, err := r.ReadAll()
+			if err != nil {
+				t.Fatalf("reading back: %v", err)
+			}
+			if len(records) != strings.Count(tt.want, "\n")-strings.Count(tt.want, "# ") {
+				t.Errorf("read %d records back", len(records))
+			}
+		})
+	}
+
+	// A definition without usages still has a row
+	var buf bytes.Buffer
+	bare := finder.MethodUsage{Method: finder.Method{Name: "orphan", Filename: "app/io.py", LineNo: 40}}
+	if err := (CSVPrinter{}).Print(&buf, []finder.MethodUsage{bare}); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := buf.String(), "method,defined_file,defined_line,usage_file,usage_line,call_type,context\norphan,app/io.py,40,,,,\n"; got != want {
+		t.Errorf("Print(orphan) = %q, want %q", got, want)
+	}
+}
+
+func TestJUnitPrinter(t *testing.T) {
+	var buf bytes.Buffer
+	if err := (JUnitPrinter{Meta: testMeta}).Print(&buf, testResults()); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(buf.String(), xml.Header) {
+		t.Errorf("report does not start with the XML header:\n%s", buf.String())
+	}
+	var report junitSuites
+	if err := xml.Unmarshal(buf.Bytes(), &report); err != nil {
+		t.Fatalf("invalid XML: %v\n%s", err, buf.String())
+	}
+
+	if report.Name != "pybr" || report.Tests != 5 || report.Failures != 1 || report.Errors != 1 || report.Timestamp != "2024-05-01T12:00:00Z" {
+		t.Errorf("testsuites = %s, %d tests, %d failures, %d errors at %s, want pybr, 5, 1, 1 at 2024-05-01T12:00:00Z",
+			report.Name, report.Tests, report.Failures, report.Errors, report.Timestamp)
+	}
+	props := make(map[string]string)
+	for _, p := range report.Properties {
+		props[p.Name] = p.Value
+	}
+	if props["tool.version"] != "1.2.3" || props["commit"] != testMeta.Commit || props["config"] != `{"format":"test"}` {
+		t.Errorf("properties = %v", props)
+	}
+
+	type suite struct {
+		name                    string
+		tests, failures, errors int
+		cases                   []string
+	}
+	var suites []suite
+	for _, s := range report.Suites {
+		var cases []string
+		for _, c := range s.Cases {
+			status := "pass"
+			switch {
+			case c.Failure != nil:
+				status = c.Failure.Type + ": " + c.Failure.Text
+			case c.Error != nil:
+				status = c.Error.Type + ": " + c.Error.Text
+			}
+			cases = append(cases, fmt.Sprintf("%s %s:%d %s", c.Name, c.File, c.Line, status))
+			if c.Classname != s.Name {
+				t.Errorf("case %s classname = %s, want its suite %s", c.Name, c.Classname, s.Name)
+			}
+		}
+		suites = append(suites, suite{s.Name, s.Tests, s.Failures, s.Errors, cases})
+	}
+	want := []suite{
+		{"app.io", 3, 1, 1, []string{
+			"load app/io.py:3 unused: Function load is never used (app/io.py:3)",
+			"parse app/io.py:8 pass",
+			"broken app/io.py:20 search-error: rg: exit status 2",
+		}},
+		{"app.svc", 2, 0, 0, []string{
+			"Svc.handle app/svc.py:10 pass",
+			"Svc.__repr__ app/svc.py:14 pass",
+		}},
+	}
+	if !reflect.DeepEqual(suites, want) {
+		t.Errorf("suites =\n%+v\nwant\n%+v", suites, want)
+	}
+}
+
+func TestCodeQualityPrinter(t *testing.T) {
+	results := testResults()
+	var buf bytes.Buffer
+	if err := (CodeQualityPrinter{}).Print(&buf, results); err != nil {
+		t.Fatal(err)
+	}
+	var issues []codeQualityIssue
+	if err := json.Unmarshal(buf.Bytes(), &issues); err != nil {
+		t.Fatalf("invalid JSON: %v\n%s", err, buf.String())
+	}
+
+	type want struct {
+		check, severity, description, path string
+		begin, end                         int
+	}
+	wants := []want{
+		{"unused", "minor", "Function load is never used", "app/io.py", 3, 5},
+		{"low-usage", "info", "Method Svc.handle is used only 1 time(s)", "app/svc.py", 10, 12},
+	}
+	if len(issues) != len(wants) {
+		t.Fatalf("got %d issues, want %d:\n%s", len(issues), len(wants), buf.String())
+	}
+	seen := make(map[string]bool)
+	for i, w := range wants {
+		issue := issues[i]
+		got := want{issue.CheckName, issue.Severity, issue.Description, issue.Location.Path, issue.Location.Lines.Begin, issue.Location.Lines.End}
+		if got != w {
+			t.Errorf("issue %d = %+v, want %+v", i, got, w)
+		}
+		if len(issue.Fingerprint) != 32 || seen[issue.Fingerprint] {
+			t.Errorf("issue %d fingerprint %q, want 32 unique hex digits", i, issue.Fingerprint)
+		}
+		seen[issue.Fingerprint] = true
+	}
+
+	// Moving a definition keeps its fingerprint
+	moved := testResults()
+	moved[0].Method.LineNo, moved[0].Method.EndLine = 30, 32
+	buf.Reset()
+	if err := (CodeQualityPrinter{}).Print(&buf, moved[:1]); err != nil {
+		t.Fatal(err)
+	}
+	var movedIssues []codeQualityIssue
+	if err := json.Unmarshal(buf.Bytes(), &movedIssues); err != nil {
+		t.Fatal(err)
+	}
+	if len(movedIssues) != 1 || movedIssues[0].Fingerprint != issues[0].Fingerprint || movedIssues[0].Location.Lines.Begin != 30 {
+		t.Errorf("moved issue = %+v, want fingerprint %s at line 30", movedIssues, issues[0].Fingerprint)
+	}
+
+	// No finding is an empty array, not null
+	buf.Reset()
+	if err := (CodeQualityPrinter{}).Print(&buf, results[2:]); err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.TrimSpace(buf.String()); got != "[]" {
+		t.Errorf("Print() without findings = %s, want []", got)
+	}
+}
+
+func TestLowUsageBuckets(t *testing.T) {
+	// parse has 3 real usages, low once the low bucket reaches 3
+	buckets := &finder.UsageBuckets{Low: 3, Medium: 5}
+	want := []string{"unused load", "low-usage Svc.handle", "low-usage parse"}
+
+	var buf bytes.Buffer
+	if err := (SARIFPrinter{Meta: testMeta, Buckets: buckets}).Print(&buf, testResults()); err != nil {
+		t.Fatal(err)
+	}
+	var log sarifLog
+	if err := json.Unmarshal(buf.Bytes(), &log); err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, r := range log.Runs[0].Results {
+		got = append(got, r.RuleID+" "+strings.Fields(r.Message.Text)[1])
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("SARIF results = %v, want %v", got, want)
+	}
+	if desc := log.Runs[0].Tool.Driver.Rules[1].ShortDescription.Text; desc != "Definition with at most 3 real usages" {
+		t.Errorf("low-usage rule description = %q", desc)
+	}
+
+	buf.Reset()
+	if err := (CodeQualityPrinter{Buckets: buckets}).Print(&buf, testResults()); err != nil {
+		t.Fatal(err)
+	}
+	var issues []codeQualityIssue
+	if err := json.Unmarshal(buf.Bytes(), &issues); err != nil {
+		t.Fatal(err)
+	}
+	got = nil
+	for _, issue := range issues {
+		got = append(got, issue.CheckName+" "+strings.Fields(issue.Description)[1])
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Code Quality issues = %v, want %v", got, want)
+	}
+}
+
+func TestCtagsPrinter(t *testing.T) {
+	results := testResults()
+	results[2].Capped = true
+	results = append(results, testResult(finder.Method{Name: "MAX_SIZE", Kind: finder.SymbolVariable, Filename: "app/io.py", LineNo: 1},
+		finder.CallTypeDefinition, finder.CallTypeRead))
+
+	var buf bytes.Buffer
+	if err := (CtagsPrinter{}).Print(&buf, results); err != nil {
+		t.Fatal(err)
+	}
+	want := "!_TAG_FILE_FORMAT\t2\t/extended format/\n" +
+		"!_TAG_FILE_SORTED\t1\t/0=unsorted, 1=sorted, 2=foldcase/\n" +
+		"!_TAG_PROGRAM_NAME\tpybr\t//\n" +
+		"!_TAG_PROGRAM_URL\thttps://github.com/sanchezhs/py-broom\t//\n" +
+		"MAX_SIZE\tapp/io.py\t1;\"\tv\tline:1\tusages:2\treal_usages:1\n" +
+		"__repr__\tapp/svc.py\t14;\"\tm\tline:14\tclass:Svc\tusages:1\treal_usages:0\n" +
+		"broken\tapp/io.py\t20;\"\tf\tline:20\tusages:0\treal_usages:0\n" +
+		"handle\tapp/svc.py\t10;\"\tm\tline:10\tclass:Svc\tusages:2\treal_usages:1\n" +
+		"load\tapp/io.py\t3;\"\tf\tline:3\tusages:1\treal_usages:0\n" +
+		"parse\tapp/io.py\t8;\"\tf\tline:8\tusages:4+\treal_usages:3\n"
+	if got := buf.String(); got != want {
+		t.Errorf("Print() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestProtoPrinter(t *testing.T) {
+	results := testResults()
+	results[0].Method.Decorators = []string{"cache", ""}
+	results[0].Method.Overloads = []int{1, 2}
+	results[0].Method.Metrics = &finder.Metrics{Lines: 3, Branches: 1, Nesting: 1, Complexity: 2}
+	results[1].Method.Binding = finder.BindingClass
+	results[1].UsagesByRoot = map[string]int{"app": 2}
+	results[1].Usages[1].Similarity = 0.75
+	results[1].Usages[1].Confidence = finder.ConfidenceHigh
+	results[1].Usages[1].Before = []string{"", "svc = Svc()"}
+	summary := finder.Summarize(results)
+	summary.Buckets = &finder.UsageBuckets{Low: 2, Medium: 5, Labels: []string{"dead", "rare", "some", "hot"}}
+
+	schema := parseProtoSchema(t, ReportProto)
+	var buf bytes.Buffer
+	if err := (ProtoPrinter{Meta: testMeta, Summary: &summary}).Print(&buf, results); err != nil {
+		t.Fatal(err)
+	}
+	report := decodeProto(t, schema, schema["Report"], buf.Bytes())
+
+	for field, want := range map[string]any{
+		"schema_version": SchemaVersion,
+		"tool":           map[string]any{"name": "pybr", "version": "1.2.3"},
+		"generated_at":   "2024-05-01T12:00:00Z",
+		"config":         map[string]any{"format": `"test"`},
+		"total_methods":  float64(len(results)),
+		"commit":         testMeta.Commit,
+	} {
+		if got := report[field]; !reflect.DeepEqual(got, want) {
+			t.Errorf("%s = %#v, want %#v", field, got, want)
+		}
+	}
+	// Fields follow the json names, apart from the class of a method
+	checkProtoMatchesJSON(t, "results", report["results"], results)
+	checkProtoMatchesJSON(t, "summary", report["summary"], summary)
+
+	buf.Reset()
+	if err := (ProtoPrinter{}).PrintSummary(&buf, summary); err != nil {
+		t.Fatal(err)
+	}
+	report = decodeProto(t, schema, schema["Report"], buf.Bytes())
+	if len(report) != 2 || report["schema_version"] != SchemaVersion {
+		t.Errorf("PrintSummary() fields = %v, want schema_version and summary", slices.Sorted(maps.Keys(report)))
+	}
+	checkProtoMatchesJSON(t, "summary", report["summary"], summary)
+}
+
+// protoField is a field of a message of a .proto file, with the key and
+// value types of map fields
+type protoField struct {
+	name, typ  string
+	key, value string
+	repeated   bool
+}
+
+var (
+	protoMessageRe = regexp.MustCompile(`^message (\w+) \{`)
+	protoFieldRe   = regexp.MustCompile(`^\s*(repeated\s+)?(?:map<(\w+),\s*(\w+)>|(\w+))\s+(\w+)\s*=\s*(\d+);`)
+)
+
+// parseProtoSchema returns the fields of every message of a .proto file by
+// message name and field number
+func parseProtoSchema(t *testing.T, proto string) map[string]map[uint64]protoField {
+	t.Helper()
+	schema := make(map[string]map[uint64]protoField)
+	var current map[uint64]protoField
+	for line := range strings.Lines(proto) {
+		if m := protoMessageRe.FindStringSubmatch(line); m != nil {
+			current = make(map[uint64]protoField)
+			schema[m[1]] = current
+			continue
+		}
+		m := protoFieldRe.FindStringSubmatch(line)
+		if m == nil || current == nil {
+			continue
+		}
+		number, _ := strconv.ParseUint(m[6], 10, 64)
+		f := protoField{name: m[5], typ: m[4], key: m[2], value: m[3], repeated: m[1] != ""}
+		if f.key != "" {
+			f.typ = "map"
+		}
+		current[number] = f
+	}
+	if len(schema["Report"]) == 0 {
+		t.Fatal("no Report message in the schema")
+	}
+	return schema
+}
+
+// decodeProto decodes a message of the given fields into the values of its
+// fields by name, with numbers as float64 like encoding/json
+func decodeProto(t *testing.T, schema map[string]map[uint64]protoField, fields map[uint64]protoField, data []byte) map[string]any {
+	t.Helper()
+	values := make(map[string]any)
+	for len(data) > 0 {
+		tag, n := binary.Uvarint(data)
+		if n <= 0 {
+			t.Fatalf("bad tag at %x", data)
+		}
+		data = data[n:]
+		f, ok := fields[tag>>3]
+		if !ok {
+			t.Fatalf("field %d is not in the schema", tag>>3)
+		}
+
+		var value any
+		switch wireType := tag & 7; {
+		case wireType == protoVarint && (f.typ == "int64" || f.typ == "bool"):
+			v, n := binary.Uvarint(data)
+			if n <= 0 {
+				t.Fatalf("%s: bad varint", f.name)
+			}
+			data = data[n:]
+			value = float64(int64(v))
+			if f.typ == "bool" {
+				value = v != 0
+			}
+		case wireType == protoFixed64 && f.typ == "double":
+			if len(data) < 8 {
+				t.Fatalf("%s: truncated double", f.name)
+			}
+			value = math.Float64frombits(binary.LittleEndian.Uint64(data))
+			data = data[8:]
+		case wireType == protoBytes:
+			size, n := binary.Uvarint(data)
+			if n <= 0 || uint64(len(data)-n) < size {
+				t.Fatalf("%s: bad length", f.name)
+			}
+			payload := data[n : n+int(size)]
+			data = data[n+int(size):]
+			switch {
+			case f.typ == "string":
+				value = string(payload)
+			case f.typ == "map":
+				entry := decodeProto(t, schema, map[uint64]protoField{
+					1: {name: "key", typ: f.key},
+					2: {name: "value", typ: f.value},
+				}, payload)
+				m, _ := values[f.name].(map[string]any)
+				if m == nil {
+					m = make(map[string]any)
+					values[f.name] = m
+				}
+				m[entry["key"].(string)] = entry["value"]
+				continue
+			case f.typ == "int64" && f.repeated:
+				var packed []any
+				for len(payload) > 0 {
+					v, n := binary.Uvarint(payload)
+					if n <= 0 {
+						t.Fatalf("%s: bad packed varint", f.name)
+					}
+					payload = payload[n:]
+					packed = append(packed, float64(int64(v)))
+				}
+				values[f.name] = append(asSlice(values[f.name]), packed...)
+				continue
+			case schema[f.typ] != nil:
+				value = decodeProto(t, schema, schema[f.typ], payload)
+			default:
+				t.Fatalf("%s: length-delimited %s", f.name, f.typ)
+			}
+		default:
+			t.Fatalf("%s: wire type %d for %s", f.name, wireType, f.typ)
+		}
+
+		if f.repeated {
+			values[f.name] = append(asSlice(values[f.name]), value)
+		} else {
+			values[f.name] = value
+		}
+	}
+	return values
+}
+
+func asSlice(v any) []any {
+	s, _ := v.([]any)
+	return s
+}
+
+// checkProtoMatchesJSON compares decoded protobuf values with the json
+// encoding of v, leaving out zero values like proto3
+func checkProtoMatchesJSON(t *testing.T, name string, got, v any) {
+	t.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var want any
+	if err := json.Unmarshal(data, &want); err != nil {
+		t.Fatal(err)
+	}
+	want = protoValues(want)
+	if !reflect.DeepEqual(got, want) {
+		gotJSON, _ := json.MarshalIndent(got, "", "  ")
+		wantJSON, _ := json.MarshalIndent(want, "", "  ")
+		t.Errorf("%s =\n%s\nwant\n%s", name, gotJSON, wantJSON)
+	}
+}
+
+// protoValues drops the zero values of decoded json, which proto3 leaves
+// out, and renames the class of methods to its field name in the schema
+func protoValues(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		out := make(map[string]any)
+		for key, value := range v {
+			value = protoValues(value)
+			if value == nil {
+				continue
+			}
+			if key == "class" {
+				key = "class_name"
+			}
+			out[key] = value
+		}
+		if len(out) == 0 {
+			return nil
+		}
+		return out
+	case []any:
+		if len(v) == 0 {
+			return nil
+		}
+		out := make([]any, len(v))
+		for i, value := range v {
+			// Repeated values are kept, zero or not
+			if value = protoValues(value); value == nil {
+				value = ""
+			}
+			out[i] = value
+		}
+		return out
+	case string, float64, bool:
+		if reflect.ValueOf(v).IsZero() {
+			return nil
+		}
+	}
+	return v
+}
+
+func TestTAPPrinter(t *testing.T) {
+	results := testResults()
+	// Directive characters of the skip reason are escaped
+	results[4].SearchError = "rg: bad pattern #1\nat line 2"
+	summary := finder.Summarize(results)
+
+	header := "TAP version 13\n" +
+		"# schema_version: " + SchemaVersion + "\n" +
+		"# tool.version: 1.2.3\n" +
+		"# generated_at: 2024-05-01T12:00:00Z\n" +
+		"# commit: 0123456789abcdef0123456789abcdef01234567\n" +
+		"# duration_ms: 0\n" +
+		"# config: {\"format\":\"test\"}\n"
+	footer := "# total_methods: 5\n" +
+		"# unused: 1\n" +
+		"# low: 3\n" +
+		"# medium: 1\n" +
+		"# high: 0\n" +
+		"# usages.definition: 4\n" +
+		"# usages.instance: 1\n" +
+		"# usages.function: 3\n"
+
+	var buf bytes.Buffer
+	if err := (TAPPrinter{Meta: testMeta, Summary: &summary}).Print(&buf, results); err != nil {
+		t.Fatal(err)
+	}
+	want := header +
+		"1..5\n" +
+		"not ok 1 - app.io.load\n" +
+		"  ---\n" +
+		"  message: \"Function load is never used\"\n" +
+		"  severity: fail\n" +
+		"  at:\n" +
+		"    file: \"app/io.py\"\n" +
+		"    line: 3\n" +
+		"  usages: 1\n" +
+		"  ...\n" +
+		"ok 2 - app.svc.Svc.handle\n" +
+		"ok 3 - app.io.parse\n" +
+		"ok 4 - app.svc.Svc.__repr__\n" +
+		"ok 5 - app.io.broken # SKIP search failed: rg: bad pattern \\#1 at line 2\n" +
+		footer
+	if got := buf.String(); got != want {
+		t.Errorf("Print() =\n%s\nwant\n%s", got, want)
+	}
+
+	buf.Reset()
+	if err := (TAPPrinter{Meta: testMeta}).PrintSummary(&buf, summary); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := buf.String(), header+"1..0 # SKIP summary only\n"+footer; got != want {
+		t.Errorf("PrintSummary() =\n%s\nwant\n%s", got, want)
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"runtime"
+	"runtime/pprof"
+	"runtime/trace"
+)
+
+// startProfiling starts the CPU profile and execution trace requested by
+// --cpuprofile and --trace. The returned stop function ends them and writes
+// the heap profile requested by --memprofile.
+func startProfiling(opts *options) (stop func(), err error) {
+	var stops []func()
+	stop = func() {
+		for i := len(stops) - 1; i >= 0; i-- {
+			stops[i]()
+		}
+	}
+	create := func(flag, path string) (*os.File, error) {
+		f, err := os.Create(path)
+		if err != nil {
+			stop()
+			return nil, fmt.Errorf("%s: --%s: %w", programName, flag, err)
+		}
+		return f, nil
+	}
+
+	if opts.cpuProfile != "" {
+		f, err := create("cpuprofile", opts.cpuProfile)
+		if err != nil {
+			return nil, err
+		}
+		if err := pprof.StartCPUProfile(f); err != nil {
+			f.Close()
+			stop()
+			return nil, fmt.Errorf("%s: --cpuprofile: %w", programName, err)
+		}
+		stops = append(stops, func() {
+			pprof.StopCPUProfile()
+			f.Close()
+		})
+	}
+
+	if opts.traceFile != "" {
+		f, err := create("trace", opts.traceFile)
+		if err != nil {
+			return nil, err
+		}
+		if err := trace.Start(f); err != nil {
+			f.Close()
+			stop()
+			return nil, fmt.Errorf("%s: --trace: %w", programName, err)
+		}
+		stops = append(stops, func() {
+			trace.Stop()
+			f.Close()
+		})
+	}
+
+	if opts.memProfile != "" {
+		path := opts.memProfile
+		stops = append(stops, func() {
+			f, err := os.Create(path)
+			if err != nil {
+				log.Printf("Error writing memory profile: %v", err)
+				return
+			}
+			defer f.Close()
+			runtime.GC() // Up to date statistics of live objects
+			if err := pprof.Lookup("allocs").WriteTo(f, 0); err != nil {
+				log.Printf("Error writing memory profile: %v", err)
+			}
+		})
+	}
+	return stop, nil
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/sanchezhs/py-broom/finder"
+	"github.com/sanchezhs/py-broom/printers"
+	"github.com/spf13/cobra"
+)
+
+// defaultTop is the number of methods per column of report top without --top
+const defaultTop = 10
+
+func newReportCmd(opts *options) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "report",
+		Short: "Print dedicated views of the results",
+	}
+	cmd.AddCommand(&cobra.Command{
+		Use:   "top [file.py ...]",
+		Short: "Show the most and least used methods side by side",
+		Long: "Show the --top most used methods, hot spots worth refactoring carefully, next to\n" +
+			"the --top least used ones, candidates for cleanup (10 of each by default).",
+		SilenceUsage: true,
+		Args:         cobra.ArbitraryArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if opts.stream || opts.summaryOnly || opts.groupBy != "" {
+				return fmt.Errorf("%s: report top can not be combined with --stream, --summary-only or --group-by", programName)
+			}
+			opts.report = "top"
+			return runAnalysis(cmd, opts, finder.SymbolFunction, args)
+		},
+	})
+	return cmd
+}
+
+// printTop writes the most and least used of results with the printer
+// selected by --format
+func printTop(cmd *cobra.Command, opts *options, results []finder.MethodUsage) error {
+	pr, err := newPrinter(cmd, opts, nil, nil)
+	if pr == nil {
+		return err
+	}
+	tp, ok := pr.(printers.TopPrinter)
+	if !ok {
+		return fmt.Errorf("%s: format '%s' does not support report top", programName, opts.format)
+	}
+	n := opts.top
+	if n == 0 {
+		n = defaultTop
+	}
+	most, least := finder.TopResults(results, n)
+	return writeOutput(opts, func(w io.Writer) error { return tp.PrintTop(w, most, least) })
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"runtime/debug"
+	"strconv"
+	"time"
+
+	"github.com/sanchezhs/py-broom/finder"
+	"github.com/sanchezhs/py-broom/printers"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// version is set at build time with -ldflags "-X main.version=v1.2.3", and
+// taken from the module otherwise
+var version = ""
+
+// toolVersion returns the version of pybr
+func toolVersion() string {
+	if version != "" {
+		return version
+	}
+	if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" && info.Main.Version != "(devel)" {
+		return info.Main.Version
+	}
+	return "dev"
+}
+
+func newSchemaCmd() *cobra.Command {
+	var proto bool
+	cmd := &cobra.Command{
+		Use:   "schema",
+		Short: "Print the JSON Schema of the json format",
+		Long: "Print the JSON Schema (draft 2020-12) of the reports written by --format json,\n" +
+			"or with --proto the protobuf schema of those written by --format pb.\n\n" +
+			"Reports carry the schema_version they follow. Its major number changes when\n" +
+			"fields are removed or change meaning, its minor number when fields are added.",
+		SilenceUsage: true,
+		Args:         cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if proto {
+				_, err := io.WriteString(cmd.OutOrStdout(), printers.ReportProto)
+				return err
+			}
+			enc := json.NewEncoder(cmd.OutOrStdout())
+			enc.SetIndent("", "  ")
+			enc.SetEscapeHTML(false)
+			return enc.Encode(printers.JSONSchema())
+		},
+	}
+	cmd.Flags().BoolVar(&proto, "proto", false, "Print the .proto file of the pb format instead")
+	return cmd
+}
+
+// reportMeta describes the run of cmd in structured reports
+func reportMeta(cmd *cobra.Command, opts *options) printers.Meta {
+	meta := printers.Meta{Version: toolVersion(), Config: effectiveConfig(cmd), TotalMethods: opts.totalMethods, Started: opts.started}
+	// Reproducible builds set the time to report instead of the current one
+	if epoch, err := strconv.ParseInt(os.Getenv("SOURCE_DATE_EPOCH"), 10, 64); err == nil {
+		meta.GeneratedAt = time.Unix(epoch, 0).UTC()
+	}
+	if len(opts.dirs) > 0 {
+		// Reports of trees outside git repositories have no commit
+		meta.Commit, _ = finder.HeadCommit(opts.dirs[0])
+	}
+	return meta
+}
+
+// effectiveConfig returns the value of every flag of cmd, set or not, keyed
+// by flag name. Help and deprecated flags are left out.
+func effectiveConfig(cmd *cobra.Command) map[string]any {
+	config := make(map[string]any)
+	flags := cmd.Flags()
+	flags.VisitAll(func(f *pflag.Flag) {
+		if f.Name == "help" || f.Deprecated != "" {
+			return
+		}
+		var value any = f.Value.String()
+		switch f.Value.Type() {
+		case "bool":
+			value, _ = flags.GetBool(f.Name)
+		case "int":
+			value, _ = flags.GetInt(f.Name)
+		case "float64":
+			value, _ = flags.GetFloat64(f.Name)
+		case "stringSlice":
+			value, _ = flags.GetStringSlice(f.Name)
+		case "stringArray":
+			value, _ = flags.GetStringArray(f.Name)
+		}
+		config[f.Name] = value
+	})
+	return config
+}
@@ -0,0 +1,18 @@
+//go:build !linux && !darwin && !freebsd && !netbsd && !openbsd
+
+package main
+
+import (
+	"errors"
+	"os"
+)
+
+var errNoTerminal = errors.New("the interactive browser is not supported on this platform")
+
+var resizeSignals []os.Signal
+
+func rawTerminal(f *os.File) (restore func() error, err error) { return nil, errNoTerminal }
+
+func terminalSize(f *os.File) (width, height int, err error) { return 0, 0, errNoTerminal }
+
+func isTerminal(f *os.File) bool { return false }
@@ -0,0 +1,57 @@
+//go:build linux || darwin || freebsd || netbsd || openbsd
+
+package main
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// resizeSignals are sent when the terminal is resized
+var resizeSignals = []os.Signal{syscall.SIGWINCH}
+
+// rawTerminal switches f to raw mode, keys are read one at a time without
+// echo, and returns a function restoring the previous mode
+func rawTerminal(f *os.File) (restore func() error, err error) {
+	var old syscall.Termios
+	if err := termios(f, ioctlGetTermios, &old); err != nil {
+		return nil, err
+	}
+	raw := old
+	raw.Iflag &^= syscall.IGNBRK | syscall.BRKINT | syscall.PARMRK | syscall.ISTRIP | syscall.INLCR | syscall.IGNCR | syscall.ICRNL | syscall.IXON
+	raw.Lflag &^= syscall.ECHO | syscall.ECHONL | syscall.ICANON | syscall.ISIG | syscall.IEXTEN
+	raw.Cflag &^= syscall.CSIZE | syscall.PARENB
+	raw.Cflag |= syscall.CS8
+	raw.Cc[syscall.VMIN], raw.Cc[syscall.VTIME] = 1, 0
+	if err := termios(f, ioctlSetTermios, &raw); err != nil {
+		return nil, err
+	}
+	return func() error { return termios(f, ioctlSetTermios, &old) }, nil
+}
+
+func termios(f *os.File, request uintptr, t *syscall.Termios) error {
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, f.Fd(), request, uintptr(unsafe.Pointer(t))); errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// terminalSize returns the columns and rows of the terminal behind f
+func terminalSize(f *os.File) (width, height int, err error) {
+	var ws struct{ Row, Col, X, Y uint16 }
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, f.Fd(), syscall.TIOCGWINSZ, uintptr(unsafe.Pointer(&ws))); errno != 0 {
+		return 0, 0, errno
+	}
+	if ws.Col == 0 || ws.Row == 0 {
+		// Serial consoles and bare ptys do not know their size
+		return 80, 24, nil
+	}
+	return int(ws.Col), int(ws.Row), nil
+}
+
+// isTerminal reports whether f is a terminal
+func isTerminal(f *os.File) bool {
+	_, _, err := terminalSize(f)
+	return err == nil
+}
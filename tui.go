@@ -0,0 +1,519 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/sanchezhs/py-broom/finder"
+	"github.com/sanchezhs/py-broom/printers"
+	"github.com/spf13/cobra"
+)
+
+func newTUICmd(opts *options) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "tui [file.py ...]",
+		Short: "Browse the results interactively",
+		Long: "Browse the results interactively.\n\n" +
+			"Methods are listed above the usages of the selected one. Keys:\n" +
+			"  up/down, j/k, pgup/pgdn, g/G  move          tab     switch between methods and usages\n" +
+			"  /                             filter        s, r    change the sort key, reverse it\n" +
+			"  enter, o                      open $EDITOR  m, space  mark the method\n" +
+			"  q, ctrl-c                     quit\n\n" +
+			"Marked methods are written to --marks as JSON on quit, for merge and later\n" +
+			"commands, or listed on stdout when it is not set.",
+		SilenceUsage: true,
+		Args:         cobra.ArbitraryArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if opts.stream || opts.summaryOnly || opts.groupBy != "" {
+				return fmt.Errorf("%s: tui can not be combined with --stream, --summary-only or --group-by", programName)
+			}
+			opts.browse = true
+			return runAnalysis(cmd, opts, finder.SymbolFunction, args)
+		},
+	}
+	cmd.Flags().StringVar(&opts.marks, "marks", "", "Write the marked methods to this file as JSON on quit")
+	return cmd
+}
+
+// browserSorts are the sort keys cycled through with s
+var browserSorts = []string{"file", "name", "usages", "complexity"}
+
+// browser is the state of the interactive result browser. It renders to a
+// string so the terminal handling stays apart.
+type browser struct {
+	results   []finder.MethodUsage
+	view      []int // Positions in results passing the filter
+	filter    string
+	filtering bool
+	sort      int // Position in browserSorts
+	asc       bool
+	marked    map[string]bool
+
+	focusUsages bool
+	cursor, top int // Selected method and first one shown
+	usage, utop int // Selected usage and first one shown
+
+	width, height int
+	status        string
+}
+
+func newBrowser(results []finder.MethodUsage, sortBy string, asc bool) *browser {
+	if sortBy == "" {
+		// Without --sort-by, list the methods file by file
+		asc = true
+	}
+	b := &browser{results: results, asc: asc, marked: make(map[string]bool)}
+	for i, key := range browserSorts {
+		if key == sortBy {
+			b.sort = i
+		}
+	}
+	b.refresh()
+	return b
+}
+
+// markKey identifies a method across sorts
+func markKey(m finder.Method) string {
+	return fmt.Sprintf("%s:%d:%s", m.Filename, m.LineNo, m.Name)
+}
+
+// refresh sorts and filters the results again, keeping the cursor in range
+func (b *browser) refresh() {
+	finder.SortResults(b.results, browserSorts[b.sort], b.asc)
+	b.view = b.view[:0]
+	filter := strings.ToLower(b.filter)
+	for i, r := range b.results {
+		name := strings.ToLower(r.Method.Class + "." + r.Method.Name + " " + r.Method.Filename)
+		if filter == "" || strings.Contains(name, filter) {
+			b.view = append(b.view, i)
+		}
+	}
+	b.cursor = max(0, min(b.cursor, len(b.view)-1))
+	b.usage, b.utop = 0, 0
+}
+
+// selected returns the result under the cursor, nil when none passes the filter
+func (b *browser) selected() *finder.MethodUsage {
+	if len(b.view) == 0 {
+		return nil
+	}
+	return &b.results[b.view[b.cursor]]
+}
+
+// panes returns the rows of the method list and of the usages
+func (b *browser) panes() (list, usages int) {
+	body := max(b.height-3, 2) // Header, separator and footer
+	list = body / 2
+	return list, body - list
+}
+
+// key handles one key press and reports whether the browser should quit.
+// Opening a location is left to open, called with the file and line.
+func (b *browser) key(k string, open func(file string, line int) error) (quit bool) {
+	b.status = ""
+	if b.filtering {
+		switch k {
+		case "enter":
+			b.filtering = false
+		case "esc":
+			b.filtering, b.filter = false, ""
+		case "backspace":
+			if b.filter != "" {
+				_, size := utf8.DecodeLastRuneInString(b.filter)
+				b.filter = b.filter[:len(b.filter)-size]
+			}
+		case "ctrl-c":
+			return true
+		default:
+			if utf8.RuneCountInString(k) == 1 {
+				b.filter += k
+			}
+		}
+		b.refresh()
+		return false
+	}
+
+	listRows, usageRows := b.panes()
+	move := func(delta int) {
+		if b.focusUsages {
+			if r := b.selected(); r != nil {
+				b.usage = max(0, min(b.usage+delta, len(r.Usages)-1))
+			}
+			return
+		}
+		b.cursor = max(0, min(b.cursor+delta, len(b.view)-1))
+		b.usage, b.utop = 0, 0
+	}
+	page := listRows
+	if b.focusUsages {
+		page = usageRows
+	}
+
+	switch k {
+	case "q", "ctrl-c":
+		return true
+	case "up", "k":
+		move(-1)
+	case "down", "j":
+		move(1)
+	case "pgup":
+		move(-page)
+	case "pgdn":
+		move(page)
+	case "g", "home":
+		move(-1 << 30)
+	case "G", "end":
+		move(1 << 30)
+	case "tab":
+		b.focusUsages = !b.focusUsages
+	case "/":
+		b.filtering, b.focusUsages = true, false
+	case "s":
+		b.sort = (b.sort + 1) % len(browserSorts)
+		b.refresh()
+	case "r":
+		b.asc = !b.asc
+		b.refresh()
+	case "m", " ":
+		if r := b.selected(); r != nil {
+			key := markKey(r.Method)
+			if b.marked[key] {
+				delete(b.marked, key)
+			} else {
+				b.marked[key] = true
+			}
+		}
+	case "enter", "o":
+		r := b.selected()
+		if r == nil {
+			break
+		}
+		file, line := r.Method.Filename, r.Method.LineNo
+		if b.focusUsages && b.usage < len(r.Usages) {
+			var parsed bool
+			file, line, parsed = splitUsageLocation(r.Usages[b.usage].Location)
+			if !parsed {
+				break
+			}
+		}
+		if err := open(file, line); err != nil {
+			b.status = err.Error()
+		}
+	}
+	return false
+}
+
+// splitUsageLocation splits the file and line of a "path:line:col" location
+func splitUsageLocation(location string) (file string, line int, ok bool) {
+	rest, _, found := cutLast(location, ":")
+	if !found {
+		return "", 0, false
+	}
+	file, lineNo, found := cutLast(rest, ":")
+	line, err := strconv.Atoi(lineNo)
+	return file, line, found && err == nil
+}
+
+func cutLast(s, sep string) (before, after string, found bool) {
+	if i := strings.LastIndex(s, sep); i >= 0 {
+		return s[:i], s[i+len(sep):], true
+	}
+	return s, "", false
+}
+
+// Terminal escapes used to draw
+const (
+	escReverse = "\x1b[7m"
+	escBold    = "\x1b[1m"
+	escReset   = "\x1b[0m"
+)
+
+// render draws the whole screen
+func (b *browser) render() string {
+	var out strings.Builder
+	out.WriteString("\x1b[H\x1b[2J")
+	line := func(text, style string) {
+		text = fit(text, b.width)
+		if style != "" {
+			text = style + text + strings.Repeat(" ", max(0, b.width-utf8.RuneCountInString(text))) + escReset
+		}
+		out.WriteString(text + "\r\n")
+	}
+
+	order := "desc"
+	if b.asc {
+		order = "asc"
+	}
+	header := fmt.Sprintf(" %s  %d/%d methods  sort: %s %s  marked: %d", programName, len(b.view), len(b.results),
+		browserSorts[b.sort], order, len(b.marked))
+	if b.filter != "" || b.filtering {
+		header += "  filter: " + b.filter
+		if b.filtering {
+			header += "_"
+		}
+	}
+	line(header, escReverse)
+
+	listRows, usageRows := b.panes()
+	b.top = scrollTo(b.cursor, b.top, listRows)
+	for row := 0; row < listRows; row++ {
+		i := b.top + row
+		if i >= len(b.view) {
+			line("", "")
+			continue
+		}
+		r := b.results[b.view[i]]
+		mark := " "
+		if b.marked[markKey(r.Method)] {
+			mark = "*"
+		}
+		total := strconv.Itoa(r.TotalUsages)
+		if r.Capped {
+			total += "+"
+		}
+		text := fmt.Sprintf("%s %-40s %6s  %s:%d", mark, methodLabel(r.Method), total, r.Method.Filename, r.Method.LineNo)
+		style := ""
+		if i == b.cursor {
+			style = escReverse
+			if b.focusUsages {
+				style = escBold
+			}
+		}
+		line(text, style)
+	}
+
+	r := b.selected()
+	title := " No method matches the filter"
+	if r != nil {
+		title = fmt.Sprintf(" Usages of %s (%d)", methodLabel(r.Method), len(r.Usages))
+	}
+	line(title, escBold)
+	b.utop = scrollTo(b.usage, b.utop, usageRows)
+	for row := 0; row < usageRows; row++ {
+		i := b.utop + row
+		if r == nil || i >= len(r.Usages) {
+			line("", "")
+			continue
+		}
+		u := r.Usages[i]
+		text := fmt.Sprintf("  %-45s %-12s %s", u.Location, u.CallType, strings.TrimSpace(u.Context))
+		style := ""
+		if b.focusUsages && i == b.usage {
+			style = escReverse
+		}
+		line(text, style)
+	}
+
+	footer := " q quit  / filter  s sort  r reverse  tab switch pane  enter open  m mark"
+	if b.status != "" {
+		footer = " " + b.status
+	}
+	out.WriteString(escReverse + fit(footer, b.width) + strings.Repeat(" ", max(0, b.width-utf8.RuneCountInString(fit(footer, b.width)))) + escReset)
+	return out.String()
+}
+
+// scrollTo returns the first row to show so that cursor stays within rows
+func scrollTo(cursor, top, rows int) int {
+	switch {
+	case cursor < top:
+		return cursor
+	case cursor >= top+rows:
+		return cursor - rows + 1
+	}
+	return top
+}
+
+// fit truncates text to width runes
+func fit(text string, width int) string {
+	if utf8.RuneCountInString(text) <= width {
+		return text
+	}
+	runes := []rune(text)
+	return string(runes[:max(width, 0)])
+}
+
+func methodLabel(m finder.Method) string {
+	if m.Class != "" {
+		return m.Class + "." + m.Name
+	}
+	return m.Name
+}
+
+// keyNames maps the bytes of special keys to their names
+var keyNames = map[string]string{
+	"\r": "enter", "\n": "enter", "\t": "tab", "\x1b": "esc", "\x7f": "backspace", "\x08": "backspace", "\x03": "ctrl-c",
+	"\x1b[A": "up", "\x1b[B": "down", "\x1bOA": "up", "\x1bOB": "down",
+	"\x1b[5~": "pgup", "\x1b[6~": "pgdn", "\x1b[H": "home", "\x1b[F": "end", "\x1b[1~": "home", "\x1b[4~": "end",
+}
+
+// parseKeys splits the bytes of one read into key names. Unknown escape
+// sequences are dropped.
+func parseKeys(buf []byte) []string {
+	var keys []string
+	for len(buf) > 0 {
+		if buf[0] == 0x1b && len(buf) > 1 {
+			end := 2
+			for end < len(buf) && (buf[end] < 0x40 || buf[end] > 0x7e) {
+				end++
+			}
+			end = min(end+1, len(buf))
+			if name, ok := keyNames[string(buf[:end])]; ok {
+				keys = append(keys, name)
+			}
+			buf = buf[end:]
+			continue
+		}
+		r, size := utf8.DecodeRune(buf)
+		if name, ok := keyNames[string(buf[:size])]; ok {
+			keys = append(keys, name)
+		} else if r >= ' ' {
+			keys = append(keys, string(r))
+		}
+		buf = buf[size:]
+	}
+	return keys
+}
+
+// browseResults runs the interactive browser over results until the user
+// quits, then saves the marked methods
+func browseResults(cmd *cobra.Command, opts *options, results []finder.MethodUsage) error {
+	in, out := os.Stdin, os.Stdout
+	b := newBrowser(results, opts.sortBy, opts.asc)
+	var err error
+	if b.width, b.height, err = terminalSize(out); err != nil {
+		return fmt.Errorf("%s: tui needs a terminal: %w", programName, err)
+	}
+	restore, err := rawTerminal(in)
+	if err != nil {
+		return fmt.Errorf("%s: tui needs a terminal: %w", programName, err)
+	}
+	enter := func() { fmt.Fprint(out, "\x1b[?1049h\x1b[?25l") }
+	leave := func() {
+		fmt.Fprint(out, "\x1b[?25h\x1b[?1049l")
+		_ = restore()
+	}
+	enter()
+	quit, err := b.run(cmd, in, out, func(file string, line int) error {
+		leave()
+		defer func() {
+			restore, _ = rawTerminal(in)
+			enter()
+		}()
+		return openEditor(file, line)
+	})
+	leave()
+	if err != nil || !quit {
+		return err
+	}
+	return saveMarks(cmd, opts, b)
+}
+
+// run draws the browser and handles keys until the user quits, which it
+// reports, or the context of cmd is done
+func (b *browser) run(cmd *cobra.Command, in, out *os.File, open func(file string, line int) error) (bool, error) {
+	keys := make(chan []byte)
+	go func() {
+		buf := make([]byte, 64)
+		for {
+			n, err := in.Read(buf)
+			if err != nil {
+				close(keys)
+				return
+			}
+			keys <- bytes.Clone(buf[:n])
+		}
+	}()
+	resized := make(chan os.Signal, 1)
+	if len(resizeSignals) > 0 {
+		signal.Notify(resized, resizeSignals...)
+		defer signal.Stop(resized)
+	}
+
+	for {
+		if _, err := fmt.Fprint(out, b.render()); err != nil {
+			return false, err
+		}
+		select {
+		case <-resized:
+			if w, h, err := terminalSize(out); err == nil {
+				b.width, b.height = w, h
+			}
+		case buf, ok := <-keys:
+			if !ok {
+				return true, nil
+			}
+			for _, k := range parseKeys(buf) {
+				if b.key(k, open) {
+					return true, nil
+				}
+			}
+		case <-cmd.Context().Done():
+			return false, nil
+		}
+	}
+}
+
+// openEditor opens file at line in $VISUAL or $EDITOR, vi by default
+func openEditor(file string, line int) error {
+	editor := os.Getenv("VISUAL")
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+	if editor == "" {
+		editor = "vi"
+	}
+	args := strings.Fields(editor)
+	args = append(args, fmt.Sprintf("+%d", line), file)
+	c := exec.Command(args[0], args[1:]...)
+	c.Stdin, c.Stdout, c.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err := c.Run(); err != nil {
+		return fmt.Errorf("running %s: %w", args[0], err)
+	}
+	return nil
+}
+
+// saveMarks writes the marked methods to --marks, or lists them on stdout
+func saveMarks(cmd *cobra.Command, opts *options, b *browser) error {
+	var marked []finder.MethodUsage
+	for _, r := range b.results {
+		if b.marked[markKey(r.Method)] {
+			marked = append(marked, r)
+		}
+	}
+	if len(marked) == 0 {
+		return nil
+	}
+	if opts.marks == "" {
+		return printers.VimPrinter{}.Print(cmd.OutOrStdout(), definitionsOnly(marked))
+	}
+	data, err := json.MarshalIndent(marked, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(opts.marks, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("%s: saving marks: %w", programName, err)
+	}
+	fmt.Fprintf(cmd.ErrOrStderr(), "%s: %d marked methods written to %s\n", programName, len(marked), opts.marks)
+	return nil
+}
+
+// definitionsOnly keeps the definition line of each result
+func definitionsOnly(results []finder.MethodUsage) []finder.MethodUsage {
+	var defs []finder.MethodUsage
+	for _, r := range results {
+		r.Usages = []finder.Usage{{
+			Location: fmt.Sprintf("%s:%d:1", r.Method.Filename, r.Method.LineNo),
+			CallType: finder.CallTypeDefinition,
+			Context:  methodLabel(r.Method),
+		}}
+		defs = append(defs, r)
+	}
+	return defs
+}